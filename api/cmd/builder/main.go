@@ -1,111 +1,220 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
 	"github.com/destel/rill"
 	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/config"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
 )
 
-const API_VERSION = "v2"
+// run builds the catalog. Unless strict is set, failing families are recorded
+// in a build report while the remaining families are built, and an error is
+// returned at the end if any family failed.
+func run(inputDir string, outputDir string, manifestPath string, baseURL string, useHbSubset bool, planOnly bool, strict bool, cfg *config.Config) error {
+	tmpDir := "tmp"
+	reportPath := filepath.Join(outputDir, "build-report.json")
+	report := builder.NewReport()
+	// record adds a failure to the report and returns it if the build should
+	// stop
+	record := func(err error) error {
+		var buildErr *builder.BuildError
+		if !errors.As(err, &buildErr) {
+			return err
+		}
+		log.Printf("error: %v", err)
+		report.Add(err)
+		if strict {
+			return err
+		}
+		return nil
+	}
+	dirs := builder.OutputDirs{
+		Index:    filepath.Join(outputDir, "api", cfg.APIVersion),
+		Fonts:    filepath.Join(outputDir, "api", cfg.APIVersion, "fonts"),
+		Licenses: filepath.Join(outputDir, "api", cfg.APIVersion, "licenses"),
+		CSS:      filepath.Join(outputDir, "api", cfg.APIVersion, "css"),
+		Zips:     filepath.Join(outputDir, "api", cfg.APIVersion, "zips"),
+	}
 
-var ignoredFamilies = []string{
-	// Ignores atma since it doesn't have a bundled license
-	"atma",
-	// Ignore blinker since it doesn't have a bundled license
-	"blinker",
-	// Ignores chathura since it doesn't have a bundled license
-	"chathura",
-	// Ignore dela-gothic-one since it doesn't have a bundled license
-	"dela-gothic-one",
-	// Ignore kulim-park since it doesn't have a bundled license
-	"kulim-park",
-	// Ignore mirza since it doesn't have a bundled license
-	"mirza",
-	// Ignore mitr since it doesn't have a bundled license
-	"mitr",
-	// Ignore mogra since it doesn't have a bundled license
-	"mogra",
-	// Ignore prata since it doesn't have a bundled license
-	"prata",
-	// Ignore source-serif-4 since it doesn't have a bundled license
-	"source-serif-4",
-	// Ignore jsMath fonts
-	"jsmath-cmr10",
-	"jsmath-cmex10",
-	"jsmath-cmsy10",
-	"jsmath-cmti10",
-	"jsmath-cmmi10",
-	"jsmath-cmbx10",
-	// Ignore jomolhari and uchen since they use OFL-1.0 rather than OFL-1.1
-	// which our code currently doesn't handle
-	"uchen",
-	"jomolhari",
-}
+	// Collect metadata. Families that fail to be collected are skipped by
+	// the plan
+	families, collectErr := builder.CollectMetadata(inputDir, cfg.ExcludedFamilies())
+	for _, id := range builder.ApplyOverrides(families, cfg.Families) {
+		log.Printf("warning: override for unknown family %s", id)
+	}
+	err := builder.ResolveInstances(families, cfg.Instances.Named, cfg.Instances.Weights)
+	if err := record(err); err != nil {
+		return fmt.Errorf("failed to resolve instances: %w", err)
+	}
+	// Families whose metrics can't be read are built without a computed
+	// fallback
+	if err := builder.ResolveMetrics(families); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	// Families whose fonts can't be read are built with all their shards
+	if err := builder.ResolveShards(families); err != nil {
+		log.Printf("warning: %v", err)
+	}
+
+	// Plan which outputs to generate
+	excluded := make(map[string]string)
+	for _, exclusion := range cfg.Exclude {
+		excluded[exclusion.Family] = exclusion.Reason
+	}
+	formats := append([]string{builder.FormatWOFF2}, cfg.Formats...)
+	plan, err := builder.NewPlan(families, collectErr, excluded, cfg.Subsets, formats, cfg.HashedFilenames, dirs)
+	if err != nil {
+		return fmt.Errorf("failed to plan build: %w", err)
+	}
+	if planOnly {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+	// Families that are skipped because they failed or can't be built are
+	// still present, and their published outputs are kept
+	var plannedFamilies []builder.FontFamily
+	var presentIds []string
+	for _, planned := range plan.Families {
+		plannedFamilies = append(plannedFamilies, planned.Family)
+		presentIds = append(presentIds, planned.Id)
+	}
+	for _, skipped := range plan.Skipped {
+		if _, found := excluded[skipped.Id]; !found {
+			presentIds = append(presentIds, skipped.Id)
+		}
+		if skipped.Err != nil {
+			if err := record(skipped.Err); err != nil {
+				return err
+			}
+		} else if _, found := excluded[skipped.Id]; !found {
+			log.Printf("skipping %s: %s", skipped.Id, skipped.Reason)
+		}
+	}
 
-func run(inputDir string, outputDir string, subsets []string) error {
 	// Create needed directories
-	tmpDir := "tmp"
-	indexOutputDir := filepath.Join(outputDir, "api", API_VERSION)
-	fontOutputDir := filepath.Join(outputDir, "api", API_VERSION, "fonts")
-	licenseOutputDir := filepath.Join(outputDir, "api", API_VERSION, "licenses")
 	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	if err := os.MkdirAll(fontOutputDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-	if err := os.MkdirAll(licenseOutputDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	for _, dir := range []string{dirs.Fonts, dirs.Licenses, dirs.CSS, dirs.Zips} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
 	}
 
-	// Collect metadata
-	families, err := builder.CollectMetadata(inputDir, ignoredFamilies)
+	// Load manifest of previously generated files
+	tools, err := builder.ToolVersions(useHbSubset)
 	if err != nil {
-		return fmt.Errorf("failed to collect metadata: %w", err)
+		return fmt.Errorf("failed to get tool versions: %w", err)
 	}
+	manifest, err := builder.LoadManifest(manifestPath, tools)
+	if err != nil {
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	// Delete outputs of families that are no longer present or are excluded
+	if err := manifest.Prune(presentIds); err != nil {
+		return fmt.Errorf("failed to prune outputs: %w", err)
+	}
+	subsets := cfg.Subsets
 	// Generate subsets JSON file
-	if err := builder.GenerateSubsetsJSONFile(subsets, indexOutputDir); err != nil {
+	if err := builder.GenerateSubsetsJSONFile(subsets, dirs.Index); err != nil {
 		return fmt.Errorf("failed to generate JSON file: %w", err)
 	}
-	// Generate index JSON file
-	if err := builder.GenerateIndexJSONFile(families, subsets, indexOutputDir); err != nil {
-		return fmt.Errorf("failed to generate JSON file: %w", err)
+	// Generate license, font, family JSON, CSS and zip files
+	jobs := rill.FromSlice(plannedFamilies, nil)
+	parallelism := cfg.Parallelism
+	if parallelism == 0 {
+		parallelism = runtime.GOMAXPROCS(0)
 	}
-
-	// Generate WOFF2 files
-	jobs := rill.FromSlice(families, nil)
-	return rill.ForEach(jobs, runtime.GOMAXPROCS(0), func(family builder.FontFamily) error {
-		err := builder.GenerateLicenseFile(family, inputDir, licenseOutputDir)
+	err = rill.ForEach(jobs, parallelism, func(family builder.FontFamily) error {
+		var hashes builder.FileHashes
+		err := builder.GenerateLicenseFile(family, dirs.Licenses, manifest)
+		if err == nil {
+			err = builder.GenerateFontFiles(family, subsets, formats, dirs.Fonts, tmpDir, useHbSubset, manifest)
+		}
+		if err == nil && cfg.HashedFilenames {
+			hashes, err = builder.GenerateHashedFontFiles(family, subsets, formats, dirs.Fonts, manifest)
+		}
+		if err == nil {
+			err = builder.GenerateFamilyJSONFile(family, subsets, formats, baseURL+"/api/"+cfg.APIVersion, hashes, dirs.Fonts, manifest)
+		}
+		if err == nil {
+			err = builder.GenerateCSSFiles(family, subsets, formats, baseURL+"/api/"+cfg.APIVersion, hashes, dirs.CSS, manifest)
+		}
+		if err == nil {
+			err = builder.GenerateZipFile(family, subsets, dirs, manifest)
+		}
 		if err != nil {
-			return err
+			return record(err)
 		}
-		return builder.GenerateWOFF2Files(family, subsets, inputDir, fontOutputDir, tmpDir)
+		return nil
 	})
+	// Generate index JSON file, leaving out the families that failed
+	if err == nil {
+		builtFamilies := slices.DeleteFunc(slices.Clone(plannedFamilies), func(family builder.FontFamily) bool {
+			return report.Failed(family.Id)
+		})
+		if indexErr := builder.GenerateIndexJSONFile(builtFamilies, subsets, formats, dirs.Index); indexErr != nil {
+			err = fmt.Errorf("failed to generate JSON file: %w", indexErr)
+		} else if languagesErr := builder.GenerateLanguagesJSONFile(builtFamilies, subsets, dirs.Index); languagesErr != nil {
+			err = fmt.Errorf("failed to generate JSON file: %w", languagesErr)
+		}
+	}
+	// Save the manifest even if the build failed so that the outputs that
+	// were generated can be skipped on the next run
+	if saveErr := manifest.Save(manifestPath); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save manifest: %w", saveErr)
+	}
+	if saveErr := report.Save(reportPath); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save build report: %w", saveErr)
+	}
+	if err == nil && report.Len() > 0 {
+		err = fmt.Errorf("%d errors, see %s", report.Len(), reportPath)
+	}
+	return err
 }
 
 func main() {
+	configPath := flag.String("config", "config.yaml", "Path to the build configuration file")
 	inputDir := flag.String("input-dir", "fonts", "Input directory containing font files")
 	outputDir := flag.String("output-dir", "out", "Output directory for generated files")
+	manifestPath := flag.String("manifest", "", "Path to the build manifest (default <output-dir>/manifest.json)")
+	baseURL := flag.String("base-url", "https://font.delivery", "Base URL used for download URLs in the generated files")
+	useHbSubset := flag.Bool("hb-subset", false, "Use hb-subset instead of the native subsetter")
+	planOnly := flag.Bool("plan", false, "Print the families and outputs that would be built as JSON without building them")
+	strict := flag.Bool("strict", false, "Stop the build at the first failing family")
+	subsetsDir := flag.String("subsets-dir", "", "Directory of additional subset definitions, as .txt range lists or .nam glyph set files")
 	flag.Parse()
 
-	subsets := []string{
-		"latin",
-		"latin-ext",
-		"vietnamese",
-		"cyrillic",
-		"cyrillic-ext",
-		"hebrew",
-		"greek",
-		"greek-ext",
+	if *manifestPath == "" {
+		*manifestPath = filepath.Join(*outputDir, "manifest.json")
+	}
+
+	// Subsets must be loaded before the config is validated against them
+	if *subsetsDir != "" {
+		if err := subsetting.Load(*subsetsDir); err != nil {
+			log.Fatalf("error: invalid subset definitions: %v", err)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("error: invalid config: %v", err)
 	}
 
-	if err := run(*inputDir, *outputDir, subsets); err != nil {
+	if err := run(*inputDir, *outputDir, *manifestPath, strings.TrimSuffix(*baseURL, "/"), *useHbSubset, *planOnly, *strict, cfg); err != nil {
 		log.Fatalf("error: %v", err)
 	}
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/config"
+	"github.com/lyxell/font.delivery/api/internal/server"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+)
+
+// warn logs build errors of families, which are left out of the catalog, and
+// returns other errors.
+func warn(err error) error {
+	var buildErr *builder.BuildError
+	if !errors.As(err, &buildErr) {
+		return err
+	}
+	log.Printf("warning: %v", err)
+	return nil
+}
+
+// run serves the catalog built from inputDir into outputDir.
+func run(addr string, inputDir string, outputDir string, baseURL string, cacheDir string, cfg *config.Config) error {
+	families, err := builder.CollectMetadata(inputDir, cfg.ExcludedFamilies())
+	if err := warn(err); err != nil {
+		return fmt.Errorf("failed to collect metadata: %w", err)
+	}
+	for _, id := range builder.ApplyOverrides(families, cfg.Families) {
+		log.Printf("warning: override for unknown family %s", id)
+	}
+	err = builder.ResolveInstances(families, cfg.Instances.Named, cfg.Instances.Weights)
+	if err := warn(err); err != nil {
+		return fmt.Errorf("failed to resolve instances: %w", err)
+	}
+	err = builder.ResolveShards(families)
+	if err := warn(err); err != nil {
+		return fmt.Errorf("failed to resolve shards: %w", err)
+	}
+
+	// The plan names the font files of the catalog and the fonts they are
+	// generated from
+	formats := append([]string{builder.FormatWOFF2}, cfg.Formats...)
+	dirs := builder.OutputDirs{
+		Index:    filepath.Join(outputDir, "api", cfg.APIVersion),
+		Fonts:    filepath.Join(outputDir, "api", cfg.APIVersion, "fonts"),
+		Licenses: filepath.Join(outputDir, "api", cfg.APIVersion, "licenses"),
+		CSS:      filepath.Join(outputDir, "api", cfg.APIVersion, "css"),
+		Zips:     filepath.Join(outputDir, "api", cfg.APIVersion, "zips"),
+	}
+	plan, err := builder.NewPlan(families, nil, nil, cfg.Subsets, formats, false, dirs)
+	if err != nil {
+		return fmt.Errorf("failed to plan catalog: %w", err)
+	}
+
+	s := server.New(plan, outputDir, cfg.APIVersion, baseURL, cacheDir)
+	log.Printf("serving %d families on %s", len(plan.Families), addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func main() {
+	configPath := flag.String("config", "config.yaml", "Path to the build configuration file")
+	inputDir := flag.String("input-dir", "fonts", "Input directory containing font files")
+	outputDir := flag.String("output-dir", "out", "Output directory of the builder that is served")
+	cacheDir := flag.String("cache-dir", "cache", "Directory where fonts subsetted on demand are cached")
+	baseURL := flag.String("base-url", "", "Base URL of the font files referred to by stylesheets, the server itself if empty")
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	subsetsDir := flag.String("subsets-dir", "", "Directory of additional subset definitions, as .txt range lists or .nam glyph set files")
+	flag.Parse()
+
+	// Subsets must be loaded before the config is validated against them
+	if *subsetsDir != "" {
+		if err := subsetting.Load(*subsetsDir); err != nil {
+			log.Fatalf("error: invalid subset definitions: %v", err)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("error: invalid config: %v", err)
+	}
+
+	if err := run(*addr, *inputDir, *outputDir, strings.TrimSuffix(*baseURL, "/"), *cacheDir, cfg); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package fontaxes
+
+import "fmt"
+
+// Descriptors are the descriptors of a @font-face rule that follow from the
+// style and the variation axes of a font.
+type Descriptors struct {
+	// FontStyle is the style of the font, or the range of oblique angles of
+	// normal fonts with a slnt axis, e.g. "oblique 0deg 10deg"
+	FontStyle string
+	// FontStretch is the range of widths of fonts with a wdth axis, e.g.
+	// "75% 100%", or "" for other fonts
+	FontStretch string
+	// FontVariationSettings selects the italic of italic fonts with an ital
+	// axis, or is "" for other fonts
+	FontVariationSettings string
+}
+
+// cssRange formats a range of axis values as a CSS descriptor value with the
+// given unit, which is a single value if the range is.
+func cssRange(min float32, max float32, unit string) string {
+	if min == max {
+		return fmt.Sprintf("%v%s", min, unit)
+	}
+	return fmt.Sprintf("%v%s %v%s", min, unit, max, unit)
+}
+
+// CSS returns the descriptors of the @font-face rule of a font of the given
+// style, "normal" or "italic", with the given axes. The ranges of the axes
+// are the ranges the rule serves, which may be narrower than the ranges of the
+// font.
+func CSS(style string, axes []Axis) Descriptors {
+	descriptors := Descriptors{FontStyle: style}
+	for _, axis := range axes {
+		switch axis.Tag {
+		case "wdth":
+			descriptors.FontStretch = cssRange(axis.MinValue, axis.MaxValue, "%")
+		case "slnt":
+			// Negative slnt values lean to the right, which corresponds
+			// to positive oblique angles in CSS
+			if style == "normal" {
+				descriptors.FontStyle = "oblique " + cssRange(0-axis.MaxValue, 0-axis.MinValue, "deg")
+			}
+		case "ital":
+			if style == "italic" {
+				descriptors.FontVariationSettings = "'ital' 1"
+			}
+		}
+	}
+	return descriptors
+}
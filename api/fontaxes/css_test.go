@@ -0,0 +1,27 @@
+package fontaxes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSS(t *testing.T) {
+	tests := []struct {
+		style    string
+		axes     []Axis
+		expected Descriptors
+	}{
+		{"normal", nil, Descriptors{FontStyle: "normal"}},
+		{"italic", []Axis{{"wght", 100, 900}}, Descriptors{FontStyle: "italic"}},
+		{"normal", []Axis{{"wdth", 62.5, 100}}, Descriptors{FontStyle: "normal", FontStretch: "62.5% 100%"}},
+		{"normal", []Axis{{"wdth", 75, 75}}, Descriptors{FontStyle: "normal", FontStretch: "75%"}},
+		{"normal", []Axis{{"slnt", -10, 0}}, Descriptors{FontStyle: "oblique 0deg 10deg"}},
+		{"italic", []Axis{{"slnt", -10, 0}}, Descriptors{FontStyle: "italic"}},
+		{"normal", []Axis{{"ital", 0, 1}}, Descriptors{FontStyle: "normal"}},
+		{"italic", []Axis{{"ital", 0, 1}}, Descriptors{FontStyle: "italic", FontVariationSettings: "'ital' 1"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, CSS(tt.style, tt.axes))
+	}
+}
@@ -0,0 +1,42 @@
+// Package fontaxes formats the variation axes of fonts the same way for the
+// builder, the font server and the fontdl command line tool.
+package fontaxes
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Axis is a variation axis of a font along with its range.
+type Axis struct {
+	Tag      string
+	MinValue float32
+	MaxValue float32
+}
+
+// fileNameValue formats an axis value for use in file names, where "-"
+// separates the ends of a range and "." separates axes. The decimal point is
+// written as "p" and the minus sign as "m", e.g. "62p5" and "m10".
+func fileNameValue(value float32) string {
+	s := strconv.FormatFloat(float64(value), 'f', -1, 32)
+	return strings.NewReplacer(".", "p", "-", "m").Replace(s)
+}
+
+// FileName formats the axes other than wght for use in file names, as the tag
+// of each axis followed by its range, sorted by tag and separated by dots.
+//
+// Returns e.g. "opsz8-144.wdth62p5-100" for a font with opsz, wdth and wght
+// axes, "slntm10-0" for a font with a slnt axis and "" for fonts without other
+// axes than wght.
+func FileName(axes []Axis) string {
+	var result []string
+	for _, axis := range axes {
+		if axis.Tag == "wght" {
+			continue
+		}
+		result = append(result, axis.Tag+fileNameValue(axis.MinValue)+"-"+fileNameValue(axis.MaxValue))
+	}
+	slices.Sort(result)
+	return strings.Join(result, ".")
+}
@@ -0,0 +1,25 @@
+package fontaxes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		axes     []Axis
+		expected string
+	}{
+		{nil, ""},
+		{[]Axis{{"wght", 100, 900}}, ""},
+		{[]Axis{{"wght", 100, 900}, {"wdth", 75, 100}, {"opsz", 8, 144}}, "opsz8-144.wdth75-100"},
+		{[]Axis{{"wdth", 62.5, 100}}, "wdth62p5-100"},
+		{[]Axis{{"slnt", -10, 0}}, "slntm10-0"},
+		{[]Axis{{"GRAD", -200, 150}, {"YTAS", 649.5, 854}}, "GRADm200-150.YTAS649p5-854"},
+		{[]Axis{{"wdth", 1000000, 1000000}}, "wdth1000000-1000000"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, FileName(tt.axes))
+	}
+}
@@ -3,13 +3,17 @@ module github.com/lyxell/font.delivery/api
 go 1.23.3
 
 require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/destel/rill v0.6.0
 	github.com/oapi-codegen/runtime v1.1.1
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.19.0
 	google.golang.org/protobuf v1.35.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/apapsch/go-jsonmerge/v2 v2.0.0 // indirect
-	github.com/destel/rill v0.6.0 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
@@ -44,6 +48,4 @@ require (
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
-	golang.org/x/text v0.19.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
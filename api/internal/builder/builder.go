@@ -3,13 +3,20 @@ package builder
 import (
 	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 
+	"github.com/lyxell/font.delivery/api/fontaxes"
+	"github.com/lyxell/font.delivery/api/internal/config"
+	"github.com/lyxell/font.delivery/api/internal/instancer"
+	"github.com/lyxell/font.delivery/api/internal/subsetter"
 	"github.com/lyxell/font.delivery/api/internal/subsetting"
 	"google.golang.org/protobuf/encoding/prototext"
 )
@@ -22,6 +29,10 @@ type FontFamilyFont struct {
 	PostScript string `json:"post_script_name"`
 	FullName   string `json:"full_name"`
 	Copyright  string `json:"copyright"`
+	// Instances are the static weights instantiated from a variable font
+	Instances []int `json:"instances,omitempty"`
+	// Metrics are read from the font file, and are nil if it couldn't be read
+	Metrics *FontMetrics `json:"metrics,omitempty"`
 }
 
 type FontFamilyAxis struct {
@@ -31,15 +42,32 @@ type FontFamilyAxis struct {
 }
 
 type FontFamily struct {
-	Id       string           `json:"id"`
-	Name     string           `json:"name"`
-	Designer string           `json:"designer"`
-	License  string           `json:"license"`
-	Category []string         `json:"category"`
-	Fonts    []FontFamilyFont `json:"fonts"`
-	Subsets  []string         `json:"subsets"`
-	Axes     []FontFamilyAxis `json:"axes"`
-	Minisite string           `json:"minisite_url"`
+	Id            string           `json:"id"`
+	Name          string           `json:"name"`
+	Designer      string           `json:"designer"`
+	License       string           `json:"license"`
+	SPDXLicense   string           `json:"spdx_license"`
+	LicenseSource LicenseSource    `json:"license_source"`
+	Category      []string         `json:"category"`
+	Fonts         []FontFamilyFont `json:"fonts"`
+	Subsets       []string         `json:"subsets"`
+	Axes          []FontFamilyAxis `json:"axes"`
+	Minisite      string           `json:"minisite_url"`
+	// Languages are the languages supported by the family, as language and
+	// script codes such as "vi_Latn"
+	Languages       []string `json:"languages"`
+	PrimaryScript   string   `json:"primary_script"`
+	PrimaryLanguage string   `json:"primary_language"`
+	// Fallbacks are the local fonts that stand in for the family while its
+	// fonts load
+	Fallbacks []FontFamilyFallback `json:"fallbacks"`
+	Dir       string               `json:"-"`
+	// LicenseText is the license text read from the family directory or the
+	// name table of its fonts, or nil if the license is synthesized
+	LicenseText []byte `json:"-"`
+	// EmptyShards are the shards of the sharded subsets of the family that
+	// none of its fonts have a character of
+	EmptyShards []string `json:"-"`
 }
 
 // Get the intersection of two slices.
@@ -54,6 +82,21 @@ func intersection(slice1, slice2 []string) []string {
 	return result
 }
 
+// shards returns the names that the fonts of a family are published under for
+// the subsets, i.e. the shards of sharded subsets such as "japanese-0" that
+// aren't empty for the family and the other subsets as they are.
+func shards(family FontFamily, subsets []string) []string {
+	var result []string
+	for _, subset := range subsets {
+		for _, shard := range subsetting.Shards(subset) {
+			if !slices.Contains(family.EmptyShards, shard) {
+				result = append(result, shard)
+			}
+		}
+	}
+	return result
+}
+
 // parseMetadataProtobuf parses a METADATA.pb file into a FamilyProto struct.
 func parseMetadataProtobuf(path string) (*FamilyProto, error) {
 	data, err := os.ReadFile(path)
@@ -67,12 +110,37 @@ func parseMetadataProtobuf(path string) (*FamilyProto, error) {
 	return &protoInstance, nil
 }
 
+// metadataNamePattern matches the name field of a METADATA.pb file.
+var metadataNamePattern = regexp.MustCompile(`(?m)^name:\s*"([^"\n]+)"`)
+
+// getFamilyId returns the id of the family with the given name, e.g.
+// "open-sans" for "Open Sans".
+func getFamilyId(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+// unparsedFamilyId returns the id of the family of a METADATA.pb file that
+// can't be parsed, from its name field if it has one and from the name of its
+// directory otherwise.
+func unparsedFamilyId(path string) string {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if match := metadataNamePattern.FindSubmatch(data); match != nil {
+			return getFamilyId(string(match[1]))
+		}
+	}
+	return filepath.Base(filepath.Dir(path))
+}
+
 // CollectMetadata walks the given directory and gathers metadata from all
 // METADATA.pb files it finds by walking the directory recursively.
 //
-// The slice of metadata will be sorted by the name of the font family.
+// The slice of metadata will be sorted by the name of the font family. Families
+// whose metadata can't be read are left out and reported as build errors in
+// the returned error, along with the families that were read.
 func CollectMetadata(rootDir string, ignoreList []string) ([]FontFamily, error) {
 	var metadata []FontFamily
+	var errs []error
 	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -80,9 +148,13 @@ func CollectMetadata(rootDir string, ignoreList []string) ([]FontFamily, error)
 		if info.Name() == "METADATA.pb" {
 			familyData, err := parseMetadataProtobuf(path)
 			if err != nil {
-				return err
+				id := unparsedFamilyId(path)
+				if !slices.Contains(ignoreList, id) {
+					errs = append(errs, &BuildError{Family: id, Stage: StageParse, Err: err})
+				}
+				return nil
 			}
-			id := strings.ToLower(strings.ReplaceAll(familyData.GetName(), " ", "-"))
+			id := getFamilyId(familyData.GetName())
 			if slices.Contains(ignoreList, id) {
 				return nil
 			}
@@ -94,6 +166,13 @@ func CollectMetadata(rootDir string, ignoreList []string) ([]FontFamily, error)
 				Category: familyData.GetCategory(),
 				Subsets:  familyData.GetSubsets(),
 				Minisite: familyData.GetMinisiteUrl(),
+				Dir:      filepath.Dir(path),
+
+				Languages:       familyData.GetLanguages(),
+				PrimaryScript:   familyData.GetPrimaryScript(),
+				PrimaryLanguage: familyData.GetPrimaryLanguage(),
+
+				Fallbacks: getFallbacks(familyData.GetFallbacks()),
 			}
 			for _, fontProto := range familyData.GetFonts() {
 				family.Fonts = append(family.Fonts, FontFamilyFont{
@@ -113,14 +192,56 @@ func CollectMetadata(rootDir string, ignoreList []string) ([]FontFamily, error)
 					MaxValue: axisProto.GetMaxValue(),
 				})
 			}
+			licenseText, licenseSource, err := readLicense(family)
+			if err != nil {
+				errs = append(errs, &BuildError{Family: id, Stage: StageLicense, Err: fmt.Errorf("failed to read license: %w", err)})
+				return nil
+			}
+			if licenseText == nil {
+				licenseSource = LicenseSourceSynthesized
+			}
+			family.SPDXLicense = getLicenseSPDXIdentifier(family.License, string(licenseText))
+			family.LicenseSource = licenseSource
+			family.LicenseText = licenseText
 			metadata = append(metadata, family)
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 	slices.SortFunc(metadata, func(a, b FontFamily) int {
 		return cmp.Compare(a.Name, b.Name)
 	})
-	return metadata, err
+	return metadata, errors.Join(errs...)
+}
+
+// ApplyOverrides applies per-family overrides to the collected families. It
+// returns the ids of overridden families that weren't collected.
+func ApplyOverrides(families []FontFamily, overrides map[string]config.FamilyOverride) []string {
+	var unknown []string
+	for id := range overrides {
+		if !slices.ContainsFunc(families, func(family FontFamily) bool { return family.Id == id }) {
+			unknown = append(unknown, id)
+		}
+	}
+	slices.Sort(unknown)
+	for i := range families {
+		override, found := overrides[families[i].Id]
+		if !found {
+			continue
+		}
+		if override.Name != "" {
+			families[i].Name = override.Name
+		}
+		if override.License != "" {
+			families[i].SPDXLicense = override.License
+		}
+		if override.Subsets != nil {
+			families[i].Subsets = override.Subsets
+		}
+	}
+	return unknown
 }
 
 // Gets the font weight for a font.
@@ -182,13 +303,34 @@ func getFontWeights(family FontFamily) []string {
 	return result
 }
 
-func getLicenseDirName(license string) string {
-	switch strings.ToLower(license) {
-	case "apache2":
-		return "apache"
-	default:
-		return strings.ToLower(license)
+// Gets the variation axes of a font family other than wght, formatted for use
+// in file names, see fontaxes.FileName.
+//
+// Returns e.g. "opsz8-144.wdth75-100" for a family with opsz, wdth and wght
+// axes and "" for families without other axes than wght.
+func getFontAxes(family FontFamily) string {
+	return fontaxes.FileName(familyAxes(family))
+}
+
+// familyAxes returns the variation axes of a font family.
+func familyAxes(family FontFamily) []fontaxes.Axis {
+	var axes []fontaxes.Axis
+	for _, axis := range family.Axes {
+		axes = append(axes, fontaxes.Axis{Tag: axis.Tag, MinValue: axis.MinValue, MaxValue: axis.MaxValue})
 	}
+	return axes
+}
+
+// Gets the file name of a font for a subset and format, e.g.
+// "alegreya-sans_latin_400_normal.woff2". The axes other than wght are
+// appended for families that have them, e.g.
+// "roboto-flex_latin_100-1000_normal_opsz8-144.woff2".
+func getFontFileName(family FontFamily, font FontFamilyFont, subset string, format string) string {
+	weight := strings.Join(getFontWeight(family, font), "-")
+	if axes := getFontAxes(family); axes != "" {
+		return fmt.Sprintf("%s_%s_%s_%s_%s.%s", family.Id, subset, weight, font.Style, axes, format)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.%s", family.Id, subset, weight, font.Style, format)
 }
 
 func getLicenseFileName(license string) string {
@@ -204,9 +346,18 @@ func getLicenseFileName(license string) string {
 	}
 }
 
-func getLicenseSPDXIdentifier(license string) string {
+// oflVersionPattern matches the version in the title of the SIL Open Font
+// License, which is either on the same line or on the line below.
+var oflVersionPattern = regexp.MustCompile(`(?i)SIL\s+OPEN\s+FONT\s+LICENSE,?\s+Version\s+(1\.[01])\b`)
+
+// Gets the SPDX identifier of a license. The version of the OFL is detected
+// from the license text and defaults to 1.1.
+func getLicenseSPDXIdentifier(license string, licenseText string) string {
 	switch strings.ToLower(license) {
 	case "ofl":
+		if match := oflVersionPattern.FindStringSubmatch(licenseText); match != nil {
+			return "OFL-" + match[1]
+		}
 		return "OFL-1.1"
 	case "ufl":
 		return "Ubuntu-font-1.0"
@@ -217,105 +368,262 @@ func getLicenseSPDXIdentifier(license string) string {
 	}
 }
 
-func GenerateLicenseFile(family FontFamily, inputDir string, outputDir string) error {
-	inputPath := filepath.Join(
-		inputDir,
-		getLicenseDirName(family.License),
-		strings.ToLower(strings.ReplaceAll(family.Name, " ", "")),
-		getLicenseFileName(family.License),
-	)
-	data, err := os.ReadFile(inputPath)
+// GenerateLicenseFile writes the license of a family as read when the family
+// was collected. Families without a license file get a license from the name
+// table of their fonts or a synthesized license. The source of the license is
+// recorded in the manifest.
+func GenerateLicenseFile(family FontFamily, outputDir string, manifest *Manifest) error {
+	data, err := completeLicense(family)
 	if err != nil {
-		return err
+		return &BuildError{Family: family.Id, Stage: StageLicense, Err: err}
 	}
 	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s-LICENSE.txt", family.Id))
-	return os.WriteFile(outputPath, data, 0o644)
+	entry := manifest.entry(family.Id, hashBytes(data), "")
+	entry.LicenseSource = family.LicenseSource
+	if manifest.UpToDate(outputPath, entry) {
+		return nil
+	}
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return &BuildError{Family: family.Id, Stage: StageMove, Err: err}
+	}
+	manifest.Record(outputPath, entry)
+	return nil
 }
 
-func GenerateWOFF2Files(family FontFamily, subsets []string, fontInputDir string, fontOutputDir string, tmpDir string) error {
-	for _, subset := range subsets {
-		// We add the family.Id here to avoid race conditions where goroutines
-		// could overwrite the files of other goroutines
-		unicodeRangesPath := filepath.Join(tmpDir, fmt.Sprintf("range-%s-%s.txt", family.Id, subset))
-		err := os.WriteFile(unicodeRangesPath, []byte(subsetting.BuildHarfbuzzString(subset)), 0o644)
+// subsetFont subsets the font at inputPath and returns the subsetted font. If
+// useHbSubset is set the hb-subset tool is used instead of the native
+// subsetter, writing its output to tempSubsetPath.
+func subsetFont(inputPath string, subset string, unicodeRangesPath string, tempSubsetPath string, useHbSubset bool) ([]byte, error) {
+	if useHbSubset {
+		// All layout features are kept, as the native subsetter does, since
+		// complex scripts such as Arabic and Devanagari can't be shaped
+		// without features outside of the hb-subset defaults
+		cmd := exec.Command("hb-subset", "--unicodes-file="+unicodeRangesPath, "--layout-features=*", "--output-file="+tempSubsetPath, inputPath)
+		if err := cmd.Run(); err != nil {
+			return nil, err
+		}
+		defer os.Remove(tempSubsetPath)
+		return os.ReadFile(tempSubsetPath)
+	}
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return subsetter.Subset(data, subsetting.Runes(subset))
+}
+
+// GenerateFont generates a font of a family that only contains the given code
+// points, in the given format. If weight is set the static instance of that
+// weight is generated from the variable font.
+func GenerateFont(family FontFamily, font FontFamilyFont, weight int, runes []rune, format string) ([]byte, error) {
+	encode, found := encoders[format]
+	if !found {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	data, err := os.ReadFile(filepath.Join(family.Dir, font.Filename))
+	if err != nil {
+		return nil, err
+	}
+	if weight != 0 {
+		data, err = instancer.Instantiate(data, map[string]float64{"wght": float64(weight)})
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+	subsetted, err := subsetter.Subset(data, runes)
+	if err != nil {
+		return nil, err
+	}
+	return encode(subsetted)
+}
+
+// GenerateFontFiles generates one file per font, subset and format, e.g. a
+// .woff2- and a .woff-file, along with the same files for the static instances
+// of the fonts. Outputs that the manifest reports as up to date are skipped. A
+// failing font or subset doesn't stop the others from being generated; all
+// failures are returned as build errors.
+func GenerateFontFiles(family FontFamily, subsets []string, formats []string, fontOutputDir string, tmpDir string, useHbSubset bool, manifest *Manifest) error {
+	if useHbSubset {
+		for _, subset := range shards(family, intersection(subsets, family.Subsets)) {
+			// We add the family.Id here to avoid race conditions where goroutines
+			// could overwrite the files of other goroutines
+			unicodeRangesPath := filepath.Join(tmpDir, fmt.Sprintf("range-%s-%s.txt", family.Id, subset))
+			err := os.WriteFile(unicodeRangesPath, []byte(subsetting.BuildHarfbuzzString(subset)), 0o644)
+			if err != nil {
+				return &BuildError{Family: family.Id, Subset: subset, Stage: StageSubset, Err: err}
+			}
 		}
 	}
 
+	var errs []error
 	for _, font := range family.Fonts {
 		// inputPath is where we find the original .tff-file
-		inputPath := filepath.Join(
-			fontInputDir,
-			getLicenseDirName(family.License),
-			strings.ToLower(strings.ReplaceAll(family.Name, " ", "")),
-			font.Filename,
-		)
-		for _, subset := range intersection(subsets, family.Subsets) {
-
-			// unicodeRangesPath is where harfbuzz reads the unicode ranges for subsetting from
-			unicodeRangesPath := filepath.Join(tmpDir, fmt.Sprintf("range-%s-%s.txt", family.Id, subset))
+		inputPath := filepath.Join(family.Dir, font.Filename)
+		sourceHash, err := hashFile(inputPath)
+		if err != nil {
+			errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageParse, Err: err})
+			continue
+		}
+		fileName := func(subset string, format string) string {
+			return getFontFileName(family, font, subset, format)
+		}
+		errs = append(errs, generateFontFiles(family, font, inputPath, sourceHash, fileName, subsets, formats, fontOutputDir, tmpDir, useHbSubset, manifest)...)
 
-			// tempSubsetPath is where the intermediary subsetted .ttf-file will be written to
-			tempSubsetPath := filepath.Join(tmpDir, fmt.Sprintf("%s_%s.subset.ttf", family.Id, subset))
+		for _, weight := range font.Instances {
+			fileName := func(subset string, format string) string {
+				return getInstanceFileName(family, font, weight, subset, format)
+			}
+			// instancePath is where the instance is written to before it is
+			// subsetted
+			instancePath := filepath.Join(tmpDir, fmt.Sprintf("%s_%s_%d.instance.ttf", family.Id, font.Style, weight))
+			instantiate := func() error {
+				data, err := os.ReadFile(inputPath)
+				if err != nil {
+					return err
+				}
+				instance, err := instancer.Instantiate(data, map[string]float64{"wght": float64(weight)})
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(instancePath, instance, 0o644)
+			}
+			if !upToDate(family, sourceHash, fileName, subsets, formats, fontOutputDir, manifest) {
+				if err := instantiate(); err != nil {
+					errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageInstance, Err: fmt.Errorf("weight %d: %w", weight, err)})
+					continue
+				}
+			}
+			errs = append(errs, generateFontFiles(family, font, instancePath, sourceHash, fileName, subsets, formats, fontOutputDir, tmpDir, useHbSubset, manifest)...)
+			os.Remove(instancePath)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-			// Perform subsetting
-			cmdSubset := exec.Command("hb-subset", "--unicodes-file="+unicodeRangesPath, "--output-file="+tempSubsetPath, inputPath)
-			if err := cmdSubset.Run(); err != nil {
-				return fmt.Errorf("error subsetting font %s for subset %s: %w", font.Name, subset, err)
+// upToDate reports whether all files generated from a font are up to date.
+func upToDate(family FontFamily, sourceHash string, fileName func(subset string, format string) string, subsets []string, formats []string, fontOutputDir string, manifest *Manifest) bool {
+	for _, subset := range shards(family, intersection(subsets, family.Subsets)) {
+		entry := manifest.entry(family.Id, sourceHash, subsetting.BuildHarfbuzzString(subset))
+		for _, format := range formats {
+			if !manifest.UpToDate(filepath.Join(fontOutputDir, fileName(subset, format)), entry) {
+				return false
 			}
+		}
+	}
+	return true
+}
 
-			// Generate woff2-file
-			cmdCompress := exec.Command("woff2_compress", tempSubsetPath)
-			if err := cmdCompress.Run(); err != nil {
-				return fmt.Errorf("error compressing to WOFF2 for font %s, subset %s: %w", font.Name, subset, err)
+// generateFontFiles subsets and encodes the font at inputPath, naming the
+// outputs with fileName. sourceHash is the hash of the source file of the
+// font that the outputs are recorded with in the manifest.
+func generateFontFiles(family FontFamily, font FontFamilyFont, inputPath string, sourceHash string, fileName func(subset string, format string) string, subsets []string, formats []string, fontOutputDir string, tmpDir string, useHbSubset bool, manifest *Manifest) []error {
+	var errs []error
+	for _, subset := range shards(family, intersection(subsets, family.Subsets)) {
+		// outputPaths are where the final font files will be written to,
+		// keyed by format
+		outputPaths := make(map[string]string)
+		entry := manifest.entry(family.Id, sourceHash, subsetting.BuildHarfbuzzString(subset))
+		for _, format := range formats {
+			outputPath := filepath.Join(fontOutputDir, fileName(subset, format))
+			if !manifest.UpToDate(outputPath, entry) {
+				outputPaths[format] = outputPath
 			}
+		}
+		if len(outputPaths) == 0 {
+			continue
+		}
+
+		// unicodeRangesPath is where harfbuzz reads the unicode ranges for subsetting from
+		unicodeRangesPath := filepath.Join(tmpDir, fmt.Sprintf("range-%s-%s.txt", family.Id, subset))
 
-			// Move file to final destination
-			tempWoff2Path := strings.TrimSuffix(tempSubsetPath, ".ttf") + ".woff2"
-			// outputPath is where the final .woff2-file will be written to
-			outputPath := filepath.Join(fontOutputDir, fmt.Sprintf("%s_%s_%s_%s.woff2", family.Id, subset, strings.Join(getFontWeight(family, font), "-"), font.Style))
-			if err := os.Rename(tempWoff2Path, outputPath); err != nil {
-				return fmt.Errorf("error moving WOFF2 file to output directory for font %s, subset %s: %w", font.Name, subset, err)
+		// tempSubsetPath is where hb-subset writes the intermediary subsetted .ttf-file to
+		tempSubsetPath := filepath.Join(tmpDir, strings.TrimSuffix(fileName(subset, ""), ".")+".subset.ttf")
+
+		// Perform subsetting
+		subsetted, err := subsetFont(inputPath, subset, unicodeRangesPath, tempSubsetPath, useHbSubset)
+		if err != nil {
+			errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Subset: subset, Stage: StageSubset, Err: err})
+			continue
+		}
+
+		// Generate the font files
+		for _, format := range formats {
+			outputPath, found := outputPaths[format]
+			if !found {
+				continue
+			}
+			data, err := encoders[format](subsetted)
+			if err != nil {
+				errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Subset: subset, Stage: StageCompress, Err: err})
+				continue
+			}
+			if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+				errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Subset: subset, Stage: StageMove, Err: err})
+				continue
 			}
+			manifest.Record(outputPath, entry)
 		}
 	}
-	return nil
+	return errs
 }
 
+// Write the subsets JSON file containing the Unicode ranges of all subsets,
+// and of each shard of sharded subsets. I.e. api/v2/subsets.json
 func GenerateSubsetsJSONFile(subsets []string, outputDir string) error {
-	type subsetData struct {
-		Subset string `json:"subset"`
+	type shardData struct {
+		Shard  string `json:"shard"`
 		Ranges string `json:"ranges"`
 	}
+	type subsetData struct {
+		Subset string      `json:"subset"`
+		Ranges string      `json:"ranges"`
+		Shards []shardData `json:"shards,omitempty"`
+	}
 
 	subsetsData := []subsetData{}
 	for _, subset := range subsets {
-		subsetsData = append(subsetsData, subsetData{
+		data := subsetData{
 			Subset: subset,
 			Ranges: subsetting.BuildCSSString(subset),
-		})
+		}
+		if shards := subsetting.Shards(subset); len(shards) > 1 {
+			for _, shard := range shards {
+				data.Shards = append(data.Shards, shardData{Shard: shard, Ranges: subsetting.BuildCSSString(shard)})
+			}
+		}
+		subsetsData = append(subsetsData, data)
 	}
 
 	subsetsJSON, err := json.MarshalIndent(subsetsData, "", "  ")
 	if err != nil {
 		return err
 	}
-	outputPath := filepath.Join(outputDir, "subsets.json")
-	return os.WriteFile(outputPath, subsetsJSON, 0o644)
+	_, err = writeArtifact(filepath.Join(outputDir, "subsets.json"), subsetsJSON)
+	return err
 }
 
 // Write the index JSON file containing names and ids for all families.
 // I.e. api/v1/fonts.json
-func GenerateIndexJSONFile(families []FontFamily, subsets []string, outputDir string) error {
+func GenerateIndexJSONFile(families []FontFamily, subsets []string, formats []string, outputDir string) error {
 	type fontData struct {
-		ID       string   `json:"id"`
-		Name     string   `json:"name"`
-		Designer string   `json:"designer"`
-		License  string   `json:"license"`
-		Subsets  []string `json:"subsets"`
-		Weights  []string `json:"weights"`
-		Styles   []string `json:"styles"`
+		ID       string           `json:"id"`
+		Name     string           `json:"name"`
+		Designer string           `json:"designer"`
+		License  string           `json:"license"`
+		Subsets  []string         `json:"subsets"`
+		Weights  []string         `json:"weights"`
+		Styles   []string         `json:"styles"`
+		Axes     []FontFamilyAxis `json:"axes"`
+		Formats  []string         `json:"formats"`
+		// StaticWeights are the weights of the static instances of a
+		// variable family
+		StaticWeights []int `json:"static_weights"`
+		// EmptyShards are the shards that are not published for the family
+		EmptyShards []string `json:"empty_shards"`
+
+		Languages       []string `json:"languages"`
+		PrimaryScript   string   `json:"primary_script"`
+		PrimaryLanguage string   `json:"primary_language"`
 	}
 
 	var apiData []fontData
@@ -324,19 +632,274 @@ func GenerateIndexJSONFile(families []FontFamily, subsets []string, outputDir st
 		if len(intersection(subsets, family.Subsets)) == 0 {
 			continue
 		}
+		axes := family.Axes
+		if axes == nil {
+			axes = []FontFamilyAxis{}
+		}
+		emptyShards := []string{}
+		for _, subset := range intersection(subsets, family.Subsets) {
+			for _, shard := range subsetting.Shards(subset) {
+				if slices.Contains(family.EmptyShards, shard) {
+					emptyShards = append(emptyShards, shard)
+				}
+			}
+		}
 		apiData = append(apiData, fontData{
 			ID:       family.Id,
 			Name:     family.Name,
 			Designer: family.Designer,
-			License:  getLicenseSPDXIdentifier(family.License),
+			License:  family.SPDXLicense,
 			Subsets:  intersection(subsets, family.Subsets),
 			Weights:  getFontWeights(family),
 			Styles:   getFontStyles(family),
+			Axes:     axes,
+			Formats:  formats,
+
+			StaticWeights: getStaticWeights(family),
+			EmptyShards:   emptyShards,
+
+			Languages:       getLanguages(family),
+			PrimaryScript:   family.PrimaryScript,
+			PrimaryLanguage: family.PrimaryLanguage,
 		})
 	}
 	apiDataBytes, err := json.MarshalIndent(apiData, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(outputDir, "fonts.json"), apiDataBytes, 0o644)
+	_, err = writeArtifact(filepath.Join(outputDir, "fonts.json"), apiDataBytes)
+	return err
+}
+
+// getLanguages returns the languages supported by a family, or an empty slice
+// if its metadata lists none.
+func getLanguages(family FontFamily) []string {
+	if family.Languages == nil {
+		return []string{}
+	}
+	return family.Languages
+}
+
+// Write the languages JSON file mapping each language to the ids of the
+// families that support it, sorted by language. I.e. api/v2/languages.json
+func GenerateLanguagesJSONFile(families []FontFamily, subsets []string, outputDir string) error {
+	type languageData struct {
+		Language string   `json:"language"`
+		Families []string `json:"families"`
+	}
+
+	familyIds := make(map[string][]string)
+	for _, family := range families {
+		// Skip families that do not have any renderable subsets
+		if len(intersection(subsets, family.Subsets)) == 0 {
+			continue
+		}
+		for _, language := range family.Languages {
+			if !slices.Contains(familyIds[language], family.Id) {
+				familyIds[language] = append(familyIds[language], family.Id)
+			}
+		}
+	}
+	languagesData := []languageData{}
+	for _, language := range slices.Sorted(maps.Keys(familyIds)) {
+		languagesData = append(languagesData, languageData{Language: language, Families: familyIds[language]})
+	}
+	languagesJSON, err := json.MarshalIndent(languagesData, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writeArtifact(filepath.Join(outputDir, "languages.json"), languagesJSON)
+	return err
+}
+
+// Write one JSON file per family containing all metadata of the family and
+// the download URLs of its fonts. I.e. api/v2/fonts/{id}.json. The files of
+// every format are listed by format, and the WOFF2 files also on their own.
+// If hashes are given, the content-addressed files are listed along with
+// their hashes and sizes.
+func GenerateFamilyJSONFile(family FontFamily, subsets []string, formats []string, baseURL string, hashes FileHashes, outputDir string, manifest *Manifest) error {
+	type hashedFileData struct {
+		URL  string `json:"url"`
+		Hash string `json:"hash"`
+		Size int64  `json:"size"`
+	}
+	type formatData struct {
+		Files       map[string]string         `json:"files"`
+		HashedFiles map[string]hashedFileData `json:"hashed_files,omitempty"`
+	}
+	type instanceData struct {
+		Weight      int                       `json:"weight"`
+		Files       map[string]string         `json:"files"`
+		HashedFiles map[string]hashedFileData `json:"hashed_files,omitempty"`
+		Formats     map[string]formatData     `json:"formats"`
+	}
+	type fontData struct {
+		Name       string            `json:"name"`
+		Style      string            `json:"style"`
+		Weight     string            `json:"weight"`
+		Filename   string            `json:"filename"`
+		PostScript string            `json:"post_script_name"`
+		FullName   string            `json:"full_name"`
+		Copyright  string            `json:"copyright"`
+		Files      map[string]string `json:"files"`
+		Instances  []instanceData    `json:"instances"`
+		// HashedFiles are the content-addressed files of the font
+		HashedFiles map[string]hashedFileData `json:"hashed_files,omitempty"`
+		// Formats are the files of the font in every format, keyed by
+		// format
+		Formats map[string]formatData `json:"formats"`
+		Metrics *FontMetrics          `json:"metrics,omitempty"`
+	}
+	type familyData struct {
+		ID         string           `json:"id"`
+		Name       string           `json:"name"`
+		Designer   string           `json:"designer"`
+		License    string           `json:"license"`
+		LicenseURL string           `json:"license_url"`
+		Category   []string         `json:"category"`
+		Minisite   string           `json:"minisite_url"`
+		Subsets    []string         `json:"subsets"`
+		Weights    []string         `json:"weights"`
+		Styles     []string         `json:"styles"`
+		Axes       []FontFamilyAxis `json:"axes"`
+		Fonts      []fontData       `json:"fonts"`
+
+		StaticWeights []int `json:"static_weights"`
+
+		Languages       []string `json:"languages"`
+		PrimaryScript   string   `json:"primary_script"`
+		PrimaryLanguage string   `json:"primary_language"`
+
+		Fallbacks  []FontFamilyFallback `json:"fallbacks"`
+		FontFamily string               `json:"font_family"`
+	}
+
+	// Skip families that do not have any renderable subsets
+	familySubsets := intersection(subsets, family.Subsets)
+	if len(familySubsets) == 0 {
+		return nil
+	}
+	data := familyData{
+		ID:         family.Id,
+		Name:       family.Name,
+		Designer:   family.Designer,
+		License:    family.SPDXLicense,
+		LicenseURL: fmt.Sprintf("%s/licenses/%s-LICENSE.txt", baseURL, family.Id),
+		Category:   family.Category,
+		Minisite:   family.Minisite,
+		Subsets:    familySubsets,
+		Weights:    getFontWeights(family),
+		Styles:     getFontStyles(family),
+		Axes:       family.Axes,
+		Fonts:      []fontData{},
+
+		StaticWeights: getStaticWeights(family),
+
+		Languages:       getLanguages(family),
+		PrimaryScript:   family.PrimaryScript,
+		PrimaryLanguage: family.PrimaryLanguage,
+
+		Fallbacks:  family.Fallbacks,
+		FontFamily: getFontFamilyStack(family),
+	}
+	if data.Category == nil {
+		data.Category = []string{}
+	}
+	if data.Axes == nil {
+		data.Axes = []FontFamilyAxis{}
+	}
+	if data.Fallbacks == nil {
+		data.Fallbacks = []FontFamilyFallback{}
+	}
+	// hashedFiles lists the content-addressed files of the given files
+	hashedFiles := func(files map[string]string) map[string]hashedFileData {
+		if hashes == nil {
+			return nil
+		}
+		hashed := make(map[string]hashedFileData)
+		for subset, name := range files {
+			hashed[subset] = hashedFileData{
+				URL:  fmt.Sprintf("%s/fonts/%s", baseURL, hashes.fileName(name)),
+				Hash: hashes[name].Hash,
+				Size: hashes[name].Size,
+			}
+		}
+		return hashed
+	}
+	// urls returns the download URLs of the given files
+	urls := func(files map[string]string) map[string]string {
+		result := make(map[string]string)
+		for subset, name := range files {
+			result[subset] = fmt.Sprintf("%s/fonts/%s", baseURL, name)
+		}
+		return result
+	}
+	// formatFiles lists the files of every format, given the name of the file
+	// of a subset in a format
+	formatFiles := func(fileName func(subset string, format string) string) map[string]formatData {
+		result := make(map[string]formatData)
+		for _, format := range formats {
+			files := make(map[string]string)
+			for _, subset := range shards(family, familySubsets) {
+				files[subset] = fileName(subset, format)
+			}
+			result[format] = formatData{Files: urls(files), HashedFiles: hashedFiles(files)}
+		}
+		return result
+	}
+	for _, font := range family.Fonts {
+		files := make(map[string]string)
+		for _, subset := range shards(family, familySubsets) {
+			files[subset] = getFontFileName(family, font, subset, FormatWOFF2)
+		}
+		instances := []instanceData{}
+		for _, weight := range font.Instances {
+			instanceFiles := make(map[string]string)
+			for _, subset := range shards(family, familySubsets) {
+				instanceFiles[subset] = getInstanceFileName(family, font, weight, subset, FormatWOFF2)
+			}
+			instances = append(instances, instanceData{
+				Weight:      weight,
+				Files:       urls(instanceFiles),
+				HashedFiles: hashedFiles(instanceFiles),
+				Formats: formatFiles(func(subset string, format string) string {
+					return getInstanceFileName(family, font, weight, subset, format)
+				}),
+			})
+		}
+		data.Fonts = append(data.Fonts, fontData{
+			Name:       font.Name,
+			Style:      font.Style,
+			Weight:     strings.Join(getFontWeight(family, font), "-"),
+			Filename:   font.Filename,
+			PostScript: font.PostScript,
+			FullName:   font.FullName,
+			Copyright:  font.Copyright,
+			Files:      urls(files),
+			Instances:  instances,
+
+			HashedFiles: hashedFiles(files),
+			Formats: formatFiles(func(subset string, format string) string {
+				return getFontFileName(family, font, subset, format)
+			}),
+			Metrics: font.Metrics,
+		})
+	}
+	dataBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	outputPath := filepath.Join(outputDir, family.Id+".json")
+	entry := manifest.entry(family.Id, hashBytes(dataBytes), "")
+	if manifest.UpToDate(outputPath, entry) {
+		return nil
+	}
+	paths, err := writeArtifact(outputPath, dataBytes)
+	if err != nil {
+		return &BuildError{Family: family.Id, Stage: StageMove, Err: err}
+	}
+	for _, path := range paths {
+		manifest.Record(path, entry)
+	}
+	return nil
 }
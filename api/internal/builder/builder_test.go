@@ -1,9 +1,15 @@
 package builder
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 func TestGetFontWeightsWithSampleData(t *testing.T) {
@@ -79,3 +85,269 @@ func TestGetFontStylesWithSampleData(t *testing.T) {
 
 	assert.Equal(t, actualStyles, expectedStyles)
 }
+
+func TestGenerateFamilyJSONFile(t *testing.T) {
+	family := FontFamily{
+		Id:          "roboto-flex",
+		Name:        "Roboto Flex",
+		Designer:    "Font Bureau",
+		License:     "OFL",
+		SPDXLicense: "OFL-1.1",
+		Category:    []string{"SANS_SERIF"},
+		Fonts: []FontFamilyFont{
+			{
+				Name:       "Roboto Flex",
+				Style:      "normal",
+				Weight:     400,
+				Filename:   "RobotoFlex[GRAD,wght].ttf",
+				PostScript: "RobotoFlex-Regular",
+				FullName:   "Roboto Flex Regular",
+				Copyright:  "Copyright 2017 The Roboto Flex Project Authors",
+			},
+		},
+		Subsets: []string{"cyrillic", "latin", "menu"},
+		Axes: []FontFamilyAxis{
+			{Tag: "GRAD", MinValue: -200, MaxValue: 150},
+			{Tag: "wght", MinValue: 100, MaxValue: 1000},
+		},
+	}
+	outputDir := t.TempDir()
+	err := GenerateFamilyJSONFile(family, []string{"latin", "cyrillic"}, []string{FormatWOFF2, FormatTTF}, "https://font.delivery/api/v2", nil, outputDir, nil)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "roboto-flex.json"))
+	require.NoError(t, err)
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, "OFL-1.1", result["license"])
+	assert.Equal(t, "https://font.delivery/api/v2/licenses/roboto-flex-LICENSE.txt", result["license_url"])
+	assert.Equal(t, []any{"latin", "cyrillic"}, result["subsets"])
+	assert.Len(t, result["axes"], 2)
+	assert.Equal(t, []any{}, result["fallbacks"])
+	assert.Equal(t, "'Roboto Flex', sans-serif", result["font_family"])
+
+	fonts := result["fonts"].([]any)
+	require.Len(t, fonts, 1)
+	font := fonts[0].(map[string]any)
+	assert.Equal(t, "RobotoFlex-Regular", font["post_script_name"])
+	assert.Equal(t, "100-1000", font["weight"])
+	assert.Equal(t, map[string]any{
+		"latin":    "https://font.delivery/api/v2/fonts/roboto-flex_latin_100-1000_normal_GRADm200-150.woff2",
+		"cyrillic": "https://font.delivery/api/v2/fonts/roboto-flex_cyrillic_100-1000_normal_GRADm200-150.woff2",
+	}, font["files"])
+
+	// The files of every format are listed by format
+	formats := font["formats"].(map[string]any)
+	require.Len(t, formats, 2)
+	assert.Equal(t, font["files"], formats["woff2"].(map[string]any)["files"])
+	assert.Equal(t, map[string]any{
+		"latin":    "https://font.delivery/api/v2/fonts/roboto-flex_latin_100-1000_normal_GRADm200-150.ttf",
+		"cyrillic": "https://font.delivery/api/v2/fonts/roboto-flex_cyrillic_100-1000_normal_GRADm200-150.ttf",
+	}, formats["ttf"].(map[string]any)["files"])
+}
+
+func TestGetFontFileName(t *testing.T) {
+	font := FontFamilyFont{Weight: 400, Style: "normal"}
+	tests := []struct {
+		axes     []FontFamilyAxis
+		expected string
+	}{
+		{nil, "roboto-flex_latin_400_normal.woff2"},
+		{[]FontFamilyAxis{{Tag: "wght", MinValue: 100, MaxValue: 1000}}, "roboto-flex_latin_100-1000_normal.woff2"},
+		{
+			[]FontFamilyAxis{
+				{Tag: "wdth", MinValue: 25, MaxValue: 151},
+				{Tag: "opsz", MinValue: 8, MaxValue: 144},
+				{Tag: "wght", MinValue: 100, MaxValue: 1000},
+			},
+			"roboto-flex_latin_100-1000_normal_opsz8-144.wdth25-151.woff2",
+		},
+		{[]FontFamilyAxis{{Tag: "slnt", MinValue: -10, MaxValue: 0}}, "roboto-flex_latin_400_normal_slntm10-0.woff2"},
+	}
+	for _, tt := range tests {
+		family := FontFamily{Id: "roboto-flex", Axes: tt.axes}
+		assert.Equal(t, tt.expected, getFontFileName(family, font, "latin", FormatWOFF2))
+	}
+}
+
+func TestGetLicenseSPDXIdentifier(t *testing.T) {
+	tests := []struct {
+		license  string
+		text     string
+		expected string
+	}{
+		{"OFL", "This Font Software is licensed under the SIL Open Font License, Version 1.1.\n\nSIL OPEN FONT LICENSE Version 1.1 - 26 February 2007", "OFL-1.1"},
+		{"OFL", "Copyright (c) 2008 Tibetan and Himalayan Library\n\nSIL OPEN FONT LICENSE \nVersion 1.0 - 22 November 2005", "OFL-1.0"},
+		{"OFL", "", "OFL-1.1"},
+		{"APACHE2", "Apache License\nVersion 2.0, January 2004", "Apache-2.0"},
+		{"UFL", "UBUNTU FONT LICENCE Version 1.0", "Ubuntu-font-1.0"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, getLicenseSPDXIdentifier(tt.license, tt.text))
+	}
+}
+
+func TestGenerateIndexJSONFile(t *testing.T) {
+	families := []FontFamily{
+		{Id: "prata", Name: "Prata", SPDXLicense: "OFL-1.1", Fonts: []FontFamilyFont{{Style: "normal", Weight: 400}}, Subsets: []string{"cyrillic", "latin"}},
+		{Id: "material-icons", Name: "Material Icons", Subsets: []string{"menu"}},
+		{
+			Id:              "roboto-flex",
+			Languages:       []string{"en_Latn", "vi_Latn"},
+			PrimaryScript:   "Latn",
+			PrimaryLanguage: "en_Latn",
+			Name:            "Roboto Flex",
+			Fonts: []FontFamilyFont{
+				{Style: "normal", Weight: 400, Instances: []int{400, 700}},
+				{Style: "italic", Weight: 400, Instances: []int{300, 700}},
+			},
+			Subsets: []string{"latin"},
+			Axes:    []FontFamilyAxis{{Tag: "wght", MinValue: 100, MaxValue: 1000}},
+		},
+	}
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateIndexJSONFile(families, []string{"latin", "cyrillic"}, []string{FormatWOFF2, FormatTTF}, outputDir))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "fonts.json"))
+	require.NoError(t, err)
+	var result []map[string]any
+	require.NoError(t, json.Unmarshal(data, &result))
+	require.Len(t, result, 2)
+	assert.Equal(t, "prata", result[0]["id"])
+	assert.Equal(t, []any{"latin", "cyrillic"}, result[0]["subsets"])
+	assert.Equal(t, []any{"woff2", "ttf"}, result[0]["formats"])
+	assert.Equal(t, []any{}, result[0]["axes"])
+	assert.Equal(t, []any{}, result[0]["static_weights"])
+	assert.Equal(t, []any{}, result[0]["languages"])
+	assert.Equal(t, "", result[0]["primary_script"])
+	assert.Equal(t, "roboto-flex", result[1]["id"])
+	assert.Equal(t, []any{"100-1000"}, result[1]["weights"])
+	assert.Equal(t, []any{300.0, 400.0, 700.0}, result[1]["static_weights"])
+	assert.Equal(t, []any{"en_Latn", "vi_Latn"}, result[1]["languages"])
+	assert.Equal(t, "Latn", result[1]["primary_script"])
+	assert.Equal(t, "en_Latn", result[1]["primary_language"])
+}
+
+func TestGenerateLanguagesJSONFile(t *testing.T) {
+	families := []FontFamily{
+		{Id: "inter", Subsets: []string{"latin"}, Languages: []string{"vi_Latn", "en_Latn"}},
+		{Id: "material-icons", Subsets: []string{"menu"}, Languages: []string{"en_Latn"}},
+		{Id: "noto-sans-hebrew", Subsets: []string{"hebrew"}, Languages: []string{"he_Hebr"}},
+		{Id: "prata", Subsets: []string{"latin"}},
+		{Id: "roboto", Subsets: []string{"latin"}, Languages: []string{"en_Latn"}},
+	}
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateLanguagesJSONFile(families, []string{"latin", "hebrew"}, outputDir))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "languages.json"))
+	require.NoError(t, err)
+	var result []struct {
+		Language string   `json:"language"`
+		Families []string `json:"families"`
+	}
+	require.NoError(t, json.Unmarshal(data, &result))
+	require.Len(t, result, 3)
+	assert.Equal(t, "en_Latn", result[0].Language)
+	assert.Equal(t, []string{"inter", "roboto"}, result[0].Families)
+	assert.Equal(t, "he_Hebr", result[1].Language)
+	assert.Equal(t, []string{"noto-sans-hebrew"}, result[1].Families)
+	assert.Equal(t, "vi_Latn", result[2].Language)
+	assert.Equal(t, []string{"inter"}, result[2].Families)
+	assert.FileExists(t, filepath.Join(outputDir, "languages.min.json.br"))
+}
+
+func TestGetInstanceFileName(t *testing.T) {
+	family := FontFamily{Id: "roboto-flex", Axes: []FontFamilyAxis{{Tag: "opsz", MinValue: 8, MaxValue: 144}, {Tag: "wght", MinValue: 100, MaxValue: 1000}}}
+	font := FontFamilyFont{Style: "italic", Instances: []int{700}}
+	assert.Equal(t, "roboto-flex_latin_100-1000_italic_opsz8-144.woff2", getFontFileName(family, font, "latin", FormatWOFF2))
+	assert.Equal(t, "roboto-flex_latin_700_italic.woff2", getInstanceFileName(family, font, 700, "latin", FormatWOFF2))
+}
+
+func TestResolveInstancesWghtOnly(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Inter[opsz,wght].ttf"), []byte("not a font"), 0o644))
+	families := []FontFamily{{
+		Id:    "inter",
+		Fonts: []FontFamilyFont{{Filename: "Inter[opsz,wght].ttf"}},
+		Axes:  []FontFamilyAxis{{Tag: "opsz", MinValue: 14, MaxValue: 32}, {Tag: "wght", MinValue: 100, MaxValue: 900}},
+		Dir:   dir,
+	}}
+	// Families with other axes than wght are not instantiated, or even read
+	require.NoError(t, ResolveInstances(families, true, []int{700}))
+	assert.Empty(t, families[0].Fonts[0].Instances)
+
+	families[0].Axes = families[0].Axes[1:]
+	assert.Error(t, ResolveInstances(families, true, []int{700}))
+}
+
+func TestGenerateSubsetsJSONFile(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateSubsetsJSONFile([]string{"latin", "japanese"}, outputDir))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "subsets.json"))
+	require.NoError(t, err)
+	var result []struct {
+		Subset string `json:"subset"`
+		Ranges string `json:"ranges"`
+		Shards []struct {
+			Shard  string `json:"shard"`
+			Ranges string `json:"ranges"`
+		} `json:"shards"`
+	}
+	require.NoError(t, json.Unmarshal(data, &result))
+	require.Len(t, result, 2)
+	assert.Equal(t, "latin", result[0].Subset)
+	assert.Empty(t, result[0].Shards)
+	assert.Equal(t, "japanese", result[1].Subset)
+	require.Len(t, result[1].Shards, 100)
+	assert.Equal(t, "japanese-42", result[1].Shards[42].Shard)
+	assert.Equal(t, subsetting.BuildCSSString("japanese-42"), result[1].Shards[42].Ranges)
+}
+
+func TestGenerateFontFilesShardSize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Go-Regular.ttf"), goregular.TTF, 0o644))
+	family := FontFamily{
+		Id:      "go",
+		Fonts:   []FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+		Subsets: []string{"japanese"},
+		Dir:     dir,
+	}
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.json"), nil)
+	require.NoError(t, err)
+	require.NoError(t, GenerateFontFiles(family, []string{"japanese"}, []string{FormatTTF}, dir, dir, false, manifest))
+
+	// A shard only contains the glyphs of its code points, none of which are
+	// in the font, rather than the outlines of the whole font
+	for _, shard := range subsetting.Shards("japanese") {
+		info, err := os.Stat(filepath.Join(dir, getFontFileName(family, family.Fonts[0], shard, FormatTTF)))
+		require.NoError(t, err)
+		assert.Less(t, info.Size(), int64(len(goregular.TTF)/10), shard)
+	}
+}
+
+func TestCollectMetadataParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"opensans/METADATA.pb": "name: \"Open Sans\"\ndesigner: \n",
+		"broken/METADATA.pb":   "not metadata",
+		"jsmath/METADATA.pb":   "name: \"jsMath cmr10\"\ninvalid",
+	}
+	for name, data := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+	families, err := CollectMetadata(dir, []string{"jsmath-cmr10"})
+	assert.Empty(t, families)
+
+	// Families are reported by their id if the metadata has a name, and by
+	// their directory otherwise. Excluded families are not reported.
+	report := NewReport()
+	report.Add(err)
+	var ids []string
+	for _, buildErr := range report.Errors {
+		assert.Equal(t, StageParse, buildErr.Stage)
+		ids = append(ids, buildErr.Family)
+	}
+	assert.ElementsMatch(t, []string{"open-sans", "broken"}, ids)
+}
@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compactJSONPath returns the path of the compact variant of a JSON artifact,
+// e.g. fonts.min.json for fonts.json.
+func compactJSONPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".min.json"
+}
+
+// gzipBytes compresses data with gzip at the best compression level. The
+// header has no name or modification time, which keeps the output
+// reproducible.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// brotliBytes compresses data with Brotli at the best compression level.
+func brotliBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressors compress the siblings of artifacts, keyed by the extension
+// that is appended to the path of the artifact.
+var compressors = map[string]func([]byte) ([]byte, error){
+	".gz": gzipBytes,
+	".br": brotliBytes,
+}
+
+// writeArtifact writes a JSON or CSS artifact to path along with its
+// variants, and returns the paths of all files written. JSON artifacts get a
+// compact variant without indentation, and every file gets a gzip- and a
+// Brotli-compressed sibling, i.e. path.gz and path.br, that hosts can serve
+// with Content-Encoding instead of compressing them on every request.
+func writeArtifact(path string, data []byte) ([]string, error) {
+	variants := map[string][]byte{path: data}
+	if filepath.Ext(path) == ".json" {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			return nil, err
+		}
+		variants[compactJSONPath(path)] = compact.Bytes()
+	}
+	files := make(map[string][]byte)
+	for variantPath, variant := range variants {
+		files[variantPath] = variant
+		for ext, compress := range compressors {
+			compressed, err := compress(variant)
+			if err != nil {
+				return nil, err
+			}
+			files[variantPath+ext] = compressed
+		}
+	}
+	var paths []string
+	for filePath, data := range files {
+		if err := os.WriteFile(filePath, data, 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, filePath)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
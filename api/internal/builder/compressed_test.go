@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteArtifact(t *testing.T) {
+	outputDir := t.TempDir()
+	data := []byte("{\n  \"id\": \"inter\",\n  \"subsets\": [\n    \"latin\"\n  ]\n}")
+	paths, err := writeArtifact(filepath.Join(outputDir, "inter.json"), data)
+	require.NoError(t, err)
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	assert.Equal(t, []string{"inter.json", "inter.json.br", "inter.json.gz", "inter.min.json", "inter.min.json.br", "inter.min.json.gz"}, names)
+
+	read := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		require.NoError(t, err)
+		return data
+	}
+	assert.Equal(t, data, read("inter.json"))
+	assert.Equal(t, `{"id":"inter","subsets":["latin"]}`, string(read("inter.min.json")))
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(read("inter.json.gz")))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(gzipReader)
+	require.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+
+	decompressed, err = io.ReadAll(brotli.NewReader(bytes.NewReader(read("inter.min.json.br"))))
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"inter","subsets":["latin"]}`, string(decompressed))
+
+	// Stylesheets have no compact variant
+	paths, err = writeArtifact(filepath.Join(outputDir, "inter.css"), []byte("@font-face {}\n"))
+	require.NoError(t, err)
+	assert.Len(t, paths, 3)
+	assert.NoFileExists(t, filepath.Join(outputDir, "inter.min.css"))
+}
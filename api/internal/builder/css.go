@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lyxell/font.delivery/api/fontaxes"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+)
+
+// fontFaceCSS generates the @font-face rule for a font and subset, or for the
+// static instance of the font of the given weight if weight isn't 0. The
+// sources are listed in the given order of formats, leaving out formats that
+// are not meant for browsers, and refer to the content-addressed files of
+// hashes.
+func fontFaceCSS(family FontFamily, font FontFamilyFont, weight int, subset string, formats []string, baseURL string, hashes FileHashes) string {
+	fontWeight := strings.Join(getFontWeight(family, font), " ")
+	fileName := func(format string) string {
+		return getFontFileName(family, font, subset, format)
+	}
+	if weight != 0 {
+		fontWeight = strconv.Itoa(weight)
+		fileName = func(format string) string {
+			return getInstanceFileName(family, font, weight, subset, format)
+		}
+	}
+	descriptors := fontaxes.CSS(font.Style, familyAxes(family))
+	var extra strings.Builder
+	if descriptors.FontStretch != "" {
+		fmt.Fprintf(&extra, "  font-stretch: %s;\n", descriptors.FontStretch)
+	}
+	if descriptors.FontVariationSettings != "" {
+		fmt.Fprintf(&extra, "  font-variation-settings: %s;\n", descriptors.FontVariationSettings)
+	}
+	var sources []string
+	for _, format := range formats {
+		cssFormat, found := cssFormats[format]
+		if !found {
+			continue
+		}
+		url := fmt.Sprintf("%s/fonts/%s", baseURL, hashes.fileName(fileName(format)))
+		sources = append(sources, fmt.Sprintf("url('%s') format('%s')", url, cssFormat))
+	}
+	return fmt.Sprintf(`/* %s */
+@font-face {
+  font-family: '%s';
+  font-style: %s;
+  font-weight: %s;
+%s  src: %s;
+  unicode-range: %s;
+}
+`, subset, family.Name, descriptors.FontStyle, fontWeight, extra.String(), strings.Join(sources, ", "), subsetting.BuildCSSString(subset))
+}
+
+// familyCSS generates the @font-face rules of a family for the given subsets,
+// one rule per font and subset, or per shard of sharded subsets, followed by
+// the rules of the static instances of the fonts and the rules of the
+// companion family of its fallbacks. Browsers use the last of the rules that
+// match a weight equally well, so the static instances are used for their
+// weights and the variable fonts for the weights in between.
+func familyCSS(family FontFamily, subsets []string, formats []string, baseURL string, hashes FileHashes) []byte {
+	var rules []string
+	for _, font := range family.Fonts {
+		for _, subset := range shards(family, subsets) {
+			rules = append(rules, fontFaceCSS(family, font, 0, subset, formats, baseURL, hashes))
+		}
+	}
+	for _, font := range family.Fonts {
+		for _, weight := range font.Instances {
+			for _, subset := range shards(family, subsets) {
+				rules = append(rules, fontFaceCSS(family, font, weight, subset, formats, baseURL, hashes))
+			}
+		}
+	}
+	rules = append(rules, fallbackCSS(family)...)
+	return []byte(strings.Join(rules, "\n"))
+}
+
+// getCSSFileNames returns the names of the stylesheets of a family keyed by
+// subset, where "" is the stylesheet with all subsets.
+func getCSSFileNames(family FontFamily, subsets []string) map[string]string {
+	names := map[string]string{"": family.Id + ".css"}
+	for _, subset := range intersection(subsets, family.Subsets) {
+		names[subset] = fmt.Sprintf("%s.%s.css", family.Id, subset)
+	}
+	return names
+}
+
+// GenerateCSSFiles writes the stylesheets of a family, i.e.
+// api/v2/css/{id}.css with all subsets and api/v2/css/{id}.{subset}.css per
+// subset. The stylesheets refer to the content-addressed font files of hashes
+// if given.
+func GenerateCSSFiles(family FontFamily, subsets []string, formats []string, baseURL string, hashes FileHashes, outputDir string, manifest *Manifest) error {
+	familySubsets := intersection(subsets, family.Subsets)
+	if len(familySubsets) == 0 {
+		return nil
+	}
+	for subset, name := range getCSSFileNames(family, subsets) {
+		data := familyCSS(family, familySubsets, formats, baseURL, hashes)
+		if subset != "" {
+			data = familyCSS(family, []string{subset}, formats, baseURL, hashes)
+		}
+		outputPath := filepath.Join(outputDir, name)
+		entry := manifest.entry(family.Id, hashBytes(data), "")
+		if manifest.UpToDate(outputPath, entry) {
+			continue
+		}
+		paths, err := writeArtifact(outputPath, data)
+		if err != nil {
+			return &BuildError{Family: family.Id, Subset: subset, Stage: StageMove, Err: err}
+		}
+		for _, path := range paths {
+			manifest.Record(path, entry)
+		}
+	}
+	return nil
+}
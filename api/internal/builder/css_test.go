@@ -0,0 +1,81 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCSSFiles(t *testing.T) {
+	family := FontFamily{
+		Id:   "roboto-flex",
+		Name: "Roboto Flex",
+		Fonts: []FontFamilyFont{
+			{Style: "normal", Weight: 400},
+		},
+		Subsets: []string{"cyrillic", "latin", "menu"},
+		Axes: []FontFamilyAxis{
+			{Tag: "slnt", MinValue: -10, MaxValue: 0},
+			{Tag: "wdth", MinValue: 25, MaxValue: 151},
+			{Tag: "wght", MinValue: 100, MaxValue: 1000},
+		},
+	}
+	outputDir := t.TempDir()
+	err := GenerateCSSFiles(family, []string{"latin", "cyrillic"}, []string{FormatWOFF2, FormatWOFF, FormatTTF}, "https://font.delivery/api/v2", nil, outputDir, nil)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "roboto-flex.latin.css"))
+	require.NoError(t, err)
+	assert.Equal(t, `/* latin */
+@font-face {
+  font-family: 'Roboto Flex';
+  font-style: oblique 0deg 10deg;
+  font-weight: 100 1000;
+  font-stretch: 25% 151%;
+  src: url('https://font.delivery/api/v2/fonts/roboto-flex_latin_100-1000_normal_slntm10-0.wdth25-151.woff2') format('woff2'), url('https://font.delivery/api/v2/fonts/roboto-flex_latin_100-1000_normal_slntm10-0.wdth25-151.woff') format('woff');
+  unicode-range: `+subsetting.BuildCSSString("latin")+`;
+}
+`, string(data))
+
+	data, err = os.ReadFile(filepath.Join(outputDir, "roboto-flex.css"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "@font-face"))
+	assert.Regexp(t, `^/\* latin \*/\n(?s:.*)\n/\* cyrillic \*/\n`, string(data))
+
+	assert.NoFileExists(t, filepath.Join(outputDir, "roboto-flex.menu.css"))
+
+	// Sharded subsets get one rule per shard
+	family.Subsets = append(family.Subsets, "korean")
+	require.NoError(t, GenerateCSSFiles(family, []string{"korean"}, []string{FormatWOFF2}, "https://font.delivery/api/v2", nil, outputDir, nil))
+	data, err = os.ReadFile(filepath.Join(outputDir, "roboto-flex.korean.css"))
+	require.NoError(t, err)
+	assert.Equal(t, 100, strings.Count(string(data), "@font-face"))
+	assert.Contains(t, string(data), "/* korean-99 */\n")
+	assert.Contains(t, string(data), "roboto-flex_korean-7_100-1000_normal_slntm10-0.wdth25-151.woff2")
+	assert.Contains(t, string(data), "unicode-range: "+subsetting.BuildCSSString("korean-7")+";")
+}
+
+func TestGenerateCSSFilesInstances(t *testing.T) {
+	family := FontFamily{
+		Id:      "inter",
+		Name:    "Inter",
+		Fonts:   []FontFamilyFont{{Style: "normal", Weight: 400, Instances: []int{400, 700}}},
+		Subsets: []string{"latin"},
+		Axes:    []FontFamilyAxis{{Tag: "wght", MinValue: 100, MaxValue: 900}},
+	}
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateCSSFiles(family, []string{"latin"}, []string{FormatWOFF2}, "https://font.delivery/api/v2", nil, outputDir, nil))
+	data, err := os.ReadFile(filepath.Join(outputDir, "inter.css"))
+	require.NoError(t, err)
+
+	// The static instances follow the variable font
+	assert.Equal(t, 3, strings.Count(string(data), "@font-face"))
+	assert.Regexp(t, `(?s)font-weight: 100 900;\n  src: url\('https://font.delivery/api/v2/fonts/inter_latin_100-900_normal.woff2'\)`+
+		`.*font-weight: 400;\n  src: url\('https://font.delivery/api/v2/fonts/inter_latin_400_normal.woff2'\)`+
+		`.*font-weight: 700;\n  src: url\('https://font.delivery/api/v2/fonts/inter_latin_700_normal.woff2'\)`, string(data))
+}
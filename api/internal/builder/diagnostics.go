@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Stage is the stage of the build that an error occurred in.
+type Stage string
+
+const (
+	// StageParse is reading the metadata and source files of a family
+	StageParse Stage = "parse"
+	// StageLicense is finding and generating the license of a family
+	StageLicense Stage = "license"
+	// StageInstance is instantiating a static weight of a variable font
+	StageInstance Stage = "instance"
+	// StageSubset is subsetting a font
+	StageSubset Stage = "subset"
+	// StageCompress is compressing a font to WOFF2
+	StageCompress Stage = "compress"
+	// StageZip is bundling the generated files of a family in a zip file
+	StageZip Stage = "zip"
+	// StageMove is writing a generated file to the output directory
+	StageMove Stage = "move"
+)
+
+// BuildError is an error tagged with where in the build it occurred. Font and
+// Subset are empty for errors that don't concern a single font or subset.
+type BuildError struct {
+	// Family is the id of the family, or the name of the directory of its
+	// METADATA.pb file if the file can't be parsed and has no name field
+	Family string `json:"family"`
+	Font   string `json:"font,omitempty"`
+	Subset string `json:"subset,omitempty"`
+	Stage  Stage  `json:"stage"`
+	Err    error  `json:"-"`
+}
+
+func (e *BuildError) Error() string {
+	var location []string
+	for _, s := range []string{e.Family, e.Font, e.Subset} {
+		if s != "" {
+			location = append(location, s)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %v", strings.Join(location, "/"), e.Stage, e.Err)
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON includes the message of the underlying error, which is empty if
+// there is none.
+func (e *BuildError) MarshalJSON() ([]byte, error) {
+	type buildError BuildError
+	var message string
+	if e.Err != nil {
+		message = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		*buildError
+		Message string `json:"message"`
+	}{(*buildError)(e), message})
+}
+
+// Report collects the errors of a build. It is safe for concurrent use.
+type Report struct {
+	mu     sync.Mutex
+	Errors []*BuildError `json:"errors"`
+}
+
+// NewReport returns an empty report.
+func NewReport() *Report {
+	return &Report{Errors: []*BuildError{}}
+}
+
+// Add records err. Joined errors are recorded one by one and errors that are
+// not build errors are recorded without family and stage.
+func (r *Report) Add(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			r.Add(err)
+		}
+		return
+	}
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		buildErr = &BuildError{Err: err}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Errors = append(r.Errors, buildErr)
+}
+
+// Failed reports whether the build of a family failed.
+func (r *Report) Failed(family string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, err := range r.Errors {
+		if err.Family == family {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of recorded errors.
+func (r *Report) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Errors)
+}
+
+// Save writes the report to path.
+func (r *Report) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
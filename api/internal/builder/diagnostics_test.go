@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+	report.Add(errors.Join(
+		&BuildError{Family: "inter", Font: "Inter[wght].ttf", Subset: "latin", Stage: StageSubset, Err: errors.New("no glyphs")},
+		&BuildError{Family: "prata", Stage: StageLicense, Err: errors.New("no license text available")},
+	))
+	assert.Equal(t, 2, report.Len())
+	assert.True(t, report.Failed("inter"))
+	assert.True(t, report.Failed("prata"))
+	assert.False(t, report.Failed("atma"))
+	assert.EqualError(t, report.Errors[0], "inter/Inter[wght].ttf/latin: subset: no glyphs")
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"errors": [
+		{"family": "inter", "font": "Inter[wght].ttf", "subset": "latin", "stage": "subset", "message": "no glyphs"},
+		{"family": "prata", "stage": "license", "message": "no license text available"}
+	]}`, string(data))
+
+	data, err = json.Marshal(&BuildError{Family: "atma", Stage: StageParse})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"family": "atma", "stage": "parse", "message": ""}`, string(data))
+}
+
+func TestGenerateWOFF2FilesCollectsErrors(t *testing.T) {
+	dir := t.TempDir()
+	family := FontFamily{
+		Id:      "prata",
+		Fonts:   []FontFamilyFont{{Filename: "Prata-Regular.ttf"}, {Filename: "Prata-Italic.ttf"}},
+		Subsets: []string{"latin"},
+		Dir:     dir,
+	}
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.json"), nil)
+	require.NoError(t, err)
+	err = GenerateFontFiles(family, []string{"latin"}, []string{FormatWOFF2}, dir, dir, false, manifest)
+
+	report := NewReport()
+	report.Add(err)
+	require.Equal(t, 2, report.Len())
+	assert.Equal(t, "Prata-Regular.ttf", report.Errors[0].Font)
+	assert.Equal(t, "Prata-Italic.ttf", report.Errors[1].Font)
+	assert.Equal(t, StageParse, report.Errors[1].Stage)
+}
@@ -0,0 +1,122 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FontFamilyFallback is a local font that stands in for a family while its
+// fonts load. The metric overrides make the local font take up the same space
+// as the fonts of the family, which avoids layout shift when they are swapped.
+type FontFamilyFallback struct {
+	// LocalSrc are the names of the local fonts in order of preference
+	LocalSrc []string `json:"local_src"`
+	// SizeAdjust and AscentOverride are percentages, or 0 if not set
+	SizeAdjust     float32 `json:"size_adjust"`
+	AscentOverride float32 `json:"ascent_override"`
+	// DescentOverride and LineGapOverride are percentages, or nil if not set
+	// since 0% is a meaningful override for them
+	DescentOverride *float32 `json:"descent_override,omitempty"`
+	LineGapOverride *float32 `json:"line_gap_override,omitempty"`
+	// Weight is the weight the fallback applies to, formatted as a range for
+	// ranges of weights, or an empty string if it applies to all weights
+	Weight string `json:"weight,omitempty"`
+}
+
+// genericFamilies are the generic CSS font families that end the font-family
+// stacks of families, keyed by category.
+var genericFamilies = map[string]string{
+	"SANS_SERIF":  "sans-serif",
+	"SERIF":       "serif",
+	"MONOSPACE":   "monospace",
+	"HANDWRITING": "cursive",
+}
+
+// getFallbacks converts the fallbacks of the metadata of a family. Fallbacks
+// without local fonts and fallbacks for regions of other axes than wght can't
+// be expressed in CSS and are left out.
+func getFallbacks(fallbackProtos []*FamilyFallbackProto) []FontFamilyFallback {
+	var fallbacks []FontFamilyFallback
+	for _, fallbackProto := range fallbackProtos {
+		if len(fallbackProto.GetLocalSrc()) == 0 {
+			continue
+		}
+		fallback := FontFamilyFallback{
+			LocalSrc:       fallbackProto.GetLocalSrc(),
+			SizeAdjust:     fallbackProto.GetSizeAdjustPct(),
+			AscentOverride: fallbackProto.GetAscentOverridePct(),
+		}
+		supported := true
+		for _, axisTarget := range fallbackProto.GetAxisTarget() {
+			if axisTarget.GetTag() != "wght" {
+				supported = false
+				break
+			}
+			fallback.Weight = fmt.Sprintf("%v", axisTarget.GetMinValue())
+			if axisTarget.GetMaxValue() != axisTarget.GetMinValue() {
+				fallback.Weight += fmt.Sprintf("-%v", axisTarget.GetMaxValue())
+			}
+		}
+		if supported {
+			fallbacks = append(fallbacks, fallback)
+		}
+	}
+	return fallbacks
+}
+
+// getFallbackFamilyName returns the name of the companion family of the
+// fallbacks of a family, e.g. "Inter Fallback".
+func getFallbackFamilyName(family FontFamily) string {
+	return family.Name + " Fallback"
+}
+
+// getFontFamilyStack returns the value of the CSS font-family property that
+// uses a family, followed by its fallbacks and the generic family of its
+// category, e.g. "'Inter', 'Inter Fallback', sans-serif".
+func getFontFamilyStack(family FontFamily) string {
+	stack := []string{fmt.Sprintf("'%s'", family.Name)}
+	if len(family.Fallbacks) > 0 {
+		stack = append(stack, fmt.Sprintf("'%s'", getFallbackFamilyName(family)))
+	}
+	for _, category := range family.Category {
+		if generic, found := genericFamilies[category]; found {
+			stack = append(stack, generic)
+			break
+		}
+	}
+	return strings.Join(stack, ", ")
+}
+
+// fallbackCSS generates the @font-face rules of the companion family of the
+// fallbacks of a family, one rule per fallback.
+func fallbackCSS(family FontFamily) []string {
+	var rules []string
+	for _, fallback := range family.Fallbacks {
+		var rule strings.Builder
+		rule.WriteString("/* fallback */\n@font-face {\n")
+		fmt.Fprintf(&rule, "  font-family: '%s';\n", getFallbackFamilyName(family))
+		if fallback.Weight != "" {
+			fmt.Fprintf(&rule, "  font-weight: %s;\n", strings.Replace(fallback.Weight, "-", " ", 1))
+		}
+		var sources []string
+		for _, name := range fallback.LocalSrc {
+			sources = append(sources, fmt.Sprintf("local('%s')", name))
+		}
+		fmt.Fprintf(&rule, "  src: %s;\n", strings.Join(sources, ", "))
+		if fallback.SizeAdjust != 0 {
+			fmt.Fprintf(&rule, "  size-adjust: %v%%;\n", fallback.SizeAdjust)
+		}
+		if fallback.AscentOverride != 0 {
+			fmt.Fprintf(&rule, "  ascent-override: %v%%;\n", fallback.AscentOverride)
+		}
+		if fallback.DescentOverride != nil {
+			fmt.Fprintf(&rule, "  descent-override: %v%%;\n", *fallback.DescentOverride)
+		}
+		if fallback.LineGapOverride != nil {
+			fmt.Fprintf(&rule, "  line-gap-override: %v%%;\n", *fallback.LineGapOverride)
+		}
+		rule.WriteString("}\n")
+		rules = append(rules, rule.String())
+	}
+	return rules
+}
@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetFallbacks(t *testing.T) {
+	fallbacks := getFallbacks([]*FamilyFallbackProto{
+		{
+			SizeAdjustPct:     proto.Float32(107.4),
+			AscentOverridePct: proto.Float32(90),
+			LocalSrc:          []string{"Arial", "Helvetica"},
+		},
+		{
+			AxisTarget:    []*AxisSegmentProto{{Tag: proto.String("wght"), MinValue: proto.Float32(700), MaxValue: proto.Float32(900)}},
+			SizeAdjustPct: proto.Float32(112),
+			LocalSrc:      []string{"Arial Bold"},
+		},
+		// Fallbacks without local fonts or for other axes are left out
+		{SizeAdjustPct: proto.Float32(100)},
+		{
+			AxisTarget: []*AxisSegmentProto{{Tag: proto.String("wdth"), MinValue: proto.Float32(75), MaxValue: proto.Float32(75)}},
+			LocalSrc:   []string{"Arial Narrow"},
+		},
+	})
+	assert.Equal(t, []FontFamilyFallback{
+		{LocalSrc: []string{"Arial", "Helvetica"}, SizeAdjust: 107.4, AscentOverride: 90},
+		{LocalSrc: []string{"Arial Bold"}, SizeAdjust: 112, Weight: "700-900"},
+	}, fallbacks)
+}
+
+func TestFallbackCSS(t *testing.T) {
+	family := FontFamily{
+		Id:       "inter",
+		Name:     "Inter",
+		Category: []string{"DISPLAY", "SANS_SERIF"},
+		Fonts:    []FontFamilyFont{{Style: "normal", Weight: 400}},
+		Subsets:  []string{"latin"},
+		Fallbacks: []FontFamilyFallback{
+			{LocalSrc: []string{"Arial", "Helvetica"}, SizeAdjust: 107.4, AscentOverride: 90},
+			{LocalSrc: []string{"Arial Bold"}, SizeAdjust: 112, Weight: "700-900"},
+		},
+	}
+	assert.Equal(t, "'Inter', 'Inter Fallback', sans-serif", getFontFamilyStack(family))
+
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateCSSFiles(family, []string{"latin"}, []string{FormatWOFF2}, "https://font.delivery/api/v2", nil, outputDir, nil))
+	data, err := os.ReadFile(filepath.Join(outputDir, "inter.latin.css"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `
+/* fallback */
+@font-face {
+  font-family: 'Inter Fallback';
+  src: local('Arial'), local('Helvetica');
+  size-adjust: 107.4%;
+  ascent-override: 90%;
+}
+
+/* fallback */
+@font-face {
+  font-family: 'Inter Fallback';
+  font-weight: 700 900;
+  src: local('Arial Bold');
+  size-adjust: 112%;
+}
+`)
+
+	// A line gap override of 0% is kept
+	descentOverride, lineGapOverride := float32(20), float32(0)
+	family.Fallbacks = []FontFamilyFallback{{LocalSrc: []string{"Arial"}, SizeAdjust: 99, AscentOverride: 80, DescentOverride: &descentOverride, LineGapOverride: &lineGapOverride}}
+	assert.Equal(t, []string{`/* fallback */
+@font-face {
+  font-family: 'Inter Fallback';
+  src: local('Arial');
+  size-adjust: 99%;
+  ascent-override: 80%;
+  descent-override: 20%;
+  line-gap-override: 0%;
+}
+`}, fallbackCSS(family))
+
+	// Families without fallbacks leave them out of the stack, and display
+	// families have no generic family
+	family.Fallbacks = nil
+	family.Category = []string{"DISPLAY"}
+	assert.Equal(t, "'Inter'", getFontFamilyStack(family))
+}
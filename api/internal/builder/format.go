@@ -0,0 +1,31 @@
+package builder
+
+import (
+	"github.com/lyxell/font.delivery/api/internal/woff"
+	"github.com/lyxell/font.delivery/api/internal/woff2"
+)
+
+// Font formats that fonts can be generated in. WOFF2 is always generated.
+const (
+	FormatWOFF2 = "woff2"
+	FormatWOFF  = "woff"
+	// FormatTTF is the subsetted font as it is, for desktop and native apps
+	FormatTTF = "ttf"
+)
+
+// encoders convert subsetted fonts to the font formats, keyed by format.
+var encoders = map[string]func([]byte) ([]byte, error){
+	FormatWOFF2: woff2.Encode,
+	FormatWOFF:  woff.Encode,
+	FormatTTF: func(data []byte) ([]byte, error) {
+		return data, nil
+	},
+}
+
+// cssFormats are the format() hints of the formats that are listed in
+// stylesheets, keyed by format. Formats without a hint are not meant for
+// browsers.
+var cssFormats = map[string]string{
+	FormatWOFF2: "woff2",
+	FormatWOFF:  "woff",
+}
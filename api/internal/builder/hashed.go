@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hashLength is the number of hex digits of the content hash in the names of
+// content-addressed files.
+const hashLength = 16
+
+// hashPlaceholder stands in for the content hash in the names of
+// content-addressed files in build plans, since the hash is only known once
+// the file is generated.
+const hashPlaceholder = "{hash}"
+
+// FileHash is the content hash and size of a font file.
+type FileHash struct {
+	// Hash is the SHA-256 hash of the file
+	Hash string
+	Size int64
+}
+
+// FileHashes are the hashes of the font files of a family, keyed by file
+// name. A nil FileHashes is valid and refers to the files by their stable
+// names.
+type FileHashes map[string]FileHash
+
+// fileName returns the content-addressed name of a font file, e.g.
+// roboto_latin_400_normal.0123456789abcdef.woff2, or the name itself if the
+// file has no hash.
+func (h FileHashes) fileName(name string) string {
+	hash, found := h[name]
+	if !found {
+		return name
+	}
+	return hashedFileName(name, hash.Hash[:hashLength])
+}
+
+// hashedFileName inserts a hash into a file name before its extension.
+func hashedFileName(name string, hash string) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// GenerateHashedFontFiles writes a content-addressed copy of each font file of
+// a family next to the file with the stable name, and returns the hashes of
+// the files. Since the content of a content-addressed file never changes it
+// can be cached indefinitely. Previous versions of the files are kept for
+// pages that still refer to them, until the family is removed.
+func GenerateHashedFontFiles(family FontFamily, subsets []string, formats []string, fontOutputDir string, manifest *Manifest) (FileHashes, error) {
+	hashes := make(FileHashes)
+	familySubsets := intersection(subsets, family.Subsets)
+	for _, font := range family.Fonts {
+		var names []string
+		for _, subset := range shards(family, familySubsets) {
+			for _, format := range formats {
+				names = append(names, getFontFileName(family, font, subset, format))
+				for _, weight := range font.Instances {
+					names = append(names, getInstanceFileName(family, font, weight, subset, format))
+				}
+			}
+		}
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(fontOutputDir, name))
+			if err != nil {
+				return nil, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageMove, Err: err}
+			}
+			hashes[name] = FileHash{Hash: hashBytes(data), Size: int64(len(data))}
+			outputPath := filepath.Join(fontOutputDir, hashes.fileName(name))
+			_, err = os.Stat(outputPath)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = os.WriteFile(outputPath, data, 0o644)
+			}
+			if err != nil {
+				return nil, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageMove, Err: err}
+			}
+			manifest.Record(outputPath, manifest.entry(family.Id, hashes[name].Hash, ""))
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateHashedFontFiles(t *testing.T) {
+	family := FontFamily{
+		Id:      "inter",
+		Name:    "Inter",
+		Fonts:   []FontFamilyFont{{Filename: "Inter[wght].ttf", Style: "normal", Weight: 400, Instances: []int{700}}},
+		Axes:    []FontFamilyAxis{{Tag: "wght", MinValue: 100, MaxValue: 900}},
+		Subsets: []string{"latin"},
+	}
+	fontsDir := t.TempDir()
+	files := map[string]string{
+		"inter_latin_100-900_normal.woff2": "variable",
+		"inter_latin_700_normal.woff2":     "instance",
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(fontsDir, name), []byte(data), 0o644))
+	}
+	manifest, err := LoadManifest(filepath.Join(t.TempDir(), "manifest.json"), nil)
+	require.NoError(t, err)
+
+	hashes, err := GenerateHashedFontFiles(family, []string{"latin"}, []string{FormatWOFF2}, fontsDir, manifest)
+	require.NoError(t, err)
+	require.Len(t, hashes, 2)
+	variable := hashes["inter_latin_100-900_normal.woff2"]
+	assert.Equal(t, hashBytes([]byte("variable")), variable.Hash)
+	assert.Equal(t, int64(len("variable")), variable.Size)
+	hashedName := "inter_latin_100-900_normal." + variable.Hash[:16] + ".woff2"
+	assert.Equal(t, hashedName, hashes.fileName("inter_latin_100-900_normal.woff2"))
+	data, err := os.ReadFile(filepath.Join(fontsDir, hashedName))
+	require.NoError(t, err)
+	assert.Equal(t, "variable", string(data))
+	assert.Contains(t, manifest.Outputs, filepath.Join(fontsDir, hashedName))
+	assert.FileExists(t, filepath.Join(fontsDir, hashes.fileName("inter_latin_700_normal.woff2")))
+
+	// Files without a hash keep their names
+	assert.Equal(t, "inter_latin_400_normal.woff2", FileHashes(nil).fileName("inter_latin_400_normal.woff2"))
+
+	// Stylesheets and the family JSON refer to the hashed files
+	cssDir := t.TempDir()
+	require.NoError(t, GenerateCSSFiles(family, []string{"latin"}, []string{FormatWOFF2}, "https://font.delivery/api/v2", hashes, cssDir, nil))
+	css, err := os.ReadFile(filepath.Join(cssDir, "inter.css"))
+	require.NoError(t, err)
+	assert.Contains(t, string(css), "url('https://font.delivery/api/v2/fonts/"+hashedName+"')")
+
+	require.NoError(t, GenerateFamilyJSONFile(family, []string{"latin"}, []string{FormatWOFF2}, "https://font.delivery/api/v2", hashes, fontsDir, nil))
+	data, err = os.ReadFile(filepath.Join(fontsDir, "inter.json"))
+	require.NoError(t, err)
+	var result struct {
+		Fonts []struct {
+			Files       map[string]string `json:"files"`
+			HashedFiles map[string]struct {
+				URL  string `json:"url"`
+				Hash string `json:"hash"`
+				Size int64  `json:"size"`
+			} `json:"hashed_files"`
+			Instances []struct {
+				HashedFiles map[string]struct {
+					URL string `json:"url"`
+				} `json:"hashed_files"`
+			} `json:"instances"`
+		} `json:"fonts"`
+	}
+	require.NoError(t, json.Unmarshal(data, &result))
+	require.Len(t, result.Fonts, 1)
+	font := result.Fonts[0]
+	assert.Equal(t, "https://font.delivery/api/v2/fonts/inter_latin_100-900_normal.woff2", font.Files["latin"])
+	assert.Equal(t, "https://font.delivery/api/v2/fonts/"+hashedName, font.HashedFiles["latin"].URL)
+	assert.Equal(t, variable.Hash, font.HashedFiles["latin"].Hash)
+	assert.Equal(t, variable.Size, font.HashedFiles["latin"].Size)
+	require.Len(t, font.Instances, 1)
+	assert.Equal(t, "https://font.delivery/api/v2/fonts/"+hashes.fileName("inter_latin_700_normal.woff2"), font.Instances[0].HashedFiles["latin"].URL)
+}
@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/instancer"
+)
+
+// instanceWeights returns the static weights to instantiate from a variable
+// font, in ascending order. If named is set these are the weights of the named
+// instances that only vary in weight, along with the requested weights that
+// are within the weight range of the font.
+func instanceWeights(data []byte, named bool, weights []int) ([]int, error) {
+	axes, instances, err := instancer.Variations(data)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(axes, func(axis instancer.Axis) bool { return axis.Tag == "wght" })
+	if i < 0 {
+		return nil, nil
+	}
+	wght := axes[i]
+	var result []int
+	if named {
+		for _, instance := range instances {
+			if !onlyVariesInWeight(axes, instance) {
+				continue
+			}
+			result = append(result, int(instance.Coordinates["wght"]))
+		}
+	}
+	for _, weight := range weights {
+		if float64(weight) >= wght.Min && float64(weight) <= wght.Max {
+			result = append(result, weight)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// onlyVariesInWeight reports whether all coordinates of an instance except the
+// weight are at the default of their axis.
+func onlyVariesInWeight(axes []instancer.Axis, instance instancer.Instance) bool {
+	for _, axis := range axes {
+		if axis.Tag != "wght" && instance.Coordinates[axis.Tag] != axis.Default {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveInstances sets the static weights that are instantiated from the
+// fonts of the families whose only axis is wght. Families with other axes get
+// no instances, since an instance would pin those axes at their defaults
+// without its file name or @font-face rule saying so. The fonts of a family
+// that can't be read are left without instances and reported as build errors.
+func ResolveInstances(families []FontFamily, named bool, weights []int) error {
+	if !named && len(weights) == 0 {
+		return nil
+	}
+	var errs []error
+	for i := range families {
+		family := &families[i]
+		if len(family.Axes) != 1 || family.Axes[0].Tag != "wght" {
+			continue
+		}
+		for j := range family.Fonts {
+			font := &family.Fonts[j]
+			data, err := os.ReadFile(filepath.Join(family.Dir, font.Filename))
+			if errors.Is(err, fs.ErrNotExist) {
+				// Missing files are reported when the build is planned
+				continue
+			}
+			if err == nil {
+				font.Instances, err = instanceWeights(data, named, weights)
+			}
+			if err != nil {
+				errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageInstance, Err: err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Gets the file name of a static instance of a variable font for a subset and
+// format, e.g. "inter_latin_700_normal.woff2". Only fonts whose only axis is
+// wght have instances, so the name has no axes suffix.
+func getInstanceFileName(family FontFamily, font FontFamilyFont, weight int, subset string, format string) string {
+	return fmt.Sprintf("%s_%s_%d_%s.%s", family.Id, subset, weight, font.Style, format)
+}
+
+// getStaticWeights returns the weights of the static instances of a family in
+// ascending order.
+func getStaticWeights(family FontFamily) []int {
+	weights := []int{}
+	for _, font := range family.Fonts {
+		weights = append(weights, font.Instances...)
+	}
+	slices.Sort(weights)
+	return slices.Compact(weights)
+}
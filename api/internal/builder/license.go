@@ -0,0 +1,156 @@
+package builder
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// LicenseSource describes how the license text of a family was obtained.
+type LicenseSource string
+
+const (
+	// LicenseSourceFile is the license file expected for the license of the
+	// family, e.g. OFL.txt
+	LicenseSourceFile LicenseSource = "file"
+	// LicenseSourceSearch is the license file of another license in the
+	// family directory, e.g. LICENSE.txt for a family licensed under the OFL
+	LicenseSourceSearch LicenseSource = "search"
+	// LicenseSourceNameTable is the copyright and license of the name table
+	// of a font of the family
+	LicenseSourceNameTable LicenseSource = "name-table"
+	// LicenseSourceSynthesized is the canonical license text filled in with
+	// the copyright of the fonts of the family
+	LicenseSourceSynthesized LicenseSource = "synthesized"
+)
+
+//go:embed licenses/*.txt
+var licenseTemplates embed.FS
+
+// readLicense reads the license text of a family, trying the expected license
+// file, the license files of other licenses and the name tables of the fonts in that
+// order. It returns a nil text if no license was found.
+func readLicense(family FontFamily) ([]byte, LicenseSource, error) {
+	text, err := os.ReadFile(filepath.Join(family.Dir, getLicenseFileName(family.License)))
+	if err == nil {
+		return text, LicenseSourceFile, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, "", err
+	}
+
+	entries, err := os.ReadDir(family.Dir)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isLicenseFileName(entry.Name()) {
+			text, err := os.ReadFile(filepath.Join(family.Dir, entry.Name()))
+			if err != nil {
+				return nil, "", err
+			}
+			return text, LicenseSourceSearch, nil
+		}
+	}
+
+	for _, font := range family.Fonts {
+		text, err := readNameTableLicense(filepath.Join(family.Dir, font.Filename))
+		if err != nil {
+			return nil, "", err
+		}
+		if text != nil {
+			return text, LicenseSourceNameTable, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// licenseFileNames are the names of the license files of the families of the
+// Google Fonts repository. Other files such as OFL-FAQ.txt are not licenses.
+var licenseFileNames = []string{"OFL.txt", "LICENSE.txt", "LICENCE.txt", "UFL.txt"}
+
+// isLicenseFileName reports whether a file name is the name of a license file.
+func isLicenseFileName(name string) bool {
+	return slices.Contains(licenseFileNames, name)
+}
+
+// readNameTableLicense builds a license text from the copyright notice,
+// license description and license URL of the name table of a font. It returns
+// a nil text if the font doesn't exist or has no license description.
+func readNameTableLicense(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	description := font.Name(sfnt.NameLicense)
+	if description == "" {
+		return nil, nil
+	}
+	var parts []string
+	for _, part := range []string{font.Name(sfnt.NameCopyright), description, font.Name(sfnt.NameLicenseURL)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return []byte(strings.Join(parts, "\n\n") + "\n"), nil
+}
+
+// completeLicense returns the license text of a family. Licenses from the
+// name table only refer to the OFL, so its canonical text is appended to them,
+// and families without a license text get a synthesized license.
+func completeLicense(family FontFamily) ([]byte, error) {
+	if family.LicenseText == nil {
+		return synthesizeLicense(family)
+	}
+	if family.LicenseSource != LicenseSourceNameTable || !strings.HasPrefix(family.SPDXLicense, "OFL-") {
+		return family.LicenseText, nil
+	}
+	template, err := licenseTemplates.ReadFile("licenses/" + family.SPDXLicense + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("no license text available for %s (%s)", family.Name, family.SPDXLicense)
+	}
+	// The notice that the template starts with is part of the name table
+	license := strings.TrimLeft(string(template), "\n")
+	if notice, rest, found := strings.Cut(license, "\n\n"); found && strings.HasPrefix(notice, "This Font Software is licensed") {
+		license = rest
+	}
+	return append(slices.Clip(family.LicenseText), "\n"+license...), nil
+}
+
+// synthesizeLicense fills in the canonical text of the license of a family
+// with the copyright notices of its fonts.
+func synthesizeLicense(family FontFamily) ([]byte, error) {
+	template, err := licenseTemplates.ReadFile("licenses/" + family.SPDXLicense + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("no license text available for %s (%s)", family.Name, family.SPDXLicense)
+	}
+	var copyrights []string
+	for _, font := range family.Fonts {
+		if font.Copyright != "" && !slices.Contains(copyrights, font.Copyright) {
+			copyrights = append(copyrights, font.Copyright)
+		}
+	}
+	var text strings.Builder
+	for _, copyright := range copyrights {
+		text.WriteString(copyright + "\n")
+	}
+	if len(copyrights) > 0 {
+		text.WriteString("\n")
+	}
+	text.WriteString(strings.TrimLeft(string(template), "\n"))
+	return []byte(text.String()), nil
+}
@@ -0,0 +1,155 @@
+package builder
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// writeFontWithNames writes Go Regular with the given English names to path.
+func writeFontWithNames(t *testing.T, path string, names map[uint16]string) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	var records []sfnt.NameRecord
+	for nameID, name := range names {
+		var value []byte
+		for _, c := range utf16.Encode([]rune(name)) {
+			value = binary.BigEndian.AppendUint16(value, c)
+		}
+		records = append(records, sfnt.NameRecord{
+			PlatformID: sfnt.PlatformWindows,
+			EncodingID: 1,
+			LanguageID: sfnt.LanguageWindowsEnglish,
+			NameID:     nameID,
+			Value:      value,
+		})
+	}
+	font.Tables["name"] = sfnt.BuildName(records)
+	require.NoError(t, os.WriteFile(path, font.Bytes(), 0o644))
+}
+
+func TestReadLicense(t *testing.T) {
+	dir := t.TempDir()
+	family := FontFamily{
+		Name:    "Atma",
+		License: "OFL",
+		Fonts:   []FontFamilyFont{{Filename: "Atma-Regular.ttf"}},
+		Dir:     dir,
+	}
+	writeFontWithNames(t, filepath.Join(dir, "Atma-Regular.ttf"), map[uint16]string{
+		sfnt.NameCopyright:  "Copyright 2015 The Atma Project Authors",
+		sfnt.NameLicense:    "This Font Software is licensed under the SIL Open Font License, Version 1.1.",
+		sfnt.NameLicenseURL: "https://openfontlicense.org",
+	})
+
+	// Name table
+	text, source, err := readLicense(family)
+	require.NoError(t, err)
+	assert.Equal(t, LicenseSourceNameTable, source)
+	assert.Equal(t, "Copyright 2015 The Atma Project Authors\n\nThis Font Software is licensed under the SIL Open Font License, Version 1.1.\n\nhttps://openfontlicense.org\n", string(text))
+	assert.Equal(t, "OFL-1.1", getLicenseSPDXIdentifier(family.License, string(text)))
+
+	// License file of another license
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "OFL-FAQ.txt"), []byte("faq"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LICENSE.txt"), []byte("license"), 0o644))
+	text, source, err = readLicense(family)
+	require.NoError(t, err)
+	assert.Equal(t, LicenseSourceSearch, source)
+	assert.Equal(t, "license", string(text))
+
+	// Expected license file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "OFL.txt"), []byte("ofl"), 0o644))
+	text, source, err = readLicense(family)
+	require.NoError(t, err)
+	assert.Equal(t, LicenseSourceFile, source)
+	assert.Equal(t, "ofl", string(text))
+}
+
+func TestReadLicenseNotFound(t *testing.T) {
+	dir := t.TempDir()
+	family := FontFamily{
+		License: "OFL",
+		Fonts:   []FontFamilyFont{{Filename: "Prata-Regular.ttf"}},
+		Dir:     dir,
+	}
+	writeFontWithNames(t, filepath.Join(dir, "Prata-Regular.ttf"), map[uint16]string{
+		sfnt.NameCopyright: "Copyright 2011 Cyreal",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "DESCRIPTION.en_us.html"), []byte("<p>Prata</p>"), 0o644))
+	text, _, err := readLicense(family)
+	require.NoError(t, err)
+	assert.Nil(t, text)
+}
+
+func TestCompleteLicense(t *testing.T) {
+	notice := "Copyright 2015 The Atma Project Authors\n\nThis Font Software is licensed under the SIL Open Font License, Version 1.1.\n"
+	family := FontFamily{
+		Name:          "Atma",
+		SPDXLicense:   "OFL-1.1",
+		LicenseSource: LicenseSourceNameTable,
+		LicenseText:   []byte(notice),
+	}
+
+	// Licenses from the name table are followed by the text of the OFL
+	text, err := completeLicense(family)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(text), notice+"\n---"), string(text))
+	assert.Contains(t, string(text), "SIL OPEN FONT LICENSE Version 1.1 - 26 February 2007")
+	assert.Equal(t, 1, strings.Count(string(text), "This Font Software is licensed"))
+	assert.Equal(t, notice, string(family.LicenseText))
+
+	// License files are used as they are
+	family.LicenseSource = LicenseSourceFile
+	text, err = completeLicense(family)
+	require.NoError(t, err)
+	assert.Equal(t, notice, string(text))
+
+	// Families without a license text get a synthesized license
+	family.LicenseText = nil
+	family.Fonts = []FontFamilyFont{{Copyright: "Copyright 2015 The Atma Project Authors"}}
+	text, err = completeLicense(family)
+	require.NoError(t, err)
+	assert.Regexp(t, `^Copyright 2015 The Atma Project Authors\n\nThis Font Software`, string(text))
+}
+
+func TestIsLicenseFileName(t *testing.T) {
+	for _, name := range []string{"OFL.txt", "LICENSE.txt", "LICENCE.txt", "UFL.txt"} {
+		assert.True(t, isLicenseFileName(name), name)
+	}
+	for _, name := range []string{"METADATA.pb", "DESCRIPTION.en_us.html", "Oflo-Regular.ttf", "FONTLOG.txt", "OFL-FAQ.txt", "COPYRIGHT.txt", "LICENSE.md"} {
+		assert.False(t, isLicenseFileName(name), name)
+	}
+}
+
+func TestSynthesizeLicense(t *testing.T) {
+	family := FontFamily{
+		Name:        "Source Serif 4",
+		SPDXLicense: "OFL-1.1",
+		Fonts: []FontFamilyFont{
+			{Copyright: "Copyright 2014 Adobe"},
+			{Copyright: "Copyright 2014 Adobe"},
+			{Copyright: ""},
+		},
+	}
+	text, err := synthesizeLicense(family)
+	require.NoError(t, err)
+	assert.Regexp(t, `^Copyright 2014 Adobe\n\nThis Font Software is licensed under the SIL Open Font License, Version 1.1.\n`, string(text))
+	assert.Equal(t, "OFL-1.1", getLicenseSPDXIdentifier("OFL", string(text)))
+
+	family.SPDXLicense = "Apache-2.0"
+	text, err = synthesizeLicense(family)
+	require.NoError(t, err)
+	assert.Regexp(t, `^Copyright 2014 Adobe\n\n +Apache License\n +Version 2.0, January 2004\n`, string(text))
+
+	family.SPDXLicense = "Ubuntu-font-1.0"
+	_, err = synthesizeLicense(family)
+	assert.Error(t, err)
+}
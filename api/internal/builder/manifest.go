@@ -0,0 +1,192 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ManifestEntry describes the inputs that an output file was generated from.
+type ManifestEntry struct {
+	// Family is the id of the font family the output belongs to
+	Family string `json:"family"`
+	// Source is the SHA-256 hash of the source file, i.e. the .ttf-file or
+	// the license file
+	Source string `json:"source"`
+	// Ranges is the SHA-256 hash of the Unicode ranges used for subsetting
+	Ranges string `json:"ranges,omitempty"`
+	// Tools is the SHA-256 hash of the versions of the tools used
+	Tools string `json:"tools,omitempty"`
+	// LicenseSource is how the text of a license file was obtained
+	LicenseSource LicenseSource `json:"license_source,omitempty"`
+}
+
+// Manifest keeps track of the inputs of every generated output file so that
+// outputs whose inputs are unchanged can be skipped on subsequent runs.
+//
+// A nil *Manifest is valid and treats every output as out of date.
+type Manifest struct {
+	mu        sync.Mutex
+	toolsHash string
+
+	Tools   map[string]string        `json:"tools"`
+	Outputs map[string]ManifestEntry `json:"outputs"`
+}
+
+// LoadManifest reads the manifest at path. A missing file results in an empty
+// manifest. The given tool versions are recorded in the manifest and are
+// compared against the tool versions of existing entries.
+func LoadManifest(path string, tools map[string]string) (*Manifest, error) {
+	m := &Manifest{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+		}
+	}
+	if m.Outputs == nil {
+		m.Outputs = make(map[string]ManifestEntry)
+	}
+	m.Tools = tools
+	toolsJSON, err := json.Marshal(tools)
+	if err != nil {
+		return nil, err
+	}
+	m.toolsHash = hashBytes(toolsJSON)
+	return m, nil
+}
+
+// Save writes the manifest to path.
+func (m *Manifest) Save(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// entry creates a manifest entry for the current tool versions.
+func (m *Manifest) entry(family string, source string, ranges string) ManifestEntry {
+	entry := ManifestEntry{Family: family, Source: source}
+	if ranges != "" {
+		entry.Ranges = hashBytes([]byte(ranges))
+	}
+	if m != nil {
+		entry.Tools = m.toolsHash
+	}
+	return entry
+}
+
+// UpToDate reports whether outputPath exists and was generated from the same
+// inputs as described by entry.
+func (m *Manifest) UpToDate(outputPath string, entry ManifestEntry) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	previous, found := m.Outputs[outputPath]
+	m.mu.Unlock()
+	if !found || previous != entry {
+		return false
+	}
+	_, err := os.Stat(outputPath)
+	return err == nil
+}
+
+// lookup returns the entry of outputPath, if it is in the manifest.
+func (m *Manifest) lookup(outputPath string) (ManifestEntry, bool) {
+	if m == nil {
+		return ManifestEntry{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, found := m.Outputs[outputPath]
+	return entry, found
+}
+
+// Record stores the inputs that outputPath was generated from.
+func (m *Manifest) Record(outputPath string, entry ManifestEntry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Outputs[outputPath] = entry
+}
+
+// Prune deletes all outputs belonging to families whose ids are not in ids
+// and removes them from the manifest.
+func (m *Manifest) Prune(ids []string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for outputPath, entry := range m.Outputs {
+		if slices.Contains(ids, entry.Family) {
+			continue
+		}
+		if err := os.Remove(outputPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		delete(m.Outputs, outputPath)
+	}
+	return nil
+}
+
+// ToolVersions returns the versions of the tools used by the builder.
+//
+// The native subsetter and the WOFF2 encoder are part of the builder so the
+// hash of the builder executable is used as their version.
+func ToolVersions(useHbSubset bool) (map[string]string, error) {
+	versions := make(map[string]string)
+	if useHbSubset {
+		out, err := exec.Command("hb-subset", "--version").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hb-subset version: %w", err)
+		}
+		hbSubsetVersion, _, _ := strings.Cut(string(out), "\n")
+		versions["hb-subset"] = strings.TrimSpace(hbSubsetVersion)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	versions["builder"], err = hashFile(executable)
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
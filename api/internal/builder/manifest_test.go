@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+	outputPath := filepath.Join(dir, "roboto_latin_400_normal.woff2")
+	tools := map[string]string{"hb-subset": "hb-subset (HarfBuzz) 10.0.1"}
+
+	manifest, err := LoadManifest(manifestPath, tools)
+	require.NoError(t, err)
+	entry := manifest.entry("roboto", "abc", "0000-00FF\n")
+	assert.False(t, manifest.UpToDate(outputPath, entry))
+
+	require.NoError(t, os.WriteFile(outputPath, []byte("wOF2"), 0o644))
+	manifest.Record(outputPath, entry)
+	require.NoError(t, manifest.Save(manifestPath))
+
+	manifest, err = LoadManifest(manifestPath, tools)
+	require.NoError(t, err)
+	assert.True(t, manifest.UpToDate(outputPath, manifest.entry("roboto", "abc", "0000-00FF\n")))
+	assert.False(t, manifest.UpToDate(outputPath, manifest.entry("roboto", "def", "0000-00FF\n")))
+	assert.False(t, manifest.UpToDate(outputPath, manifest.entry("roboto", "abc", "0000-017F\n")))
+
+	// Changing the tool versions invalidates all outputs
+	manifest, err = LoadManifest(manifestPath, map[string]string{"hb-subset": "hb-subset (HarfBuzz) 10.1.0"})
+	require.NoError(t, err)
+	assert.False(t, manifest.UpToDate(outputPath, manifest.entry("roboto", "abc", "0000-00FF\n")))
+}
+
+func TestManifestPrune(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.json"), nil)
+	require.NoError(t, err)
+
+	robotoPath := filepath.Join(dir, "roboto_latin_400_normal.woff2")
+	latoPath := filepath.Join(dir, "lato_latin_400_normal.woff2")
+	for _, path := range []string{robotoPath, latoPath} {
+		require.NoError(t, os.WriteFile(path, []byte("wOF2"), 0o644))
+	}
+	manifest.Record(robotoPath, manifest.entry("roboto", "abc", ""))
+	manifest.Record(latoPath, manifest.entry("lato", "def", ""))
+
+	require.NoError(t, manifest.Prune([]string{"roboto"}))
+
+	assert.FileExists(t, robotoPath)
+	assert.NoFileExists(t, latoPath)
+	assert.Contains(t, manifest.Outputs, robotoPath)
+	assert.NotContains(t, manifest.Outputs, latoPath)
+}
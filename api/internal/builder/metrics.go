@@ -0,0 +1,167 @@
+package builder
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// FontMetrics are the metrics of a font in font units, see sfnt.Metrics.
+type FontMetrics struct {
+	UnitsPerEm   int `json:"units_per_em"`
+	Ascender     int `json:"ascender"`
+	Descender    int `json:"descender"`
+	LineGap      int `json:"line_gap"`
+	XHeight      int `json:"x_height"`
+	CapHeight    int `json:"cap_height"`
+	AvgCharWidth int `json:"avg_char_width"`
+}
+
+// systemFont is a font that is installed on most systems, along with the
+// metric-compatible fonts that stand in for it where it isn't. Its metrics are
+// read the same way as those of the fonts of families, see sfnt.Metrics.
+type systemFont struct {
+	localSrc []string
+	metrics  FontMetrics
+}
+
+var (
+	arial = systemFont{
+		localSrc: []string{"Arial", "ArialMT", "Liberation Sans"},
+		metrics:  FontMetrics{UnitsPerEm: 2048, Ascender: 1854, Descender: -434, LineGap: 67, XHeight: 1062, CapHeight: 1467, AvgCharWidth: 904},
+	}
+	timesNewRoman = systemFont{
+		localSrc: []string{"Times New Roman", "TimesNewRomanPSMT", "Liberation Serif"},
+		metrics:  FontMetrics{UnitsPerEm: 2048, Ascender: 1825, Descender: -443, LineGap: 87, XHeight: 916, CapHeight: 1356, AvgCharWidth: 819},
+	}
+	courierNew = systemFont{
+		localSrc: []string{"Courier New", "CourierNewPSMT", "Liberation Mono"},
+		metrics:  FontMetrics{UnitsPerEm: 2048, Ascender: 1705, Descender: -615, LineGap: 0, XHeight: 866, CapHeight: 1170, AvgCharWidth: 1229},
+	}
+)
+
+// systemFonts are the system fonts that fallbacks are computed against, keyed
+// by category. Families of other categories fall back to Arial.
+var systemFonts = map[string]systemFont{
+	"SANS_SERIF": arial,
+	"SERIF":      timesNewRoman,
+	"MONOSPACE":  courierNew,
+}
+
+// readMetrics reads the metrics of a font file.
+func readMetrics(path string) (*FontMetrics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := font.Metrics()
+	if err != nil {
+		return nil, err
+	}
+	return &FontMetrics{
+		UnitsPerEm:   metrics.UnitsPerEm,
+		Ascender:     metrics.Ascender,
+		Descender:    metrics.Descender,
+		LineGap:      metrics.LineGap,
+		XHeight:      metrics.XHeight,
+		CapHeight:    metrics.CapHeight,
+		AvgCharWidth: metrics.AvgCharWidth,
+	}, nil
+}
+
+// percent rounds a ratio to a percentage with two decimals.
+func percent(ratio float64) float32 {
+	return float32(math.Round(ratio*10000) / 100)
+}
+
+// computeFallback computes the fallback of a family from the metrics of its
+// regular font, i.e. the upright font with the weight closest to 400. The
+// system font of the category of the family is scaled to the average
+// character width of the font, and its vertical metrics are overridden with
+// those of the font so that lines of text take up the same space. It returns
+// false if the family has no font with usable metrics.
+func computeFallback(family FontFamily) (FontFamilyFallback, bool) {
+	var regular *FontMetrics
+	distance := math.MaxInt
+	for _, font := range family.Fonts {
+		if font.Metrics == nil || font.Metrics.UnitsPerEm == 0 || font.Metrics.AvgCharWidth <= 0 {
+			continue
+		}
+		fontDistance := abs(font.Weight - 400)
+		if font.Style != "normal" {
+			// Prefer upright fonts of any weight over italic fonts
+			fontDistance += 1000
+		}
+		if fontDistance < distance {
+			regular, distance = font.Metrics, fontDistance
+		}
+	}
+	if regular == nil {
+		return FontFamilyFallback{}, false
+	}
+	system := arial
+	for _, category := range family.Category {
+		if categoryFont, found := systemFonts[category]; found {
+			system = categoryFont
+			break
+		}
+	}
+	unitsPerEm := float64(regular.UnitsPerEm)
+	sizeAdjust := (float64(regular.AvgCharWidth) / unitsPerEm) /
+		(float64(system.metrics.AvgCharWidth) / float64(system.metrics.UnitsPerEm))
+	descentOverride := percent(float64(-regular.Descender) / unitsPerEm / sizeAdjust)
+	lineGapOverride := percent(float64(regular.LineGap) / unitsPerEm / sizeAdjust)
+	return FontFamilyFallback{
+		LocalSrc:        system.localSrc,
+		SizeAdjust:      percent(sizeAdjust),
+		AscentOverride:  percent(float64(regular.Ascender) / unitsPerEm / sizeAdjust),
+		DescentOverride: &descentOverride,
+		LineGapOverride: &lineGapOverride,
+	}, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ResolveMetrics reads the metrics of the fonts of the families, and computes
+// a fallback for the families whose metadata has none. The fonts of a family
+// that can't be read are left without metrics and returned as build errors,
+// which don't keep the family from being built.
+func ResolveMetrics(families []FontFamily) error {
+	var errs []error
+	for i := range families {
+		family := &families[i]
+		for j := range family.Fonts {
+			font := &family.Fonts[j]
+			metrics, err := readMetrics(filepath.Join(family.Dir, font.Filename))
+			if errors.Is(err, fs.ErrNotExist) {
+				// Missing files are reported when the build is planned
+				continue
+			}
+			if err != nil {
+				errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageParse, Err: err})
+				continue
+			}
+			font.Metrics = metrics
+		}
+		if len(family.Fallbacks) > 0 {
+			continue
+		}
+		if fallback, found := computeFallback(*family); found {
+			family.Fallbacks = []FontFamilyFallback{fallback}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestResolveMetrics(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Go-Regular.ttf"), goregular.TTF, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Broken-Regular.ttf"), []byte("not a font"), 0o644))
+	families := []FontFamily{
+		{
+			Id:       "go",
+			Name:     "Go",
+			Category: []string{"SANS_SERIF"},
+			Fonts:    []FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+			Dir:      dir,
+		},
+		{
+			Id:        "go-fallbacks",
+			Name:      "Go Fallbacks",
+			Fonts:     []FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+			Fallbacks: []FontFamilyFallback{{LocalSrc: []string{"Helvetica"}, SizeAdjust: 110}},
+			Dir:       dir,
+		},
+		{
+			Id:    "broken",
+			Name:  "Broken",
+			Fonts: []FontFamilyFont{{Filename: "Broken-Regular.ttf", Style: "normal", Weight: 400}},
+			Dir:   dir,
+		},
+	}
+	err := ResolveMetrics(families)
+	var buildErr *BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, "broken", buildErr.Family)
+	assert.Equal(t, StageParse, buildErr.Stage)
+
+	assert.Equal(t, &FontMetrics{
+		UnitsPerEm:   2048,
+		Ascender:     1935,
+		Descender:    -432,
+		LineGap:      0,
+		XHeight:      1086,
+		CapHeight:    1480,
+		AvgCharWidth: 910,
+	}, families[0].Fonts[0].Metrics)
+	descentOverride, lineGapOverride := float32(20.95), float32(0)
+	assert.Equal(t, []FontFamilyFallback{{
+		LocalSrc:        []string{"Arial", "ArialMT", "Liberation Sans"},
+		SizeAdjust:      100.66,
+		AscentOverride:  93.86,
+		DescentOverride: &descentOverride,
+		LineGapOverride: &lineGapOverride,
+	}}, families[0].Fallbacks)
+
+	// Fallbacks from the metadata are kept
+	assert.Equal(t, []FontFamilyFallback{{LocalSrc: []string{"Helvetica"}, SizeAdjust: 110}}, families[1].Fallbacks)
+
+	assert.Nil(t, families[2].Fonts[0].Metrics)
+	assert.Empty(t, families[2].Fallbacks)
+}
+
+func TestComputeFallback(t *testing.T) {
+	metrics := &FontMetrics{UnitsPerEm: 1000, Ascender: 800, Descender: -200, LineGap: 100, AvgCharWidth: 600}
+	family := FontFamily{
+		Category: []string{"MONOSPACE"},
+		Fonts: []FontFamilyFont{
+			{Style: "italic", Weight: 400, Metrics: &FontMetrics{UnitsPerEm: 1000, AvgCharWidth: 500}},
+			{Style: "normal", Weight: 700, Metrics: &FontMetrics{UnitsPerEm: 1000, AvgCharWidth: 700}},
+			{Style: "normal", Weight: 300, Metrics: metrics},
+		},
+	}
+	fallback, found := computeFallback(family)
+	require.True(t, found)
+	assert.Equal(t, []string{"Courier New", "CourierNewPSMT", "Liberation Mono"}, fallback.LocalSrc)
+	// 600/1000 over 1229/2048
+	assert.Equal(t, float32(99.98), fallback.SizeAdjust)
+	assert.Equal(t, float32(80.01), fallback.AscentOverride)
+	assert.Equal(t, float32(20), *fallback.DescentOverride)
+	assert.Equal(t, float32(10), *fallback.LineGapOverride)
+
+	// Families without metrics get no fallback
+	_, found = computeFallback(FontFamily{Fonts: []FontFamilyFont{{Style: "normal", Weight: 400}}})
+	assert.False(t, found)
+}
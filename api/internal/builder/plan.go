@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// OutputDirs are the directories the builder writes its outputs to.
+type OutputDirs struct {
+	Index    string
+	Fonts    string
+	Licenses string
+	CSS      string
+	Zips     string
+}
+
+// Plan describes the outputs a build generates without generating them. The
+// compact and precompressed variants of JSON and CSS outputs are not listed.
+// Content-addressed font files are listed with {hash} in place of their
+// content hash.
+type Plan struct {
+	// Outputs are the outputs that don't belong to a single family
+	Outputs  []PlanOutput        `json:"outputs"`
+	Families []PlanFamily        `json:"families"`
+	Skipped  []PlanSkippedFamily `json:"skipped"`
+}
+
+// PlanFamily is a family that is built.
+type PlanFamily struct {
+	Id            string        `json:"id"`
+	Name          string        `json:"name"`
+	License       string        `json:"license"`
+	LicenseSource LicenseSource `json:"license_source"`
+	Outputs       []PlanOutput  `json:"outputs"`
+
+	Family FontFamily `json:"-"`
+}
+
+// PlanOutput is a file that is generated. Font and Format are only set for
+// font outputs and Weight only for static instances of variable fonts. Hashed
+// is set for the content-addressed copies of font files.
+type PlanOutput struct {
+	Path   string `json:"path"`
+	Font   string `json:"font,omitempty"`
+	Weight int    `json:"weight,omitempty"`
+	Subset string `json:"subset,omitempty"`
+	Format string `json:"format,omitempty"`
+	Hashed bool   `json:"hashed,omitempty"`
+}
+
+// PlanSkippedFamily is a family that is not built. Err is set if the family
+// is skipped because it can't be built.
+type PlanSkippedFamily struct {
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+
+	Err error `json:"-"`
+}
+
+// NewPlan plans the build of the given families. collectErr is the error of
+// collecting the families, whose build errors are reported as skipped
+// families, as are excluded families with the given reasons, keyed by family
+// id. If hashed is set a content-addressed copy of each font file is planned.
+func NewPlan(families []FontFamily, collectErr error, excluded map[string]string, subsets []string, formats []string, hashed bool, dirs OutputDirs) (*Plan, error) {
+	plan := &Plan{
+		Outputs: []PlanOutput{
+			{Path: filepath.Join(dirs.Index, "subsets.json")},
+			{Path: filepath.Join(dirs.Index, "fonts.json")},
+			{Path: filepath.Join(dirs.Index, "languages.json")},
+		},
+		Families: []PlanFamily{},
+		Skipped:  []PlanSkippedFamily{},
+	}
+	for id, reason := range excluded {
+		plan.Skipped = append(plan.Skipped, PlanSkippedFamily{Id: id, Reason: "excluded: " + reason})
+	}
+	collectErrs := []error{collectErr}
+	if joined, ok := collectErr.(interface{ Unwrap() []error }); ok {
+		collectErrs = joined.Unwrap()
+	}
+	for _, err := range collectErrs {
+		if err == nil {
+			continue
+		}
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) {
+			return nil, fmt.Errorf("failed to collect families: %w", err)
+		}
+		plan.Skipped = append(plan.Skipped, PlanSkippedFamily{
+			Id:     buildErr.Family,
+			Reason: fmt.Sprintf("%s failed: %v", buildErr.Stage, buildErr.Err),
+			Err:    buildErr,
+		})
+	}
+
+	for _, family := range families {
+		familySubsets := intersection(subsets, family.Subsets)
+		if len(familySubsets) == 0 {
+			plan.Skipped = append(plan.Skipped, PlanSkippedFamily{Id: family.Id, Reason: "no renderable subsets"})
+			continue
+		}
+		missing, err := missingFontFile(family)
+		if err != nil {
+			return nil, err
+		}
+		if missing != "" {
+			plan.Skipped = append(plan.Skipped, PlanSkippedFamily{
+				Id:     family.Id,
+				Reason: "missing file " + missing,
+				Err:    &BuildError{Family: family.Id, Font: missing, Stage: StageParse, Err: fs.ErrNotExist},
+			})
+			continue
+		}
+
+		planned := PlanFamily{
+			Id:            family.Id,
+			Name:          family.Name,
+			License:       family.SPDXLicense,
+			LicenseSource: family.LicenseSource,
+			Outputs: []PlanOutput{
+				{Path: filepath.Join(dirs.Licenses, fmt.Sprintf("%s-LICENSE.txt", family.Id))},
+				{Path: filepath.Join(dirs.Fonts, family.Id+".json")},
+			},
+			Family: family,
+		}
+		cssFileNames := getCSSFileNames(family, subsets)
+		planned.Outputs = append(planned.Outputs, PlanOutput{Path: filepath.Join(dirs.CSS, cssFileNames[""])})
+		for _, subset := range familySubsets {
+			planned.Outputs = append(planned.Outputs, PlanOutput{Path: filepath.Join(dirs.CSS, cssFileNames[subset]), Subset: subset})
+		}
+		planned.Outputs = append(planned.Outputs, PlanOutput{Path: filepath.Join(dirs.Zips, family.Id+".zip")})
+		for _, font := range family.Fonts {
+			for _, subset := range shards(family, familySubsets) {
+				for _, format := range formats {
+					planned.Outputs = append(planned.Outputs, PlanOutput{
+						Path:   filepath.Join(dirs.Fonts, getFontFileName(family, font, subset, format)),
+						Font:   font.Filename,
+						Subset: subset,
+						Format: format,
+					})
+				}
+			}
+			for _, weight := range font.Instances {
+				for _, subset := range shards(family, familySubsets) {
+					for _, format := range formats {
+						planned.Outputs = append(planned.Outputs, PlanOutput{
+							Path:   filepath.Join(dirs.Fonts, getInstanceFileName(family, font, weight, subset, format)),
+							Font:   font.Filename,
+							Weight: weight,
+							Subset: subset,
+							Format: format,
+						})
+					}
+				}
+			}
+		}
+		if hashed {
+			for _, output := range planned.Outputs {
+				if output.Font == "" {
+					continue
+				}
+				output.Path = filepath.Join(dirs.Fonts, hashedFileName(filepath.Base(output.Path), hashPlaceholder))
+				output.Hashed = true
+				planned.Outputs = append(planned.Outputs, output)
+			}
+		}
+		plan.Families = append(plan.Families, planned)
+	}
+
+	slices.SortFunc(plan.Skipped, func(a, b PlanSkippedFamily) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return plan, nil
+}
+
+// missingFontFile returns the name of the first font file of a family that
+// doesn't exist, or "" if all exist.
+func missingFontFile(family FontFamily) (string, error) {
+	for _, font := range family.Fonts {
+		_, err := os.Stat(filepath.Join(family.Dir, font.Filename))
+		if errors.Is(err, fs.ErrNotExist) {
+			return font.Filename, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
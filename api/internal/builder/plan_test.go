@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlan(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Inter[wght].ttf"), nil, 0o644))
+	families := []FontFamily{
+		{
+			Id:          "inter",
+			Name:        "Inter",
+			SPDXLicense: "OFL-1.1",
+			Fonts:       []FontFamilyFont{{Filename: "Inter[wght].ttf", Style: "normal", Weight: 400, Instances: []int{700}}},
+			Subsets:     []string{"cyrillic", "latin", "menu"},
+			Axes:        []FontFamilyAxis{{Tag: "wght", MinValue: 100, MaxValue: 900}},
+			Dir:         dir,
+		},
+		{
+			Id:      "material-icons",
+			Fonts:   []FontFamilyFont{{Filename: "MaterialIcons-Regular.ttf"}},
+			Subsets: []string{"menu"},
+			Dir:     dir,
+		},
+		{
+			Id:      "prata",
+			Fonts:   []FontFamilyFont{{Filename: "Prata-Regular.ttf"}},
+			Subsets: []string{"latin"},
+			Dir:     dir,
+		},
+	}
+	dirs := OutputDirs{Index: "out", Fonts: "out/fonts", Licenses: "out/licenses", CSS: "out/css", Zips: "out/zips"}
+	collectErr := errors.Join(
+		&BuildError{Family: "abel", Stage: StageLicense, Err: errors.New("no license text available")},
+		&BuildError{Family: "broken", Stage: StageParse, Err: errors.New("invalid METADATA.pb")},
+	)
+	plan, err := NewPlan(families, collectErr, map[string]string{"atma": "no license"}, []string{"latin", "cyrillic"}, []string{FormatWOFF2, FormatWOFF}, false, dirs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []PlanOutput{{Path: "out/subsets.json"}, {Path: "out/fonts.json"}, {Path: "out/languages.json"}}, plan.Outputs)
+	require.Len(t, plan.Families, 1)
+	assert.Equal(t, "inter", plan.Families[0].Id)
+	assert.Equal(t, []PlanOutput{
+		{Path: "out/licenses/inter-LICENSE.txt"},
+		{Path: "out/fonts/inter.json"},
+		{Path: "out/css/inter.css"},
+		{Path: "out/css/inter.latin.css", Subset: "latin"},
+		{Path: "out/css/inter.cyrillic.css", Subset: "cyrillic"},
+		{Path: "out/zips/inter.zip"},
+		{Path: "out/fonts/inter_latin_100-900_normal.woff2", Font: "Inter[wght].ttf", Subset: "latin", Format: "woff2"},
+		{Path: "out/fonts/inter_latin_100-900_normal.woff", Font: "Inter[wght].ttf", Subset: "latin", Format: "woff"},
+		{Path: "out/fonts/inter_cyrillic_100-900_normal.woff2", Font: "Inter[wght].ttf", Subset: "cyrillic", Format: "woff2"},
+		{Path: "out/fonts/inter_cyrillic_100-900_normal.woff", Font: "Inter[wght].ttf", Subset: "cyrillic", Format: "woff"},
+		{Path: "out/fonts/inter_latin_700_normal.woff2", Font: "Inter[wght].ttf", Weight: 700, Subset: "latin", Format: "woff2"},
+		{Path: "out/fonts/inter_latin_700_normal.woff", Font: "Inter[wght].ttf", Weight: 700, Subset: "latin", Format: "woff"},
+		{Path: "out/fonts/inter_cyrillic_700_normal.woff2", Font: "Inter[wght].ttf", Weight: 700, Subset: "cyrillic", Format: "woff2"},
+		{Path: "out/fonts/inter_cyrillic_700_normal.woff", Font: "Inter[wght].ttf", Weight: 700, Subset: "cyrillic", Format: "woff"},
+	}, plan.Families[0].Outputs)
+	require.Len(t, plan.Skipped, 5)
+	assert.EqualError(t, plan.Skipped[0].Err, "abel: license: no license text available")
+	assert.EqualError(t, plan.Skipped[2].Err, "broken: parse: invalid METADATA.pb")
+	assert.EqualError(t, plan.Skipped[4].Err, "prata/Prata-Regular.ttf: parse: file does not exist")
+	for i := range plan.Skipped {
+		plan.Skipped[i].Err = nil
+	}
+	assert.Equal(t, []PlanSkippedFamily{
+		{Id: "abel", Reason: "license failed: no license text available"},
+		{Id: "atma", Reason: "excluded: no license"},
+		{Id: "broken", Reason: "parse failed: invalid METADATA.pb"},
+		{Id: "material-icons", Reason: "no renderable subsets"},
+		{Id: "prata", Reason: "missing file Prata-Regular.ttf"},
+	}, plan.Skipped)
+
+	// Errors that aren't tied to a family fail the plan
+	_, err = NewPlan(families, errors.New("permission denied"), nil, []string{"latin"}, []string{FormatWOFF2}, false, dirs)
+	assert.Error(t, err)
+}
+
+func TestNewPlanHashed(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Prata-Regular.ttf"), nil, 0o644))
+	families := []FontFamily{{
+		Id:      "prata",
+		Fonts:   []FontFamilyFont{{Filename: "Prata-Regular.ttf", Style: "normal", Weight: 400}},
+		Subsets: []string{"latin"},
+		Dir:     dir,
+	}}
+	dirs := OutputDirs{Index: "out", Fonts: "out/fonts", Licenses: "out/licenses", CSS: "out/css", Zips: "out/zips"}
+	plan, err := NewPlan(families, nil, nil, []string{"latin"}, []string{FormatWOFF2}, true, dirs)
+	require.NoError(t, err)
+	require.Len(t, plan.Families, 1)
+	outputs := plan.Families[0].Outputs
+	assert.Equal(t, []PlanOutput{
+		{Path: "out/fonts/prata_latin_400_normal.woff2", Font: "Prata-Regular.ttf", Subset: "latin", Format: "woff2"},
+		{Path: "out/fonts/prata_latin_400_normal.{hash}.woff2", Font: "Prata-Regular.ttf", Subset: "latin", Format: "woff2", Hashed: true},
+	}, outputs[len(outputs)-2:])
+}
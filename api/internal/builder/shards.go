@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+)
+
+// readCharacters returns the characters that a font has glyphs for.
+func readCharacters(path string) (map[rune]uint16, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return font.CharacterMap()
+}
+
+// ResolveShards sets the empty shards of the families, i.e. the shards of
+// their sharded subsets that none of their fonts have a character of. These
+// are left out of the font files, stylesheets and family JSON, so that a
+// Japanese family that only covers the common kanji doesn't publish files
+// without glyphs for the rare ones. The fonts of a family that can't be read
+// are returned as build errors, and the family keeps all its shards.
+func ResolveShards(families []FontFamily) error {
+	var errs []error
+	for i := range families {
+		family := &families[i]
+		if !slices.ContainsFunc(family.Subsets, subsetting.IsSharded) {
+			continue
+		}
+		characters := make(map[rune]bool)
+		readable := true
+		for _, font := range family.Fonts {
+			cmap, err := readCharacters(filepath.Join(family.Dir, font.Filename))
+			if errors.Is(err, fs.ErrNotExist) {
+				// Missing files are reported when the build is planned
+				readable = false
+				continue
+			}
+			if err != nil {
+				errs = append(errs, &BuildError{Family: family.Id, Font: font.Filename, Stage: StageParse, Err: err})
+				readable = false
+				continue
+			}
+			for r := range cmap {
+				characters[r] = true
+			}
+		}
+		if !readable {
+			continue
+		}
+		for _, subset := range family.Subsets {
+			if !subsetting.IsSharded(subset) {
+				continue
+			}
+			for _, shard := range subsetting.Shards(subset) {
+				empty := !slices.ContainsFunc(subsetting.Runes(shard), func(r rune) bool { return characters[r] })
+				if empty {
+					family.EmptyShards = append(family.EmptyShards, shard)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestResolveShards(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Go-Regular.ttf"), goregular.TTF, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Broken-Regular.ttf"), []byte("not a font"), 0o644))
+	families := []FontFamily{
+		{
+			Id:      "go",
+			Fonts:   []FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+			Subsets: []string{"japanese", "latin"},
+			Dir:     dir,
+		},
+		{
+			Id:      "go-latin",
+			Fonts:   []FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+			Subsets: []string{"latin"},
+			Dir:     dir,
+		},
+		{
+			Id:      "broken",
+			Fonts:   []FontFamilyFont{{Filename: "Broken-Regular.ttf", Style: "normal", Weight: 400}},
+			Subsets: []string{"japanese"},
+			Dir:     dir,
+		},
+	}
+	err := ResolveShards(families)
+	var buildErr *BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, "broken", buildErr.Family)
+	assert.Equal(t, StageParse, buildErr.Stage)
+
+	// Go has no Japanese characters, so none of the shards are published
+	assert.Equal(t, subsetting.Shards("japanese"), families[0].EmptyShards)
+	assert.Equal(t, []string{"latin"}, shards(families[0], []string{"japanese", "latin"}))
+	assert.Nil(t, families[1].EmptyShards)
+	// Families whose fonts can't be read keep all their shards
+	assert.Nil(t, families[2].EmptyShards)
+	assert.Len(t, shards(families[2], []string{"japanese"}), 100)
+}
@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// zipModified is the modification time of all files in a zip bundle, which
+// keeps the bundles reproducible. It is the earliest time a zip file can hold.
+var zipModified = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// familyReadme generates the README of the zip bundle of a family.
+func familyReadme(family FontFamily) []byte {
+	var readme strings.Builder
+	fmt.Fprintf(&readme, "%s\n\n", family.Name)
+	if family.Designer != "" {
+		fmt.Fprintf(&readme, "Designed by %s.\n", family.Designer)
+	}
+	fmt.Fprintf(&readme, "Licensed under %s, see LICENSE.txt.\n\n", family.SPDXLicense)
+	fmt.Fprintf(&readme, "To use the fonts, copy %s.css and the fonts directory to your site and\n", family.Id)
+	readme.WriteString("include the stylesheet in your pages:\n\n")
+	fmt.Fprintf(&readme, "  <link rel=\"stylesheet\" href=\"%s.css\">\n\n", family.Id)
+	readme.WriteString("Then use the family in your CSS:\n\n")
+	fmt.Fprintf(&readme, "  font-family: %s;\n", getFontFamilyStack(family))
+	return []byte(readme.String())
+}
+
+// buildZip builds a zip archive of the given files, keyed by name. The entries
+// are sorted by name and have a fixed modification time.
+func buildZip(files map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: zipModified,
+		}
+		header.SetMode(0o644)
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateZipFile writes a zip bundle of a family for self-hosting, i.e.
+// api/v2/zips/{id}.zip. The bundle contains the WOFF2 files, including the
+// static instances, and the license of the family as generated in dirs, a
+// stylesheet that refers to the bundled fonts and a README. Other formats are
+// left out, since every browser that is still in use supports WOFF2.
+//
+// The bundle is only rebuilt if the manifest entries of the bundled font files
+// or the other files have changed, so that unchanged fonts aren't read again.
+func GenerateZipFile(family FontFamily, subsets []string, dirs OutputDirs, manifest *Manifest) error {
+	familySubsets := intersection(subsets, family.Subsets)
+	if len(familySubsets) == 0 {
+		return nil
+	}
+	files := map[string][]byte{
+		family.Id + ".css": familyCSS(family, familySubsets, []string{FormatWOFF2}, ".", nil),
+		"README.txt":       familyReadme(family),
+	}
+	license, err := os.ReadFile(filepath.Join(dirs.Licenses, fmt.Sprintf("%s-LICENSE.txt", family.Id)))
+	if err != nil {
+		return &BuildError{Family: family.Id, Stage: StageLicense, Err: err}
+	}
+	files["LICENSE.txt"] = license
+
+	// The font files are identified by the inputs they were generated from,
+	// or by their contents if they are not in the manifest
+	inputs := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		fmt.Fprintf(inputs, "%s\n%s\n", name, hashBytes(files[name]))
+	}
+	type fontFile struct {
+		font, subset, name string
+	}
+	var fontFiles []fontFile
+	for _, font := range family.Fonts {
+		for _, subset := range shards(family, familySubsets) {
+			names := []string{getFontFileName(family, font, subset, FormatWOFF2)}
+			for _, weight := range font.Instances {
+				names = append(names, getInstanceFileName(family, font, weight, subset, FormatWOFF2))
+			}
+			for _, name := range names {
+				path := filepath.Join(dirs.Fonts, name)
+				fontFiles = append(fontFiles, fontFile{font.Filename, subset, name})
+				if entry, found := manifest.lookup(path); found {
+					fmt.Fprintf(inputs, "fonts/%s\n%s\n%s\n%s\n", name, entry.Source, entry.Ranges, entry.Tools)
+					continue
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return &BuildError{Family: family.Id, Font: font.Filename, Subset: subset, Stage: StageZip, Err: err}
+				}
+				files["fonts/"+name] = data
+				fmt.Fprintf(inputs, "fonts/%s\n%s\n", name, hashBytes(data))
+			}
+		}
+	}
+	outputPath := filepath.Join(dirs.Zips, family.Id+".zip")
+	entry := manifest.entry(family.Id, hex.EncodeToString(inputs.Sum(nil)), "")
+	if manifest.UpToDate(outputPath, entry) {
+		return nil
+	}
+
+	for _, file := range fontFiles {
+		if _, found := files["fonts/"+file.name]; found {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dirs.Fonts, file.name))
+		if err != nil {
+			return &BuildError{Family: family.Id, Font: file.font, Subset: file.subset, Stage: StageZip, Err: err}
+		}
+		files["fonts/"+file.name] = data
+	}
+	data, err := buildZip(files)
+	if err != nil {
+		return &BuildError{Family: family.Id, Stage: StageZip, Err: err}
+	}
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return &BuildError{Family: family.Id, Stage: StageMove, Err: err}
+	}
+	manifest.Record(outputPath, entry)
+	return nil
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateZipFile(t *testing.T) {
+	dir := t.TempDir()
+	dirs := OutputDirs{Fonts: dir, Licenses: dir, Zips: dir}
+	family := FontFamily{
+		Id:          "prata",
+		Name:        "Prata",
+		Designer:    "Cyreal",
+		SPDXLicense: "OFL-1.1",
+		Fonts:       []FontFamilyFont{{Style: "normal", Weight: 400}},
+		Subsets:     []string{"cyrillic", "latin"},
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prata-LICENSE.txt"), []byte("license"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prata_latin_400_normal.woff2"), []byte("latin"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prata_cyrillic_400_normal.woff2"), []byte("cyrillic"), 0o644))
+	// Only WOFF2 files are bundled
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prata_latin_400_normal.woff"), []byte("woff"), 0o644))
+
+	require.NoError(t, GenerateZipFile(family, []string{"latin", "cyrillic"}, dirs, nil))
+	data, err := os.ReadFile(filepath.Join(dir, "prata.zip"))
+	require.NoError(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+		assert.Equal(t, zipModified, file.Modified.UTC())
+	}
+	assert.Equal(t, []string{
+		"LICENSE.txt",
+		"README.txt",
+		"fonts/prata_cyrillic_400_normal.woff2",
+		"fonts/prata_latin_400_normal.woff2",
+		"prata.css",
+	}, names)
+
+	css, err := reader.Open("prata.css")
+	require.NoError(t, err)
+	cssData, err := io.ReadAll(css)
+	require.NoError(t, err)
+	assert.Contains(t, string(cssData), "src: url('./fonts/prata_latin_400_normal.woff2') format('woff2');")
+
+	// The bundle is reproducible
+	require.NoError(t, os.Remove(filepath.Join(dir, "prata.zip")))
+	require.NoError(t, GenerateZipFile(family, []string{"latin", "cyrillic"}, dirs, nil))
+	again, err := os.ReadFile(filepath.Join(dir, "prata.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+}
+
+func TestGenerateZipFileUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	dirs := OutputDirs{Fonts: dir, Licenses: dir, Zips: dir}
+	family := FontFamily{
+		Id:          "prata",
+		Name:        "Prata",
+		SPDXLicense: "OFL-1.1",
+		Fonts:       []FontFamilyFont{{Style: "normal", Weight: 400}},
+		Subsets:     []string{"latin"},
+	}
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.json"), nil)
+	require.NoError(t, err)
+	fontPath := filepath.Join(dir, "prata_latin_400_normal.woff2")
+	zipPath := filepath.Join(dir, "prata.zip")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prata-LICENSE.txt"), []byte("license"), 0o644))
+	require.NoError(t, os.WriteFile(fontPath, []byte("latin"), 0o644))
+	manifest.Record(fontPath, manifest.entry("prata", "abc", "0000-00FF"))
+	require.NoError(t, GenerateZipFile(family, []string{"latin"}, dirs, manifest))
+	data, err := os.ReadFile(zipPath)
+	require.NoError(t, err)
+
+	// Font files with unchanged inputs are not read again
+	require.NoError(t, os.Remove(fontPath))
+	require.NoError(t, GenerateZipFile(family, []string{"latin"}, dirs, manifest))
+	again, err := os.ReadFile(zipPath)
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	// The bundle is rebuilt when the inputs of a font file change
+	manifest.Record(fontPath, manifest.entry("prata", "def", "0000-00FF"))
+	err = GenerateZipFile(family, []string{"latin"}, dirs, manifest)
+	var buildErr *BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, StageZip, buildErr.Stage)
+	require.NoError(t, os.WriteFile(fontPath, []byte("changed"), 0o644))
+	require.NoError(t, GenerateZipFile(family, []string{"latin"}, dirs, manifest))
+	again, err = os.ReadFile(zipPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, again)
+}
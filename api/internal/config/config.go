@@ -0,0 +1,188 @@
+// Package config loads and validates the build configuration of the builder.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the build configuration of the builder.
+type Config struct {
+	// APIVersion is the version of the API that is generated, e.g. "v2"
+	APIVersion string `yaml:"api_version"`
+	// Parallelism is the number of families that are built concurrently. Zero
+	// means one per CPU.
+	Parallelism int `yaml:"parallelism"`
+	// Subsets are the subsets that fonts are generated for
+	Subsets []string `yaml:"subsets"`
+	// Formats are the font formats that fonts are generated in besides WOFF2
+	Formats []string `yaml:"formats"`
+	// Instances are the static instances generated from variable fonts
+	Instances Instances `yaml:"instances"`
+	// HashedFilenames generates a content-addressed copy of each font file,
+	// which stylesheets refer to, so that the files can be cached
+	// indefinitely
+	HashedFilenames bool `yaml:"hashed_filenames"`
+	// Exclude are the families that are not published
+	Exclude []Exclusion `yaml:"exclude"`
+	// Families are per-family overrides keyed by family id
+	Families map[string]FamilyOverride `yaml:"families"`
+}
+
+// Instances selects the static weights that are instantiated from variable
+// fonts whose only axis is wght.
+type Instances struct {
+	// Named generates the named instances of the fonts that only vary in
+	// weight
+	Named bool `yaml:"named"`
+	// Weights are additional weights that are generated when they are within
+	// the weight range of a font
+	Weights []int `yaml:"weights"`
+}
+
+// Exclusion is a family that is not published.
+type Exclusion struct {
+	Family string `yaml:"family"`
+	Reason string `yaml:"reason"`
+}
+
+// FamilyOverride overrides the metadata of a family.
+type FamilyOverride struct {
+	// Subsets replaces the subsets declared in the metadata of the family
+	Subsets []string `yaml:"subsets"`
+	// License replaces the SPDX identifier detected from the license file
+	License string `yaml:"license"`
+	// Name replaces the display name of the family
+	Name string `yaml:"name"`
+}
+
+// Formats are the font formats that can be generated besides WOFF2.
+var Formats = []string{"woff", "ttf"}
+
+// Licenses are the SPDX identifiers that can be used as license overrides.
+var Licenses = []string{"OFL-1.0", "OFL-1.1", "Apache-2.0", "Ubuntu-font-1.0"}
+
+var apiVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a configuration. Unknown keys are rejected.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the configuration and returns all problems found.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if !apiVersionPattern.MatchString(c.APIVersion) {
+		invalid("api_version: must be of the form v<number>, got %q", c.APIVersion)
+	}
+	if c.Parallelism < 0 {
+		invalid("parallelism: must not be negative, got %d", c.Parallelism)
+	}
+	if len(c.Subsets) == 0 {
+		invalid("subsets: at least one subset is required")
+	}
+	for i, subset := range c.Subsets {
+		if !subsetting.Exists(subset) {
+			invalid("subsets[%d]: unknown subset %q", i, subset)
+		} else if slices.Index(c.Subsets, subset) != i {
+			invalid("subsets[%d]: duplicate subset %q", i, subset)
+		}
+	}
+	for i, format := range c.Formats {
+		if !slices.Contains(Formats, format) {
+			invalid("formats[%d]: unknown format %q, must be one of %v", i, format, Formats)
+		} else if slices.Index(c.Formats, format) != i {
+			invalid("formats[%d]: duplicate format %q", i, format)
+		}
+	}
+	for i, weight := range c.Instances.Weights {
+		if weight < 1 || weight > 1000 {
+			invalid("instances.weights[%d]: weight must be between 1 and 1000, got %d", i, weight)
+		} else if slices.Index(c.Instances.Weights, weight) != i {
+			invalid("instances.weights[%d]: duplicate weight %d", i, weight)
+		}
+	}
+
+	excluded := make(map[string]bool)
+	for i, exclusion := range c.Exclude {
+		switch {
+		case exclusion.Family == "":
+			invalid("exclude[%d]: family is required", i)
+		case excluded[exclusion.Family]:
+			invalid("exclude[%d]: family %q is excluded more than once", i, exclusion.Family)
+		}
+		if exclusion.Reason == "" {
+			invalid("exclude[%d]: reason is required", i)
+		}
+		excluded[exclusion.Family] = true
+	}
+
+	for _, id := range c.familyIds() {
+		override := c.Families[id]
+		if excluded[id] {
+			invalid("families.%s: family is excluded", id)
+		}
+		for i, subset := range override.Subsets {
+			if !subsetting.Exists(subset) {
+				invalid("families.%s.subsets[%d]: unknown subset %q", id, i, subset)
+			}
+		}
+		if override.License != "" && !slices.Contains(Licenses, override.License) {
+			invalid("families.%s.license: unknown license %q, must be one of %v", id, override.License, Licenses)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExcludedFamilies returns the ids of all excluded families.
+func (c *Config) ExcludedFamilies() []string {
+	var ids []string
+	for _, exclusion := range c.Exclude {
+		ids = append(ids, exclusion.Family)
+	}
+	return ids
+}
+
+// familyIds returns the ids of the overridden families in sorted order so
+// that errors are reported deterministically.
+func (c *Config) familyIds() []string {
+	var ids []string
+	for id := range c.Families {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
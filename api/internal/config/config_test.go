@@ -0,0 +1,100 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	c, err := config.Parse([]byte(`
+api_version: v2
+parallelism: 4
+subsets:
+  - latin
+  - cyrillic
+formats: [woff, ttf]
+instances:
+  named: true
+  weights: [400, 700]
+exclude:
+  - family: atma
+    reason: no bundled license
+families:
+  roboto:
+    subsets: [latin]
+    license: Apache-2.0
+    name: Roboto Sans
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &config.Config{
+		APIVersion:  "v2",
+		Parallelism: 4,
+		Subsets:     []string{"latin", "cyrillic"},
+		Formats:     []string{"woff", "ttf"},
+		Instances:   config.Instances{Named: true, Weights: []int{400, 700}},
+		Exclude:     []config.Exclusion{{Family: "atma", Reason: "no bundled license"}},
+		Families: map[string]config.FamilyOverride{
+			"roboto": {Subsets: []string{"latin"}, License: "Apache-2.0", Name: "Roboto Sans"},
+		},
+	}, c)
+	assert.Equal(t, []string{"atma"}, c.ExcludedFamilies())
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected []string
+	}{
+		{
+			config:   "api_version: v2\nsubsets: [latin]\nunknown: true\n",
+			expected: []string{"field unknown not found"},
+		},
+		{
+			config:   "api_version: 2\nparallelism: -1\n",
+			expected: []string{"api_version: must be of the form v<number>", "parallelism: must not be negative", "subsets: at least one subset is required"},
+		},
+		{
+			config:   "api_version: v2\nsubsets: [latin, klingon, latin]\n",
+			expected: []string{`subsets[1]: unknown subset "klingon"`, `subsets[2]: duplicate subset "latin"`},
+		},
+		{
+			config:   "api_version: v2\nsubsets: [latin]\nformats: [woff, woff2, woff]\n",
+			expected: []string{`formats[1]: unknown format "woff2"`, `formats[2]: duplicate format "woff"`},
+		},
+		{
+			config:   "api_version: v2\nsubsets: [latin]\ninstances:\n  weights: [0, 700, 700]\n",
+			expected: []string{"instances.weights[0]: weight must be between 1 and 1000, got 0", "instances.weights[2]: duplicate weight 700"},
+		},
+		{
+			config:   "api_version: v2\nsubsets: [latin]\nexclude:\n  - family: atma\n  - reason: missing family\n",
+			expected: []string{"exclude[0]: reason is required", "exclude[1]: family is required"},
+		},
+		{
+			config: "api_version: v2\nsubsets: [latin]\nexclude:\n  - family: atma\n    reason: no license\nfamilies:\n  atma:\n    name: Atma\n  roboto:\n    subsets: [klingon]\n    license: MIT\n",
+			expected: []string{
+				"families.atma: family is excluded",
+				`families.roboto.subsets[0]: unknown subset "klingon"`,
+				`families.roboto.license: unknown license "MIT"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		_, err := config.Parse([]byte(tt.config))
+		require.Error(t, err)
+		for _, expected := range tt.expected {
+			assert.ErrorContains(t, err, expected)
+		}
+	}
+}
+
+func TestLoadIncludesPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("api_version: v2\n"), 0o644))
+	_, err := config.Load(path)
+	assert.ErrorContains(t, err, path+": subsets: at least one subset is required")
+}
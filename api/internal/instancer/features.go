@@ -0,0 +1,137 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// conditionAxisRange is the format of conditions on the range of an axis,
+// the only format of the conditions of feature variations of OpenType 1.8.
+const conditionAxisRange = 1
+
+// applyFeatureVariations returns a copy of a GSUB or GPOS table without
+// feature variations. The features are replaced by the alternate features of
+// the first feature variation record whose conditions the given normalized
+// coordinates meet, as a shaper would do at that location, so that e.g. the
+// rvrn substitutions of heavy weights are kept in their static instances.
+//
+// The new feature list is placed in front of a copy of the original table, so
+// that the script and lookup lists keep their contents.
+func applyFeatureVariations(table []byte, coords []float64) (out []byte, err error) {
+	// Version 1.1 adds the offset of the feature variations
+	if len(table) < 14 || binary.BigEndian.Uint16(table) != 1 || binary.BigEndian.Uint16(table[2:]) != 1 {
+		return table, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed feature variations: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	substitutions := matchFeatureVariations(table, coords)
+	if substitutions == nil {
+		return downgradeMinorVersion(table, 1, 0), nil
+	}
+
+	featureList := int(binary.BigEndian.Uint16(table[6:]))
+	featureCount := int(binary.BigEndian.Uint16(table[featureList:]))
+	listSize := 2 + 6*featureCount
+	for _, alternate := range substitutions {
+		listSize += 4 + 2*int(binary.BigEndian.Uint16(table[alternate+2:]))
+	}
+	// The original table follows the header and the new feature list
+	start := 10 + listSize
+	list := make([]byte, 2+6*featureCount, listSize)
+	binary.BigEndian.PutUint16(list, uint16(featureCount))
+	for i := 0; i < featureCount; i++ {
+		record := featureList + 2 + 6*i
+		copy(list[2+6*i:], table[record:record+4])
+		feature := featureList + int(binary.BigEndian.Uint16(table[record+4:]))
+		offset := start + feature - 10
+		if alternate, found := substitutions[i]; found {
+			offset = len(list)
+			list = append(list, table[alternate:alternate+4+2*int(binary.BigEndian.Uint16(table[alternate+2:]))]...)
+			// The alternate feature keeps the parameters of the feature
+			params := 0
+			if p := int(binary.BigEndian.Uint16(table[feature:])); p != 0 {
+				params = start + feature + p - (10 + offset)
+			}
+			if params > math.MaxUint16 {
+				return nil, fmt.Errorf("feature variations: feature parameters are out of reach")
+			}
+			binary.BigEndian.PutUint16(list[offset:], uint16(params))
+		}
+		if offset > math.MaxUint16 {
+			return nil, fmt.Errorf("feature variations: feature list is out of reach")
+		}
+		binary.BigEndian.PutUint16(list[2+6*i+4:], uint16(offset))
+	}
+
+	out = make([]byte, 10, start+len(table))
+	binary.BigEndian.PutUint16(out, 1)
+	binary.BigEndian.PutUint16(out[6:], 10)
+	for _, pos := range []int{4, 8} {
+		if offset := int(binary.BigEndian.Uint16(table[pos:])); offset != 0 {
+			if start+offset > math.MaxUint16 {
+				return nil, fmt.Errorf("feature variations: table is too large")
+			}
+			binary.BigEndian.PutUint16(out[pos:], uint16(start+offset))
+		}
+	}
+	out = append(out, list...)
+	return append(out, table...), nil
+}
+
+// matchFeatureVariations returns the offsets of the alternate features of the
+// first feature variation record whose conditions are met at the given
+// coordinates, keyed by the index of the feature they replace. It returns nil
+// if no record matches.
+func matchFeatureVariations(table []byte, coords []float64) map[int]int {
+	variations := int(binary.BigEndian.Uint32(table[10:]))
+	if variations == 0 {
+		return nil
+	}
+	recordCount := int(binary.BigEndian.Uint32(table[variations+4:]))
+	for i := 0; i < recordCount; i++ {
+		record := variations + 8 + 8*i
+		conditionSet := int(binary.BigEndian.Uint32(table[record:]))
+		if conditionSet != 0 && !meetsConditions(table[variations+conditionSet:], coords) {
+			continue
+		}
+		substitutions := make(map[int]int)
+		substitution := int(binary.BigEndian.Uint32(table[record+4:]))
+		if substitution == 0 {
+			return substitutions
+		}
+		substitution += variations
+		count := int(binary.BigEndian.Uint16(table[substitution+4:]))
+		for j := 0; j < count; j++ {
+			entry := substitution + 6 + 6*j
+			featureIndex := int(binary.BigEndian.Uint16(table[entry:]))
+			substitutions[featureIndex] = substitution + int(binary.BigEndian.Uint32(table[entry+2:]))
+		}
+		return substitutions
+	}
+	return nil
+}
+
+// meetsConditions reports whether coordinates are within the axis ranges of
+// all conditions of a condition set. Conditions of unknown formats are never
+// met.
+func meetsConditions(conditionSet []byte, coords []float64) bool {
+	count := int(binary.BigEndian.Uint16(conditionSet))
+	for i := 0; i < count; i++ {
+		condition := conditionSet[binary.BigEndian.Uint32(conditionSet[2+4*i:]):]
+		if binary.BigEndian.Uint16(condition) != conditionAxisRange {
+			return false
+		}
+		axis := int(binary.BigEndian.Uint16(condition[2:]))
+		bounds := readTuple(condition[4:], 2)
+		if axis >= len(coords) || coords[axis] < bounds[0] || coords[axis] > bounds[1] {
+			return false
+		}
+	}
+	return true
+}
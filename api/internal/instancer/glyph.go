@@ -0,0 +1,342 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Flags used by simple glyph descriptions
+const (
+	onCurvePoint  = 0x01
+	xShortVector  = 0x02
+	yShortVector  = 0x04
+	repeatFlag    = 0x08
+	xIsSameOrPos  = 0x10
+	yIsSameOrPos  = 0x20
+	overlapSimple = 0x40
+)
+
+// Flags used by composite glyph descriptions
+const (
+	argsAreWords       = 0x0001
+	argsAreXYValues    = 0x0002
+	weHaveAScale       = 0x0008
+	moreComponents     = 0x0020
+	weHaveXYScale      = 0x0040
+	weHaveTwoByTwo     = 0x0080
+	weHaveInstructions = 0x0100
+)
+
+// glyph is a parsed glyph description. The points of a simple glyph and the
+// offsets of the components of a composite glyph are the ones that are varied
+// by the gvar table.
+type glyph struct {
+	// Simple glyphs
+	endPoints []uint16
+	onCurve   []bool
+	overlap   bool
+
+	// Composite glyphs
+	components []component
+
+	// xs and ys are the coordinates of the points of a simple glyph or the
+	// offsets of the components of a composite glyph
+	xs, ys       []float64
+	instructions []byte
+}
+
+// component is a component of a composite glyph.
+type component struct {
+	flags   uint16
+	glyphID uint16
+	// args are the raw arguments if they are point numbers rather than an
+	// offset
+	args [2]int
+	// transform is the raw scale of the component and matrix is its 2x2
+	// transformation matrix
+	transform []byte
+	matrix    [4]float64
+}
+
+func (g *glyph) isComposite() bool {
+	return g.components != nil
+}
+
+// parseGlyph parses a glyph description. Empty glyphs are returned as simple
+// glyphs without points.
+func parseGlyph(data []byte) (g *glyph, err error) {
+	g = &glyph{}
+	if len(data) == 0 {
+		return g, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed glyph: %v", r)
+		}
+	}()
+	numberOfContours := int16(binary.BigEndian.Uint16(data))
+	if numberOfContours < 0 {
+		return parseComposite(data)
+	}
+
+	offset := 10
+	numPoints := 0
+	for i := 0; i < int(numberOfContours); i++ {
+		end := binary.BigEndian.Uint16(data[offset:])
+		if int(end)+1 <= numPoints && i > 0 {
+			return nil, fmt.Errorf("contour end points are not increasing")
+		}
+		g.endPoints = append(g.endPoints, end)
+		numPoints = int(end) + 1
+		offset += 2
+	}
+	instructionLength := int(binary.BigEndian.Uint16(data[offset:]))
+	offset += 2
+	g.instructions = data[offset : offset+instructionLength]
+	offset += instructionLength
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		flag := data[offset]
+		offset++
+		flags = append(flags, flag)
+		if flag&repeatFlag != 0 {
+			count := int(data[offset])
+			offset++
+			for i := 0; i < count; i++ {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if len(flags) > numPoints {
+		return nil, fmt.Errorf("too many flags")
+	}
+	g.overlap = numPoints > 0 && flags[0]&overlapSimple != 0
+
+	g.onCurve = make([]bool, numPoints)
+	g.xs = make([]float64, numPoints)
+	g.ys = make([]float64, numPoints)
+	x := 0
+	for i, flag := range flags {
+		switch {
+		case flag&xShortVector != 0:
+			dx := int(data[offset])
+			offset++
+			if flag&xIsSameOrPos == 0 {
+				dx = -dx
+			}
+			x += dx
+		case flag&xIsSameOrPos == 0:
+			x += int(int16(binary.BigEndian.Uint16(data[offset:])))
+			offset += 2
+		}
+		g.xs[i] = float64(x)
+		g.onCurve[i] = flag&onCurvePoint != 0
+	}
+	y := 0
+	for i, flag := range flags {
+		switch {
+		case flag&yShortVector != 0:
+			dy := int(data[offset])
+			offset++
+			if flag&yIsSameOrPos == 0 {
+				dy = -dy
+			}
+			y += dy
+		case flag&yIsSameOrPos == 0:
+			y += int(int16(binary.BigEndian.Uint16(data[offset:])))
+			offset += 2
+		}
+		g.ys[i] = float64(y)
+	}
+	if offset > len(data) {
+		return nil, fmt.Errorf("glyph is truncated")
+	}
+	return g, nil
+}
+
+func parseComposite(data []byte) (*glyph, error) {
+	g := &glyph{components: []component{}}
+	offset := 10
+	for {
+		c := component{
+			flags:   binary.BigEndian.Uint16(data[offset:]),
+			glyphID: binary.BigEndian.Uint16(data[offset+2:]),
+			matrix:  [4]float64{1, 0, 0, 1},
+		}
+		offset += 4
+		var arg1, arg2 int
+		switch {
+		case c.flags&argsAreWords != 0 && c.flags&argsAreXYValues != 0:
+			arg1 = int(int16(binary.BigEndian.Uint16(data[offset:])))
+			arg2 = int(int16(binary.BigEndian.Uint16(data[offset+2:])))
+			offset += 4
+		case c.flags&argsAreWords != 0:
+			arg1 = int(binary.BigEndian.Uint16(data[offset:]))
+			arg2 = int(binary.BigEndian.Uint16(data[offset+2:]))
+			offset += 4
+		case c.flags&argsAreXYValues != 0:
+			arg1 = int(int8(data[offset]))
+			arg2 = int(int8(data[offset+1]))
+			offset += 2
+		default:
+			arg1 = int(data[offset])
+			arg2 = int(data[offset+1])
+			offset += 2
+		}
+		var x, y float64
+		if c.flags&argsAreXYValues != 0 {
+			x, y = float64(arg1), float64(arg2)
+		} else {
+			c.args = [2]int{arg1, arg2}
+		}
+
+		transformLength := 0
+		switch {
+		case c.flags&weHaveAScale != 0:
+			transformLength = 2
+		case c.flags&weHaveXYScale != 0:
+			transformLength = 4
+		case c.flags&weHaveTwoByTwo != 0:
+			transformLength = 8
+		}
+		c.transform = data[offset : offset+transformLength]
+		offset += transformLength
+		f2dot14 := func(i int) float64 {
+			return float64(int16(binary.BigEndian.Uint16(c.transform[2*i:]))) / 16384
+		}
+		switch transformLength {
+		case 2:
+			c.matrix = [4]float64{f2dot14(0), 0, 0, f2dot14(0)}
+		case 4:
+			c.matrix = [4]float64{f2dot14(0), 0, 0, f2dot14(1)}
+		case 8:
+			c.matrix = [4]float64{f2dot14(0), f2dot14(1), f2dot14(2), f2dot14(3)}
+		}
+
+		g.components = append(g.components, c)
+		g.xs = append(g.xs, x)
+		g.ys = append(g.ys, y)
+		if c.flags&moreComponents == 0 {
+			break
+		}
+	}
+	if g.components[len(g.components)-1].flags&weHaveInstructions != 0 {
+		instructionLength := int(binary.BigEndian.Uint16(data[offset:]))
+		g.instructions = data[offset+2 : offset+2+instructionLength]
+	}
+	return g, nil
+}
+
+// bytes encodes the glyph description with the given bounding box.
+func (g *glyph) bytes(bbox [4]int16) []byte {
+	if g.isComposite() {
+		return g.compositeBytes(bbox)
+	}
+	if len(g.xs) == 0 && len(g.endPoints) == 0 {
+		return nil
+	}
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(g.endPoints)))
+	for _, v := range bbox {
+		out = binary.BigEndian.AppendUint16(out, uint16(v))
+	}
+	for _, end := range g.endPoints {
+		out = binary.BigEndian.AppendUint16(out, end)
+	}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(g.instructions)))
+	out = append(out, g.instructions...)
+
+	flags := make([]byte, len(g.xs))
+	var xData, yData []byte
+	prevX, prevY := 0, 0
+	for i := range g.xs {
+		x, y := round(g.xs[i]), round(g.ys[i])
+		dx, dy := x-prevX, y-prevY
+		prevX, prevY = x, y
+		var flag byte
+		if g.onCurve[i] {
+			flag |= onCurvePoint
+		}
+		if i == 0 && g.overlap {
+			flag |= overlapSimple
+		}
+		switch {
+		case dx == 0:
+			flag |= xIsSameOrPos
+		case dx >= -255 && dx <= 255:
+			flag |= xShortVector
+			if dx > 0 {
+				flag |= xIsSameOrPos
+			}
+			xData = append(xData, byte(abs(dx)))
+		default:
+			xData = binary.BigEndian.AppendUint16(xData, uint16(int16(dx)))
+		}
+		switch {
+		case dy == 0:
+			flag |= yIsSameOrPos
+		case dy >= -255 && dy <= 255:
+			flag |= yShortVector
+			if dy > 0 {
+				flag |= yIsSameOrPos
+			}
+			yData = append(yData, byte(abs(dy)))
+		default:
+			yData = binary.BigEndian.AppendUint16(yData, uint16(int16(dy)))
+		}
+		flags[i] = flag
+	}
+	for i := 0; i < len(flags); {
+		repeat := 0
+		for i+repeat+1 < len(flags) && flags[i+repeat+1] == flags[i] && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			out = append(out, flags[i]|repeatFlag, byte(repeat))
+		} else {
+			out = append(out, flags[i])
+		}
+		i += repeat + 1
+	}
+	out = append(out, xData...)
+	out = append(out, yData...)
+	return out
+}
+
+// compositeBytes encodes a composite glyph description. Arguments are always
+// written as words.
+func (g *glyph) compositeBytes(bbox [4]int16) []byte {
+	out := binary.BigEndian.AppendUint16(nil, 0xFFFF)
+	for _, v := range bbox {
+		out = binary.BigEndian.AppendUint16(out, uint16(v))
+	}
+	for i, c := range g.components {
+		out = binary.BigEndian.AppendUint16(out, c.flags|argsAreWords)
+		out = binary.BigEndian.AppendUint16(out, c.glyphID)
+		if c.flags&argsAreXYValues != 0 {
+			out = binary.BigEndian.AppendUint16(out, uint16(int16(round(g.xs[i]))))
+			out = binary.BigEndian.AppendUint16(out, uint16(int16(round(g.ys[i]))))
+		} else {
+			out = binary.BigEndian.AppendUint16(out, uint16(c.args[0]))
+			out = binary.BigEndian.AppendUint16(out, uint16(c.args[1]))
+		}
+		out = append(out, c.transform...)
+	}
+	if g.components[len(g.components)-1].flags&weHaveInstructions != 0 {
+		out = binary.BigEndian.AppendUint16(out, uint16(len(g.instructions)))
+		out = append(out, g.instructions...)
+	}
+	return out
+}
+
+func round(x float64) int {
+	return int(math.Floor(x + 0.5))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
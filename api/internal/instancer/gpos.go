@@ -0,0 +1,235 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// variationIndexFormat is the delta format of device tables that are
+// VariationIndex tables, which refer to the deltas of an item variation store.
+const variationIndexFormat = 0x8000
+
+// GPOS lookup types that have positioning values
+const (
+	singleAdjustment     = 1
+	pairAdjustment       = 2
+	cursiveAttachment    = 3
+	markToBaseAttach     = 4
+	markToLigatureAttach = 5
+	markToMarkAttach     = 6
+	extensionPositioning = 9
+)
+
+// gposInstancer applies the deltas of an item variation store to the
+// positioning values of a GPOS table.
+type gposInstancer struct {
+	gpos   []byte
+	store  *itemVariationStore
+	coords []float64
+	// visited are the offsets of the subtables, pair sets and anchors that
+	// have been instantiated, which may be shared
+	visited map[int]bool
+}
+
+// applyGPOSVariations returns a copy of a GPOS table with the deltas of the
+// item variation store of the GDEF table applied to its positioning values at
+// the given normalized coordinates, so that kerning and mark positions match
+// the instance. The VariationIndex tables that refer to the deltas are kept,
+// and are ignored once the item variation store is left out of GDEF.
+func applyGPOSVariations(gpos []byte, gdef []byte, coords []float64) (out []byte, err error) {
+	// Version 1.3 of GDEF adds the item variation store
+	if len(gpos) < 10 || len(gdef) < 18 || binary.BigEndian.Uint16(gdef) != 1 || binary.BigEndian.Uint16(gdef[2:]) < 3 {
+		return gpos, nil
+	}
+	storeOffset := binary.BigEndian.Uint32(gdef[14:])
+	if storeOffset == 0 {
+		return gpos, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed GPOS variations: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	store, err := parseItemVariationStore(gdef[storeOffset:], len(coords))
+	if err != nil {
+		return nil, err
+	}
+	g := &gposInstancer{
+		gpos:    slices.Clone(gpos),
+		store:   store,
+		coords:  coords,
+		visited: make(map[int]bool),
+	}
+	lookupList := g.offset(0, 8)
+	lookupCount := g.uint16(lookupList)
+	for i := 0; i < lookupCount; i++ {
+		lookup := g.offset(lookupList, lookupList+2+2*i)
+		lookupType := g.uint16(lookup)
+		subtableCount := g.uint16(lookup + 4)
+		for j := 0; j < subtableCount; j++ {
+			g.subtable(lookupType, g.offset(lookup, lookup+6+2*j))
+		}
+	}
+	return g.gpos, nil
+}
+
+func (g *gposInstancer) uint16(pos int) int {
+	return int(binary.BigEndian.Uint16(g.gpos[pos:]))
+}
+
+// offset reads the 16-bit offset at pos, which is relative to base.
+func (g *gposInstancer) offset(base int, pos int) int {
+	return base + g.uint16(pos)
+}
+
+// visit reports whether the table at offset hasn't been visited yet, and
+// marks it as visited.
+func (g *gposInstancer) visit(offset int) bool {
+	if g.visited[offset] {
+		return false
+	}
+	g.visited[offset] = true
+	return true
+}
+
+func (g *gposInstancer) subtable(lookupType int, offset int) {
+	if !g.visit(offset) {
+		return
+	}
+	format := g.uint16(offset)
+	switch lookupType {
+	case singleAdjustment:
+		valueFormat := g.uint16(offset + 4)
+		if format == 1 {
+			g.valueRecord(offset, offset+6, valueFormat)
+			break
+		}
+		valueCount := g.uint16(offset + 6)
+		for i := 0; i < valueCount; i++ {
+			g.valueRecord(offset, offset+8+i*valueRecordSize(valueFormat), valueFormat)
+		}
+	case pairAdjustment:
+		valueFormat1, valueFormat2 := g.uint16(offset+4), g.uint16(offset+6)
+		size1, size2 := valueRecordSize(valueFormat1), valueRecordSize(valueFormat2)
+		if format == 1 {
+			pairSetCount := g.uint16(offset + 8)
+			for i := 0; i < pairSetCount; i++ {
+				pairSet := g.offset(offset, offset+10+2*i)
+				if !g.visit(pairSet) {
+					continue
+				}
+				pairValueCount := g.uint16(pairSet)
+				for j := 0; j < pairValueCount; j++ {
+					record := pairSet + 2 + j*(2+size1+size2) + 2
+					g.valueRecord(pairSet, record, valueFormat1)
+					g.valueRecord(pairSet, record+size1, valueFormat2)
+				}
+			}
+			break
+		}
+		classCount := g.uint16(offset+12) * g.uint16(offset+14)
+		for i := 0; i < classCount; i++ {
+			record := offset + 16 + i*(size1+size2)
+			g.valueRecord(offset, record, valueFormat1)
+			g.valueRecord(offset, record+size1, valueFormat2)
+		}
+	case cursiveAttachment:
+		// Each record has an entry and an exit anchor
+		anchorCount := 2 * g.uint16(offset+4)
+		for i := 0; i < anchorCount; i++ {
+			g.anchor(offset, offset+6+2*i)
+		}
+	case markToBaseAttach, markToLigatureAttach, markToMarkAttach:
+		markClassCount := g.uint16(offset + 6)
+		markArray := g.offset(offset, offset+8)
+		markCount := g.uint16(markArray)
+		for i := 0; i < markCount; i++ {
+			g.anchor(markArray, markArray+2+4*i+2)
+		}
+		array := g.offset(offset, offset+10)
+		count := g.uint16(array)
+		for i := 0; i < count; i++ {
+			if lookupType != markToLigatureAttach {
+				for j := 0; j < markClassCount; j++ {
+					g.anchor(array, array+2+2*(i*markClassCount+j))
+				}
+				continue
+			}
+			attach := g.offset(array, array+2+2*i)
+			anchorCount := g.uint16(attach) * markClassCount
+			for j := 0; j < anchorCount; j++ {
+				g.anchor(attach, attach+2+2*j)
+			}
+		}
+	case extensionPositioning:
+		g.subtable(g.uint16(offset+2), offset+int(binary.BigEndian.Uint32(g.gpos[offset+4:])))
+	}
+}
+
+// valueRecordSize returns the size of the value records of a value format,
+// which has one bit per field.
+func valueRecordSize(valueFormat int) int {
+	size := 0
+	for bit := 0; bit < 8; bit++ {
+		if valueFormat&(1<<bit) != 0 {
+			size += 2
+		}
+	}
+	return size
+}
+
+// valueRecord applies the deltas of the value record at pos, whose device
+// offsets are relative to base. The four placement and advance values are
+// followed by the offsets of their devices.
+func (g *gposInstancer) valueRecord(base int, pos int, valueFormat int) {
+	var values [4]int
+	for bit := 0; bit < 4; bit++ {
+		values[bit] = -1
+		if valueFormat&(1<<bit) != 0 {
+			values[bit] = pos
+			pos += 2
+		}
+	}
+	for bit := 0; bit < 4; bit++ {
+		if valueFormat&(1<<(bit+4)) == 0 {
+			continue
+		}
+		if values[bit] >= 0 {
+			g.device(base, pos, values[bit])
+		}
+		pos += 2
+	}
+}
+
+// anchor applies the deltas of the coordinates of the anchor whose offset
+// relative to base is at pos. Only anchors of format 3 have devices.
+func (g *gposInstancer) anchor(base int, pos int) {
+	if g.uint16(pos) == 0 {
+		return
+	}
+	anchor := g.offset(base, pos)
+	if !g.visit(anchor) || g.uint16(anchor) != 3 {
+		return
+	}
+	g.device(anchor, anchor+6, anchor+2)
+	g.device(anchor, anchor+8, anchor+4)
+}
+
+// device adds the delta of the device whose offset relative to base is at pos
+// to the value at valuePos, if the device is a VariationIndex table.
+func (g *gposInstancer) device(base int, pos int, valuePos int) {
+	if g.uint16(pos) == 0 {
+		return
+	}
+	device := g.offset(base, pos)
+	if g.uint16(device+4) != variationIndexFormat {
+		return
+	}
+	delta := g.store.delta(g.uint16(device), g.uint16(device+2), g.coords)
+	value := int(int16(binary.BigEndian.Uint16(g.gpos[valuePos:]))) + round(delta)
+	binary.BigEndian.PutUint16(g.gpos[valuePos:], uint16(int16(max(math.MinInt16, min(math.MaxInt16, value)))))
+}
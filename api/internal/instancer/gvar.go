@@ -0,0 +1,320 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Flags used by the gvar table
+const (
+	sharedPointNumbers    = 0x8000
+	tupleCountMask        = 0x0FFF
+	embeddedPeakTuple     = 0x8000
+	intermediateRegion    = 0x4000
+	privatePointNumbers   = 0x2000
+	tupleIndexMask        = 0x0FFF
+	pointsAreWords        = 0x80
+	pointRunCountMask     = 0x7F
+	deltasAreZero         = 0x80
+	deltasAreWords        = 0x40
+	deltaRunCountMask     = 0x3F
+	gvarLongOffsetsFlag   = 0x0001
+	numberOfPhantomPoints = 4
+)
+
+// gvarTable is a parsed glyph variations table.
+type gvarTable struct {
+	data         []byte
+	axisCount    int
+	sharedTuples [][]float64
+	glyphCount   int
+	longOffsets  bool
+	dataOffset   int
+}
+
+func parseGvar(data []byte) (*gvarTable, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("%w: truncated gvar table", sfnt.ErrInvalidFont)
+	}
+	t := &gvarTable{
+		data:        data,
+		axisCount:   int(binary.BigEndian.Uint16(data[4:])),
+		glyphCount:  int(binary.BigEndian.Uint16(data[12:])),
+		longOffsets: binary.BigEndian.Uint16(data[14:])&gvarLongOffsetsFlag != 0,
+		dataOffset:  int(binary.BigEndian.Uint32(data[16:])),
+	}
+	sharedTupleCount := int(binary.BigEndian.Uint16(data[6:]))
+	sharedTuplesOffset := int(binary.BigEndian.Uint32(data[8:]))
+	offsetsSize := 2 * (t.glyphCount + 1)
+	if t.longOffsets {
+		offsetsSize *= 2
+	}
+	if len(data) < 20+offsetsSize || len(data) < sharedTuplesOffset+2*t.axisCount*sharedTupleCount {
+		return nil, fmt.Errorf("%w: truncated gvar table", sfnt.ErrInvalidFont)
+	}
+	for i := 0; i < sharedTupleCount; i++ {
+		t.sharedTuples = append(t.sharedTuples, readTuple(data[sharedTuplesOffset+2*t.axisCount*i:], t.axisCount))
+	}
+	return t, nil
+}
+
+// glyphData returns the variation data of a glyph.
+func (t *gvarTable) glyphData(gid int) ([]byte, error) {
+	if gid >= t.glyphCount {
+		return nil, nil
+	}
+	offset := func(i int) int {
+		if t.longOffsets {
+			return int(binary.BigEndian.Uint32(t.data[20+4*i:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(t.data[20+2*i:]))
+	}
+	start, end := t.dataOffset+offset(gid), t.dataOffset+offset(gid+1)
+	if start > end || end > len(t.data) {
+		return nil, fmt.Errorf("%w: gvar data for glyph %d out of bounds", sfnt.ErrInvalidFont, gid)
+	}
+	return t.data[start:end], nil
+}
+
+// deltas calculates the deltas of the points of a glyph, followed by the four
+// phantom points, at the given normalized coordinates. The deltas of points
+// that are not referenced by a variation are inferred from the surrounding
+// points for simple glyphs.
+func (t *gvarTable) deltas(gid int, g *glyph, coords []float64) (dx []float64, dy []float64, err error) {
+	numPoints := len(g.xs) + numberOfPhantomPoints
+	dx = make([]float64, numPoints)
+	dy = make([]float64, numPoints)
+	data, err := t.glyphData(gid)
+	if err != nil || len(data) == 0 {
+		return dx, dy, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed gvar data for glyph %d: %v", sfnt.ErrInvalidFont, gid, r)
+		}
+	}()
+
+	tupleCount := binary.BigEndian.Uint16(data)
+	serialized := data[binary.BigEndian.Uint16(data[2:]):]
+	var sharedPoints []int
+	if tupleCount&sharedPointNumbers != 0 {
+		var n int
+		sharedPoints, n = readPoints(serialized)
+		serialized = serialized[n:]
+	}
+
+	header := data[4:]
+	for i := 0; i < int(tupleCount&tupleCountMask); i++ {
+		size := int(binary.BigEndian.Uint16(header))
+		index := binary.BigEndian.Uint16(header[2:])
+		header = header[4:]
+		var peak, start, end []float64
+		if index&embeddedPeakTuple != 0 {
+			peak = readTuple(header, t.axisCount)
+			header = header[2*t.axisCount:]
+		} else {
+			peak = t.sharedTuples[index&tupleIndexMask]
+		}
+		if index&intermediateRegion != 0 {
+			start = readTuple(header, t.axisCount)
+			end = readTuple(header[2*t.axisCount:], t.axisCount)
+			header = header[4*t.axisCount:]
+		}
+		tupleData := serialized[:size]
+		serialized = serialized[size:]
+
+		scalar := tupleScalar(coords, peak, start, end)
+		if scalar == 0 {
+			continue
+		}
+		points := sharedPoints
+		if index&privatePointNumbers != 0 {
+			var n int
+			points, n = readPoints(tupleData)
+			tupleData = tupleData[n:]
+		}
+		count := len(points)
+		if points == nil {
+			count = numPoints
+		}
+		xDeltas, n := readDeltas(tupleData, count)
+		yDeltas, _ := readDeltas(tupleData[n:], count)
+
+		tupleDx := make([]float64, numPoints)
+		tupleDy := make([]float64, numPoints)
+		touched := make([]bool, numPoints)
+		for j := 0; j < count; j++ {
+			point := j
+			if points != nil {
+				point = points[j]
+			}
+			if point >= numPoints {
+				continue
+			}
+			tupleDx[point] += xDeltas[j]
+			tupleDy[point] += yDeltas[j]
+			touched[point] = true
+		}
+		if points != nil && !g.isComposite() {
+			interpolateUntouched(g, tupleDx, tupleDy, touched)
+		}
+		for j := range dx {
+			dx[j] += scalar * tupleDx[j]
+			dy[j] += scalar * tupleDy[j]
+		}
+	}
+	return dx, dy, nil
+}
+
+// tupleScalar calculates how much a variation applies at the given
+// coordinates. start and end are nil for variations without an intermediate
+// region.
+func tupleScalar(coords, peak, start, end []float64) float64 {
+	scalar := 1.0
+	for i, p := range peak {
+		v := coords[i]
+		if p == 0 || v == p {
+			continue
+		}
+		if start != nil {
+			s, e := start[i], end[i]
+			if s > p || p > e || (s < 0 && e > 0) {
+				continue
+			}
+			if v < s || v > e {
+				return 0
+			}
+			if v < p {
+				scalar *= (v - s) / (p - s)
+			} else {
+				scalar *= (e - v) / (e - p)
+			}
+			continue
+		}
+		if v == 0 || v < min(0, p) || v > max(0, p) {
+			return 0
+		}
+		scalar *= v / p
+	}
+	return scalar
+}
+
+// interpolateUntouched infers the deltas of the points of a simple glyph that
+// are not referenced by a variation from the nearest referenced points of the
+// same contour.
+func interpolateUntouched(g *glyph, dx, dy []float64, touched []bool) {
+	start := 0
+	for _, endPoint := range g.endPoints {
+		end := int(endPoint)
+		var refs []int
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				refs = append(refs, i)
+			}
+		}
+		if len(refs) > 0 && len(refs) < end-start+1 {
+			for j, ref := range refs {
+				next := refs[(j+1)%len(refs)]
+				// Visit the points between ref and next, wrapping around
+				// the end of the contour
+				for i := ref + 1; ; i++ {
+					if i > end {
+						i = start
+					}
+					if i == next {
+						break
+					}
+					dx[i] = interpolate(g.xs[i], g.xs[ref], g.xs[next], dx[ref], dx[next])
+					dy[i] = interpolate(g.ys[i], g.ys[ref], g.ys[next], dy[ref], dy[next])
+				}
+			}
+		}
+		start = end + 1
+	}
+}
+
+// interpolate infers the delta of a coordinate c from the coordinates c1 and
+// c2 of two reference points and their deltas d1 and d2.
+func interpolate(c, c1, c2, d1, d2 float64) float64 {
+	if c1 > c2 {
+		c1, c2 = c2, c1
+		d1, d2 = d2, d1
+	}
+	switch {
+	case c1 == c2:
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	case c <= c1:
+		return d1
+	case c >= c2:
+		return d2
+	}
+	return d1 + (c-c1)*(d2-d1)/(c2-c1)
+}
+
+// readTuple reads a tuple of F2Dot14 coordinates.
+func readTuple(data []byte, axisCount int) []float64 {
+	tuple := make([]float64, axisCount)
+	for i := range tuple {
+		tuple[i] = float64(int16(binary.BigEndian.Uint16(data[2*i:]))) / 16384
+	}
+	return tuple
+}
+
+// readPoints reads packed point numbers. It returns nil if all points are
+// referenced, along with the number of bytes read.
+func readPoints(data []byte) ([]int, int) {
+	count := int(data[0])
+	offset := 1
+	if count == 0 {
+		return nil, offset
+	}
+	if count&0x80 != 0 {
+		count = (count&0x7F)<<8 | int(data[1])
+		offset++
+	}
+	points := make([]int, 0, count)
+	point := 0
+	for len(points) < count {
+		control := data[offset]
+		offset++
+		for i := 0; i <= int(control&pointRunCountMask); i++ {
+			if control&pointsAreWords != 0 {
+				point += int(binary.BigEndian.Uint16(data[offset:]))
+				offset += 2
+			} else {
+				point += int(data[offset])
+				offset++
+			}
+			points = append(points, point)
+		}
+	}
+	return points, offset
+}
+
+// readDeltas reads count packed deltas, along with the number of bytes read.
+func readDeltas(data []byte, count int) ([]float64, int) {
+	deltas := make([]float64, 0, count)
+	offset := 0
+	for len(deltas) < count {
+		control := data[offset]
+		offset++
+		for i := 0; i <= int(control&deltaRunCountMask); i++ {
+			switch {
+			case control&deltasAreZero != 0:
+				deltas = append(deltas, 0)
+			case control&deltasAreWords != 0:
+				deltas = append(deltas, float64(int16(binary.BigEndian.Uint16(data[offset:]))))
+				offset += 2
+			default:
+				deltas = append(deltas, float64(int8(data[offset])))
+				offset++
+			}
+		}
+	}
+	return deltas, offset
+}
@@ -0,0 +1,449 @@
+// Package instancer generates static instances of variable TrueType fonts.
+//
+// The outlines and advance widths of the instance are calculated from the
+// glyph variations of the gvar table and the advance variations of the HVAR
+// table. The variations of the global metrics of the MVAR table, of the
+// positioning values of the GPOS table and the feature variations of the GSUB
+// and GPOS tables are applied as well, and the names are those of the
+// instance. Other variation data, such as variations of the hinting and of
+// vertical metrics, is dropped.
+package instancer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+var ErrNotVariable = errors.New("font is not a variable TrueType font")
+
+// variationTables are the tables that only apply to variable fonts.
+var variationTables = []string{"fvar", "gvar", "avar", "cvar", "HVAR", "VVAR", "MVAR", "STAT"}
+
+// Axis is a variation axis of a font.
+type Axis struct {
+	Tag     string
+	Min     float64
+	Default float64
+	Max     float64
+}
+
+// Instance is a named instance of a font, i.e. a location in its design
+// space.
+type Instance struct {
+	SubfamilyNameID uint16
+	Coordinates     map[string]float64
+}
+
+// Variations returns the variation axes and named instances of a font. It
+// returns ErrNotVariable for fonts without a fvar table.
+func Variations(data []byte) ([]Axis, []Instance, error) {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseFvar(font.Tables["fvar"])
+}
+
+func parseFvar(fvar []byte) (axes []Axis, instances []Instance, err error) {
+	if fvar == nil {
+		return nil, nil, ErrNotVariable
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed fvar table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	axesOffset := int(binary.BigEndian.Uint16(fvar[4:]))
+	axisCount := int(binary.BigEndian.Uint16(fvar[8:]))
+	axisSize := int(binary.BigEndian.Uint16(fvar[10:]))
+	instanceCount := int(binary.BigEndian.Uint16(fvar[12:]))
+	instanceSize := int(binary.BigEndian.Uint16(fvar[14:]))
+	fixed := func(offset int) float64 {
+		return float64(int32(binary.BigEndian.Uint32(fvar[offset:]))) / 65536
+	}
+	for i := 0; i < axisCount; i++ {
+		record := axesOffset + axisSize*i
+		axes = append(axes, Axis{
+			Tag:     string(fvar[record : record+4]),
+			Min:     fixed(record + 4),
+			Default: fixed(record + 8),
+			Max:     fixed(record + 12),
+		})
+	}
+	instancesOffset := axesOffset + axisSize*axisCount
+	for i := 0; i < instanceCount; i++ {
+		record := instancesOffset + instanceSize*i
+		instance := Instance{
+			SubfamilyNameID: binary.BigEndian.Uint16(fvar[record:]),
+			Coordinates:     make(map[string]float64),
+		}
+		for j, axis := range axes {
+			instance.Coordinates[axis.Tag] = fixed(record + 4 + 4*j)
+		}
+		instances = append(instances, instance)
+	}
+	return axes, instances, nil
+}
+
+// normalize maps a location in user coordinates to normalized coordinates,
+// applying the axis variations of the avar table if present. Axes that are
+// not in the location are at their default.
+func normalize(axes []Axis, avar []byte, location map[string]float64) ([]float64, error) {
+	coords := make([]float64, len(axes))
+	for i, axis := range axes {
+		v, found := location[axis.Tag]
+		if !found {
+			continue
+		}
+		v = math.Max(axis.Min, math.Min(axis.Max, v))
+		switch {
+		case v < axis.Default:
+			coords[i] = (v - axis.Default) / (axis.Default - axis.Min)
+		case v > axis.Default:
+			coords[i] = (v - axis.Default) / (axis.Max - axis.Default)
+		}
+	}
+	if avar != nil {
+		if err := applyAvar(avar, coords); err != nil {
+			return nil, err
+		}
+	}
+	// Coordinates are F2Dot14 values
+	for i := range coords {
+		coords[i] = math.Round(coords[i]*16384) / 16384
+	}
+	return coords, nil
+}
+
+// applyAvar maps normalized coordinates through the segment maps of the avar
+// table.
+func applyAvar(avar []byte, coords []float64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed avar table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	axisCount := int(binary.BigEndian.Uint16(avar[6:]))
+	if axisCount != len(coords) {
+		return fmt.Errorf("%w: avar table has %d axes, fvar has %d", sfnt.ErrInvalidFont, axisCount, len(coords))
+	}
+	offset := 8
+	for i := range coords {
+		count := int(binary.BigEndian.Uint16(avar[offset:]))
+		offset += 2
+		from := readTuple(avar[offset:], 2*count)
+		offset += 4 * count
+		v := coords[i]
+		for j := 0; j+2 < 2*count; j += 2 {
+			fromStart, toStart, fromEnd, toEnd := from[j], from[j+1], from[j+2], from[j+3]
+			if v >= fromStart && v <= fromEnd {
+				if fromEnd == fromStart {
+					coords[i] = toStart
+				} else {
+					coords[i] = toStart + (v-fromStart)*(toEnd-toStart)/(fromEnd-fromStart)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Instantiate returns a static instance of a variable TrueType font at the
+// given location in user coordinates, e.g. {"wght": 700}. Axes that are not
+// in the location are at their default. The usWeightClass of the OS/2 table
+// is set to the weight of the instance, and the family and subfamily names
+// are those of the named instance at the location or of the weight.
+func Instantiate(data []byte, location map[string]float64) ([]byte, error) {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	axes, instances, err := parseFvar(font.Tables["fvar"])
+	if err != nil {
+		return nil, err
+	}
+	if font.Tables["glyf"] == nil {
+		return nil, ErrNotVariable
+	}
+	coords, err := normalize(axes, font.Tables["avar"], location)
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := font.Glyphs()
+	if err != nil {
+		return nil, err
+	}
+	advances, bearings, err := readHmtx(font)
+	if err != nil {
+		return nil, err
+	}
+	var gvar *gvarTable
+	if font.Tables["gvar"] != nil {
+		if gvar, err = parseGvar(font.Tables["gvar"]); err != nil {
+			return nil, err
+		}
+		if gvar.axisCount != len(axes) {
+			return nil, fmt.Errorf("%w: gvar table has %d axes, fvar has %d", sfnt.ErrInvalidFont, gvar.axisCount, len(axes))
+		}
+	}
+	// The deltas of the HVAR table take precedence over the deltas of the
+	// phantom points
+	var hvarDeltas []float64
+	if font.Tables["HVAR"] != nil {
+		if hvarDeltas, err = advanceDeltas(font.Tables["HVAR"], len(advances), coords); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply the variations to the points and advance widths of all glyphs
+	glyphs := make([]*glyph, len(outlines))
+	leftSides := make([]float64, len(outlines))
+	for gid, outline := range outlines {
+		g, err := parseGlyph(outline)
+		if err != nil {
+			return nil, fmt.Errorf("glyph %d: %w", gid, err)
+		}
+		glyphs[gid] = g
+		xMin := 0
+		if len(outline) >= 10 {
+			xMin = int(int16(binary.BigEndian.Uint16(outline[2:])))
+		}
+		// The phantom points are the left and right side of the glyph
+		leftSide := float64(xMin - int(bearings[gid]))
+		rightSide := leftSide + float64(advances[gid])
+		if gvar != nil {
+			dx, dy, err := gvar.deltas(gid, g, coords)
+			if err != nil {
+				return nil, err
+			}
+			for i := range g.xs {
+				g.xs[i] += dx[i]
+				g.ys[i] += dy[i]
+			}
+			n := len(g.xs)
+			leftSide += dx[n]
+			rightSide += dx[n+1]
+		}
+		advance := round(rightSide) - round(leftSide)
+		if hvarDeltas != nil {
+			advance = round(float64(advances[gid]) + hvarDeltas[gid])
+		}
+		advances[gid] = uint16(max(0, advance))
+		leftSides[gid] = leftSide
+	}
+
+	// Encode the glyphs with their new bounding boxes
+	boxes := make([]*[4]int16, len(glyphs))
+	encoded := make([][]byte, len(glyphs))
+	var fontBox *[4]int16
+	for gid, g := range glyphs {
+		box, err := boundingBox(glyphs, boxes, gid, 0)
+		if err != nil {
+			return nil, err
+		}
+		if box == nil {
+			continue
+		}
+		encoded[gid] = g.bytes(*box)
+		bearings[gid] = int16(int(box[0]) - round(leftSides[gid]))
+		if fontBox == nil {
+			fontBox = &[4]int16{box[0], box[1], box[2], box[3]}
+		} else {
+			fontBox[0] = min(fontBox[0], box[0])
+			fontBox[1] = min(fontBox[1], box[1])
+			fontBox[2] = max(fontBox[2], box[2])
+			fontBox[3] = max(fontBox[3], box[3])
+		}
+	}
+
+	out := &sfnt.Font{
+		Version: font.Version,
+		Tables:  make(map[string][]byte),
+	}
+	for tag, table := range font.Tables {
+		switch {
+		case slices.Contains(variationTables, tag), tag == "DSIG":
+			continue
+		case tag == "GDEF":
+			// Version 1.3 adds the item variation store
+			out.Tables[tag] = downgradeMinorVersion(table, 3, 2)
+		case tag == "GPOS":
+			gpos, err := applyGPOSVariations(table, font.Tables["GDEF"], coords)
+			if err != nil {
+				return nil, err
+			}
+			if out.Tables[tag], err = applyFeatureVariations(gpos, coords); err != nil {
+				return nil, err
+			}
+		case tag == "GSUB":
+			if out.Tables[tag], err = applyFeatureVariations(table, coords); err != nil {
+				return nil, err
+			}
+		default:
+			out.Tables[tag] = table
+		}
+	}
+
+	glyf, loca, indexToLocFormat := sfnt.BuildGlyf(encoded)
+	out.Tables["glyf"] = glyf
+	out.Tables["loca"] = loca
+	head := slices.Clone(font.Tables["head"])
+	binary.BigEndian.PutUint16(head[50:], indexToLocFormat)
+	if fontBox != nil {
+		for i, v := range fontBox {
+			binary.BigEndian.PutUint16(head[36+2*i:], uint16(v))
+		}
+	}
+	out.Tables["head"] = head
+	out.Tables["hhea"], out.Tables["hmtx"] = buildHmtx(font.Tables["hhea"], advances, bearings, boxes)
+
+	if weight, found := location["wght"]; found && len(font.Tables["OS/2"]) >= 6 {
+		os2 := slices.Clone(font.Tables["OS/2"])
+		binary.BigEndian.PutUint16(os2[4:], uint16(max(1, min(1000, round(weight)))))
+		out.Tables["OS/2"] = os2
+	}
+	if font.Tables["MVAR"] != nil {
+		if err := applyMetricsVariations(out.Tables, font.Tables["MVAR"], coords); err != nil {
+			return nil, err
+		}
+	}
+	if font.Tables["name"] != nil {
+		subfamily := instanceSubfamily(font, axes, instances, location)
+		if out.Tables["name"], err = buildInstanceNames(font, subfamily); err != nil {
+			return nil, err
+		}
+		setStyleBits(out.Tables, subfamily)
+	}
+	return out.Bytes(), nil
+}
+
+// boundingBox calculates the bounding box of a glyph, resolving the
+// components of composite glyphs. It returns nil for empty glyphs. Bounding
+// boxes are cached in boxes.
+func boundingBox(glyphs []*glyph, boxes []*[4]int16, gid int, depth int) (*[4]int16, error) {
+	if boxes[gid] != nil {
+		return boxes[gid], nil
+	}
+	if depth > 16 {
+		return nil, fmt.Errorf("%w: composite glyphs are nested too deeply", sfnt.ErrInvalidFont)
+	}
+	g := glyphs[gid]
+	var xs, ys []float64
+	if g.isComposite() {
+		for i, c := range g.components {
+			if int(c.glyphID) >= len(glyphs) {
+				return nil, fmt.Errorf("%w: glyph %d references missing glyph %d", sfnt.ErrInvalidFont, gid, c.glyphID)
+			}
+			box, err := boundingBox(glyphs, boxes, int(c.glyphID), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if box == nil {
+				continue
+			}
+			// Transform the corners of the bounding box of the component
+			for _, corner := range [][2]int16{{box[0], box[1]}, {box[0], box[3]}, {box[2], box[1]}, {box[2], box[3]}} {
+				x, y := float64(corner[0]), float64(corner[1])
+				xs = append(xs, c.matrix[0]*x+c.matrix[2]*y+g.xs[i])
+				ys = append(ys, c.matrix[1]*x+c.matrix[3]*y+g.ys[i])
+			}
+		}
+	} else {
+		xs, ys = g.xs, g.ys
+	}
+	if len(xs) == 0 {
+		return nil, nil
+	}
+	box := &[4]int16{int16(round(xs[0])), int16(round(ys[0])), int16(round(xs[0])), int16(round(ys[0]))}
+	for i := range xs {
+		x, y := int16(round(xs[i])), int16(round(ys[i]))
+		box[0] = min(box[0], x)
+		box[1] = min(box[1], y)
+		box[2] = max(box[2], x)
+		box[3] = max(box[3], y)
+	}
+	boxes[gid] = box
+	return box, nil
+}
+
+// readHmtx reads the advance widths and left side bearings of all glyphs.
+func readHmtx(font *sfnt.Font) (advances []uint16, bearings []int16, err error) {
+	numGlyphs, err := font.NumGlyphs()
+	if err != nil {
+		return nil, nil, err
+	}
+	hhea := font.Tables["hhea"]
+	hmtx := font.Tables["hmtx"]
+	if len(hhea) < 36 {
+		return nil, nil, fmt.Errorf("%w: missing hhea table", sfnt.ErrInvalidFont)
+	}
+	numberOfHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if numberOfHMetrics == 0 || numberOfHMetrics > numGlyphs || len(hmtx) < 4*numberOfHMetrics+2*(numGlyphs-numberOfHMetrics) {
+		return nil, nil, fmt.Errorf("%w: truncated hmtx table", sfnt.ErrInvalidFont)
+	}
+	advances = make([]uint16, numGlyphs)
+	bearings = make([]int16, numGlyphs)
+	for gid := 0; gid < numGlyphs; gid++ {
+		if gid < numberOfHMetrics {
+			advances[gid] = binary.BigEndian.Uint16(hmtx[4*gid:])
+			bearings[gid] = int16(binary.BigEndian.Uint16(hmtx[4*gid+2:]))
+		} else {
+			advances[gid] = advances[numberOfHMetrics-1]
+			bearings[gid] = int16(binary.BigEndian.Uint16(hmtx[4*numberOfHMetrics+2*(gid-numberOfHMetrics):]))
+		}
+	}
+	return advances, bearings, nil
+}
+
+// buildHmtx builds the hmtx table and updates the metrics of the hhea table.
+func buildHmtx(hhea []byte, advances []uint16, bearings []int16, boxes []*[4]int16) ([]byte, []byte) {
+	hhea = slices.Clone(hhea)
+	numGlyphs := len(advances)
+	// Glyphs after the last long metric share its advance width
+	numberOfHMetrics := numGlyphs
+	for numberOfHMetrics > 1 && advances[numberOfHMetrics-1] == advances[numberOfHMetrics-2] {
+		numberOfHMetrics--
+	}
+	hmtx := make([]byte, 0, 4*numberOfHMetrics+2*(numGlyphs-numberOfHMetrics))
+	var advanceWidthMax uint16
+	minLeftSideBearing, minRightSideBearing, xMaxExtent := math.MaxInt16, math.MaxInt16, math.MinInt16
+	for gid := 0; gid < numGlyphs; gid++ {
+		if gid < numberOfHMetrics {
+			hmtx = binary.BigEndian.AppendUint16(hmtx, advances[gid])
+		}
+		hmtx = binary.BigEndian.AppendUint16(hmtx, uint16(bearings[gid]))
+		advanceWidthMax = max(advanceWidthMax, advances[gid])
+		if box := boxes[gid]; box != nil {
+			extent := int(bearings[gid]) + int(box[2]) - int(box[0])
+			minLeftSideBearing = min(minLeftSideBearing, int(bearings[gid]))
+			minRightSideBearing = min(minRightSideBearing, int(advances[gid])-extent)
+			xMaxExtent = max(xMaxExtent, extent)
+		}
+	}
+	binary.BigEndian.PutUint16(hhea[10:], advanceWidthMax)
+	if xMaxExtent != math.MinInt16 {
+		binary.BigEndian.PutUint16(hhea[12:], uint16(int16(minLeftSideBearing)))
+		binary.BigEndian.PutUint16(hhea[14:], uint16(int16(minRightSideBearing)))
+		binary.BigEndian.PutUint16(hhea[16:], uint16(int16(xMaxExtent)))
+	}
+	binary.BigEndian.PutUint16(hhea[34:], uint16(numberOfHMetrics))
+	return hhea, hmtx
+}
+
+// downgradeMinorVersion sets the minor version of a table with a 1.x version
+// header to to if it is from, which makes the fields added in that version
+// ignored.
+func downgradeMinorVersion(table []byte, from uint16, to uint16) []byte {
+	if len(table) < 4 || binary.BigEndian.Uint16(table) != 1 || binary.BigEndian.Uint16(table[2:]) != from {
+		return table
+	}
+	table = slices.Clone(table)
+	binary.BigEndian.PutUint16(table[2:], to)
+	return table
+}
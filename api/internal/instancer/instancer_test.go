@@ -0,0 +1,353 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// packDeltas packs deltas as runs of words.
+func packDeltas(deltas []int) []byte {
+	var out []byte
+	for len(deltas) > 0 {
+		n := min(len(deltas), 64)
+		out = append(out, deltasAreWords|byte(n-1))
+		for _, d := range deltas[:n] {
+			out = binary.BigEndian.AppendUint16(out, uint16(int16(d)))
+		}
+		deltas = deltas[n:]
+	}
+	return out
+}
+
+// variableGoRegular returns Go Regular with a wght axis from 100 to 900. At
+// weight 900 all points of H move 50 units to the right and its advance width
+// grows by 100. At weight 100 the first point of H moves 20 units to the left,
+// which moves the rest of its contour along.
+func variableGoRegular(t *testing.T) (data []byte, gid int, numPoints int) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	cmap, err := font.CharacterMap()
+	require.NoError(t, err)
+	gid = int(cmap['H'])
+	glyphs, err := font.Glyphs()
+	require.NoError(t, err)
+	g, err := parseGlyph(glyphs[gid])
+	require.NoError(t, err)
+	numPoints = len(g.xs)
+
+	// fvar with one axis and two named instances
+	fvar := []byte{0, 1, 0, 0, 0, 16, 0, 2, 0, 1, 0, 20, 0, 2, 0, 8}
+	fvar = append(fvar, "wght"...)
+	for _, v := range []int32{100, 400, 900} {
+		fvar = binary.BigEndian.AppendUint32(fvar, uint32(v<<16))
+	}
+	fvar = append(fvar, 0, 0, 1, 0)
+	for _, weight := range []int32{300, 700} {
+		fvar = append(fvar, 1, 0, 0, 0)
+		fvar = binary.BigEndian.AppendUint32(fvar, uint32(weight<<16))
+	}
+
+	// Deltas of the points and the four phantom points
+	xDeltas := make([]int, numPoints+numberOfPhantomPoints)
+	for i := 0; i < numPoints; i++ {
+		xDeltas[i] = 50
+	}
+	xDeltas[numPoints+1] = 100
+	yDeltas := make([]int, numPoints+numberOfPhantomPoints)
+	heavy := append(packDeltas(xDeltas), packDeltas(yDeltas)...)
+	light := []byte{1, 0, 0}
+	light = append(light, packDeltas([]int{-20})...)
+	light = append(light, packDeltas([]int{0})...)
+
+	glyphData := []byte{0, 2, 0, 16}
+	glyphData = binary.BigEndian.AppendUint16(glyphData, uint16(len(heavy)))
+	glyphData = append(glyphData, 0x80, 0, 0x40, 0)
+	glyphData = binary.BigEndian.AppendUint16(glyphData, uint16(len(light)))
+	glyphData = append(glyphData, 0xA0, 0, 0xC0, 0)
+	glyphData = append(glyphData, heavy...)
+	glyphData = append(glyphData, light...)
+
+	glyphCount := len(glyphs)
+	dataOffset := 20 + 4*(glyphCount+1)
+	gvar := []byte{0, 1, 0, 0, 0, 1, 0, 0}
+	gvar = binary.BigEndian.AppendUint32(gvar, uint32(dataOffset))
+	gvar = binary.BigEndian.AppendUint16(gvar, uint16(glyphCount))
+	gvar = binary.BigEndian.AppendUint16(gvar, 1)
+	gvar = binary.BigEndian.AppendUint32(gvar, uint32(dataOffset))
+	for i := 0; i <= glyphCount; i++ {
+		offset := 0
+		if i > gid {
+			offset = len(glyphData)
+		}
+		gvar = binary.BigEndian.AppendUint32(gvar, uint32(offset))
+	}
+	gvar = append(gvar, glyphData...)
+
+	font.Tables["fvar"] = fvar
+	font.Tables["gvar"] = gvar
+	return font.Bytes(), gid, numPoints
+}
+
+// glyphAt returns the parsed glyph and advance width of a glyph of a font.
+func glyphAt(t *testing.T, data []byte, gid int) (*glyph, int) {
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	glyphs, err := font.Glyphs()
+	require.NoError(t, err)
+	g, err := parseGlyph(glyphs[gid])
+	require.NoError(t, err)
+	advances, _, err := readHmtx(font)
+	require.NoError(t, err)
+	return g, int(advances[gid])
+}
+
+func TestVariations(t *testing.T) {
+	data, _, _ := variableGoRegular(t)
+	axes, instances, err := Variations(data)
+	require.NoError(t, err)
+	assert.Equal(t, []Axis{{Tag: "wght", Min: 100, Default: 400, Max: 900}}, axes)
+	require.Len(t, instances, 2)
+	assert.Equal(t, map[string]float64{"wght": 300}, instances[0].Coordinates)
+	assert.Equal(t, map[string]float64{"wght": 700}, instances[1].Coordinates)
+
+	_, _, err = Variations(goregular.TTF)
+	assert.ErrorIs(t, err, ErrNotVariable)
+}
+
+func TestInstantiate(t *testing.T) {
+	data, gid, numPoints := variableGoRegular(t)
+	source, sourceAdvance := glyphAt(t, goregular.TTF, gid)
+
+	tests := []struct {
+		weight  float64
+		dx      float64
+		advance int
+	}{
+		{400, 0, 0},
+		{900, 50, 100},
+		{650, 25, 50},
+		{1000, 50, 100},
+	}
+	for _, tt := range tests {
+		instance, err := Instantiate(data, map[string]float64{"wght": tt.weight})
+		require.NoError(t, err)
+		g, advance := glyphAt(t, instance, gid)
+		require.Len(t, g.xs, numPoints)
+		for i := range g.xs {
+			assert.Equal(t, source.xs[i]+tt.dx, g.xs[i], "weight %v, point %d", tt.weight, i)
+			assert.Equal(t, source.ys[i], g.ys[i], "weight %v, point %d", tt.weight, i)
+		}
+		assert.Equal(t, sourceAdvance+tt.advance, advance, "weight %v", tt.weight)
+
+		font, err := sfnt.Parse(instance)
+		require.NoError(t, err)
+		assert.Nil(t, font.Tables["fvar"])
+		assert.Nil(t, font.Tables["gvar"])
+		assert.Equal(t, uint16(min(tt.weight, 1000)), binary.BigEndian.Uint16(font.Tables["OS/2"][4:]))
+	}
+}
+
+func TestInstantiateInterpolatesUntouchedPoints(t *testing.T) {
+	data, gid, _ := variableGoRegular(t)
+	source, _ := glyphAt(t, goregular.TTF, gid)
+	instance, err := Instantiate(data, map[string]float64{"wght": 100})
+	require.NoError(t, err)
+	g, _ := glyphAt(t, instance, gid)
+	// The only referenced point of the first contour moves the whole contour
+	for i := 0; i <= int(source.endPoints[0]); i++ {
+		assert.Equal(t, source.xs[i]-20, g.xs[i], "point %d", i)
+	}
+	for i := int(source.endPoints[0]) + 1; i < len(source.xs); i++ {
+		assert.Equal(t, source.xs[i], g.xs[i], "point %d", i)
+	}
+}
+
+// variableKerning returns a GPOS table with a kerning pair whose advance is
+// -50 at the default weight, and a GDEF table whose item variation store
+// changes it by -30 at weight 900.
+func variableKerning() (gpos []byte, gdef []byte) {
+	gpos = []byte{
+		0, 1, 0, 0, 0, 0, 0, 0, 0, 10, // Header with the lookup list at 10
+		0, 1, 0, 4, // Lookup list
+		0, 2, 0, 0, 0, 1, 0, 8, // Pair adjustment lookup
+		0, 1, 0, 0, 0, 0x44, 0, 0, 0, 1, 0, 12, // Pair adjustment subtable
+		0, 1, 0, 42, 0xFF, 0xCE, 0, 8, // Pair set with an advance of -50
+		0, 0, 0, 0, 0x80, 0, // VariationIndex table
+	}
+	gdef = []byte{0, 1, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 18}
+	gdef = append(gdef, 0, 1, 0, 0, 0, 12, 0, 1, 0, 0, 0, 22) // Item variation store
+	gdef = append(gdef, 0, 1, 0, 1, 0, 0, 0x40, 0, 0x40, 0)   // Region from 0 to 1
+	gdef = append(gdef, 0, 1, 0, 1, 0, 1, 0, 0, 0xFF, 0xE2)   // Delta of -30
+	return gpos, gdef
+}
+
+func TestInstantiateAppliesGPOSVariations(t *testing.T) {
+	data, _, _ := variableGoRegular(t)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	font.Tables["GPOS"], font.Tables["GDEF"] = variableKerning()
+	data = font.Bytes()
+
+	for weight, advance := range map[float64]int16{400: -50, 900: -80, 650: -65, 100: -50} {
+		instance, err := Instantiate(data, map[string]float64{"wght": weight})
+		require.NoError(t, err)
+		font, err := sfnt.Parse(instance)
+		require.NoError(t, err)
+		assert.Equal(t, advance, int16(binary.BigEndian.Uint16(font.Tables["GPOS"][38:])), "weight %v", weight)
+		// The item variation store is ignored from now on
+		assert.Equal(t, uint16(2), binary.BigEndian.Uint16(font.Tables["GDEF"][2:]))
+	}
+}
+
+func TestInstantiateStaticFont(t *testing.T) {
+	_, err := Instantiate(goregular.TTF, map[string]float64{"wght": 700})
+	assert.ErrorIs(t, err, ErrNotVariable)
+}
+
+func TestInterpolate(t *testing.T) {
+	assert.Equal(t, 10.0, interpolate(0, 100, 200, 10, 20))
+	assert.Equal(t, 20.0, interpolate(300, 100, 200, 10, 20))
+	assert.Equal(t, 15.0, interpolate(150, 200, 100, 20, 10))
+	assert.Equal(t, 0.0, interpolate(150, 100, 100, 10, 20))
+	assert.Equal(t, 10.0, interpolate(150, 100, 100, 10, 10))
+}
+
+func TestTupleScalar(t *testing.T) {
+	assert.Equal(t, 0.5, tupleScalar([]float64{0.5}, []float64{1}, nil, nil))
+	assert.Equal(t, 0.0, tupleScalar([]float64{-0.5}, []float64{1}, nil, nil))
+	assert.Equal(t, 0.0, tupleScalar([]float64{0}, []float64{1}, nil, nil))
+	assert.Equal(t, 1.0, tupleScalar([]float64{0.5}, []float64{0}, nil, nil))
+	// Intermediate region from 0.2 to 1 with the peak at 0.6
+	assert.InDelta(t, 0.5, tupleScalar([]float64{0.4}, []float64{0.6}, []float64{0.2}, []float64{1}), 1e-9)
+	assert.InDelta(t, 0.5, tupleScalar([]float64{0.8}, []float64{0.6}, []float64{0.2}, []float64{1}), 1e-9)
+	assert.Equal(t, 0.0, tupleScalar([]float64{0.1}, []float64{0.6}, []float64{0.2}, []float64{1}))
+}
+
+// deltaStore returns an item variation store with a single delta of -30 at
+// the maximum of a single axis.
+func deltaStore() []byte {
+	store := []byte{0, 1, 0, 0, 0, 12, 0, 1, 0, 0, 0, 22}
+	store = append(store, 0, 1, 0, 1, 0, 0, 0x40, 0, 0x40, 0) // Region from 0 to 1
+	return append(store, 0, 1, 0, 1, 0, 1, 0, 0, 0xFF, 0xE2)  // Delta of -30
+}
+
+func TestInstantiateAppliesMetricsVariations(t *testing.T) {
+	data, gid, _ := variableGoRegular(t)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	// HVAR whose delta-set index map maps all glyphs to the delta
+	hvar := []byte{0, 1, 0, 0, 0, 0, 0, 20, 0, 0, 0, 52, 0, 0, 0, 0, 0, 0, 0, 0}
+	hvar = append(hvar, deltaStore()...)
+	hvar = append(hvar, 0, 0, 0, 1, 0)
+	font.Tables["HVAR"] = hvar
+	// MVAR with a delta of the typographic ascender
+	mvar := []byte{0, 1, 0, 0, 0, 0, 0, 8, 0, 1, 0, 20, 'h', 'a', 's', 'c', 0, 0, 0, 0}
+	font.Tables["MVAR"] = append(mvar, deltaStore()...)
+	data = font.Bytes()
+	_, sourceAdvance := glyphAt(t, goregular.TTF, gid)
+	sourceAscender := int16(binary.BigEndian.Uint16(font.Tables["OS/2"][68:]))
+
+	for weight, delta := range map[float64]int{400: 0, 900: -30, 650: -15, 100: 0} {
+		instance, err := Instantiate(data, map[string]float64{"wght": weight})
+		require.NoError(t, err)
+		// The HVAR table takes precedence over the phantom points of gvar
+		_, advance := glyphAt(t, instance, gid)
+		assert.Equal(t, sourceAdvance+delta, advance, "weight %v", weight)
+		font, err := sfnt.Parse(instance)
+		require.NoError(t, err)
+		assert.Equal(t, int(sourceAscender)+delta, int(int16(binary.BigEndian.Uint16(font.Tables["OS/2"][68:]))), "weight %v", weight)
+		assert.Nil(t, font.Tables["HVAR"])
+		assert.Nil(t, font.Tables["MVAR"])
+	}
+}
+
+// variableRvrn returns a GSUB table whose rvrn feature has no lookups, and
+// the first lookup at normalized weights from 0.5 to 1.
+func variableRvrn() []byte {
+	return []byte{
+		0, 1, 0, 1, 0, 0, 0, 14, 0, 0, 0, 0, 0, 26, // Header with the feature list at 14
+		0, 1, 'r', 'v', 'r', 'n', 0, 8, // Feature list
+		0, 0, 0, 0, // Feature without lookups
+		0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 16, 0, 0, 0, 30, // Feature variations
+		0, 1, 0, 0, 0, 6, // Condition set
+		0, 1, 0, 0, 0x20, 0, 0x40, 0, // Condition from 0.5 to 1
+		0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 12, // Feature table substitution
+		0, 0, 0, 1, 0, 0, // Feature with the first lookup
+	}
+}
+
+func TestInstantiateAppliesFeatureVariations(t *testing.T) {
+	data, _, _ := variableGoRegular(t)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	font.Tables["GSUB"] = variableRvrn()
+	data = font.Bytes()
+
+	for weight, lookups := range map[float64][]uint16{400: {}, 900: {0}, 650: {0}, 600: {}} {
+		instance, err := Instantiate(data, map[string]float64{"wght": weight})
+		require.NoError(t, err)
+		font, err := sfnt.Parse(instance)
+		require.NoError(t, err)
+		gsub := font.Tables["GSUB"]
+		assert.Equal(t, []byte{0, 1, 0, 0}, gsub[:4], "weight %v", weight)
+		featureList := gsub[binary.BigEndian.Uint16(gsub[6:]):]
+		require.Equal(t, uint16(1), binary.BigEndian.Uint16(featureList))
+		assert.Equal(t, "rvrn", string(featureList[2:6]))
+		feature := featureList[binary.BigEndian.Uint16(featureList[6:]):]
+		var indices []uint16
+		for i := 0; i < int(binary.BigEndian.Uint16(feature[2:])); i++ {
+			indices = append(indices, binary.BigEndian.Uint16(feature[4+2*i:]))
+		}
+		assert.ElementsMatch(t, lookups, indices, "weight %v", weight)
+	}
+}
+
+func TestInstantiateRenamesInstances(t *testing.T) {
+	data, _, _ := variableGoRegular(t)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	// Name the named instances, with names that are only used by fvar
+	fvar := slices.Clone(font.Tables["fvar"])
+	binary.BigEndian.PutUint16(fvar[44:], 257)
+	font.Tables["fvar"] = fvar
+	records, err := font.Names()
+	require.NoError(t, err)
+	records = append(records,
+		sfnt.NameRecord{PlatformID: sfnt.PlatformMacintosh, NameID: 256, Value: []byte("Book")},
+		sfnt.NameRecord{PlatformID: sfnt.PlatformMacintosh, NameID: 257, Value: []byte("Bold")},
+	)
+	font.Tables["name"] = sfnt.BuildName(records)
+	data = font.Bytes()
+
+	tests := []struct {
+		weight                        float64
+		family, subfamily, postScript string
+		typographicSubfamily          string
+		bold                          bool
+	}{
+		{700, "Go", "Bold", "Go-Bold", "", true},
+		{300, "Go Book", "Regular", "Go-Book", "Book", false},
+		{650, "Go 650", "Regular", "Go-650", "650", false},
+		{400, "Go", "Regular", "Go-Regular", "", false},
+	}
+	for _, tt := range tests {
+		instance, err := Instantiate(data, map[string]float64{"wght": tt.weight})
+		require.NoError(t, err)
+		font, err := sfnt.Parse(instance)
+		require.NoError(t, err)
+		assert.Equal(t, tt.family, font.Name(sfnt.NameFamily), "weight %v", tt.weight)
+		assert.Equal(t, tt.subfamily, font.Name(sfnt.NameSubfamily), "weight %v", tt.weight)
+		assert.Equal(t, tt.postScript, font.Name(sfnt.NamePostScript), "weight %v", tt.weight)
+		assert.Equal(t, tt.typographicSubfamily, font.Name(sfnt.NameTypographicSubfamily), "weight %v", tt.weight)
+		assert.Empty(t, font.Name(256)+font.Name(257), "weight %v", tt.weight)
+		assert.Empty(t, font.Name(sfnt.NameCompatibleFull), "weight %v", tt.weight)
+		bold := binary.BigEndian.Uint16(font.Tables["OS/2"][62:])&selectionBold != 0
+		assert.Equal(t, tt.bold, bold, "weight %v", tt.weight)
+	}
+}
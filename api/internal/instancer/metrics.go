@@ -0,0 +1,146 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// metricsValue is a value of a global metric that the MVAR table has deltas
+// for, i.e. a field of a table.
+type metricsValue struct {
+	table  string
+	offset int
+	// unsigned is set for uint16 fields, which are int16 fields otherwise
+	unsigned bool
+}
+
+// metricsValues are the values of the MVAR table, keyed by value tag.
+var metricsValues = map[string]metricsValue{
+	"hasc": {"OS/2", 68, false},
+	"hdsc": {"OS/2", 70, false},
+	"hlgp": {"OS/2", 72, false},
+	"hcla": {"OS/2", 74, true},
+	"hcld": {"OS/2", 76, true},
+	"xhgt": {"OS/2", 86, false},
+	"cpht": {"OS/2", 88, false},
+	"sbxs": {"OS/2", 10, false},
+	"sbys": {"OS/2", 12, false},
+	"sbxo": {"OS/2", 14, false},
+	"sbyo": {"OS/2", 16, false},
+	"spxs": {"OS/2", 18, false},
+	"spys": {"OS/2", 20, false},
+	"spxo": {"OS/2", 22, false},
+	"spyo": {"OS/2", 24, false},
+	"strs": {"OS/2", 26, false},
+	"stro": {"OS/2", 28, false},
+	"hcrs": {"hhea", 18, false},
+	"hcrn": {"hhea", 20, false},
+	"hcof": {"hhea", 22, false},
+	"undo": {"post", 8, false},
+	"unds": {"post", 10, false},
+	"vasc": {"vhea", 4, false},
+	"vdsc": {"vhea", 6, false},
+	"vlgp": {"vhea", 8, false},
+	"vcrs": {"vhea", 18, false},
+	"vcrn": {"vhea", 20, false},
+	"vcof": {"vhea", 22, false},
+}
+
+// Masks of the entry format of delta-set index maps
+const (
+	innerIndexBitCountMask = 0x0F
+	mapEntrySizeMask       = 0x30
+)
+
+// deltaSetIndex returns the outer and inner index of the deltas of item i of
+// a delta-set index map. Items after the end of the map use its last entry.
+func deltaSetIndex(indexMap []byte, i int) (outer int, inner int) {
+	entryFormat := int(indexMap[1])
+	mapCount, entries := int(binary.BigEndian.Uint16(indexMap[2:])), indexMap[4:]
+	if indexMap[0] == 1 {
+		mapCount, entries = int(binary.BigEndian.Uint32(indexMap[2:])), indexMap[6:]
+	}
+	i = min(i, mapCount-1)
+	entrySize := (entryFormat&mapEntrySizeMask)>>4 + 1
+	entry := 0
+	for _, b := range entries[entrySize*i : entrySize*(i+1)] {
+		entry = entry<<8 | int(b)
+	}
+	innerBits := entryFormat&innerIndexBitCountMask + 1
+	return entry >> innerBits, entry & (1<<innerBits - 1)
+}
+
+// advanceDeltas returns the deltas of the advance widths of the glyphs of a
+// font at the given normalized coordinates from its HVAR table.
+func advanceDeltas(hvar []byte, numGlyphs int, coords []float64) (deltas []float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed HVAR table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	store, err := parseItemVariationStore(hvar[binary.BigEndian.Uint32(hvar[4:]):], len(coords))
+	if err != nil {
+		return nil, err
+	}
+	var advanceMap []byte
+	if offset := binary.BigEndian.Uint32(hvar[8:]); offset != 0 {
+		advanceMap = hvar[offset:]
+	}
+	deltas = make([]float64, numGlyphs)
+	for gid := range deltas {
+		// Without a map the deltas are indexed by glyph id
+		outer, inner := 0, gid
+		if advanceMap != nil {
+			outer, inner = deltaSetIndex(advanceMap, gid)
+		}
+		deltas[gid] = store.delta(outer, inner, coords)
+	}
+	return deltas, nil
+}
+
+// applyMetricsVariations applies the deltas of the MVAR table at the given
+// normalized coordinates to the global metrics of the tables of a font, which
+// are replaced with updated copies.
+func applyMetricsVariations(tables map[string][]byte, mvar []byte, coords []float64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed MVAR table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	recordSize := int(binary.BigEndian.Uint16(mvar[6:]))
+	recordCount := int(binary.BigEndian.Uint16(mvar[8:]))
+	storeOffset := int(binary.BigEndian.Uint16(mvar[10:]))
+	if recordCount == 0 || storeOffset == 0 {
+		return nil
+	}
+	store, err := parseItemVariationStore(mvar[storeOffset:], len(coords))
+	if err != nil {
+		return err
+	}
+	cloned := make(map[string]bool)
+	for i := 0; i < recordCount; i++ {
+		record := mvar[12+recordSize*i:]
+		value, found := metricsValues[string(record[:4])]
+		if !found || len(tables[value.table]) < value.offset+2 {
+			continue
+		}
+		if !cloned[value.table] {
+			tables[value.table] = slices.Clone(tables[value.table])
+			cloned[value.table] = true
+		}
+		table := tables[value.table]
+		delta := round(store.delta(int(binary.BigEndian.Uint16(record[4:])), int(binary.BigEndian.Uint16(record[6:])), coords))
+		if value.unsigned {
+			v := int(binary.BigEndian.Uint16(table[value.offset:])) + delta
+			binary.BigEndian.PutUint16(table[value.offset:], uint16(max(0, min(math.MaxUint16, v))))
+			continue
+		}
+		v := int(int16(binary.BigEndian.Uint16(table[value.offset:]))) + delta
+		binary.BigEndian.PutUint16(table[value.offset:], uint16(int16(max(math.MinInt16, min(math.MaxInt16, v)))))
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// weightNames are the conventional subfamily names of standard weights.
+var weightNames = map[int]string{
+	100: "Thin",
+	200: "ExtraLight",
+	300: "Light",
+	400: "Regular",
+	500: "Medium",
+	600: "SemiBold",
+	700: "Bold",
+	800: "ExtraBold",
+	900: "Black",
+}
+
+// Style bits of the fsSelection field of the OS/2 table and of the macStyle
+// field of the head table
+const (
+	selectionItalic  = 1 << 0
+	selectionBold    = 1 << 5
+	selectionRegular = 1 << 6
+	macStyleBold     = 1 << 0
+	macStyleItalic   = 1 << 1
+)
+
+// instanceSubfamily returns the subfamily name of the instance of a font at a
+// location, which is the name of the named instance at that location or else
+// the name of its weight. Italic fonts keep their italic style.
+func instanceSubfamily(font *sfnt.Font, axes []Axis, instances []Instance, location map[string]float64) string {
+	at := func(axis Axis) float64 {
+		v, found := location[axis.Tag]
+		if !found {
+			return axis.Default
+		}
+		return math.Max(axis.Min, math.Min(axis.Max, v))
+	}
+	subfamily := ""
+	for _, instance := range instances {
+		matches := true
+		for _, axis := range axes {
+			matches = matches && instance.Coordinates[axis.Tag] == at(axis)
+		}
+		if matches {
+			subfamily = font.Name(instance.SubfamilyNameID)
+			break
+		}
+	}
+	if subfamily == "" {
+		weight := 400
+		for _, axis := range axes {
+			if axis.Tag == "wght" {
+				weight = round(at(axis))
+			}
+		}
+		subfamily = weightNames[weight]
+		if subfamily == "" {
+			subfamily = strconv.Itoa(weight)
+		}
+	}
+	switch {
+	case !isItalic(font) || strings.Contains(subfamily, "Italic"):
+		return subfamily
+	case subfamily == "Regular":
+		return "Italic"
+	default:
+		return subfamily + " Italic"
+	}
+}
+
+// isItalic reports whether the style bits of the OS/2 or head table of a font
+// are set to italic.
+func isItalic(font *sfnt.Font) bool {
+	if os2 := font.Tables["OS/2"]; len(os2) >= 64 && binary.BigEndian.Uint16(os2[62:])&selectionItalic != 0 {
+		return true
+	}
+	head := font.Tables["head"]
+	return len(head) >= 46 && binary.BigEndian.Uint16(head[44:])&macStyleItalic != 0
+}
+
+// variationNameIDs returns the name ids that the fvar and STAT tables refer
+// to, i.e. the names of axes, named instances and axis values.
+func variationNameIDs(fvar []byte, stat []byte) (ids []uint16, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed fvar or STAT table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	if fvar != nil {
+		axesOffset := int(binary.BigEndian.Uint16(fvar[4:]))
+		axisCount := int(binary.BigEndian.Uint16(fvar[8:]))
+		axisSize := int(binary.BigEndian.Uint16(fvar[10:]))
+		instanceCount := int(binary.BigEndian.Uint16(fvar[12:]))
+		instanceSize := int(binary.BigEndian.Uint16(fvar[14:]))
+		for i := 0; i < axisCount; i++ {
+			ids = append(ids, binary.BigEndian.Uint16(fvar[axesOffset+axisSize*i+18:]))
+		}
+		instancesOffset := axesOffset + axisSize*axisCount
+		for i := 0; i < instanceCount; i++ {
+			record := instancesOffset + instanceSize*i
+			ids = append(ids, binary.BigEndian.Uint16(fvar[record:]))
+			// Instance records may end with the id of a PostScript name
+			if instanceSize >= 4*axisCount+6 {
+				ids = append(ids, binary.BigEndian.Uint16(fvar[record+4+4*axisCount:]))
+			}
+		}
+	}
+	if stat != nil {
+		axisSize := int(binary.BigEndian.Uint16(stat[4:]))
+		axisCount := int(binary.BigEndian.Uint16(stat[6:]))
+		axesOffset := int(binary.BigEndian.Uint32(stat[8:]))
+		valueCount := int(binary.BigEndian.Uint16(stat[12:]))
+		valuesOffset := int(binary.BigEndian.Uint32(stat[14:]))
+		// Version 1.1 adds the name of the default instance
+		if binary.BigEndian.Uint16(stat[2:]) >= 1 {
+			ids = append(ids, binary.BigEndian.Uint16(stat[18:]))
+		}
+		for i := 0; i < axisCount; i++ {
+			ids = append(ids, binary.BigEndian.Uint16(stat[axesOffset+axisSize*i+4:]))
+		}
+		for i := 0; i < valueCount; i++ {
+			value := valuesOffset + int(binary.BigEndian.Uint16(stat[valuesOffset+2*i:]))
+			// All formats of axis values have the name at the same position
+			ids = append(ids, binary.BigEndian.Uint16(stat[value+6:]))
+		}
+	}
+	// Ids below 256 are predefined and never only refer to variations
+	ids = slices.DeleteFunc(ids, func(id uint16) bool { return id < 256 })
+	return ids, nil
+}
+
+// encodeName encodes a name for a platform. Macintosh names are encoded as
+// Latin-1, like sfnt.NameRecord decodes them.
+func encodeName(platformID uint16, name string) []byte {
+	var out []byte
+	if platformID == sfnt.PlatformMacintosh {
+		for _, r := range name {
+			if r > 0xFF {
+				r = '?'
+			}
+			out = append(out, byte(r))
+		}
+		return out
+	}
+	for _, unit := range utf16.Encode([]rune(name)) {
+		out = binary.BigEndian.AppendUint16(out, unit)
+	}
+	return out
+}
+
+// buildInstanceNames builds the name table of the instance of a font with the
+// given subfamily name. The family, subfamily, unique, full and PostScript
+// names are replaced, using the style linking of the legacy names for regular, italic,
+// bold and bold italic subfamilies and the typographic names otherwise, and
+// the names of the variations are removed.
+func buildInstanceNames(font *sfnt.Font, subfamily string) ([]byte, error) {
+	records, err := font.Names()
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := variationNameIDs(font.Tables["fvar"], font.Tables["STAT"])
+	if err != nil {
+		return nil, err
+	}
+	family := font.Name(sfnt.NameTypographicFamily)
+	if family == "" {
+		family = font.Name(sfnt.NameFamily)
+	}
+	prefix := font.Name(sfnt.NameVariationsPrefix)
+	if prefix == "" {
+		prefix = strings.ReplaceAll(family, " ", "")
+	}
+	names := map[uint16]string{
+		sfnt.NameFamily:     family,
+		sfnt.NameSubfamily:  subfamily,
+		sfnt.NameFull:       family + " " + subfamily,
+		sfnt.NamePostScript: prefix + "-" + strings.ReplaceAll(subfamily, " ", ""),
+	}
+	// Unique ids usually contain the full or PostScript name
+	if uniqueID := font.Name(sfnt.NameUniqueID); uniqueID != "" {
+		names[sfnt.NameUniqueID] = uniqueID
+		for _, nameID := range []uint16{sfnt.NameFull, sfnt.NamePostScript} {
+			if old := font.Name(nameID); old != "" {
+				names[sfnt.NameUniqueID] = strings.ReplaceAll(names[sfnt.NameUniqueID], old, names[nameID])
+			}
+		}
+		if names[sfnt.NameUniqueID] == uniqueID {
+			names[sfnt.NameUniqueID] += ";" + names[sfnt.NamePostScript]
+		}
+	}
+	if !slices.Contains([]string{"Regular", "Italic", "Bold", "Bold Italic"}, subfamily) {
+		legacy, style := strings.TrimSuffix(subfamily, " Italic"), "Regular"
+		if legacy != subfamily {
+			style = "Italic"
+		}
+		names[sfnt.NameFamily] = family + " " + legacy
+		names[sfnt.NameSubfamily] = style
+		names[sfnt.NameTypographicFamily] = family
+		names[sfnt.NameTypographicSubfamily] = subfamily
+	}
+	dropped = append(dropped, sfnt.NameTypographicFamily, sfnt.NameTypographicSubfamily, sfnt.NameCompatibleFull, sfnt.NameVariationsPrefix)
+
+	// The new names are written for every platform and language of the
+	// family name
+	type language struct{ platformID, encodingID, languageID uint16 }
+	var languages []language
+	var retained []sfnt.NameRecord
+	for _, r := range records {
+		if r.NameID == sfnt.NameFamily {
+			languages = append(languages, language{r.PlatformID, r.EncodingID, r.LanguageID})
+		}
+		if _, replaced := names[r.NameID]; !replaced && !slices.Contains(dropped, r.NameID) {
+			retained = append(retained, r)
+		}
+	}
+	for _, l := range languages {
+		for nameID, name := range names {
+			retained = append(retained, sfnt.NameRecord{
+				PlatformID: l.platformID,
+				EncodingID: l.encodingID,
+				LanguageID: l.languageID,
+				NameID:     nameID,
+				Value:      encodeName(l.platformID, name),
+			})
+		}
+	}
+	return sfnt.BuildName(retained), nil
+}
+
+// setStyleBits sets the bold and regular bits of the OS/2 and head tables of
+// an instance to its subfamily name, keeping the italic bits.
+func setStyleBits(tables map[string][]byte, subfamily string) {
+	bold := subfamily == "Bold" || subfamily == "Bold Italic"
+	if os2 := tables["OS/2"]; len(os2) >= 64 {
+		os2 = slices.Clone(os2)
+		selection := binary.BigEndian.Uint16(os2[62:]) &^ (selectionBold | selectionRegular)
+		switch {
+		case bold:
+			selection |= selectionBold
+		case selection&selectionItalic == 0:
+			selection |= selectionRegular
+		}
+		binary.BigEndian.PutUint16(os2[62:], selection)
+		tables["OS/2"] = os2
+	}
+	if head := tables["head"]; len(head) >= 46 {
+		head = slices.Clone(head)
+		macStyle := binary.BigEndian.Uint16(head[44:]) &^ macStyleBold
+		if bold {
+			macStyle |= macStyleBold
+		}
+		binary.BigEndian.PutUint16(head[44:], macStyle)
+		tables["head"] = head
+	}
+}
@@ -0,0 +1,93 @@
+package instancer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Flags used by item variation stores and the tables that refer to them
+const (
+	noVariationIndex   = 0xFFFF
+	longWords          = 0x8000
+	wordDeltaCountMask = 0x7FFF
+)
+
+// variationRegion is a region of the design space that a delta applies to.
+type variationRegion struct {
+	start, peak, end []float64
+}
+
+// itemVariationStore is a parsed item variation store, which holds the deltas
+// of values of tables such as GDEF and GPOS.
+type itemVariationStore struct {
+	data        []byte
+	regions     []variationRegion
+	dataOffsets []int
+}
+
+func parseItemVariationStore(data []byte, axisCount int) (*itemVariationStore, error) {
+	s := &itemVariationStore{data: data}
+	regionList := data[binary.BigEndian.Uint32(data[2:]):]
+	if n := int(binary.BigEndian.Uint16(regionList)); n != axisCount {
+		return nil, fmt.Errorf("%w: item variation store has %d axes, fvar has %d", sfnt.ErrInvalidFont, n, axisCount)
+	}
+	regionCount := int(binary.BigEndian.Uint16(regionList[2:]))
+	for i := 0; i < regionCount; i++ {
+		coords := readTuple(regionList[4+6*axisCount*i:], 3*axisCount)
+		var region variationRegion
+		for j := 0; j < axisCount; j++ {
+			region.start = append(region.start, coords[3*j])
+			region.peak = append(region.peak, coords[3*j+1])
+			region.end = append(region.end, coords[3*j+2])
+		}
+		s.regions = append(s.regions, region)
+	}
+	dataCount := int(binary.BigEndian.Uint16(data[6:]))
+	for i := 0; i < dataCount; i++ {
+		s.dataOffsets = append(s.dataOffsets, int(binary.BigEndian.Uint32(data[8+4*i:])))
+	}
+	return s, nil
+}
+
+// delta calculates the delta of an item at the given normalized coordinates.
+func (s *itemVariationStore) delta(outer int, inner int, coords []float64) float64 {
+	if outer == noVariationIndex {
+		return 0
+	}
+	data := s.data[s.dataOffsets[outer]:]
+	itemCount := int(binary.BigEndian.Uint16(data))
+	wordDeltaCount := binary.BigEndian.Uint16(data[2:])
+	regionIndexCount := int(binary.BigEndian.Uint16(data[4:]))
+	if inner >= itemCount {
+		return 0
+	}
+	wordSize, byteSize := 2, 1
+	if wordDeltaCount&longWords != 0 {
+		wordSize, byteSize = 4, 2
+	}
+	wordCount := int(wordDeltaCount & wordDeltaCountMask)
+	rowSize := wordCount*wordSize + (regionIndexCount-wordCount)*byteSize
+	row := data[6+2*regionIndexCount+inner*rowSize:]
+	delta := 0.0
+	for i := 0; i < regionIndexCount; i++ {
+		size := byteSize
+		if i < wordCount {
+			size = wordSize
+		}
+		var d int
+		switch size {
+		case 4:
+			d = int(int32(binary.BigEndian.Uint32(row)))
+		case 2:
+			d = int(int16(binary.BigEndian.Uint16(row)))
+		default:
+			d = int(int8(row[0]))
+		}
+		row = row[size:]
+		region := s.regions[binary.BigEndian.Uint16(data[6+2*i:])]
+		delta += tupleScalar(coords, region.peak, region.start, region.end) * float64(d)
+	}
+	return delta
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lyxell/font.delivery/api/fontaxes"
+	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+)
+
+// displays are the values of the display parameter of the css2 endpoint,
+// which sets the font-display of the rules.
+var displays = []string{"auto", "block", "swap", "fallback", "optional"}
+
+// rawQueryKey is the context key of the query of a css2 request as it was
+// received.
+type rawQueryKey struct{}
+
+// css2Handler wraps the handler of the css2 endpoint. The css2 query grammar
+// separates axis values with semicolons, which the query parser of net/http
+// treats as invalid separators and the server warns about. The handler is
+// therefore passed through http.AllowQuerySemicolons, which silences the
+// warning, and parses the query as it was received.
+func css2Handler(handler http.HandlerFunc) http.Handler {
+	allowed := http.AllowQuerySemicolons(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rawQueryKey{}, r.URL.RawQuery)))
+	})
+}
+
+// parseRawQuery parses a query that is separated by ampersands only.
+func parseRawQuery(rawQuery string) (url.Values, error) {
+	query := make(url.Values)
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		query[key] = append(query[key], value)
+	}
+	return query, nil
+}
+
+// css2Family is a family requested from the css2 endpoint, along with the
+// values of its axes for each requested style, e.g.
+// "Roboto:ital,wght@0,400;1,700" requests Roboto in normal 400 and italic
+// 700.
+type css2Family struct {
+	name   string
+	axes   []string
+	tuples [][]string
+}
+
+// parseCSS2Family parses the value of a family parameter. A family without
+// axes requests its normal 400 style.
+func parseCSS2Family(value string) (css2Family, error) {
+	name, spec, found := strings.Cut(value, ":")
+	request := css2Family{name: strings.TrimSpace(name)}
+	if request.name == "" {
+		return css2Family{}, errors.New("missing family name")
+	}
+	if !found {
+		request.tuples = [][]string{{}}
+		return request, nil
+	}
+	axes, tuples, found := strings.Cut(spec, "@")
+	if !found {
+		return css2Family{}, fmt.Errorf("%s: missing axis values", value)
+	}
+	request.axes = strings.Split(axes, ",")
+	for i, axis := range request.axes {
+		if slices.Contains(request.axes[:i], axis) {
+			return css2Family{}, fmt.Errorf("%s: duplicate axis %s", value, axis)
+		}
+	}
+	for _, tuple := range strings.Split(tuples, ";") {
+		values := strings.Split(tuple, ",")
+		if len(values) != len(request.axes) {
+			return css2Family{}, fmt.Errorf("%s: %s does not have one value per axis", value, tuple)
+		}
+		request.tuples = append(request.tuples, values)
+	}
+	return request, nil
+}
+
+// axisValue is a value of an axis, or a range of values such as "100..900".
+type axisValue struct {
+	min float32
+	max float32
+}
+
+func parseAxisValue(s string) (axisValue, error) {
+	first, last, isRange := strings.Cut(s, "..")
+	min, err := strconv.ParseFloat(first, 32)
+	if err != nil {
+		return axisValue{}, err
+	}
+	if !isRange {
+		return axisValue{float32(min), float32(min)}, nil
+	}
+	max, err := strconv.ParseFloat(last, 32)
+	if err != nil {
+		return axisValue{}, err
+	}
+	if max < min {
+		return axisValue{}, fmt.Errorf("range %s is reversed", s)
+	}
+	return axisValue{float32(min), float32(max)}, nil
+}
+
+// css returns the value as a CSS descriptor value with the given unit.
+func (v axisValue) css(unit string) string {
+	if v.min == v.max {
+		return fmt.Sprintf("%v%s", v.min, unit)
+	}
+	return fmt.Sprintf("%v%s %v%s", v.min, unit, v.max, unit)
+}
+
+// fontFace is a font of a family matched to a style requested from the css2
+// endpoint.
+type fontFace struct {
+	font builder.FontFamilyFont
+	// instance is the weight of the static instance that is served for the
+	// style, or 0 if the font itself is served
+	instance int
+	weight   axisValue
+	// axes are the axes whose values or ranges were requested and that
+	// have descriptors, see fontaxes.CSS
+	axes []fontaxes.Axis
+}
+
+// matchFont returns the font of a family that serves the given axis values.
+// Variable fonts serve every value within the ranges of their axes, while
+// static fonts only serve their own weight.
+func matchFont(family builder.FontFamily, axes []string, values []string) (fontFace, error) {
+	style := "normal"
+	face := fontFace{weight: axisValue{400, 400}}
+	variable := false
+	for _, axis := range family.Axes {
+		variable = variable || axis.Tag == "wght"
+	}
+	for i, tag := range axes {
+		value, err := parseAxisValue(values[i])
+		if err != nil {
+			return fontFace{}, fmt.Errorf("%s: invalid %s value %s", family.Name, tag, values[i])
+		}
+		if tag == "ital" {
+			if value.min != value.max || (value.min != 0 && value.min != 1) {
+				return fontFace{}, fmt.Errorf("%s: invalid ital value %s", family.Name, values[i])
+			}
+			if value.min == 1 {
+				style = "italic"
+			}
+			continue
+		}
+		j := slices.IndexFunc(family.Axes, func(axis builder.FontFamilyAxis) bool {
+			return axis.Tag == tag
+		})
+		if j < 0 && tag != "wght" {
+			return fontFace{}, fmt.Errorf("%s: no axis %s", family.Name, tag)
+		}
+		if j >= 0 && (value.min < family.Axes[j].MinValue || value.max > family.Axes[j].MaxValue) {
+			return fontFace{}, fmt.Errorf("%s: %s value %s is out of range", family.Name, tag, values[i])
+		}
+		switch tag {
+		case "wght":
+			face.weight = value
+		case "wdth", "slnt":
+			face.axes = append(face.axes, fontaxes.Axis{Tag: tag, MinValue: value.min, MaxValue: value.max})
+		}
+	}
+	for _, font := range family.Fonts {
+		if font.Style != style {
+			continue
+		}
+		if variable {
+			face.font = font
+			if face.weight.min == face.weight.max && slices.Contains(font.Instances, int(face.weight.min)) {
+				face.instance = int(face.weight.min)
+			}
+			return face, nil
+		}
+		if face.weight.min == face.weight.max && float32(font.Weight) == face.weight.min {
+			face.font = font
+			return face, nil
+		}
+	}
+	return fontFace{}, fmt.Errorf("%s: no %s font of weight %s", family.Name, style, face.weight.css(""))
+}
+
+// fontFaceCSS generates a @font-face rule in the format of the css2 endpoint.
+// subset is left out of rules for text, which have no unicode-range.
+func fontFaceCSS(family builder.FontFamily, face fontFace, subset string, url string, display string) string {
+	var b strings.Builder
+	if subset != "" {
+		fmt.Fprintf(&b, "/* %s */\n", subset)
+	}
+	axes := face.axes
+	for _, axis := range family.Axes {
+		if axis.Tag == "ital" {
+			axes = append(axes, fontaxes.Axis{Tag: axis.Tag, MinValue: axis.MinValue, MaxValue: axis.MaxValue})
+		}
+	}
+	descriptors := fontaxes.CSS(face.font.Style, axes)
+	fmt.Fprintf(&b, "@font-face {\n  font-family: '%s';\n  font-style: %s;\n  font-weight: %s;\n", family.Name, descriptors.FontStyle, face.weight.css(""))
+	if descriptors.FontStretch != "" {
+		fmt.Fprintf(&b, "  font-stretch: %s;\n", descriptors.FontStretch)
+	}
+	if descriptors.FontVariationSettings != "" {
+		fmt.Fprintf(&b, "  font-variation-settings: %s;\n", descriptors.FontVariationSettings)
+	}
+	if display != "" {
+		fmt.Fprintf(&b, "  font-display: %s;\n", display)
+	}
+	fmt.Fprintf(&b, "  src: url('%s') format('woff2');\n", url)
+	if subset != "" {
+		fmt.Fprintf(&b, "  unicode-range: %s;\n", subsetting.BuildCSSString(subset))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// css2 generates the stylesheet for a css2 query, with one rule per requested
+// style and subset of each family. When text is requested, there is one rule
+// per style that refers to a font subsetted on demand to the text.
+func (s *Server) css2(query url.Values) (string, error) {
+	if len(query["family"]) == 0 {
+		return "", errors.New("missing family")
+	}
+	display := query.Get("display")
+	if display != "" && !slices.Contains(displays, display) {
+		return "", fmt.Errorf("invalid display %s", display)
+	}
+	text := query.Get("text")
+	fontsURL := fmt.Sprintf("%s/api/%s/fonts/", s.baseURL, s.apiVersion)
+
+	var rules []string
+	for _, value := range query["family"] {
+		request, err := parseCSS2Family(value)
+		if err != nil {
+			return "", err
+		}
+		entry, found := s.families[strings.ToLower(request.name)]
+		if !found {
+			return "", fmt.Errorf("unknown family %s", request.name)
+		}
+		for _, tuple := range request.tuples {
+			face, err := matchFont(entry.family, request.axes, tuple)
+			if err != nil {
+				return "", err
+			}
+			if text != "" {
+				// The subset of the file is ignored when it is subsetted
+				// on demand
+				name := entry.files[fileKey{face.font.Filename, face.instance, entry.shards[0], builder.FormatWOFF2}]
+				rules = append(rules, fontFaceCSS(entry.family, face, "", fontsURL+name+"?text="+url.QueryEscape(text), display))
+				continue
+			}
+			for _, shard := range entry.shards {
+				name := entry.files[fileKey{face.font.Filename, face.instance, shard, builder.FormatWOFF2}]
+				rules = append(rules, fontFaceCSS(entry.family, face, shard, fontsURL+name, display))
+			}
+		}
+	}
+	return strings.Join(rules, "\n"), nil
+}
+
+// serveCSS2 serves a stylesheet in the format of the Google Fonts css2 API,
+// e.g. for /css2?family=Inter:wght@400;700&display=swap.
+func (s *Server) serveCSS2(w http.ResponseWriter, r *http.Request) {
+	query, err := parseRawQuery(r.Context().Value(rawQueryKey{}).(string))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	css, err := s.css2(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write([]byte(css))
+}
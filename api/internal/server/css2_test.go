@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCSS2TestServer(t *testing.T) *Server {
+	fontsDir := t.TempDir()
+	families := []builder.FontFamily{
+		{
+			Id:   "inter",
+			Name: "Inter",
+			Fonts: []builder.FontFamilyFont{
+				{Filename: "Inter[opsz,wght].ttf", Style: "normal", Weight: 400, Instances: []int{700}},
+				{Filename: "Inter-Italic[opsz,wght].ttf", Style: "italic", Weight: 400},
+			},
+			Subsets: []string{"cyrillic", "latin"},
+			Axes: []builder.FontFamilyAxis{
+				{Tag: "opsz", MinValue: 14, MaxValue: 32},
+				{Tag: "wght", MinValue: 100, MaxValue: 900},
+			},
+			Dir: fontsDir,
+		},
+		{
+			Id:   "open-sans",
+			Name: "Open Sans",
+			Fonts: []builder.FontFamilyFont{
+				{Filename: "OpenSans-Regular.ttf", Style: "normal", Weight: 400},
+				{Filename: "OpenSans-Bold.ttf", Style: "normal", Weight: 700},
+			},
+			Subsets: []string{"latin"},
+			Dir:     fontsDir,
+		},
+		{
+			Id:    "roboto-flex",
+			Name:  "Roboto Flex",
+			Fonts: []builder.FontFamilyFont{{Filename: "RobotoFlex[slnt,wdth,wght].ttf", Style: "normal", Weight: 400}},
+			Axes: []builder.FontFamilyAxis{
+				{Tag: "slnt", MinValue: -10, MaxValue: 0},
+				{Tag: "wdth", MinValue: 25, MaxValue: 151},
+				{Tag: "wght", MinValue: 100, MaxValue: 1000},
+			},
+			Subsets: []string{"latin"},
+			Dir:     fontsDir,
+		},
+	}
+	for _, family := range families {
+		for _, font := range family.Fonts {
+			require.NoError(t, os.WriteFile(filepath.Join(fontsDir, font.Filename), nil, 0o644))
+		}
+	}
+	dirs := builder.OutputDirs{Fonts: filepath.Join(t.TempDir(), "fonts")}
+	plan, err := builder.NewPlan(families, nil, nil, []string{"latin", "cyrillic"}, []string{builder.FormatWOFF2}, false, dirs)
+	require.NoError(t, err)
+	return New(plan, t.TempDir(), "v2", "https://font.delivery", t.TempDir())
+}
+
+func TestServeCSS2(t *testing.T) {
+	handler := newCSS2TestServer(t).Handler()
+
+	response := get(t, handler, "/api/v2/css2?family=Open+Sans:wght@700&display=swap")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, "text/css; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.Equal(t, `/* latin */
+@font-face {
+  font-family: 'Open Sans';
+  font-style: normal;
+  font-weight: 700;
+  font-display: swap;
+  src: url('https://font.delivery/api/v2/fonts/open-sans_latin_700_normal.woff2') format('woff2');
+  unicode-range: `+subsetting.BuildCSSString("latin")+`;
+}
+`, response.Body.String())
+
+	// Subsets are in the order of the catalog, and static instances are
+	// served for their weights
+	response = get(t, handler, "/css2?family=Inter:ital,wght@0,400;0,700;1,100..900&family=Open+Sans")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	css := response.Body.String()
+	assert.Equal(t, 7, strings.Count(css, "@font-face"))
+	assert.Regexp(t, `^/\* latin \*/\n(?s:.*)\n/\* cyrillic \*/\n`, css)
+	assert.Contains(t, css, "font-weight: 400;\n  src: url('https://font.delivery/api/v2/fonts/inter_latin_100-900_normal_opsz14-32.woff2')")
+	assert.Contains(t, css, "font-weight: 700;\n  src: url('https://font.delivery/api/v2/fonts/inter_latin_700_normal.woff2')")
+	assert.Contains(t, css, "font-style: italic;\n  font-weight: 100 900;\n  src: url('https://font.delivery/api/v2/fonts/inter_cyrillic_100-900_italic_opsz14-32.woff2')")
+	assert.Contains(t, css, "open-sans_latin_400_normal.woff2")
+
+	// Requested slnt and wdth ranges are described like in the stylesheets
+	// of the builder
+	response = get(t, handler, "/css2?family=Roboto+Flex:slnt,wdth,wght@-10..0,75..100,400")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Contains(t, response.Body.String(), "font-style: oblique 0deg 10deg;\n  font-weight: 400;\n  font-stretch: 75% 100%;\n")
+
+	// Text is subsetted on demand
+	response = get(t, handler, "/css2?family=Inter&text=Hello%20World")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, `@font-face {
+  font-family: 'Inter';
+  font-style: normal;
+  font-weight: 400;
+  src: url('https://font.delivery/api/v2/fonts/inter_latin_100-900_normal_opsz14-32.woff2?text=Hello+World') format('woff2');
+}
+`, response.Body.String())
+}
+
+func TestServeCSS2Invalid(t *testing.T) {
+	handler := newCSS2TestServer(t).Handler()
+	for _, target := range []string{
+		"/css2",
+		"/css2?family=Unknown",
+		"/css2?family=Inter&display=eventually",
+		"/css2?family=Inter:wght",
+		"/css2?family=Inter:wght@1000",
+		"/css2?family=Inter:wght@900..100",
+		"/css2?family=Inter:ital,wght@1",
+		"/css2?family=Inter:wdth@100",
+		"/css2?family=Open+Sans:wght@500",
+		"/css2?family=Open+Sans:wght@400..700",
+		"/css2?family=Open+Sans:ital@1",
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(t, handler, target).Code, target)
+	}
+}
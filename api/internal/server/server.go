@@ -0,0 +1,387 @@
+// Package server serves the catalog generated by the builder and subsets fonts
+// on demand to the code points that are requested.
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/subsetting"
+)
+
+// maxCodePoints is the maximum number of code points a font can be subsetted
+// to on demand.
+const maxCodePoints = 0x10000
+
+// hashedFilePattern matches the names of the content-addressed font files of
+// the builder, which never change.
+var hashedFilePattern = regexp.MustCompile(`\.[0-9a-f]{16}\.[a-z0-9]+$`)
+
+// encodings are the content encodings of the precompressed siblings of the
+// JSON and CSS files of the catalog, in order of preference.
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// contentTypes are the content types of the font formats, keyed by format.
+var contentTypes = map[string]string{
+	builder.FormatWOFF2: "font/woff2",
+	builder.FormatWOFF:  "font/woff",
+	builder.FormatTTF:   "font/ttf",
+}
+
+// source is the font that a font file of the catalog is generated from.
+type source struct {
+	family builder.FontFamily
+	font   builder.FontFamilyFont
+	// weight is the weight of the static instance the file is generated
+	// from, or 0 if it is generated from the font itself
+	weight int
+	format string
+}
+
+// fileKey identifies a font file of a family in the catalog.
+type fileKey struct {
+	font string
+	// weight is the weight of the static instance the file is generated
+	// from, or 0 if it is generated from the font itself
+	weight int
+	subset string
+	format string
+}
+
+// catalogFamily is a family of the catalog along with its font files.
+type catalogFamily struct {
+	family builder.FontFamily
+	// shards are the subsets the family is published in, with sharded
+	// subsets split into their shards, in the order of the catalog
+	shards []string
+	// files are the names of the font files of the family
+	files map[fileKey]string
+}
+
+// Server serves the files of the catalog as they were generated by the
+// builder. Font files that are requested with the text or unicodes query
+// parameters are instead subsetted on demand from the source fonts and
+// cached on disk. Stylesheets for the families of the catalog are served in
+// the format of the Google Fonts css2 API.
+type Server struct {
+	outputDir  string
+	apiVersion string
+	baseURL    string
+	cacheDir   string
+	// sources are the sources of the font files of the catalog, keyed by
+	// file name
+	sources map[string]source
+	// families are the families of the catalog, keyed by lowercase name
+	families map[string]*catalogFamily
+	// hashes are the hashes of the source fonts, keyed by path
+	hashes sync.Map
+}
+
+// sourceHashEntry is the hash of a source font along with the modification
+// time and size of the font when it was hashed.
+type sourceHashEntry struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// New creates a server for the catalog of a build plan. outputDir is the
+// output directory of the builder and cacheDir is where fonts that are
+// subsetted on demand are cached. Stylesheets refer to font files below
+// baseURL, or below the server itself if it is empty.
+func New(plan *builder.Plan, outputDir string, apiVersion string, baseURL string, cacheDir string) *Server {
+	s := &Server{
+		outputDir:  outputDir,
+		apiVersion: apiVersion,
+		baseURL:    baseURL,
+		cacheDir:   cacheDir,
+		sources:    make(map[string]source),
+		families:   make(map[string]*catalogFamily),
+	}
+	for _, planned := range plan.Families {
+		entry := &catalogFamily{family: planned.Family, files: make(map[fileKey]string)}
+		for _, output := range planned.Outputs {
+			if output.Font == "" {
+				continue
+			}
+			i := slices.IndexFunc(planned.Family.Fonts, func(font builder.FontFamilyFont) bool {
+				return font.Filename == output.Font
+			})
+			name := filepath.Base(output.Path)
+			s.sources[name] = source{
+				family: planned.Family,
+				font:   planned.Family.Fonts[i],
+				weight: output.Weight,
+				format: output.Format,
+			}
+			entry.files[fileKey{output.Font, output.Weight, output.Subset, output.Format}] = name
+			if !slices.Contains(entry.shards, output.Subset) {
+				entry.shards = append(entry.shards, output.Subset)
+			}
+		}
+		s.families[strings.ToLower(planned.Family.Name)] = entry
+	}
+	return s
+}
+
+// Handler returns the handler that serves the catalog.
+func (s *Server) Handler() http.Handler {
+	static := s.precompressed(http.FileServer(http.Dir(s.outputDir)))
+	mux := http.NewServeMux()
+	mux.Handle("GET /", static)
+	mux.HandleFunc(fmt.Sprintf("GET /api/%s/fonts/{file}", s.apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("text") && !query.Has("unicodes") {
+			if hashedFilePattern.MatchString(r.PathValue("file")) {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			}
+			static.ServeHTTP(w, r)
+			return
+		}
+		s.serveSubset(w, r, query)
+	})
+	// The stylesheets are also served at the path of the css2 API, so
+	// that its URLs only need a change of hostname
+	mux.Handle(fmt.Sprintf("GET /api/%s/css2", s.apiVersion), css2Handler(s.serveCSS2))
+	mux.Handle("GET /css2", css2Handler(s.serveCSS2))
+	return mux
+}
+
+// precompressed wraps a handler of the files of the catalog so that files
+// with precompressed siblings are served compressed to clients that accept
+// their encoding, instead of being compressed on every request.
+func (s *Server) precompressed(static http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean(r.URL.Path)
+		for _, encoding := range encodings {
+			f, err := http.Dir(s.outputDir).Open(name + encoding.ext)
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+			stat, err := f.Stat()
+			if err != nil || stat.IsDir() {
+				continue
+			}
+			w.Header().Set("Vary", "Accept-Encoding")
+			if !acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding.name) {
+				continue
+			}
+			contentType := mime.TypeByExtension(path.Ext(name))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Encoding", encoding.name)
+			http.ServeContent(w, r, "", stat.ModTime(), f)
+			return
+		}
+		static.ServeHTTP(w, r)
+	})
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts a content
+// encoding, i.e. lists it without a quality of zero.
+func acceptsEncoding(header string, encoding string) bool {
+	for _, accepted := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(accepted, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		quality, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		q, err := strconv.ParseFloat(quality, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+// source returns the source of a font file of the catalog. Content-addressed
+// files that exist have the source of the file they are a copy of, e.g.
+// roboto_latin_400_normal.0123456789abcdef.woff2 that of
+// roboto_latin_400_normal.woff2, since their names change with every build.
+func (s *Server) source(name string) (source, bool) {
+	if src, found := s.sources[name]; found {
+		return src, true
+	}
+	loc := hashedFilePattern.FindStringIndex(name)
+	if loc == nil {
+		return source{}, false
+	}
+	// The hash is preceded by a dot and followed by the extension
+	src, found := s.sources[name[:loc[0]]+name[loc[0]+17:]]
+	if !found {
+		return source{}, false
+	}
+	fontsDir := filepath.Join(s.outputDir, "api", s.apiVersion, "fonts")
+	if _, err := os.Stat(filepath.Join(fontsDir, name)); err != nil {
+		return source{}, false
+	}
+	return src, true
+}
+
+// serveSubset serves a font file subsetted to the requested code points.
+func (s *Server) serveSubset(w http.ResponseWriter, r *http.Request, query url.Values) {
+	src, found := s.source(r.PathValue("file"))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	runes, err := requestedRunes(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, hash, err := s.subset(src, runes)
+	if err != nil {
+		log.Printf("error: %s: %v", r.URL, err)
+		http.Error(w, "failed to subset font", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypes[src.format])
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// requestedRunes returns the code points requested with the text and unicodes
+// query parameters, in ascending order.
+func requestedRunes(query url.Values) ([]rune, error) {
+	var runes []rune
+	for _, text := range query["text"] {
+		runes = append(runes, []rune(text)...)
+	}
+	for _, unicodes := range query["unicodes"] {
+		unicodeRanges, err := subsetting.ParseRanges(unicodes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unicodes: %w", err)
+		}
+		for _, r := range unicodeRanges {
+			last := r[len(r)-1]
+			if len(runes)+int(last-r[0]) >= maxCodePoints {
+				return nil, fmt.Errorf("more than %d code points requested", maxCodePoints)
+			}
+			for c := r[0]; c <= last; c++ {
+				runes = append(runes, c)
+			}
+		}
+	}
+	slices.Sort(runes)
+	runes = slices.Compact(runes)
+	if len(runes) == 0 {
+		return nil, errors.New("no code points requested")
+	}
+	if len(runes) > maxCodePoints {
+		return nil, fmt.Errorf("more than %d code points requested", maxCodePoints)
+	}
+	return runes, nil
+}
+
+// subset returns a font subsetted to the given code points along with its
+// hash. Subsetted fonts are cached on disk by a hash of the source font and
+// the requested code points, so that a changed source font is subsetted
+// again.
+func (s *Server) subset(src source, runes []rune) ([]byte, string, error) {
+	sourceHash, err := s.sourceHash(filepath.Join(src.family.Dir, src.font.Filename))
+	if err != nil {
+		return nil, "", err
+	}
+	key := sha256.New()
+	fmt.Fprintf(key, "%s\n%d\n%s\n", sourceHash, src.weight, src.format)
+	for _, r := range runes {
+		fmt.Fprintf(key, "%X\n", r)
+	}
+	hash := hex.EncodeToString(key.Sum(nil))
+	cachePath := filepath.Join(s.cacheDir, hash[:2], hash+"."+src.format)
+
+	data, err := os.ReadFile(cachePath)
+	if err == nil {
+		return data, hash, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+	data, err = builder.GenerateFont(src.family, src.font, src.weight, runes, src.format)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writeAtomic(cachePath, data); err != nil {
+		return nil, "", err
+	}
+	return data, hash, nil
+}
+
+// sourceHash returns the hash of a source font. The hash is kept until the
+// modification time or size of the font changes, so that a changed font is
+// hashed again.
+func (s *Server) sourceHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if entry, found := s.hashes.Load(path); found {
+		entry := entry.(sourceHashEntry)
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			return entry.hash, nil
+		}
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	s.hashes.Store(path, sourceHashEntry{modTime: info.ModTime(), size: info.Size(), hash: hash})
+	return hash, nil
+}
+
+// writeAtomic writes a file through a temporary file, so that concurrent
+// requests never read a partially written file.
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/builder"
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func newTestServer(t *testing.T) (*Server, string) {
+	fontsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(fontsDir, "Go-Regular.ttf"), goregular.TTF, 0o644))
+	families := []builder.FontFamily{{
+		Id:      "go",
+		Name:    "Go",
+		Fonts:   []builder.FontFamilyFont{{Filename: "Go-Regular.ttf", Style: "normal", Weight: 400}},
+		Subsets: []string{"latin"},
+		Dir:     fontsDir,
+	}}
+	outputDir := t.TempDir()
+	dirs := builder.OutputDirs{Fonts: filepath.Join(outputDir, "api", "v2", "fonts")}
+	plan, err := builder.NewPlan(families, nil, nil, []string{"latin"}, []string{builder.FormatWOFF2, builder.FormatTTF}, false, dirs)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dirs.Fonts, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dirs.Fonts, "go_latin_400_normal.woff2"), []byte("static"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dirs.Fonts, "go_latin_400_normal.0123456789abcdef.woff2"), []byte("hashed"), 0o644))
+	cacheDir := t.TempDir()
+	return New(plan, outputDir, "v2", "", cacheDir), cacheDir
+}
+
+func get(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+	return recorder
+}
+
+func TestServeStatic(t *testing.T) {
+	s, _ := newTestServer(t)
+	response := get(t, s.Handler(), "/api/v2/fonts/go_latin_400_normal.woff2")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "static", response.Body.String())
+	assert.Empty(t, response.Header().Get("Cache-Control"))
+
+	// Content-addressed files never change
+	response = get(t, s.Handler(), "/api/v2/fonts/go_latin_400_normal.0123456789abcdef.woff2")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "hashed", response.Body.String())
+	assert.Equal(t, "public, max-age=31536000, immutable", response.Header().Get("Cache-Control"))
+}
+
+func TestServePrecompressed(t *testing.T) {
+	s, _ := newTestServer(t)
+	indexDir := filepath.Join(s.outputDir, "api", "v2")
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "fonts.json"), []byte("[]"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "fonts.json.gz"), []byte("gzipped"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "fonts.json.br"), []byte("brotli"), 0o644))
+	handler := s.Handler()
+
+	tests := []struct {
+		acceptEncoding string
+		body           string
+		encoding       string
+	}{
+		{"", "[]", ""},
+		{"gzip, deflate", "gzipped", "gzip"},
+		{"gzip, deflate, br", "brotli", "br"},
+		{"gzip, br;q=0", "gzipped", "gzip"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/api/v2/fonts.json", nil)
+		request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, tt.body, recorder.Body.String(), tt.acceptEncoding)
+		assert.Equal(t, tt.encoding, recorder.Header().Get("Content-Encoding"), tt.acceptEncoding)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), tt.acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"), tt.acceptEncoding)
+	}
+}
+
+func TestServeSubset(t *testing.T) {
+	s, cacheDir := newTestServer(t)
+	handler := s.Handler()
+
+	response := get(t, handler, "/api/v2/fonts/go_latin_400_normal.ttf?text=ACME&unicodes=U%2B0030-0032")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, "font/ttf", response.Header().Get("Content-Type"))
+	font, err := sfnt.Parse(response.Body.Bytes())
+	require.NoError(t, err)
+	cmap, err := font.CharacterMap()
+	require.NoError(t, err)
+	assert.Len(t, cmap, 7)
+	for _, r := range "ACME012" {
+		assert.Contains(t, cmap, r)
+	}
+
+	// The subsetted font is cached by its hash
+	etag := response.Header().Get("ETag")
+	cached, err := filepath.Glob(filepath.Join(cacheDir, "*", "*.ttf"))
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	assert.Equal(t, `"`+strings.TrimSuffix(filepath.Base(cached[0]), ".ttf")+`"`, etag)
+	require.NoError(t, os.WriteFile(cached[0], []byte("cached"), 0o644))
+	response = get(t, handler, "/api/v2/fonts/go_latin_400_normal.ttf?text=MECA012")
+	assert.Equal(t, "cached", response.Body.String())
+
+	response = get(t, handler, "/api/v2/fonts/go_latin_400_normal.woff2?text=A")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "font/woff2", response.Header().Get("Content-Type"))
+	assert.Equal(t, "wOF2", response.Body.String()[:4])
+
+	// Content-addressed files are subsetted from the source of their file
+	response = get(t, handler, "/api/v2/fonts/go_latin_400_normal.0123456789abcdef.woff2?text=A")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "wOF2", response.Body.String()[:4])
+	response = get(t, handler, "/api/v2/fonts/go_latin_400_normal.fedcba9876543210.woff2?text=A")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestSourceHash(t *testing.T) {
+	s, _ := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "Go-Regular.ttf")
+	require.NoError(t, os.WriteFile(path, []byte("font"), 0o644))
+	hash, err := s.sourceHash(path)
+	require.NoError(t, err)
+	cached, err := s.sourceHash(path)
+	require.NoError(t, err)
+	assert.Equal(t, hash, cached)
+
+	// A changed font is hashed again
+	require.NoError(t, os.WriteFile(path, []byte("changed font"), 0o644))
+	changed, err := s.sourceHash(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, changed)
+}
+
+func TestServeSubsetInvalid(t *testing.T) {
+	s, _ := newTestServer(t)
+	handler := s.Handler()
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/v2/fonts/unknown_latin_400_normal.woff2?text=A", http.StatusNotFound},
+		{"/api/v2/fonts/go_latin_400_normal.woff2?text=", http.StatusBadRequest},
+		{"/api/v2/fonts/go_latin_400_normal.woff2?unicodes=XYZ", http.StatusBadRequest},
+		{"/api/v2/fonts/go_latin_400_normal.woff2?unicodes=0-10FFFF", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, get(t, handler, tt.target).Code, tt.target)
+	}
+}
@@ -0,0 +1,322 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// CharacterMap returns the mapping from code points to glyph ids described by
+// the best Unicode subtable of the cmap table.
+func (f *Font) CharacterMap() (map[rune]uint16, error) {
+	cmap := f.Tables["cmap"]
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("%w: missing cmap table", ErrInvalidFont)
+	}
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	if len(cmap) < 4+8*numTables {
+		return nil, fmt.Errorf("%w: truncated cmap table", ErrInvalidFont)
+	}
+	// Subtables in order of preference
+	preference := []struct{ platform, encoding uint16 }{
+		{3, 10}, {0, 6}, {0, 4}, {3, 1}, {0, 3}, {0, 2}, {0, 1}, {0, 0}, {3, 0},
+	}
+	best := -1
+	bestOffset := 0
+	for i := 0; i < numTables; i++ {
+		record := cmap[4+8*i:]
+		platform := binary.BigEndian.Uint16(record[0:])
+		encoding := binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		index := slices.IndexFunc(preference, func(p struct{ platform, encoding uint16 }) bool {
+			return p.platform == platform && p.encoding == encoding
+		})
+		if index >= 0 && (best < 0 || index < best) && offset < len(cmap) {
+			// Skip format 14 subtables, they do not map code points to glyphs
+			if offset+2 <= len(cmap) && binary.BigEndian.Uint16(cmap[offset:]) == 14 {
+				continue
+			}
+			best = index
+			bestOffset = offset
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("%w: no Unicode cmap subtable", ErrInvalidFont)
+	}
+	mapping, err := parseCmapSubtable(cmap[bestOffset:])
+	if err != nil {
+		return nil, err
+	}
+	// Symbol fonts map code points to the private use area U+F0xx
+	if preference[best].platform == 3 && preference[best].encoding == 0 {
+		for r, gid := range mapping {
+			if r >= 0xF000 && r <= 0xF0FF {
+				mapping[r-0xF000] = gid
+			}
+		}
+	}
+	return mapping, nil
+}
+
+// IsSymbolFont reports whether the font only has a Windows Symbol cmap
+// subtable.
+func (f *Font) IsSymbolFont() bool {
+	cmap := f.Tables["cmap"]
+	if len(cmap) < 4 {
+		return false
+	}
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	symbol := false
+	for i := 0; i < numTables && 4+8*i+8 <= len(cmap); i++ {
+		platform := binary.BigEndian.Uint16(cmap[4+8*i:])
+		encoding := binary.BigEndian.Uint16(cmap[4+8*i+2:])
+		switch {
+		case platform == 3 && encoding == 0:
+			symbol = true
+		case platform == 0, platform == 3 && (encoding == 1 || encoding == 10):
+			return false
+		}
+	}
+	return symbol
+}
+
+func parseCmapSubtable(data []byte) (mapping map[rune]uint16, err error) {
+	defer recoverMalformed("cmap", &err)
+	mapping = make(map[rune]uint16)
+	u16 := func(off int) int { return int(binary.BigEndian.Uint16(data[off:])) }
+	u32 := func(off int) int { return int(binary.BigEndian.Uint32(data[off:])) }
+	switch format := u16(0); format {
+	case 0:
+		for i := 0; i < 256; i++ {
+			if gid := data[6+i]; gid != 0 {
+				mapping[rune(i)] = uint16(gid)
+			}
+		}
+	case 4:
+		segCount := u16(6) / 2
+		endCodes := 14
+		startCodes := endCodes + 2*segCount + 2
+		idDeltas := startCodes + 2*segCount
+		idRangeOffsets := idDeltas + 2*segCount
+		for i := 0; i < segCount; i++ {
+			end := u16(endCodes + 2*i)
+			start := u16(startCodes + 2*i)
+			delta := u16(idDeltas + 2*i)
+			rangeOffset := u16(idRangeOffsets + 2*i)
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if rangeOffset == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					gid = u16(idRangeOffsets + 2*i + rangeOffset + 2*(c-start))
+					if gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					mapping[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	case 6:
+		firstCode := u16(6)
+		entryCount := u16(8)
+		for i := 0; i < entryCount; i++ {
+			if gid := u16(10 + 2*i); gid != 0 {
+				mapping[rune(firstCode+i)] = uint16(gid)
+			}
+		}
+	case 12, 13:
+		numGroups := u32(12)
+		for i := 0; i < numGroups; i++ {
+			group := 16 + 12*i
+			start := u32(group)
+			end := u32(group + 4)
+			startGlyph := u32(group + 8)
+			if end > 0x10FFFF || end < start {
+				return nil, fmt.Errorf("%w: invalid cmap group", ErrInvalidFont)
+			}
+			for c := start; c <= end; c++ {
+				gid := startGlyph
+				if format == 12 {
+					gid += c - start
+				}
+				if gid != 0 && gid <= 0xFFFF {
+					mapping[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cmap subtable format %d", format)
+	}
+	return mapping, nil
+}
+
+// BuildCmap builds a cmap table from a mapping of code points to glyph ids.
+//
+// The table contains a format 4 subtable for the Basic Multilingual Plane and,
+// if needed, a format 12 subtable for all code points. If symbol is set the
+// format 4 subtable is written as a Windows Symbol subtable.
+func BuildCmap(mapping map[rune]uint16, symbol bool) []byte {
+	var runes []rune
+	for r := range mapping {
+		runes = append(runes, r)
+	}
+	slices.Sort(runes)
+
+	format4 := buildCmapFormat4(runes, mapping)
+	var format12 []byte
+	if len(runes) > 0 && runes[len(runes)-1] > 0xFFFF {
+		format12 = buildCmapFormat12(runes, mapping)
+	}
+
+	type record struct {
+		platform, encoding uint16
+		data               []byte
+	}
+	var records []record
+	if symbol {
+		records = append(records, record{3, 0, format4})
+	} else {
+		records = append(records, record{0, 3, format4})
+		if format12 != nil {
+			records = append(records, record{0, 4, format12})
+		}
+		records = append(records, record{3, 1, format4})
+		if format12 != nil {
+			records = append(records, record{3, 10, format12})
+		}
+	}
+
+	out := make([]byte, 4+8*len(records))
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	offsets := map[*byte]int{}
+	for i, r := range records {
+		offset, found := offsets[&r.data[0]]
+		if !found {
+			offset = len(out)
+			offsets[&r.data[0]] = offset
+			out = append(out, r.data...)
+		}
+		binary.BigEndian.PutUint16(out[4+8*i:], r.platform)
+		binary.BigEndian.PutUint16(out[4+8*i+2:], r.encoding)
+		binary.BigEndian.PutUint32(out[4+8*i+4:], uint32(offset))
+	}
+	return out
+}
+
+func buildCmapFormat4(runes []rune, mapping map[rune]uint16) []byte {
+	type segment struct {
+		start, end rune
+		delta      uint16
+		glyphs     []uint16 // nil if delta is used
+	}
+	var segments []segment
+	for i := 0; i < len(runes) && runes[i] < 0xFFFF; {
+		// Extend the segment as long as code points are contiguous
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[j]+1 && runes[j+1] < 0xFFFF {
+			j++
+		}
+		// Use a delta if the glyph ids are contiguous as well, otherwise
+		// store the glyph ids explicitly
+		contiguous := true
+		for k := i + 1; k <= j; k++ {
+			if mapping[runes[k]] != mapping[runes[k-1]]+1 {
+				contiguous = false
+				break
+			}
+		}
+		s := segment{start: runes[i], end: runes[j]}
+		if contiguous {
+			s.delta = mapping[runes[i]] - uint16(runes[i])
+		} else {
+			for k := i; k <= j; k++ {
+				s.glyphs = append(s.glyphs, mapping[runes[k]])
+			}
+		}
+		segments = append(segments, s)
+		i = j + 1
+	}
+	segments = append(segments, segment{start: 0xFFFF, end: 0xFFFF, delta: 1})
+
+	segCount := len(segments)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 * (1 << entrySelector)
+
+	glyphIdsLength := 0
+	for _, s := range segments {
+		glyphIdsLength += 2 * len(s.glyphs)
+	}
+	length := 16 + 8*segCount + glyphIdsLength
+	out := make([]byte, length)
+	binary.BigEndian.PutUint16(out[0:], 4)
+	binary.BigEndian.PutUint16(out[2:], uint16(length))
+	binary.BigEndian.PutUint16(out[6:], uint16(2*segCount))
+	binary.BigEndian.PutUint16(out[8:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[12:], uint16(2*segCount-searchRange))
+	endCodes := 14
+	startCodes := endCodes + 2*segCount + 2
+	idDeltas := startCodes + 2*segCount
+	idRangeOffsets := idDeltas + 2*segCount
+	glyphIds := idRangeOffsets + 2*segCount
+	for i, s := range segments {
+		binary.BigEndian.PutUint16(out[endCodes+2*i:], uint16(s.end))
+		binary.BigEndian.PutUint16(out[startCodes+2*i:], uint16(s.start))
+		binary.BigEndian.PutUint16(out[idDeltas+2*i:], s.delta)
+		if s.glyphs != nil {
+			binary.BigEndian.PutUint16(out[idRangeOffsets+2*i:], uint16(glyphIds-(idRangeOffsets+2*i)))
+			for _, gid := range s.glyphs {
+				binary.BigEndian.PutUint16(out[glyphIds:], gid)
+				glyphIds += 2
+			}
+		}
+	}
+	if length > 0xFFFF {
+		// The length field overflows for very large subtables, which
+		// parsers tolerate as long as the rest of the table is consistent
+		binary.BigEndian.PutUint16(out[2:], 0xFFFF)
+	}
+	return out
+}
+
+func buildCmapFormat12(runes []rune, mapping map[rune]uint16) []byte {
+	type group struct {
+		start, end rune
+		glyph      uint16
+	}
+	var groups []group
+	for _, r := range runes {
+		gid := mapping[r]
+		if n := len(groups); n > 0 {
+			last := &groups[n-1]
+			if r == last.end+1 && gid == last.glyph+uint16(r-last.start) {
+				last.end = r
+				continue
+			}
+		}
+		groups = append(groups, group{start: r, end: r, glyph: gid})
+	}
+	out := make([]byte, 16+12*len(groups))
+	binary.BigEndian.PutUint16(out[0:], 12)
+	binary.BigEndian.PutUint32(out[4:], uint32(len(out)))
+	binary.BigEndian.PutUint32(out[12:], uint32(len(groups)))
+	for i, g := range groups {
+		binary.BigEndian.PutUint32(out[16+12*i:], uint32(g.start))
+		binary.BigEndian.PutUint32(out[16+12*i+4:], uint32(g.end))
+		binary.BigEndian.PutUint32(out[16+12*i+8:], uint32(g.glyph))
+	}
+	return out
+}
+
+// recoverMalformed converts out of bounds panics caused by malformed table
+// data into errors.
+func recoverMalformed(table string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: malformed %s table: %v", ErrInvalidFont, table, r)
+	}
+}
@@ -0,0 +1,159 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Flags used by composite glyph descriptions
+const (
+	argsAreWords       = 0x0001
+	weHaveAScale       = 0x0008
+	moreComponents     = 0x0020
+	weHaveXYScale      = 0x0040
+	weHaveTwoByTwo     = 0x0080
+	weHaveInstructions = 0x0100
+)
+
+// Glyphs returns the glyph descriptions of the glyf table, indexed by glyph
+// id. Empty glyphs have zero length. The returned slices share memory with
+// the font.
+func (f *Font) Glyphs() ([][]byte, error) {
+	head := f.Tables["head"]
+	glyf := f.Tables["glyf"]
+	loca := f.Tables["loca"]
+	if len(head) < 54 {
+		return nil, fmt.Errorf("%w: missing head table", ErrInvalidFont)
+	}
+	if glyf == nil || loca == nil {
+		return nil, fmt.Errorf("%w: missing glyf or loca table", ErrInvalidFont)
+	}
+	numGlyphs, err := f.NumGlyphs()
+	if err != nil {
+		return nil, err
+	}
+	longFormat := binary.BigEndian.Uint16(head[50:]) != 0
+	offset := func(i int) int {
+		if longFormat {
+			return int(binary.BigEndian.Uint32(loca[4*i:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+	}
+	if (longFormat && len(loca) < 4*(numGlyphs+1)) || (!longFormat && len(loca) < 2*(numGlyphs+1)) {
+		return nil, fmt.Errorf("%w: truncated loca table", ErrInvalidFont)
+	}
+	glyphs := make([][]byte, numGlyphs)
+	for i := range glyphs {
+		start, end := offset(i), offset(i+1)
+		if start > end || end > len(glyf) {
+			return nil, fmt.Errorf("%w: glyph %d out of bounds", ErrInvalidFont, i)
+		}
+		glyphs[i] = glyf[start:end]
+	}
+	return glyphs, nil
+}
+
+// BuildGlyf builds the glyf and loca tables from glyph descriptions indexed
+// by glyph id. Glyphs are padded to even lengths. The returned indexToLocFormat
+// should be written to the head table.
+func BuildGlyf(glyphs [][]byte) (glyf []byte, loca []byte, indexToLocFormat uint16) {
+	size := 0
+	for _, g := range glyphs {
+		size += len(g) + len(g)%2
+	}
+	longFormat := size > 0x1FFFE
+	if longFormat {
+		indexToLocFormat = 1
+		loca = make([]byte, 4*(len(glyphs)+1))
+	} else {
+		loca = make([]byte, 2*(len(glyphs)+1))
+	}
+	glyf = make([]byte, 0, size)
+	for i := 0; i <= len(glyphs); i++ {
+		if longFormat {
+			binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+		} else {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(len(glyf)/2))
+		}
+		if i < len(glyphs) {
+			glyf = append(glyf, glyphs[i]...)
+			if len(glyphs[i])%2 == 1 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	return glyf, loca, indexToLocFormat
+}
+
+// Components returns the glyph ids referenced by a composite glyph. It
+// returns nil for simple and empty glyphs.
+func Components(glyph []byte) ([]uint16, error) {
+	components, _, _, err := parseComposite(glyph)
+	return components, err
+}
+
+// RemapComponents returns a copy of a composite glyph whose component glyph
+// ids are replaced using the given function. Simple and empty glyphs are
+// returned as they are.
+func RemapComponents(glyph []byte, remap func(gid uint16) uint16) ([]byte, error) {
+	components, _, _, err := parseComposite(glyph)
+	if err != nil || components == nil {
+		return glyph, err
+	}
+	out := slices.Clone(glyph)
+	offset := 10
+	for _, component := range components {
+		flags := binary.BigEndian.Uint16(out[offset:])
+		binary.BigEndian.PutUint16(out[offset+2:], remap(component))
+		offset += 4 + componentArgsLength(flags)
+	}
+	return out, nil
+}
+
+// CompositeLength returns the length of the header and component records of
+// a composite glyph, and whether the components are followed by instructions.
+func CompositeLength(glyph []byte) (length int, hasInstructions bool, err error) {
+	_, length, hasInstructions, err = parseComposite(glyph)
+	return length, hasInstructions, err
+}
+
+func parseComposite(glyph []byte) (components []uint16, length int, hasInstructions bool, err error) {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil, 0, false, nil
+	}
+	defer recoverMalformed("glyf", &err)
+	offset := 10
+	for {
+		flags := binary.BigEndian.Uint16(glyph[offset:])
+		components = append(components, binary.BigEndian.Uint16(glyph[offset+2:]))
+		offset += 4 + componentArgsLength(flags)
+		if flags&weHaveInstructions != 0 {
+			hasInstructions = true
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	// Access the last byte to detect truncated glyphs
+	_ = glyph[offset-1]
+	return components, offset, hasInstructions, nil
+}
+
+// componentArgsLength returns the length of the arguments and transformation
+// that follow the flags and glyph id of a component with the given flags.
+func componentArgsLength(flags uint16) int {
+	length := 2
+	if flags&argsAreWords != 0 {
+		length = 4
+	}
+	switch {
+	case flags&weHaveAScale != 0:
+		length += 2
+	case flags&weHaveXYScale != 0:
+		length += 4
+	case flags&weHaveTwoByTwo != 0:
+		length += 8
+	}
+	return length
+}
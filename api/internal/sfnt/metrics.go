@@ -0,0 +1,120 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// useTypoMetrics is the bit of fsSelection in the OS/2 table that tells
+// applications to use the typographic metrics of the OS/2 table rather than
+// those of the hhea table.
+const useTypoMetrics = 1 << 7
+
+// latinFrequencies are the frequencies of the lowercase letters and the space
+// in English text.
+var latinFrequencies = []struct {
+	r         rune
+	frequency float64
+}{
+	{'a', 0.0668}, {'b', 0.0122}, {'c', 0.0228}, {'d', 0.0348}, {'e', 0.1039},
+	{'f', 0.0182}, {'g', 0.0165}, {'h', 0.0499}, {'i', 0.0570}, {'j', 0.0013},
+	{'k', 0.0063}, {'l', 0.0329}, {'m', 0.0197}, {'n', 0.0552}, {'o', 0.0614},
+	{'p', 0.0158}, {'q', 0.0008}, {'r', 0.0490}, {'s', 0.0518}, {'t', 0.0741},
+	{'u', 0.0226}, {'v', 0.0080}, {'w', 0.0193}, {'x', 0.0012}, {'y', 0.0162},
+	{'z', 0.0006}, {' ', 0.1818},
+}
+
+// Metrics are the metrics of a font in font units as read from the head, hhea,
+// OS/2, cmap and hmtx tables.
+type Metrics struct {
+	UnitsPerEm int
+	// Ascender, Descender and LineGap are the vertical metrics browsers lay
+	// out lines with. The descender is negative for descents below the
+	// baseline.
+	Ascender  int
+	Descender int
+	LineGap   int
+	// XHeight and CapHeight are 0 for fonts with OS/2 tables older than
+	// version 2, which don't have them
+	XHeight   int
+	CapHeight int
+	// AvgCharWidth is the average advance width of the characters of English
+	// text, i.e. of the lowercase letters and the space weighted by their
+	// frequency. It is the xAvgCharWidth of the OS/2 table for fonts without
+	// any of these characters.
+	AvgCharWidth int
+}
+
+// Metrics returns the metrics of the font. The vertical metrics are those of
+// the hhea table, or the typographic metrics of the OS/2 table if the font
+// asks for them to be used.
+func (f *Font) Metrics() (Metrics, error) {
+	head := f.Tables["head"]
+	if len(head) < 54 {
+		return Metrics{}, fmt.Errorf("%w: missing head table", ErrInvalidFont)
+	}
+	hhea := f.Tables["hhea"]
+	if len(hhea) < 36 {
+		return Metrics{}, fmt.Errorf("%w: missing hhea table", ErrInvalidFont)
+	}
+	os2 := f.Tables["OS/2"]
+	if len(os2) < 78 {
+		return Metrics{}, fmt.Errorf("%w: missing OS/2 table", ErrInvalidFont)
+	}
+	metrics := Metrics{
+		UnitsPerEm:   int(binary.BigEndian.Uint16(head[18:])),
+		Ascender:     int(int16(binary.BigEndian.Uint16(hhea[4:]))),
+		Descender:    int(int16(binary.BigEndian.Uint16(hhea[6:]))),
+		LineGap:      int(int16(binary.BigEndian.Uint16(hhea[8:]))),
+		AvgCharWidth: int(int16(binary.BigEndian.Uint16(os2[2:]))),
+	}
+	if binary.BigEndian.Uint16(os2[62:])&useTypoMetrics != 0 {
+		metrics.Ascender = int(int16(binary.BigEndian.Uint16(os2[68:])))
+		metrics.Descender = int(int16(binary.BigEndian.Uint16(os2[70:])))
+		metrics.LineGap = int(int16(binary.BigEndian.Uint16(os2[72:])))
+	}
+	if binary.BigEndian.Uint16(os2[0:]) >= 2 && len(os2) >= 90 {
+		metrics.XHeight = int(int16(binary.BigEndian.Uint16(os2[86:])))
+		metrics.CapHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	avgCharWidth, found, err := f.latinAvgCharWidth()
+	if err != nil {
+		return Metrics{}, err
+	}
+	if found {
+		metrics.AvgCharWidth = avgCharWidth
+	}
+	return metrics, nil
+}
+
+// latinAvgCharWidth returns the average advance width of the characters of
+// English text as read from the cmap and hmtx tables, weighted by their
+// frequency. Characters that the font doesn't map are left out of the
+// average. It returns false if the font maps none of them.
+func (f *Font) latinAvgCharWidth() (int, bool, error) {
+	cmap, err := f.CharacterMap()
+	if err != nil {
+		return 0, false, err
+	}
+	numberOfHMetrics := int(binary.BigEndian.Uint16(f.Tables["hhea"][34:]))
+	hmtx := f.Tables["hmtx"]
+	if numberOfHMetrics == 0 || len(hmtx) < 4*numberOfHMetrics {
+		return 0, false, fmt.Errorf("%w: truncated hmtx table", ErrInvalidFont)
+	}
+	var width, total float64
+	for _, c := range latinFrequencies {
+		gid, found := cmap[c.r]
+		if !found {
+			continue
+		}
+		// Glyphs after the last long metric share its advance width
+		i := min(int(gid), numberOfHMetrics-1)
+		width += c.frequency * float64(binary.BigEndian.Uint16(hmtx[4*i:]))
+		total += c.frequency
+	}
+	if total == 0 {
+		return 0, false, nil
+	}
+	return int(math.Round(width / total)), true, nil
+}
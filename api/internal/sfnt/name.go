@@ -0,0 +1,151 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// Name ids as defined in the OpenType specification
+const (
+	NameCopyright            = 0
+	NameFamily               = 1
+	NameSubfamily            = 2
+	NameUniqueID             = 3
+	NameFull                 = 4
+	NameVersion              = 5
+	NamePostScript           = 6
+	NameLicense              = 13
+	NameLicenseURL           = 14
+	NameTypographicFamily    = 16
+	NameTypographicSubfamily = 17
+	NameCompatibleFull       = 18
+	NameVariationsPrefix     = 25
+)
+
+// Platform and language ids used by name records
+const (
+	PlatformUnicode        = 0
+	PlatformMacintosh      = 1
+	PlatformWindows        = 3
+	LanguageWindowsEnglish = 0x0409
+)
+
+// NameRecord is a single record of the name table.
+type NameRecord struct {
+	PlatformID uint16
+	EncodingID uint16
+	LanguageID uint16
+	NameID     uint16
+	// Value is the raw, encoded, string
+	Value []byte
+}
+
+// String decodes the value of the record.
+func (r NameRecord) String() string {
+	switch r.PlatformID {
+	case PlatformUnicode, PlatformWindows:
+		units := make([]uint16, len(r.Value)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(r.Value[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		// Decode Mac Roman as Latin-1, which is correct for ASCII
+		var b strings.Builder
+		for _, c := range r.Value {
+			b.WriteRune(rune(c))
+		}
+		return b.String()
+	}
+}
+
+// Names returns the records of the name table.
+func (f *Font) Names() (records []NameRecord, err error) {
+	name := f.Tables["name"]
+	if len(name) < 6 {
+		return nil, fmt.Errorf("%w: missing name table", ErrInvalidFont)
+	}
+	defer recoverMalformed("name", &err)
+	count := int(binary.BigEndian.Uint16(name[2:]))
+	storage := int(binary.BigEndian.Uint16(name[4:]))
+	for i := 0; i < count; i++ {
+		record := name[6+12*i:]
+		length := int(binary.BigEndian.Uint16(record[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+		records = append(records, NameRecord{
+			PlatformID: binary.BigEndian.Uint16(record[0:]),
+			EncodingID: binary.BigEndian.Uint16(record[2:]),
+			LanguageID: binary.BigEndian.Uint16(record[4:]),
+			NameID:     binary.BigEndian.Uint16(record[6:]),
+			Value:      name[offset : offset+length],
+		})
+	}
+	return records, nil
+}
+
+// Name returns the English value of the given name id, preferring Windows
+// records over Macintosh records. It returns an empty string if the font has
+// no such record.
+func (f *Font) Name(nameID uint16) string {
+	records, err := f.Names()
+	if err != nil {
+		return ""
+	}
+	result := ""
+	for _, r := range records {
+		if r.NameID != nameID {
+			continue
+		}
+		if r.PlatformID == PlatformWindows && r.LanguageID == LanguageWindowsEnglish {
+			return r.String()
+		}
+		if result == "" && (r.PlatformID == PlatformUnicode || (r.PlatformID == PlatformMacintosh && r.LanguageID == 0)) {
+			result = r.String()
+		}
+	}
+	return result
+}
+
+// BuildName builds a version 0 name table from the given records. Records
+// are sorted and identical values are only stored once.
+func BuildName(records []NameRecord) []byte {
+	records = slices.Clone(records)
+	slices.SortFunc(records, func(a, b NameRecord) int {
+		for _, d := range []int{
+			int(a.PlatformID) - int(b.PlatformID),
+			int(a.EncodingID) - int(b.EncodingID),
+			int(a.LanguageID) - int(b.LanguageID),
+			int(a.NameID) - int(b.NameID),
+		} {
+			if d != 0 {
+				return d
+			}
+		}
+		return 0
+	})
+	storageOffset := 6 + 12*len(records)
+	out := make([]byte, storageOffset)
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	binary.BigEndian.PutUint16(out[4:], uint16(storageOffset))
+	offsets := map[string]int{}
+	var storage []byte
+	for i, r := range records {
+		offset, found := offsets[string(r.Value)]
+		if !found {
+			offset = len(storage)
+			offsets[string(r.Value)] = offset
+			storage = append(storage, r.Value...)
+		}
+		record := out[6+12*i:]
+		binary.BigEndian.PutUint16(record[0:], r.PlatformID)
+		binary.BigEndian.PutUint16(record[2:], r.EncodingID)
+		binary.BigEndian.PutUint16(record[4:], r.LanguageID)
+		binary.BigEndian.PutUint16(record[6:], r.NameID)
+		binary.BigEndian.PutUint16(record[8:], uint16(len(r.Value)))
+		binary.BigEndian.PutUint16(record[10:], uint16(offset))
+	}
+	return append(out, storage...)
+}
@@ -0,0 +1,147 @@
+// Package sfnt reads and writes TrueType and OpenType font files.
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// VersionTrueType is the sfnt version of fonts with TrueType outlines
+	VersionTrueType = 0x00010000
+	// VersionOpenType is the sfnt version of fonts with CFF outlines
+	VersionOpenType = 0x4F54544F // "OTTO"
+	// versionApple is the sfnt version used by some older Apple fonts
+	versionApple = 0x74727565 // "true"
+	// versionCollection is the tag used by font collections
+	versionCollection = 0x74746366 // "ttcf"
+)
+
+var ErrInvalidFont = errors.New("invalid font")
+
+// Font is a parsed sfnt font file, i.e. a .ttf- or .otf-file.
+type Font struct {
+	Version uint32
+	// Tables maps table tags, e.g. "glyf", to the raw table data.
+	Tables map[string][]byte
+}
+
+// Parse parses the table directory of a font file. The returned tables share
+// memory with data.
+func Parse(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidFont
+	}
+	version := binary.BigEndian.Uint32(data)
+	switch version {
+	case VersionTrueType, VersionOpenType, versionApple:
+	case versionCollection:
+		return nil, errors.New("font collections are not supported")
+	default:
+		return nil, fmt.Errorf("%w: unknown sfnt version %08X", ErrInvalidFont, version)
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, ErrInvalidFont
+	}
+	font := &Font{
+		Version: version,
+		Tables:  make(map[string][]byte, numTables),
+	}
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		tag := string(record[0:4])
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) || offset+length < offset {
+			return nil, fmt.Errorf("%w: table %q out of bounds", ErrInvalidFont, tag)
+		}
+		font.Tables[tag] = data[offset : offset+length]
+	}
+	return font, nil
+}
+
+// Tags returns the table tags of the font in sorted order.
+func (f *Font) Tags() []string {
+	var tags []string
+	for tag := range f.Tables {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Bytes serializes the font. Tables are written in tag order, padded to four
+// bytes, and the checksums, including the checksum adjustment of the head
+// table, are recalculated.
+func (f *Font) Bytes() []byte {
+	tags := f.Tags()
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	size := 12 + 16*numTables
+	for _, tag := range tags {
+		size += pad4(len(f.Tables[tag]))
+	}
+	out := make([]byte, 12+16*numTables, size)
+	binary.BigEndian.PutUint32(out[0:], f.Version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(numTables*16-searchRange))
+
+	headOffset := -1
+	for i, tag := range tags {
+		data := f.Tables[tag]
+		offset := len(out)
+		out = append(out, data...)
+		out = append(out, make([]byte, pad4(len(data))-len(data))...)
+		if tag == "head" && len(data) >= 12 {
+			headOffset = offset
+			// The checksum adjustment is zero when calculating checksums
+			binary.BigEndian.PutUint32(out[offset+8:], 0)
+		}
+		record := out[12+16*i:]
+		copy(record[0:4], tag)
+		binary.BigEndian.PutUint32(record[4:], Checksum(out[offset:offset+pad4(len(data))]))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+	}
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-Checksum(out))
+	}
+	return out
+}
+
+// Checksum calculates the checksum of a table as described in the OpenType
+// specification.
+func Checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// NumGlyphs returns the number of glyphs in the font as reported by the maxp
+// table.
+func (f *Font) NumGlyphs() (int, error) {
+	maxp := f.Tables["maxp"]
+	if len(maxp) < 6 {
+		return 0, fmt.Errorf("%w: missing maxp table", ErrInvalidFont)
+	}
+	return int(binary.BigEndian.Uint16(maxp[4:])), nil
+}
+
+// pad4 rounds n up to the nearest multiple of four.
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
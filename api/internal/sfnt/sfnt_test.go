@@ -0,0 +1,128 @@
+package sfnt_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestParseAndSerialize(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(sfnt.VersionTrueType), font.Version)
+	assert.Contains(t, font.Tags(), "glyf")
+
+	reparsed, err := sfnt.Parse(font.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, font.Tags(), reparsed.Tags())
+	for _, tag := range font.Tags() {
+		if tag == "head" {
+			// The checksum adjustment is recalculated
+			assert.Equal(t, font.Tables[tag][12:], reparsed.Tables[tag][12:])
+			continue
+		}
+		assert.Equal(t, font.Tables[tag], reparsed.Tables[tag], "table %s differs", tag)
+	}
+	// The checksum of a font with a correct checksum adjustment is constant
+	assert.Equal(t, uint32(0xB1B0AFBA), sfnt.Checksum(reparsed.Bytes()))
+}
+
+func TestParseInvalidFont(t *testing.T) {
+	_, err := sfnt.Parse([]byte("not a font"))
+	assert.ErrorIs(t, err, sfnt.ErrInvalidFont)
+}
+
+func TestBuildCmap(t *testing.T) {
+	mapping := map[rune]uint16{
+		'A':     1,
+		'B':     2,
+		'C':     3,
+		'a':     7,
+		'b':     5,
+		0x1F600: 9,
+		0x1F601: 10,
+	}
+	font := &sfnt.Font{
+		Version: sfnt.VersionTrueType,
+		Tables:  map[string][]byte{"cmap": sfnt.BuildCmap(mapping, false)},
+	}
+	actual, err := font.CharacterMap()
+	require.NoError(t, err)
+	assert.Equal(t, mapping, actual)
+}
+
+func TestNames(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	assert.Equal(t, "Go", font.Name(sfnt.NameFamily))
+
+	records, err := font.Names()
+	require.NoError(t, err)
+	font.Tables["name"] = sfnt.BuildName(records)
+	assert.Equal(t, "Go", font.Name(sfnt.NameFamily))
+	assert.Equal(t, "Regular", font.Name(sfnt.NameSubfamily))
+}
+
+func TestUnicodeRanges(t *testing.T) {
+	ranges := sfnt.UnicodeRanges([]rune{'A', 0x0410, 0x1F600})
+	// Basic Latin
+	assert.Equal(t, uint32(1<<0), ranges[0]&(1<<0))
+	// Cyrillic
+	assert.Equal(t, uint32(1<<9), ranges[0]&(1<<9))
+	// Non-Plane 0
+	assert.Equal(t, uint32(1<<(57-32)), ranges[1]&(1<<(57-32)))
+	// Greek
+	assert.Zero(t, ranges[0]&(1<<7))
+}
+
+func TestMetrics(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	metrics, err := font.Metrics()
+	require.NoError(t, err)
+	assert.Equal(t, sfnt.Metrics{
+		UnitsPerEm:   2048,
+		Ascender:     1935,
+		Descender:    -432,
+		LineGap:      0,
+		XHeight:      1086,
+		CapHeight:    1480,
+		AvgCharWidth: 910,
+	}, metrics)
+
+	// Fonts without Latin characters have the average width of the OS/2 table
+	font.Tables["cmap"] = sfnt.BuildCmap(map[rune]uint16{'日': 1}, false)
+	metrics, err = font.Metrics()
+	require.NoError(t, err)
+	assert.Equal(t, 1202, metrics.AvgCharWidth)
+
+	delete(font.Tables, "OS/2")
+	_, err = font.Metrics()
+	assert.ErrorIs(t, err, sfnt.ErrInvalidFont)
+}
+
+func TestRemapComponents(t *testing.T) {
+	// A composite glyph with a scaled component with word arguments followed
+	// by a component with a two by two transformation
+	var glyph []byte
+	for _, v := range []uint16{0xFFFF, 0, 0, 0, 0, 0x0029, 7, 1, 2, 0x4000, 0x0080, 9, 0, 0x4000, 0, 0, 0x4000} {
+		glyph = binary.BigEndian.AppendUint16(glyph, v)
+	}
+	components, err := sfnt.Components(glyph)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{7, 9}, components)
+
+	remapped, err := sfnt.RemapComponents(glyph, func(gid uint16) uint16 { return gid - 5 })
+	require.NoError(t, err)
+	components, err = sfnt.Components(remapped)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{2, 4}, components)
+	// Only the glyph ids at 12 and 22 change
+	assert.Equal(t, glyph[14:22], remapped[14:22])
+	assert.Equal(t, glyph[24:], remapped[24:])
+	assert.Equal(t, uint16(9), binary.BigEndian.Uint16(glyph[22:]), "the source should be unchanged")
+}
@@ -0,0 +1,452 @@
+package subsetter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Operators of CFF DICTs. Two-byte operators are escaped with 12.
+const (
+	opCharset     = 15
+	opEncoding    = 16
+	opCharStrings = 17
+	opPrivate     = 18
+	opSubrs       = 19
+	opROS         = 12<<8 | 30
+	opFDArray     = 12<<8 | 36
+	opFDSelect    = 12<<8 | 37
+)
+
+// endchar is a Type 2 charstring without outlines.
+var endchar = []byte{14}
+
+// dictEntry is an operator of a CFF DICT and its operands.
+type dictEntry struct {
+	operator int
+	operands []byte
+	// values are the integer values of the operands, real numbers are zero
+	values []int
+}
+
+// subsetCFF subsets the charstrings of a CFF table, and its charset and
+// FDSelect for CID-keyed fonts. The charstrings of glyphs that are emptied
+// are replaced with endchar. Subroutines are copied as they are, as are
+// the Font DICTs of CID-keyed fonts.
+//
+// Accented glyphs that are built with the deprecated seac operator of
+// endchar don't retain their components.
+func subsetCFF(cff []byte, glyphs glyphMap) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed CFF table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	headerSize := int(cff[2])
+	_, nameEnd := parseIndex(cff, headerSize)
+	topDicts, stringsStart := parseIndex(cff, nameEnd)
+	_, globalSubrsStart := parseIndex(cff, stringsStart)
+	_, globalSubrsEnd := parseIndex(cff, globalSubrsStart)
+	if len(topDicts) != 1 {
+		panic("fonts with more than one font are not supported")
+	}
+	top := parseDict(topDicts[0])
+	charStrings, _ := parseIndex(cff, dictValue(top, opCharStrings, 0))
+	numGlyphs := len(charStrings)
+	charset := parseCharset(cff, dictValue(top, opCharset, 0), numGlyphs)
+
+	newCharStrings := make([][]byte, len(glyphs.oldGids))
+	newCharset := make([]uint16, len(glyphs.oldGids))
+	for gid, old := range glyphs.oldGids {
+		newCharset[gid] = charset[old]
+		newCharStrings[gid] = endchar
+		if _, retained := glyphs.newGids[old]; retained {
+			newCharStrings[gid] = charStrings[old]
+		}
+	}
+
+	// CID-keyed fonts select a Font DICT, with its own Private DICT, for
+	// every glyph
+	cid := slices.ContainsFunc(top, func(e dictEntry) bool { return e.operator == opROS })
+	var fontDicts [][]dictEntry
+	var fdSelect []byte
+	var privateDicts []*privateDict
+	if !cid {
+		privateDicts = append(privateDicts, readPrivateDict(cff, top))
+	} else {
+		fds := parseFDSelect(cff, dictValue(top, opFDSelect, 0), numGlyphs)
+		fdSelect = make([]byte, len(glyphs.oldGids))
+		for gid, old := range glyphs.oldGids {
+			fdSelect[gid] = fds[old]
+		}
+		items, _ := parseIndex(cff, dictValue(top, opFDArray, 0))
+		for _, item := range items {
+			fontDict := parseDict(item)
+			fontDicts = append(fontDicts, fontDict)
+			privateDicts = append(privateDicts, readPrivateDict(cff, fontDict))
+		}
+	}
+
+	// Offsets in DICTs are written as 32-bit integers, so that the length
+	// of the DICTs is known before the offsets are
+	charsetData := buildCharset(newCharset)
+	var fdSelectData []byte
+	if cid {
+		fdSelectData = buildFDSelect(fdSelect)
+	}
+	charStringsData := buildIndex(newCharStrings)
+	var privateData [][]byte
+	for _, p := range privateDicts {
+		privateData = append(privateData, p.bytes())
+	}
+	encodeTop := func(charsetOffset, charStringsOffset, privateOffset, fdArrayOffset, fdSelectOffset int) []byte {
+		var entries []dictEntry
+		for _, e := range top {
+			switch e.operator {
+			case opEncoding:
+				// Fonts in sfnt containers use the cmap table instead
+				continue
+			case opCharset:
+				e = intEntry(e.operator, charsetOffset)
+			case opCharStrings:
+				e = intEntry(e.operator, charStringsOffset)
+			case opPrivate:
+				e = intEntry(e.operator, len(privateData[0]), privateOffset)
+			case opFDArray:
+				e = intEntry(e.operator, fdArrayOffset)
+			case opFDSelect:
+				e = intEntry(e.operator, fdSelectOffset)
+			}
+			entries = append(entries, e)
+		}
+		if !slices.ContainsFunc(entries, func(e dictEntry) bool { return e.operator == opCharset }) {
+			entries = append(entries, intEntry(opCharset, charsetOffset))
+		}
+		return buildIndex([][]byte{encodeDict(entries)})
+	}
+	encodeFontDicts := func(privateOffsets []int) []byte {
+		var items [][]byte
+		for i, fontDict := range fontDicts {
+			var entries []dictEntry
+			for _, e := range fontDict {
+				if e.operator == opPrivate {
+					e = intEntry(e.operator, len(privateData[i]), privateOffsets[i])
+				}
+				entries = append(entries, e)
+			}
+			items = append(items, encodeDict(entries))
+		}
+		return buildIndex(items)
+	}
+
+	charsetOffset := nameEnd + len(encodeTop(0, 0, 0, 0, 0)) + globalSubrsEnd - stringsStart
+	fdSelectOffset := charsetOffset + len(charsetData)
+	charStringsOffset := fdSelectOffset + len(fdSelectData)
+	fdArrayOffset := charStringsOffset + len(charStringsData)
+	privateOffsets := make([]int, len(privateData))
+	offset := fdArrayOffset
+	if cid {
+		offset += len(encodeFontDicts(privateOffsets))
+	}
+	for i, data := range privateData {
+		privateOffsets[i] = offset
+		offset += len(data)
+	}
+
+	out = slices.Clone(cff[:nameEnd])
+	out = append(out, encodeTop(charsetOffset, charStringsOffset, privateOffsets[0], fdArrayOffset, fdSelectOffset)...)
+	out = append(out, cff[stringsStart:globalSubrsEnd]...)
+	out = append(out, charsetData...)
+	out = append(out, fdSelectData...)
+	out = append(out, charStringsData...)
+	if cid {
+		out = append(out, encodeFontDicts(privateOffsets)...)
+	}
+	for _, data := range privateData {
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// privateDict is a Private DICT and the local subroutines it refers to.
+type privateDict struct {
+	entries []dictEntry
+	subrs   []byte
+}
+
+// readPrivateDict reads the Private DICT of a Top or Font DICT.
+func readPrivateDict(cff []byte, dict []dictEntry) *privateDict {
+	i := slices.IndexFunc(dict, func(e dictEntry) bool { return e.operator == opPrivate })
+	if i == -1 || len(dict[i].values) != 2 {
+		panic("missing Private DICT")
+	}
+	size, offset := dict[i].values[0], dict[i].values[1]
+	p := &privateDict{entries: parseDict(cff[offset : offset+size])}
+	// Local subroutines are at an offset from the Private DICT
+	if subrs := dictValue(p.entries, opSubrs, 0); subrs != 0 {
+		_, end := parseIndex(cff, offset+subrs)
+		p.subrs = cff[offset+subrs : end]
+	}
+	return p
+}
+
+// bytes serializes the Private DICT followed by its local subroutines.
+func (p *privateDict) bytes() []byte {
+	entries := slices.Clone(p.entries)
+	i := slices.IndexFunc(entries, func(e dictEntry) bool { return e.operator == opSubrs })
+	if i == -1 {
+		return encodeDict(entries)
+	}
+	entries[i] = intEntry(opSubrs, 0)
+	entries[i] = intEntry(opSubrs, len(encodeDict(entries)))
+	return append(encodeDict(entries), p.subrs...)
+}
+
+// parseIndex returns the items of the CFF INDEX at an offset and the offset
+// of its end.
+func parseIndex(cff []byte, offset int) ([][]byte, int) {
+	count := int(binary.BigEndian.Uint16(cff[offset:]))
+	if count == 0 {
+		return nil, offset + 2
+	}
+	offSize := int(cff[offset+2])
+	itemOffset := func(i int) int {
+		v := 0
+		for _, b := range cff[offset+3+offSize*i : offset+3+offSize*(i+1)] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	// Item offsets start at 1
+	data := offset + 3 + offSize*(count+1) - 1
+	items := make([][]byte, count)
+	for i := range items {
+		items[i] = cff[data+itemOffset(i) : data+itemOffset(i+1)]
+	}
+	return items, data + itemOffset(count)
+}
+
+// buildIndex builds a CFF INDEX with the smallest offsets that fit it.
+func buildIndex(items [][]byte) []byte {
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+	offSize := 1
+	for size >= 1<<(8*offSize) {
+		offSize++
+	}
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(items)))
+	out = append(out, byte(offSize))
+	offset := 1
+	for i := 0; i <= len(items); i++ {
+		for j := offSize - 1; j >= 0; j-- {
+			out = append(out, byte(offset>>(8*j)))
+		}
+		if i < len(items) {
+			offset += len(items[i])
+		}
+	}
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// parseDict parses a CFF DICT.
+func parseDict(data []byte) []dictEntry {
+	var entries []dictEntry
+	var values []int
+	start := 0
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b <= 21:
+			end := i
+			operator := int(b)
+			if b == 12 {
+				operator = 12<<8 | int(data[i+1])
+				i++
+			}
+			i++
+			entries = append(entries, dictEntry{operator, data[start:end], values})
+			start = i
+			values = nil
+		case b == 28:
+			values = append(values, int(int16(binary.BigEndian.Uint16(data[i+1:]))))
+			i += 3
+		case b == 29:
+			values = append(values, int(int32(binary.BigEndian.Uint32(data[i+1:]))))
+			i += 5
+		case b == 30:
+			// Real numbers end with a nibble of 0xF
+			i++
+			for data[i]>>4 != 0xF && data[i]&0xF != 0xF {
+				i++
+			}
+			i++
+			values = append(values, 0)
+		case b >= 32 && b <= 246:
+			values = append(values, int(b)-139)
+			i++
+		case b >= 247 && b <= 250:
+			values = append(values, (int(b)-247)*256+int(data[i+1])+108)
+			i += 2
+		case b >= 251 && b <= 254:
+			values = append(values, -(int(b)-251)*256-int(data[i+1])-108)
+			i += 2
+		default:
+			panic("invalid DICT operand")
+		}
+	}
+	return entries
+}
+
+// encodeDict serializes the entries of a CFF DICT.
+func encodeDict(entries []dictEntry) []byte {
+	var out []byte
+	for _, e := range entries {
+		out = append(out, e.operands...)
+		if e.operator > 0xFF {
+			out = append(out, 12)
+		}
+		out = append(out, byte(e.operator))
+	}
+	return out
+}
+
+// intEntry returns a DICT entry with operands encoded as 32-bit integers.
+func intEntry(operator int, values ...int) dictEntry {
+	var operands []byte
+	for _, v := range values {
+		operands = append(operands, 29)
+		operands = binary.BigEndian.AppendUint32(operands, uint32(int32(v)))
+	}
+	return dictEntry{operator, operands, values}
+}
+
+// dictValue returns the first operand of an operator of a DICT, or a
+// default value if the DICT doesn't have the operator.
+func dictValue(entries []dictEntry, operator int, defaultValue int) int {
+	for _, e := range entries {
+		if e.operator == operator && len(e.values) > 0 {
+			return e.values[0]
+		}
+	}
+	return defaultValue
+}
+
+// parseCharset returns the SIDs, or CIDs of CID-keyed fonts, of the glyphs
+// of a CFF charset.
+func parseCharset(cff []byte, offset int, numGlyphs int) []uint16 {
+	charset := make([]uint16, numGlyphs)
+	switch offset {
+	case 0:
+		// The ISOAdobe charset maps glyph ids to the same SIDs
+		for gid := range charset {
+			charset[gid] = uint16(gid)
+		}
+		return charset
+	case 1, 2:
+		panic("expert charsets are not supported")
+	}
+	format := cff[offset]
+	position := offset + 1
+	for gid := 1; gid < numGlyphs; {
+		switch format {
+		case 0:
+			charset[gid] = binary.BigEndian.Uint16(cff[position:])
+			position += 2
+			gid++
+		case 1, 2:
+			// Ranges of consecutive ids, of which format 2 has a larger
+			// count of the ids left
+			first := binary.BigEndian.Uint16(cff[position:])
+			left := int(cff[position+2])
+			position += 3
+			if format == 2 {
+				left = int(binary.BigEndian.Uint16(cff[position-1:]))
+				position++
+			}
+			for i := 0; i <= left && gid < numGlyphs; i++ {
+				charset[gid] = first + uint16(i)
+				gid++
+			}
+		default:
+			panic("unknown charset format")
+		}
+	}
+	return charset
+}
+
+// buildCharset builds a charset from the SIDs or CIDs of the glyphs, using
+// ranges if that is smaller.
+func buildCharset(charset []uint16) []byte {
+	var ranges [][2]uint16
+	for gid := 1; gid < len(charset); gid++ {
+		if len(ranges) > 0 && charset[gid] == charset[gid-1]+1 {
+			ranges[len(ranges)-1][1]++
+			continue
+		}
+		ranges = append(ranges, [2]uint16{charset[gid], 0})
+	}
+	if 4*len(ranges) < 2*(len(charset)-1) {
+		out := []byte{2}
+		for _, r := range ranges {
+			out = binary.BigEndian.AppendUint16(out, r[0])
+			out = binary.BigEndian.AppendUint16(out, r[1])
+		}
+		return out
+	}
+	out := []byte{0}
+	for _, sid := range charset[1:] {
+		out = binary.BigEndian.AppendUint16(out, sid)
+	}
+	return out
+}
+
+// parseFDSelect returns the Font DICT index of every glyph of a CID-keyed
+// font.
+func parseFDSelect(cff []byte, offset int, numGlyphs int) []byte {
+	fds := make([]byte, numGlyphs)
+	switch cff[offset] {
+	case 0:
+		copy(fds, cff[offset+1:offset+1+numGlyphs])
+	case 3:
+		rangeCount := int(binary.BigEndian.Uint16(cff[offset+1:]))
+		for i := 0; i < rangeCount; i++ {
+			record := offset + 3 + 3*i
+			first := int(binary.BigEndian.Uint16(cff[record:]))
+			// The range ends at the first glyph of the next range, or of the
+			// sentinel
+			end := min(int(binary.BigEndian.Uint16(cff[record+3:])), numGlyphs)
+			for gid := first; gid < end; gid++ {
+				fds[gid] = cff[record+2]
+			}
+		}
+	default:
+		panic("unknown FDSelect format")
+	}
+	return fds
+}
+
+// buildFDSelect builds a format 3 FDSelect from the Font DICT index of every
+// glyph.
+func buildFDSelect(fds []byte) []byte {
+	out := []byte{3, 0, 0}
+	rangeCount := 0
+	for gid, fd := range fds {
+		if gid == 0 || fd != fds[gid-1] {
+			out = binary.BigEndian.AppendUint16(out, uint16(gid))
+			out = append(out, fd)
+			rangeCount++
+		}
+	}
+	binary.BigEndian.PutUint16(out[1:], uint16(rangeCount))
+	return binary.BigEndian.AppendUint16(out, uint16(len(fds)))
+}
@@ -0,0 +1,117 @@
+package subsetter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCharStrings are the charstrings of the glyphs of testCFF, which move
+// the pen by their glyph id.
+var testCharStrings = [][]byte{{139, 22, 14}, {140, 22, 14}, {141, 22, 14}, {142, 22, 14}}
+
+// testSubrs are the local subroutines of the Private DICTs of testCFF.
+var testSubrs = buildIndex([][]byte{{11}})
+
+// testCFF returns a CFF table with four glyphs. The glyphs of CID-keyed
+// fonts alternate between two Font DICTs.
+func testCFF(cid bool) []byte {
+	private := (&privateDict{entries: []dictEntry{intEntry(20, 500), intEntry(opSubrs, 0)}, subrs: testSubrs}).bytes()
+	charset := buildCharset([]uint16{0, 391, 392, 393})
+	fdSelect := buildFDSelect([]byte{0, 1, 0, 1})
+	charStrings := buildIndex(testCharStrings)
+	fdArray := func(privateOffset int) []byte {
+		fontDict := encodeDict([]dictEntry{intEntry(opPrivate, len(private), privateOffset)})
+		return buildIndex([][]byte{fontDict, fontDict})
+	}
+	top := func(charsetOffset, fdSelectOffset, charStringsOffset, fdArrayOffset, privateOffset int) []byte {
+		entries := []dictEntry{intEntry(opCharset, charsetOffset), intEntry(opCharStrings, charStringsOffset)}
+		if cid {
+			entries = append(entries, intEntry(opROS, 391, 392, 0), intEntry(opFDSelect, fdSelectOffset), intEntry(opFDArray, fdArrayOffset))
+		} else {
+			entries = append(entries, intEntry(opPrivate, len(private), privateOffset))
+		}
+		return buildIndex([][]byte{encodeDict(entries)})
+	}
+
+	cff := []byte{1, 0, 4, 4}
+	cff = append(cff, buildIndex([][]byte{[]byte("Test")})...)
+	charsetOffset := len(cff) + len(top(0, 0, 0, 0, 0)) + 2 + 2
+	fdSelectOffset := charsetOffset + len(charset)
+	charStringsOffset := fdSelectOffset + len(fdSelect)
+	fdArrayOffset := charStringsOffset + len(charStrings)
+	privateOffset := fdArrayOffset + len(fdArray(0))
+	cff = append(cff, top(charsetOffset, fdSelectOffset, charStringsOffset, fdArrayOffset, privateOffset)...)
+	cff = append(cff, buildIndex(nil)...)
+	cff = append(cff, buildIndex(nil)...)
+	cff = append(cff, charset...)
+	cff = append(cff, fdSelect...)
+	cff = append(cff, charStrings...)
+	cff = append(cff, fdArray(privateOffset)...)
+	return append(cff, private...)
+}
+
+func TestSubsetCFF(t *testing.T) {
+	tests := []struct {
+		cid         bool
+		renumber    bool
+		charStrings [][]byte
+		charset     []uint16
+		fds         []byte
+	}{
+		{
+			renumber:    true,
+			charStrings: [][]byte{testCharStrings[0], testCharStrings[2], testCharStrings[3]},
+			charset:     []uint16{0, 392, 393},
+		},
+		{
+			renumber:    false,
+			charStrings: [][]byte{testCharStrings[0], endchar, testCharStrings[2], testCharStrings[3]},
+			charset:     []uint16{0, 391, 392, 393},
+		},
+		{
+			cid:         true,
+			renumber:    true,
+			charStrings: [][]byte{testCharStrings[0], testCharStrings[2], testCharStrings[3]},
+			charset:     []uint16{0, 392, 393},
+			fds:         []byte{0, 0, 1},
+		},
+	}
+	for _, tt := range tests {
+		glyphs := newGlyphMap(glyphSet{0: true, 2: true, 3: true}, 4, tt.renumber)
+		out, err := subsetCFF(testCFF(tt.cid), glyphs)
+		require.NoError(t, err)
+
+		_, nameEnd := parseIndex(out, int(out[2]))
+		topDicts, _ := parseIndex(out, nameEnd)
+		require.Len(t, topDicts, 1)
+		top := parseDict(topDicts[0])
+		charStrings, _ := parseIndex(out, dictValue(top, opCharStrings, 0))
+		assert.Equal(t, tt.charStrings, charStrings)
+		assert.Equal(t, tt.charset, parseCharset(out, dictValue(top, opCharset, 0), len(charStrings)))
+
+		// The Private DICTs keep their local subroutines
+		var privateDicts []*privateDict
+		if tt.cid {
+			assert.Equal(t, tt.fds, parseFDSelect(out, dictValue(top, opFDSelect, 0), len(charStrings)))
+			fontDicts, _ := parseIndex(out, dictValue(top, opFDArray, 0))
+			require.Len(t, fontDicts, 2)
+			for _, fontDict := range fontDicts {
+				privateDicts = append(privateDicts, readPrivateDict(out, parseDict(fontDict)))
+			}
+		} else {
+			privateDicts = append(privateDicts, readPrivateDict(out, top))
+		}
+		for _, p := range privateDicts {
+			assert.Equal(t, 500, dictValue(p.entries, 20, 0))
+			assert.Equal(t, testSubrs, p.subrs)
+		}
+	}
+}
+
+func TestSubsetCFFMalformed(t *testing.T) {
+	cff := testCFF(false)
+	_, err := subsetCFF(cff[:len(cff)/2], newGlyphMap(glyphSet{0: true}, 4, true))
+	assert.Error(t, err)
+}
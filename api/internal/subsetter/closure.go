@@ -0,0 +1,154 @@
+package subsetter
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// glyphSet is a set of glyph ids.
+type glyphSet map[uint16]bool
+
+// closeOverGSUB adds every glyph that can be produced by the substitutions of
+// the GSUB table from the glyphs in the set. All lookups are considered,
+// regardless of which features or contexts reference them, which results in
+// a superset of the glyphs a shaper could produce. The closure is repeated
+// until no new glyphs are added.
+func closeOverGSUB(gsub []byte, glyphs glyphSet) (err error) {
+	if len(gsub) < 10 {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed GSUB table: %v", r)
+		}
+	}()
+	lookupList := int(u16(gsub, 8))
+	lookupCount := int(u16(gsub, lookupList))
+	var subtables []gsubSubtable
+	for i := 0; i < lookupCount; i++ {
+		lookup := lookupList + int(u16(gsub, lookupList+2+2*i))
+		lookupType := u16(gsub, lookup)
+		subTableCount := int(u16(gsub, lookup+4))
+		for j := 0; j < subTableCount; j++ {
+			offset := lookup + int(u16(gsub, lookup+6+2*j))
+			subtableType := lookupType
+			// Extension subtables point to a subtable of another type
+			if subtableType == 7 {
+				subtableType = u16(gsub, offset+2)
+				offset += int(u32(gsub, offset+4))
+			}
+			subtables = append(subtables, gsubSubtable{subtableType, offset})
+		}
+	}
+	for {
+		size := len(glyphs)
+		for _, subtable := range subtables {
+			closeOverSubtable(gsub, subtable, glyphs)
+		}
+		if len(glyphs) == size {
+			return nil
+		}
+	}
+}
+
+type gsubSubtable struct {
+	lookupType uint16
+	offset     int
+}
+
+func closeOverSubtable(data []byte, subtable gsubSubtable, glyphs glyphSet) {
+	offset := subtable.offset
+	format := u16(data, offset)
+	switch subtable.lookupType {
+	case 1: // Single substitution
+		coverage := parseCoverage(data, offset+int(u16(data, offset+2)))
+		for i, g := range coverage {
+			if !glyphs[g] {
+				continue
+			}
+			if format == 1 {
+				glyphs[g+u16(data, offset+4)] = true
+			} else {
+				glyphs[u16(data, offset+6+2*i)] = true
+			}
+		}
+	case 2, 3: // Multiple and alternate substitution
+		coverage := parseCoverage(data, offset+int(u16(data, offset+2)))
+		for i, g := range coverage {
+			if !glyphs[g] {
+				continue
+			}
+			sequence := offset + int(u16(data, offset+6+2*i))
+			glyphCount := int(u16(data, sequence))
+			for k := 0; k < glyphCount; k++ {
+				glyphs[u16(data, sequence+2+2*k)] = true
+			}
+		}
+	case 4: // Ligature substitution
+		coverage := parseCoverage(data, offset+int(u16(data, offset+2)))
+		for i, g := range coverage {
+			if !glyphs[g] {
+				continue
+			}
+			ligatureSet := offset + int(u16(data, offset+6+2*i))
+			ligatureCount := int(u16(data, ligatureSet))
+			for k := 0; k < ligatureCount; k++ {
+				ligature := ligatureSet + int(u16(data, ligatureSet+2+2*k))
+				componentCount := int(u16(data, ligature+2))
+				complete := true
+				for c := 1; c < componentCount; c++ {
+					if !glyphs[u16(data, ligature+4+2*(c-1))] {
+						complete = false
+						break
+					}
+				}
+				if complete {
+					glyphs[u16(data, ligature)] = true
+				}
+			}
+		}
+	case 8: // Reverse chaining contextual single substitution
+		coverage := parseCoverage(data, offset+int(u16(data, offset+2)))
+		backtrackCount := int(u16(data, offset+4))
+		lookaheadCount := int(u16(data, offset+6+2*backtrackCount))
+		substitutes := offset + 8 + 2*backtrackCount + 2*lookaheadCount
+		for i, g := range coverage {
+			if glyphs[g] {
+				glyphs[u16(data, substitutes+2+2*i)] = true
+			}
+		}
+	}
+	// Contextual substitutions (5 and 6) only reference other lookups, which
+	// are closed over on their own.
+}
+
+// parseCoverage returns the glyphs of a coverage table in coverage index
+// order.
+func parseCoverage(data []byte, offset int) []uint16 {
+	var coverage []uint16
+	switch u16(data, offset) {
+	case 1:
+		glyphCount := int(u16(data, offset+2))
+		for i := 0; i < glyphCount; i++ {
+			coverage = append(coverage, u16(data, offset+4+2*i))
+		}
+	case 2:
+		rangeCount := int(u16(data, offset+2))
+		for i := 0; i < rangeCount; i++ {
+			start := int(u16(data, offset+4+6*i))
+			end := int(u16(data, offset+4+6*i+2))
+			for g := start; g <= end; g++ {
+				coverage = append(coverage, uint16(g))
+			}
+		}
+	}
+	return coverage
+}
+
+func u16(data []byte, offset int) uint16 {
+	return binary.BigEndian.Uint16(data[offset:])
+}
+
+func u32(data []byte, offset int) uint32 {
+	return binary.BigEndian.Uint32(data[offset:])
+}
@@ -0,0 +1,76 @@
+package subsetter
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendUint16s(data []byte, values ...uint16) []byte {
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return data
+}
+
+func TestCloseOverGSUB(t *testing.T) {
+	// A GSUB table with a single substitution 1 -> 2 and a ligature
+	// substitution 3 + 4 -> 5
+	var gsub []byte
+	gsub = appendUint16s(gsub, 1, 0, 0, 0, 10) // header, lookup list at 10
+	gsub = appendUint16s(gsub, 2, 6, 26)       // lookup list, lookups at 16 and 36
+	gsub = appendUint16s(gsub, 1, 0, 1, 8)     // single substitution lookup
+	gsub = appendUint16s(gsub, 1, 6, 1)        // format 1 with a delta of 1
+	gsub = appendUint16s(gsub, 1, 1, 1)        // coverage of glyph 1
+	gsub = appendUint16s(gsub, 4, 0, 1, 8)     // ligature substitution lookup
+	gsub = appendUint16s(gsub, 1, 8, 1, 14)    // format 1 with one ligature set
+	gsub = appendUint16s(gsub, 1, 1, 3)        // coverage of glyph 3
+	gsub = appendUint16s(gsub, 1, 4)           // ligature set with one ligature
+	gsub = appendUint16s(gsub, 5, 2, 4)        // ligature 3 + 4 -> 5
+
+	tests := []struct {
+		glyphs   glyphSet
+		expected glyphSet
+	}{
+		{glyphSet{1: true}, glyphSet{1: true, 2: true}},
+		{glyphSet{1: true, 3: true}, glyphSet{1: true, 2: true, 3: true}},
+		{glyphSet{3: true, 4: true}, glyphSet{3: true, 4: true, 5: true}},
+	}
+	for _, tt := range tests {
+		require.NoError(t, closeOverGSUB(gsub, tt.glyphs))
+		assert.Equal(t, tt.expected, tt.glyphs)
+	}
+}
+
+func TestCloseOverGSUBMalformed(t *testing.T) {
+	gsub := appendUint16s(nil, 1, 0, 0, 0, 10, 1, 200)
+	assert.Error(t, closeOverGSUB(gsub, glyphSet{1: true}))
+}
+
+func TestCloseOverComposites(t *testing.T) {
+	composite := func(components ...uint16) []byte {
+		glyph := appendUint16s(nil, 0xFFFF, 0, 0, 0, 0)
+		for i, c := range components {
+			flags := uint16(0)
+			if i < len(components)-1 {
+				flags = 0x0020
+			}
+			glyph = appendUint16s(glyph, flags, c, 0)
+		}
+		return glyph
+	}
+	simple := appendUint16s(nil, 1, 0, 0, 0, 0)
+	outlines := [][]byte{
+		nil,
+		composite(2, 4),
+		composite(3),
+		simple,
+		simple,
+		simple,
+	}
+	glyphs := glyphSet{0: true, 1: true}
+	require.NoError(t, closeOverComposites(outlines, glyphs))
+	assert.Equal(t, glyphSet{0: true, 1: true, 2: true, 3: true, 4: true}, glyphs)
+}
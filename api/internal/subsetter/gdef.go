@@ -0,0 +1,129 @@
+package subsetter
+
+import (
+	"fmt"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// subsetGDEF subsets a GDEF table. Mark glyph sets are all retained, since
+// lookups refer to them by index.
+func subsetGDEF(gdef []byte, glyphs glyphMap) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed GDEF table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	s := &layoutSubsetter{data: gdef, glyphs: glyphs}
+	minorVersion := u16(gdef, 2)
+	classDef := func(offset int) *object {
+		if offset == 0 {
+			return nil
+		}
+		return buildClassDef(s.classDef(offset))
+	}
+	o := (&object{}).u16(1, minorVersion)
+	o.offset16(classDef(int(u16(gdef, 4))))
+	o.offset16(s.attachList(int(u16(gdef, 6))))
+	o.offset16(s.ligatureCaretList(int(u16(gdef, 8))))
+	o.offset16(classDef(int(u16(gdef, 10))))
+	if minorVersion >= 2 {
+		var markGlyphSets *object
+		if offset := int(u16(gdef, 12)); offset != 0 {
+			setCount := int(u16(gdef, offset+2))
+			markGlyphSets = (&object{}).u16(1, uint16(setCount))
+			for i := 0; i < setCount; i++ {
+				coverage := s.coverage(offset + int(u32(gdef, offset+4+4*i)))
+				markGlyphSets.offset32(buildCoverage(newGids(coverage)))
+			}
+		}
+		o.offset16(markGlyphSets)
+	}
+	if minorVersion >= 3 {
+		var store *object
+		if offset := int(u32(gdef, 14)); offset != 0 {
+			store = (&object{}).bytes(gdef[offset : offset+itemVariationStoreLength(gdef, offset)])
+		}
+		o.offset32(store)
+	}
+	out, err = pack(o)
+	if err != nil {
+		return nil, fmt.Errorf("GDEF: %w", err)
+	}
+	return out, nil
+}
+
+// attachList subsets the attachment point list of a GDEF table, or returns
+// nil if none of its glyphs are retained.
+func (s *layoutSubsetter) attachList(offset int) *object {
+	if offset == 0 {
+		return nil
+	}
+	glyphs := s.coverage(offset + int(u16(s.data, offset)))
+	if len(glyphs) == 0 {
+		return nil
+	}
+	o := (&object{}).offset16(buildCoverage(newGids(glyphs))).u16(uint16(len(glyphs)))
+	for _, g := range glyphs {
+		points := offset + int(u16(s.data, offset+4+2*g.index))
+		o.offset16((&object{}).bytes(s.data[points : points+2+2*int(u16(s.data, points))]))
+	}
+	return o
+}
+
+// ligatureCaretList subsets the ligature caret list of a GDEF table, or
+// returns nil if none of its glyphs are retained.
+func (s *layoutSubsetter) ligatureCaretList(offset int) *object {
+	if offset == 0 {
+		return nil
+	}
+	glyphs := s.coverage(offset + int(u16(s.data, offset)))
+	if len(glyphs) == 0 {
+		return nil
+	}
+	o := (&object{}).offset16(buildCoverage(newGids(glyphs))).u16(uint16(len(glyphs)))
+	for _, g := range glyphs {
+		ligature := offset + int(u16(s.data, offset+4+2*g.index))
+		caretCount := int(u16(s.data, ligature))
+		ligatureObject := (&object{}).u16(uint16(caretCount))
+		for i := 0; i < caretCount; i++ {
+			caret := ligature + int(u16(s.data, ligature+2+2*i))
+			caretObject := (&object{}).bytes(s.data[caret : caret+4])
+			// Format 3 carets are adjusted by a device table
+			if u16(s.data, caret) == 3 {
+				if device := int(u16(s.data, caret+4)); device != 0 {
+					caretObject.offset16(s.device(caret + device))
+				} else {
+					caretObject.offset16(nil)
+				}
+			}
+			ligatureObject.offset16(caretObject)
+		}
+		o.offset16(ligatureObject)
+	}
+	return o
+}
+
+// itemVariationStoreLength returns the length of an item variation store,
+// including the region list and item variation data it refers to.
+func itemVariationStoreLength(data []byte, offset int) int {
+	dataCount := int(u16(data, offset+6))
+	end := offset + 8 + 4*dataCount
+	regionList := offset + int(u32(data, offset+2))
+	end = max(end, regionList+4+6*int(u16(data, regionList))*int(u16(data, regionList+2)))
+	for i := 0; i < dataCount; i++ {
+		itemData := offset + int(u32(data, offset+8+4*i))
+		itemCount := int(u16(data, itemData))
+		wordDeltaCount := int(u16(data, itemData+2))
+		regionIndexCount := int(u16(data, itemData+4))
+		// Word deltas are 32-bit and the others 16-bit if the high bit of
+		// the word delta count is set, and 16-bit and 8-bit otherwise
+		words := wordDeltaCount & 0x7FFF
+		rowSize := 2*words + (regionIndexCount - words)
+		if wordDeltaCount&0x8000 != 0 {
+			rowSize *= 2
+		}
+		end = max(end, itemData+6+2*regionIndexCount+itemCount*rowSize)
+	}
+	return end - offset
+}
@@ -0,0 +1,1055 @@
+package subsetter
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Lookup types of extension subtables, which wrap a subtable of another type
+const (
+	gsubExtension = 7
+	gposExtension = 9
+)
+
+// useMarkFilteringSet is the lookup flag that marks lookups that end with the
+// index of a mark glyph set.
+const useMarkFilteringSet = 0x0010
+
+// layoutSubsetter subsets the subtables of a GSUB, GPOS or GDEF table to the
+// retained glyphs, which are renumbered.
+type layoutSubsetter struct {
+	data   []byte
+	gpos   bool
+	glyphs glyphMap
+	// referenced are the lookups referenced by the rules of the retained
+	// contextual subtables
+	referenced map[int]bool
+	// lookupRecords are the lookup indices written by contextual subtables,
+	// which are renumbered once the retained lookups are known
+	lookupRecords []lookupRecord
+}
+
+// lookupRecord is a lookup index that was written to an object.
+type lookupRecord struct {
+	object *object
+	pos    int
+	lookup int
+}
+
+// covered is a glyph of a coverage table that is retained.
+type covered struct {
+	// index is the coverage index of the glyph in the font
+	index int
+	old   uint16
+	new   uint16
+}
+
+// subsetLayout subsets a GSUB or GPOS table. Lookups are retained when they
+// have subtables that apply to retained glyphs or are referenced by retained
+// contextual rules, and features when they have retained lookups.
+func subsetLayout(table []byte, gpos bool, glyphs glyphMap) (out []byte, err error) {
+	tag := "GSUB"
+	if gpos {
+		tag = "GPOS"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed %s table: %v", sfnt.ErrInvalidFont, tag, r)
+		}
+	}()
+	s := &layoutSubsetter{data: table, gpos: gpos, glyphs: glyphs, referenced: make(map[int]bool)}
+	scriptList := int(u16(table, 4))
+	featureList := int(u16(table, 6))
+	lookupList := int(u16(table, 8))
+	featureVariations := 0
+	if u16(table, 2) >= 1 {
+		featureVariations = int(u32(table, 10))
+	}
+
+	// Subset the lookups, then renumber those that are retained
+	type subsettedLookup struct {
+		lookupType       uint16
+		flag             uint16
+		markFilteringSet uint16
+		subtables        []*object
+	}
+	lookups := make([]subsettedLookup, u16(table, lookupList))
+	for i := range lookups {
+		lookup := &lookups[i]
+		offset := lookupList + int(u16(table, lookupList+2+2*i))
+		lookup.lookupType = u16(table, offset)
+		lookup.flag = u16(table, offset+2)
+		subtableCount := int(u16(table, offset+4))
+		if lookup.flag&useMarkFilteringSet != 0 {
+			lookup.markFilteringSet = u16(table, offset+6+2*subtableCount)
+		}
+		extension := lookup.lookupType == s.extensionType()
+		for j := 0; j < subtableCount; j++ {
+			subtable := offset + int(u16(table, offset+6+2*j))
+			if extension {
+				lookup.lookupType = u16(table, subtable+2)
+				subtable += int(u32(table, subtable+4))
+			}
+			if o := s.subtable(lookup.lookupType, subtable); o != nil {
+				lookup.subtables = append(lookup.subtables, o)
+			}
+		}
+	}
+	newLookups := make(map[int]uint16)
+	for i, lookup := range lookups {
+		if len(lookup.subtables) > 0 || s.referenced[i] {
+			newLookups[i] = uint16(len(newLookups))
+		}
+	}
+	for _, r := range s.lookupRecords {
+		index, found := newLookups[r.lookup]
+		if !found {
+			panic("lookup index out of range")
+		}
+		r.object.data[r.pos] = byte(index >> 8)
+		r.object.data[r.pos+1] = byte(index)
+	}
+
+	// Retain the features with retained lookups, including those that only
+	// have lookups in their alternates for feature variations
+	featureCount := int(u16(table, featureList))
+	features := make([]*object, featureCount)
+	retainedFeatures := make([]bool, featureCount)
+	for i := range features {
+		featureTag := string(table[featureList+2+6*i : featureList+6+6*i])
+		features[i], retainedFeatures[i] = s.feature(featureList+int(u16(table, featureList+6+6*i)), featureTag, newLookups)
+	}
+	var substitutions []int
+	if featureVariations != 0 {
+		recordCount := int(u32(table, featureVariations+4))
+		for i := 0; i < recordCount; i++ {
+			substitution := int(u32(table, featureVariations+8+8*i+4))
+			if substitution == 0 {
+				continue
+			}
+			substitution += featureVariations
+			substitutions = append(substitutions, substitution)
+			substitutionCount := int(u16(table, substitution+4))
+			for j := 0; j < substitutionCount; j++ {
+				record := substitution + 6 + 6*j
+				featureIndex := int(u16(table, record))
+				_, retained := s.feature(substitution+int(u32(table, record+2)), "", newLookups)
+				retainedFeatures[featureIndex] = retainedFeatures[featureIndex] || retained
+			}
+		}
+	}
+	newFeatures := make(map[int]uint16)
+	for i, retained := range retainedFeatures {
+		if retained {
+			newFeatures[i] = uint16(len(newFeatures))
+		}
+	}
+
+	featureListObject := (&object{}).u16(uint16(len(newFeatures)))
+	for i := range features {
+		if retainedFeatures[i] {
+			featureListObject.bytes(table[featureList+2+6*i : featureList+6+6*i]).offset16(features[i])
+		}
+	}
+	scriptListObject := s.scriptList(scriptList, newFeatures)
+	var featureVariationsObject *object
+	if featureVariations != 0 {
+		featureVariationsObject = s.featureVariations(featureVariations, newFeatures, newLookups)
+	}
+
+	build := func(extension bool) ([]byte, error) {
+		lookupListObject := (&object{}).u16(uint16(len(newLookups)))
+		for i, lookup := range lookups {
+			if _, retained := newLookups[i]; !retained {
+				continue
+			}
+			lookupType := lookup.lookupType
+			if extension {
+				lookupType = s.extensionType()
+			}
+			o := (&object{}).u16(lookupType, lookup.flag, uint16(len(lookup.subtables)))
+			for _, subtable := range lookup.subtables {
+				if !extension {
+					o.offset16(subtable)
+					continue
+				}
+				// Extension subtables are packed on their own, so that
+				// their offsets stay short
+				packed, err := pack(subtable)
+				if err != nil {
+					return nil, err
+				}
+				o.offset16((&object{}).u16(1, lookup.lookupType).offset32((&object{}).bytes(packed)))
+			}
+			if lookup.flag&useMarkFilteringSet != 0 {
+				o.u16(lookup.markFilteringSet)
+			}
+			lookupListObject.offset16(o)
+		}
+		header := (&object{}).u16(1, 0).offset16(scriptListObject).offset16(featureListObject).offset16(lookupListObject)
+		if featureVariationsObject != nil {
+			header.data[3] = 1
+			header.offset32(featureVariationsObject)
+		}
+		return pack(header)
+	}
+	out, err = build(false)
+	if errors.Is(err, errOffsetOverflow) {
+		out, err = build(true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tag, err)
+	}
+	return out, nil
+}
+
+func (s *layoutSubsetter) extensionType() uint16 {
+	if s.gpos {
+		return gposExtension
+	}
+	return gsubExtension
+}
+
+// subtable subsets a lookup subtable. It returns nil if the subtable doesn't
+// apply to any of the retained glyphs or is of an unknown type.
+func (s *layoutSubsetter) subtable(lookupType uint16, offset int) *object {
+	if s.gpos {
+		switch lookupType {
+		case 1:
+			return s.singlePos(offset)
+		case 2:
+			return s.pairPos(offset)
+		case 3:
+			return s.cursivePos(offset)
+		case 4, 6:
+			return s.markPos(offset)
+		case 5:
+			return s.markLigaturePos(offset)
+		case 7:
+			return s.context(offset, false)
+		case 8:
+			return s.context(offset, true)
+		}
+		return nil
+	}
+	switch lookupType {
+	case 1:
+		return s.singleSubst(offset)
+	case 2:
+		return s.sequenceSubst(offset, false)
+	case 3:
+		return s.sequenceSubst(offset, true)
+	case 4:
+		return s.ligatureSubst(offset)
+	case 5:
+		return s.context(offset, false)
+	case 6:
+		return s.context(offset, true)
+	case 8:
+		return s.reverseChainSubst(offset)
+	}
+	return nil
+}
+
+// feature subsets a feature table, keeping its retained lookups. It reports
+// whether the feature has retained lookups or feature parameters.
+func (s *layoutSubsetter) feature(offset int, tag string, lookups map[int]uint16) (*object, bool) {
+	var params *object
+	if paramsOffset := int(u16(s.data, offset)); paramsOffset != 0 {
+		params = s.featureParams(offset+paramsOffset, tag)
+	}
+	lookupCount := int(u16(s.data, offset+2))
+	var indices []uint16
+	for i := 0; i < lookupCount; i++ {
+		if index, retained := lookups[int(u16(s.data, offset+4+2*i))]; retained {
+			indices = append(indices, index)
+		}
+	}
+	o := (&object{}).offset16(params).u16(uint16(len(indices))).u16(indices...)
+	return o, params != nil || len(indices) > 0
+}
+
+// featureParams copies the parameters of the features whose parameters are
+// known, which are size, stylistic sets and character variants.
+func (s *layoutSubsetter) featureParams(offset int, tag string) *object {
+	length := 0
+	switch {
+	case tag == "size":
+		length = 10
+	case len(tag) == 4 && tag[:2] == "ss":
+		length = 4
+	case len(tag) == 4 && tag[:2] == "cv":
+		length = 14 + 3*int(u16(s.data, offset+12))
+	default:
+		return nil
+	}
+	return (&object{}).bytes(s.data[offset : offset+length])
+}
+
+// scriptList subsets a script list, renumbering the features of its
+// language systems.
+func (s *layoutSubsetter) scriptList(offset int, features map[int]uint16) *object {
+	langSys := func(offset int) *object {
+		o := (&object{}).u16(0)
+		required, found := features[int(u16(s.data, offset+2))]
+		if !found {
+			required = 0xFFFF
+		}
+		o.u16(required)
+		featureCount := int(u16(s.data, offset+4))
+		var indices []uint16
+		for i := 0; i < featureCount; i++ {
+			if index, found := features[int(u16(s.data, offset+6+2*i))]; found {
+				indices = append(indices, index)
+			}
+		}
+		return o.u16(uint16(len(indices))).u16(indices...)
+	}
+	scriptCount := int(u16(s.data, offset))
+	o := (&object{}).u16(uint16(scriptCount))
+	for i := 0; i < scriptCount; i++ {
+		record := offset + 2 + 6*i
+		script := offset + int(u16(s.data, record+4))
+		scriptObject := &object{}
+		if defaultLangSys := int(u16(s.data, script)); defaultLangSys != 0 {
+			scriptObject.offset16(langSys(script + defaultLangSys))
+		} else {
+			scriptObject.offset16(nil)
+		}
+		langSysCount := int(u16(s.data, script+2))
+		scriptObject.u16(uint16(langSysCount))
+		for j := 0; j < langSysCount; j++ {
+			langSysRecord := script + 4 + 6*j
+			scriptObject.bytes(s.data[langSysRecord : langSysRecord+4])
+			scriptObject.offset16(langSys(script + int(u16(s.data, langSysRecord+4))))
+		}
+		o.bytes(s.data[record : record+4]).offset16(scriptObject)
+	}
+	return o
+}
+
+// featureVariations subsets a feature variations table, dropping the
+// substitutions of features that aren't retained.
+func (s *layoutSubsetter) featureVariations(offset int, features map[int]uint16, lookups map[int]uint16) *object {
+	recordCount := int(u32(s.data, offset+4))
+	o := (&object{}).bytes(s.data[offset : offset+4]).u32(uint32(recordCount))
+	for i := 0; i < recordCount; i++ {
+		record := offset + 8 + 8*i
+		var conditionSet *object
+		if conditionSetOffset := int(u32(s.data, record)); conditionSetOffset != 0 {
+			conditionSet = &object{}
+			conditionSetOffset += offset
+			conditionCount := int(u16(s.data, conditionSetOffset))
+			conditionSet.u16(uint16(conditionCount))
+			for j := 0; j < conditionCount; j++ {
+				condition := conditionSetOffset + int(u32(s.data, conditionSetOffset+2+4*j))
+				if u16(s.data, condition) != 1 {
+					panic("unknown condition format")
+				}
+				conditionSet.offset32((&object{}).bytes(s.data[condition : condition+8]))
+			}
+		}
+		var substitution *object
+		if substitutionOffset := int(u32(s.data, record+4)); substitutionOffset != 0 {
+			substitutionOffset += offset
+			substitutionCount := int(u16(s.data, substitutionOffset+4))
+			substitution = (&object{}).bytes(s.data[substitutionOffset : substitutionOffset+4]).u16(0)
+			retained := 0
+			for j := 0; j < substitutionCount; j++ {
+				substitutionRecord := substitutionOffset + 6 + 6*j
+				index, found := features[int(u16(s.data, substitutionRecord))]
+				if !found {
+					continue
+				}
+				alternate, _ := s.feature(substitutionOffset+int(u32(s.data, substitutionRecord+2)), "", lookups)
+				substitution.u16(index).offset32(alternate)
+				retained++
+			}
+			substitution.data[4] = byte(retained >> 8)
+			substitution.data[5] = byte(retained)
+		}
+		o.offset32(conditionSet).offset32(substitution)
+	}
+	return o
+}
+
+// coverage returns the retained glyphs of a coverage table.
+func (s *layoutSubsetter) coverage(offset int) []covered {
+	var glyphs []covered
+	for i, gid := range parseCoverage(s.data, offset) {
+		if newGid, retained := s.glyphs.newGids[gid]; retained {
+			glyphs = append(glyphs, covered{i, gid, newGid})
+		}
+	}
+	return glyphs
+}
+
+// coverages subsets a list of offsets to coverage tables, relative to base.
+// It reports false if any of the coverage tables has no retained glyphs.
+func (s *layoutSubsetter) coverages(base int, offset int, count int) ([]*object, bool) {
+	var coverages []*object
+	for i := 0; i < count; i++ {
+		glyphs := s.coverage(base + int(u16(s.data, offset+2*i)))
+		if len(glyphs) == 0 {
+			return nil, false
+		}
+		coverages = append(coverages, buildCoverage(newGids(glyphs)))
+	}
+	return coverages, true
+}
+
+// newGids returns the new glyph ids of covered glyphs.
+func newGids(glyphs []covered) []uint16 {
+	gids := make([]uint16, len(glyphs))
+	for i, g := range glyphs {
+		gids[i] = g.new
+	}
+	return gids
+}
+
+// buildCoverage builds a coverage table of sorted glyph ids, using ranges if
+// that is smaller.
+func buildCoverage(gids []uint16) *object {
+	ranges := 0
+	for i, gid := range gids {
+		if i == 0 || gid != gids[i-1]+1 {
+			ranges++
+		}
+	}
+	if 3*ranges >= len(gids) {
+		return (&object{}).u16(1, uint16(len(gids))).u16(gids...)
+	}
+	o := (&object{}).u16(2, uint16(ranges))
+	for i := 0; i < len(gids); {
+		j := i + 1
+		for j < len(gids) && gids[j] == gids[j-1]+1 {
+			j++
+		}
+		o.u16(gids[i], gids[j-1], uint16(i))
+		i = j
+	}
+	return o
+}
+
+// classDef returns the classes of the retained glyphs of a class definition
+// table, by new glyph id. Glyphs of class 0 are left out.
+func (s *layoutSubsetter) classDef(offset int) map[uint16]uint16 {
+	classes := make(map[uint16]uint16)
+	add := func(gid int, class uint16) {
+		if newGid, retained := s.glyphs.newGids[uint16(gid)]; retained && class != 0 {
+			classes[newGid] = class
+		}
+	}
+	switch u16(s.data, offset) {
+	case 1:
+		start := int(u16(s.data, offset+2))
+		glyphCount := int(u16(s.data, offset+4))
+		for i := 0; i < glyphCount; i++ {
+			add(start+i, u16(s.data, offset+6+2*i))
+		}
+	case 2:
+		rangeCount := int(u16(s.data, offset+2))
+		for i := 0; i < rangeCount; i++ {
+			record := offset + 4 + 6*i
+			for gid := int(u16(s.data, record)); gid <= int(u16(s.data, record+2)); gid++ {
+				add(gid, u16(s.data, record+4))
+			}
+		}
+	}
+	return classes
+}
+
+// buildClassDef builds a class definition table from the classes of glyphs,
+// using ranges if that is smaller.
+func buildClassDef(classes map[uint16]uint16) *object {
+	var gids []uint16
+	for gid := range classes {
+		gids = append(gids, gid)
+	}
+	slices.Sort(gids)
+	ranges := 0
+	for i, gid := range gids {
+		if i == 0 || gid != gids[i-1]+1 || classes[gid] != classes[gids[i-1]] {
+			ranges++
+		}
+	}
+	if len(gids) > 0 && 6+2*int(gids[len(gids)-1]-gids[0]+1) < 4+6*ranges {
+		first, last := gids[0], gids[len(gids)-1]
+		o := (&object{}).u16(1, first, last-first+1)
+		for gid := int(first); gid <= int(last); gid++ {
+			o.u16(classes[uint16(gid)])
+		}
+		return o
+	}
+	o := (&object{}).u16(2, uint16(ranges))
+	for i := 0; i < len(gids); {
+		j := i + 1
+		for j < len(gids) && gids[j] == gids[j-1]+1 && classes[gids[j]] == classes[gids[i]] {
+			j++
+		}
+		o.u16(gids[i], gids[j-1], classes[gids[i]])
+		i = j
+	}
+	return o
+}
+
+// glyphList returns the new ids of the retained glyphs of a list of glyph
+// ids, and whether all of them are retained.
+func (s *layoutSubsetter) glyphList(offset int, count int) ([]uint16, bool) {
+	gids := []uint16{}
+	complete := true
+	for i := 0; i < count; i++ {
+		if gid, retained := s.glyphs.newGids[u16(s.data, offset+2*i)]; retained {
+			gids = append(gids, gid)
+		} else {
+			complete = false
+		}
+	}
+	return gids, complete
+}
+
+// device copies a device or variation index table.
+func (s *layoutSubsetter) device(offset int) *object {
+	length := 6
+	if format := int(u16(s.data, offset+4)); format >= 1 && format <= 3 {
+		count := int(u16(s.data, offset+2)) - int(u16(s.data, offset)) + 1
+		length += 2 * ((count<<format + 15) / 16)
+	}
+	return (&object{}).bytes(s.data[offset : offset+length])
+}
+
+// anchor copies the anchor table at an offset from base, or returns nil for
+// null offsets.
+func (s *layoutSubsetter) anchor(base int, offset uint16) *object {
+	if offset == 0 {
+		return nil
+	}
+	anchor := base + int(offset)
+	switch u16(s.data, anchor) {
+	case 2:
+		return (&object{}).bytes(s.data[anchor : anchor+8])
+	case 3:
+		o := (&object{}).bytes(s.data[anchor : anchor+6])
+		for _, device := range []int{anchor + 6, anchor + 8} {
+			if deviceOffset := u16(s.data, device); deviceOffset != 0 {
+				o.offset16(s.device(anchor + int(deviceOffset)))
+			} else {
+				o.offset16(nil)
+			}
+		}
+		return o
+	}
+	return (&object{}).bytes(s.data[anchor : anchor+6])
+}
+
+// valueRecordSize returns the size of value records of a value format.
+func valueRecordSize(valueFormat uint16) int {
+	return 2 * bits.OnesCount16(valueFormat&0xFF)
+}
+
+// valueRecord copies a value record to an object. The device tables of value
+// records are at offsets from the start of base, which the object replaces.
+func (s *layoutSubsetter) valueRecord(o *object, base int, offset int, valueFormat uint16) {
+	for bit := uint16(1); bit <= 0x80; bit <<= 1 {
+		if valueFormat&bit == 0 {
+			continue
+		}
+		value := u16(s.data, offset)
+		offset += 2
+		switch {
+		case bit < 0x10:
+			o.u16(value)
+		case value != 0:
+			o.offset16(s.device(base + int(value)))
+		default:
+			o.offset16(nil)
+		}
+	}
+}
+
+// writeLookupRecords copies sequence lookup records to an object, recording
+// their lookup indices to be renumbered.
+func (s *layoutSubsetter) writeLookupRecords(o *object, offset int, count int) {
+	for i := 0; i < count; i++ {
+		lookup := int(u16(s.data, offset+4*i+2))
+		o.u16(u16(s.data, offset+4*i))
+		s.lookupRecords = append(s.lookupRecords, lookupRecord{o, len(o.data), lookup})
+		s.referenced[lookup] = true
+		o.u16(uint16(lookup))
+	}
+}
+
+// singleSubst subsets a single substitution subtable.
+func (s *layoutSubsetter) singleSubst(offset int) *object {
+	format := u16(s.data, offset)
+	var gids, substitutes []uint16
+	for _, g := range s.coverage(offset + int(u16(s.data, offset+2))) {
+		substitute := g.old + u16(s.data, offset+4)
+		if format == 2 {
+			substitute = u16(s.data, offset+6+2*g.index)
+		}
+		if newSubstitute, retained := s.glyphs.newGids[substitute]; retained {
+			gids = append(gids, g.new)
+			substitutes = append(substitutes, newSubstitute)
+		}
+	}
+	if len(gids) == 0 {
+		return nil
+	}
+	delta := substitutes[0] - gids[0]
+	for i := range gids {
+		if substitutes[i]-gids[i] != delta {
+			return (&object{}).u16(2).offset16(buildCoverage(gids)).u16(uint16(len(substitutes))).u16(substitutes...)
+		}
+	}
+	return (&object{}).u16(1).offset16(buildCoverage(gids)).u16(delta)
+}
+
+// sequenceSubst subsets a multiple or alternate substitution subtable.
+// Sequences are retained when all their glyphs are, and alternates when any
+// of them are.
+func (s *layoutSubsetter) sequenceSubst(offset int, alternates bool) *object {
+	var gids []uint16
+	var sequences []*object
+	for _, g := range s.coverage(offset + int(u16(s.data, offset+2))) {
+		sequence := offset + int(u16(s.data, offset+6+2*g.index))
+		glyphs, complete := s.glyphList(sequence+2, int(u16(s.data, sequence)))
+		if (!alternates && !complete) || (alternates && len(glyphs) == 0) {
+			continue
+		}
+		gids = append(gids, g.new)
+		sequences = append(sequences, (&object{}).u16(uint16(len(glyphs))).u16(glyphs...))
+	}
+	if len(gids) == 0 {
+		return nil
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(gids)).u16(uint16(len(sequences)))
+	for _, sequence := range sequences {
+		o.offset16(sequence)
+	}
+	return o
+}
+
+// ligatureSubst subsets a ligature substitution subtable, retaining the
+// ligatures of which all glyphs are retained.
+func (s *layoutSubsetter) ligatureSubst(offset int) *object {
+	var gids []uint16
+	var ligatureSets []*object
+	for _, g := range s.coverage(offset + int(u16(s.data, offset+2))) {
+		ligatureSet := offset + int(u16(s.data, offset+6+2*g.index))
+		ligatureCount := int(u16(s.data, ligatureSet))
+		var ligatures []*object
+		for i := 0; i < ligatureCount; i++ {
+			ligature := ligatureSet + int(u16(s.data, ligatureSet+2+2*i))
+			ligatureGlyph, retained := s.glyphs.newGids[u16(s.data, ligature)]
+			componentCount := u16(s.data, ligature+2)
+			components, complete := s.glyphList(ligature+4, int(componentCount)-1)
+			if retained && complete {
+				ligatures = append(ligatures, (&object{}).u16(ligatureGlyph, componentCount).u16(components...))
+			}
+		}
+		if len(ligatures) == 0 {
+			continue
+		}
+		o := (&object{}).u16(uint16(len(ligatures)))
+		for _, ligature := range ligatures {
+			o.offset16(ligature)
+		}
+		gids = append(gids, g.new)
+		ligatureSets = append(ligatureSets, o)
+	}
+	if len(gids) == 0 {
+		return nil
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(gids)).u16(uint16(len(ligatureSets)))
+	for _, ligatureSet := range ligatureSets {
+		o.offset16(ligatureSet)
+	}
+	return o
+}
+
+// reverseChainSubst subsets a reverse chaining contextual single
+// substitution subtable.
+func (s *layoutSubsetter) reverseChainSubst(offset int) *object {
+	backtrackCount := int(u16(s.data, offset+4))
+	backtrack, ok := s.coverages(offset, offset+6, backtrackCount)
+	if !ok {
+		return nil
+	}
+	lookahead := offset + 6 + 2*backtrackCount
+	lookaheadCount := int(u16(s.data, lookahead))
+	lookaheads, ok := s.coverages(offset, lookahead+2, lookaheadCount)
+	if !ok {
+		return nil
+	}
+	substitutes := lookahead + 2 + 2*lookaheadCount + 2
+	var gids, newSubstitutes []uint16
+	for _, g := range s.coverage(offset + int(u16(s.data, offset+2))) {
+		if substitute, retained := s.glyphs.newGids[u16(s.data, substitutes+2*g.index)]; retained {
+			gids = append(gids, g.new)
+			newSubstitutes = append(newSubstitutes, substitute)
+		}
+	}
+	if len(gids) == 0 {
+		return nil
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(gids)).u16(uint16(len(backtrack)))
+	for _, coverage := range backtrack {
+		o.offset16(coverage)
+	}
+	o.u16(uint16(len(lookaheads)))
+	for _, coverage := range lookaheads {
+		o.offset16(coverage)
+	}
+	return o.u16(uint16(len(newSubstitutes))).u16(newSubstitutes...)
+}
+
+// context subsets a contextual or chained contextual subtable of either
+// table, which share their formats.
+func (s *layoutSubsetter) context(offset int, chained bool) *object {
+	switch u16(s.data, offset) {
+	case 1:
+		var gids []uint16
+		var ruleSets []*object
+		for _, g := range s.coverage(offset + int(u16(s.data, offset+2))) {
+			ruleSetOffset := int(u16(s.data, offset+6+2*g.index))
+			if ruleSetOffset == 0 {
+				continue
+			}
+			if ruleSet := s.ruleSet(offset+ruleSetOffset, chained, false); ruleSet != nil {
+				gids = append(gids, g.new)
+				ruleSets = append(ruleSets, ruleSet)
+			}
+		}
+		if len(gids) == 0 {
+			return nil
+		}
+		o := (&object{}).u16(1).offset16(buildCoverage(gids)).u16(uint16(len(ruleSets)))
+		for _, ruleSet := range ruleSets {
+			o.offset16(ruleSet)
+		}
+		return o
+	case 2:
+		glyphs := s.coverage(offset + int(u16(s.data, offset+2)))
+		if len(glyphs) == 0 {
+			return nil
+		}
+		o := (&object{}).u16(2).offset16(buildCoverage(newGids(glyphs)))
+		classDefCount := 1
+		if chained {
+			classDefCount = 3
+		}
+		for i := 0; i < classDefCount; i++ {
+			if classDef := int(u16(s.data, offset+4+2*i)); classDef != 0 {
+				o.offset16(buildClassDef(s.classDef(offset + classDef)))
+			} else {
+				o.offset16(nil)
+			}
+		}
+		ruleSets := offset + 4 + 2*classDefCount
+		ruleSetCount := int(u16(s.data, ruleSets))
+		o.u16(uint16(ruleSetCount))
+		for i := 0; i < ruleSetCount; i++ {
+			if ruleSet := int(u16(s.data, ruleSets+2+2*i)); ruleSet != 0 {
+				o.offset16(s.ruleSet(offset+ruleSet, chained, true))
+			} else {
+				o.offset16(nil)
+			}
+		}
+		return o
+	case 3:
+		if !chained {
+			glyphCount := int(u16(s.data, offset+2))
+			lookupCount := int(u16(s.data, offset+4))
+			coverages, ok := s.coverages(offset, offset+6, glyphCount)
+			if !ok {
+				return nil
+			}
+			o := (&object{}).u16(3, uint16(glyphCount), uint16(lookupCount))
+			for _, coverage := range coverages {
+				o.offset16(coverage)
+			}
+			s.writeLookupRecords(o, offset+6+2*glyphCount, lookupCount)
+			return o
+		}
+		var sequences [][]*object
+		position := offset + 2
+		for range 3 {
+			count := int(u16(s.data, position))
+			coverages, ok := s.coverages(offset, position+2, count)
+			if !ok {
+				return nil
+			}
+			sequences = append(sequences, coverages)
+			position += 2 + 2*count
+		}
+		o := (&object{}).u16(3)
+		for _, coverages := range sequences {
+			o.u16(uint16(len(coverages)))
+			for _, coverage := range coverages {
+				o.offset16(coverage)
+			}
+		}
+		lookupCount := int(u16(s.data, position))
+		o.u16(uint16(lookupCount))
+		s.writeLookupRecords(o, position+2, lookupCount)
+		return o
+	}
+	return nil
+}
+
+// ruleSet subsets a rule set of a contextual subtable. Rules of glyphs are
+// retained when all their glyphs are, rules of classes are copied. It returns
+// nil if no rules are retained.
+func (s *layoutSubsetter) ruleSet(offset int, chained bool, classes bool) *object {
+	ruleCount := int(u16(s.data, offset))
+	var rules []*object
+	for i := 0; i < ruleCount; i++ {
+		if rule := s.rule(offset+int(u16(s.data, offset+2+2*i)), chained, classes); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	o := (&object{}).u16(uint16(len(rules)))
+	for _, rule := range rules {
+		o.offset16(rule)
+	}
+	return o
+}
+
+func (s *layoutSubsetter) rule(offset int, chained bool, classes bool) *object {
+	o := &object{}
+	sequence := func(offset int, count int) bool {
+		if classes {
+			o.bytes(s.data[offset : offset+2*max(count, 0)])
+			return true
+		}
+		gids, complete := s.glyphList(offset, count)
+		o.u16(gids...)
+		return complete
+	}
+	if !chained {
+		glyphCount := int(u16(s.data, offset))
+		lookupCount := int(u16(s.data, offset+2))
+		o.u16(uint16(glyphCount), uint16(lookupCount))
+		if !sequence(offset+4, glyphCount-1) {
+			return nil
+		}
+		s.writeLookupRecords(o, offset+4+2*max(glyphCount-1, 0), lookupCount)
+		return o
+	}
+	// Backtrack, input and lookahead sequences, of which the input sequence
+	// leaves out the first glyph
+	for i := range 3 {
+		count := int(u16(s.data, offset))
+		o.u16(uint16(count))
+		if i == 1 {
+			count--
+		}
+		if !sequence(offset+2, count) {
+			return nil
+		}
+		offset += 2 + 2*max(count, 0)
+	}
+	lookupCount := int(u16(s.data, offset))
+	o.u16(uint16(lookupCount))
+	s.writeLookupRecords(o, offset+2, lookupCount)
+	return o
+}
+
+// singlePos subsets a single adjustment subtable.
+func (s *layoutSubsetter) singlePos(offset int) *object {
+	format := u16(s.data, offset)
+	glyphs := s.coverage(offset + int(u16(s.data, offset+2)))
+	if len(glyphs) == 0 {
+		return nil
+	}
+	valueFormat := u16(s.data, offset+4)
+	o := (&object{}).u16(format).offset16(buildCoverage(newGids(glyphs))).u16(valueFormat)
+	if format == 1 {
+		s.valueRecord(o, offset, offset+6, valueFormat)
+		return o
+	}
+	o.u16(uint16(len(glyphs)))
+	for _, g := range glyphs {
+		s.valueRecord(o, offset, offset+8+valueRecordSize(valueFormat)*g.index, valueFormat)
+	}
+	return o
+}
+
+// pairPos subsets a pair adjustment subtable. The classes of class pair
+// adjustments that have no retained glyphs are removed.
+func (s *layoutSubsetter) pairPos(offset int) *object {
+	glyphs := s.coverage(offset + int(u16(s.data, offset+2)))
+	valueFormat1 := u16(s.data, offset+4)
+	valueFormat2 := u16(s.data, offset+6)
+	size1, size2 := valueRecordSize(valueFormat1), valueRecordSize(valueFormat2)
+	switch u16(s.data, offset) {
+	case 1:
+		var gids []uint16
+		var pairSets []*object
+		for _, g := range glyphs {
+			pairSet := offset + int(u16(s.data, offset+10+2*g.index))
+			pairCount := int(u16(s.data, pairSet))
+			o := (&object{}).u16(0)
+			retained := 0
+			for i := 0; i < pairCount; i++ {
+				record := pairSet + 2 + (2+size1+size2)*i
+				second, found := s.glyphs.newGids[u16(s.data, record)]
+				if !found {
+					continue
+				}
+				o.u16(second)
+				s.valueRecord(o, pairSet, record+2, valueFormat1)
+				s.valueRecord(o, pairSet, record+2+size1, valueFormat2)
+				retained++
+			}
+			if retained == 0 {
+				continue
+			}
+			o.data[0] = byte(retained >> 8)
+			o.data[1] = byte(retained)
+			gids = append(gids, g.new)
+			pairSets = append(pairSets, o)
+		}
+		if len(gids) == 0 {
+			return nil
+		}
+		o := (&object{}).u16(1).offset16(buildCoverage(gids)).u16(valueFormat1, valueFormat2, uint16(len(pairSets)))
+		for _, pairSet := range pairSets {
+			o.offset16(pairSet)
+		}
+		return o
+	case 2:
+		if len(glyphs) == 0 {
+			return nil
+		}
+		classDef1 := s.classDef(offset + int(u16(s.data, offset+8)))
+		classDef2 := s.classDef(offset + int(u16(s.data, offset+10)))
+		class2Count := int(u16(s.data, offset+14))
+		// The first classes are only needed for covered glyphs
+		used1 := map[uint16]bool{0: true}
+		covered := make(map[uint16]bool)
+		for _, g := range glyphs {
+			used1[classDef1[g.new]] = true
+			covered[g.new] = true
+		}
+		used2 := map[uint16]bool{0: true}
+		for _, class := range classDef2 {
+			used2[class] = true
+		}
+		classes1, newClasses1 := renumberClasses(used1)
+		classes2, newClasses2 := renumberClasses(used2)
+		newClassDef1 := make(map[uint16]uint16)
+		for gid, class := range classDef1 {
+			if covered[gid] {
+				newClassDef1[gid] = newClasses1[class]
+			}
+		}
+		newClassDef2 := make(map[uint16]uint16)
+		for gid, class := range classDef2 {
+			newClassDef2[gid] = newClasses2[class]
+		}
+		o := (&object{}).u16(2).offset16(buildCoverage(newGids(glyphs))).u16(valueFormat1, valueFormat2)
+		o.offset16(buildClassDef(newClassDef1)).offset16(buildClassDef(newClassDef2))
+		o.u16(uint16(len(classes1)), uint16(len(classes2)))
+		for _, class1 := range classes1 {
+			for _, class2 := range classes2 {
+				record := offset + 16 + (size1+size2)*(int(class1)*class2Count+int(class2))
+				s.valueRecord(o, offset, record, valueFormat1)
+				s.valueRecord(o, offset, record+size1, valueFormat2)
+			}
+		}
+		return o
+	}
+	return nil
+}
+
+// renumberClasses returns the used classes in order and their new numbers.
+func renumberClasses(used map[uint16]bool) ([]uint16, map[uint16]uint16) {
+	var classes []uint16
+	for class := range used {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+	newClasses := make(map[uint16]uint16)
+	for i, class := range classes {
+		newClasses[class] = uint16(i)
+	}
+	return classes, newClasses
+}
+
+// cursivePos subsets a cursive attachment subtable.
+func (s *layoutSubsetter) cursivePos(offset int) *object {
+	glyphs := s.coverage(offset + int(u16(s.data, offset+2)))
+	if len(glyphs) == 0 {
+		return nil
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(newGids(glyphs))).u16(uint16(len(glyphs)))
+	for _, g := range glyphs {
+		record := offset + 6 + 4*g.index
+		o.offset16(s.anchor(offset, u16(s.data, record))).offset16(s.anchor(offset, u16(s.data, record+2)))
+	}
+	return o
+}
+
+// markPos subsets a mark-to-base or mark-to-mark attachment subtable, which
+// have the same structure.
+func (s *layoutSubsetter) markPos(offset int) *object {
+	marks := s.coverage(offset + int(u16(s.data, offset+2)))
+	bases := s.coverage(offset + int(u16(s.data, offset+4)))
+	if len(marks) == 0 || len(bases) == 0 {
+		return nil
+	}
+	classCount := int(u16(s.data, offset+6))
+	baseArray := offset + int(u16(s.data, offset+10))
+	baseArrayObject := (&object{}).u16(uint16(len(bases)))
+	for _, g := range bases {
+		record := baseArray + 2 + 2*classCount*g.index
+		for i := 0; i < classCount; i++ {
+			baseArrayObject.offset16(s.anchor(baseArray, u16(s.data, record+2*i)))
+		}
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(newGids(marks))).offset16(buildCoverage(newGids(bases)))
+	return o.u16(uint16(classCount)).offset16(s.markArray(offset+int(u16(s.data, offset+8)), marks)).offset16(baseArrayObject)
+}
+
+// markLigaturePos subsets a mark-to-ligature attachment subtable.
+func (s *layoutSubsetter) markLigaturePos(offset int) *object {
+	marks := s.coverage(offset + int(u16(s.data, offset+2)))
+	ligatures := s.coverage(offset + int(u16(s.data, offset+4)))
+	if len(marks) == 0 || len(ligatures) == 0 {
+		return nil
+	}
+	classCount := int(u16(s.data, offset+6))
+	ligatureArray := offset + int(u16(s.data, offset+10))
+	ligatureArrayObject := (&object{}).u16(uint16(len(ligatures)))
+	for _, g := range ligatures {
+		attach := ligatureArray + int(u16(s.data, ligatureArray+2+2*g.index))
+		componentCount := int(u16(s.data, attach))
+		attachObject := (&object{}).u16(uint16(componentCount))
+		for i := 0; i < componentCount*classCount; i++ {
+			attachObject.offset16(s.anchor(attach, u16(s.data, attach+2+2*i)))
+		}
+		ligatureArrayObject.offset16(attachObject)
+	}
+	o := (&object{}).u16(1).offset16(buildCoverage(newGids(marks))).offset16(buildCoverage(newGids(ligatures)))
+	return o.u16(uint16(classCount)).offset16(s.markArray(offset+int(u16(s.data, offset+8)), marks)).offset16(ligatureArrayObject)
+}
+
+// markArray subsets the mark array of an attachment subtable to the retained
+// marks.
+func (s *layoutSubsetter) markArray(offset int, marks []covered) *object {
+	o := (&object{}).u16(uint16(len(marks)))
+	for _, g := range marks {
+		record := offset + 2 + 4*g.index
+		o.u16(u16(s.data, record)).offset16(s.anchor(offset, u16(s.data, record+2)))
+	}
+	return o
+}
@@ -0,0 +1,146 @@
+package subsetter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGSUB returns a GSUB table with a single substitution 1 -> 2 in the ss01
+// feature and a ligature substitution 3 + 4 -> 5 in the liga feature.
+func testGSUB(t *testing.T) []byte {
+	langSys := (&object{}).u16(0, 0xFFFF, 2, 0, 1)
+	script := (&object{}).offset16(langSys).u16(0)
+	scriptList := (&object{}).u16(1).bytes([]byte("DFLT")).offset16(script)
+	featureList := (&object{}).u16(2).
+		bytes([]byte("liga")).offset16((&object{}).u16(0, 1, 1)).
+		bytes([]byte("ss01")).offset16((&object{}).u16(0, 1, 0))
+	single := (&object{}).u16(1).offset16(buildCoverage([]uint16{1})).u16(1)
+	ligature := (&object{}).u16(5, 2, 4)
+	ligatureSet := (&object{}).u16(1).offset16(ligature)
+	ligatures := (&object{}).u16(1).offset16(buildCoverage([]uint16{3})).u16(1).offset16(ligatureSet)
+	lookupList := (&object{}).u16(2).
+		offset16((&object{}).u16(1, 0, 1).offset16(single)).
+		offset16((&object{}).u16(4, 0, 1).offset16(ligatures))
+	header := (&object{}).u16(1, 0).offset16(scriptList).offset16(featureList).offset16(lookupList)
+	gsub, err := pack(header)
+	require.NoError(t, err)
+	return gsub
+}
+
+// features returns the lookup indices of the features of a GSUB or GPOS
+// table by tag.
+func features(table []byte) map[string][]uint16 {
+	featureList := int(u16(table, 6))
+	features := make(map[string][]uint16)
+	for i := 0; i < int(u16(table, featureList)); i++ {
+		tag := string(table[featureList+2+6*i : featureList+6+6*i])
+		feature := featureList + int(u16(table, featureList+6+6*i))
+		features[tag] = []uint16{}
+		for j := 0; j < int(u16(table, feature+2)); j++ {
+			features[tag] = append(features[tag], u16(table, feature+4+2*j))
+		}
+	}
+	return features
+}
+
+func TestSubsetLayout(t *testing.T) {
+	gsub := testGSUB(t)
+
+	tests := []struct {
+		glyphs   glyphSet
+		features map[string][]uint16
+		// closure is the closure of the first two glyphs of the subset
+		closure glyphSet
+	}{
+		{
+			glyphs:   glyphSet{0: true, 1: true, 2: true, 3: true, 4: true, 5: true},
+			features: map[string][]uint16{"liga": {1}, "ss01": {0}},
+			closure:  glyphSet{1: true, 2: true},
+		},
+		{
+			glyphs:   glyphSet{0: true, 1: true, 2: true},
+			features: map[string][]uint16{"ss01": {0}},
+			closure:  glyphSet{1: true, 2: true},
+		},
+		{
+			glyphs:   glyphSet{0: true, 3: true, 4: true, 5: true},
+			features: map[string][]uint16{"liga": {0}},
+			closure:  glyphSet{1: true, 2: true, 3: true},
+		},
+		{
+			glyphs:   glyphSet{0: true, 3: true, 5: true},
+			features: map[string][]uint16{},
+			closure:  glyphSet{1: true, 2: true},
+		},
+	}
+	for _, tt := range tests {
+		out, err := subsetLayout(gsub, false, newGlyphMap(tt.glyphs, 6, true))
+		require.NoError(t, err)
+		assert.Equal(t, tt.features, features(out))
+
+		// The default language system only refers to the retained features
+		scriptList := int(u16(out, 4))
+		langSys := scriptList + int(u16(out, scriptList+6))
+		langSys += int(u16(out, langSys))
+		assert.Equal(t, len(tt.features), int(u16(out, langSys+4)))
+
+		closure := glyphSet{1: true, 2: true}
+		require.NoError(t, closeOverGSUB(out, closure))
+		assert.Equal(t, tt.closure, closure)
+	}
+}
+
+func TestSubsetLayoutPairPos(t *testing.T) {
+	// A pair adjustment by class where the advance of a pair is ten times the
+	// class of the first glyph plus the class of the second glyph
+	pairs := (&object{}).u16(2).offset16(buildCoverage([]uint16{1, 2, 3})).u16(4, 0).
+		offset16(buildClassDef(map[uint16]uint16{1: 1, 2: 2, 3: 1})).
+		offset16(buildClassDef(map[uint16]uint16{4: 1, 5: 2})).
+		u16(3, 3)
+	for class1 := uint16(0); class1 < 3; class1++ {
+		for class2 := uint16(0); class2 < 3; class2++ {
+			pairs.u16(10*class1 + class2)
+		}
+	}
+	lookupList := (&object{}).u16(1).offset16((&object{}).u16(2, 0, 1).offset16(pairs))
+	featureList := (&object{}).u16(1).bytes([]byte("kern")).offset16((&object{}).u16(0, 1, 0))
+	scriptList := (&object{}).u16(0)
+	gpos, err := pack((&object{}).u16(1, 0).offset16(scriptList).offset16(featureList).offset16(lookupList))
+	require.NoError(t, err)
+
+	glyphs := newGlyphMap(glyphSet{0: true, 2: true, 5: true}, 6, true)
+	out, err := subsetLayout(gpos, true, glyphs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]uint16{"kern": {0}}, features(out))
+
+	// Only the classes of the retained glyphs remain
+	lookupListOffset := int(u16(out, 8))
+	lookup := lookupListOffset + int(u16(out, lookupListOffset+2))
+	subtable := lookup + int(u16(out, lookup+6))
+	identity := newGlyphMap(glyphSet{0: true, 1: true, 2: true}, 3, false)
+	s := &layoutSubsetter{data: out, glyphs: identity}
+	assert.Equal(t, []uint16{1}, newGids(s.coverage(subtable+int(u16(out, subtable+2)))))
+	assert.Equal(t, map[uint16]uint16{1: 1}, s.classDef(subtable+int(u16(out, subtable+8))))
+	assert.Equal(t, map[uint16]uint16{2: 1}, s.classDef(subtable+int(u16(out, subtable+10))))
+	assert.Equal(t, uint16(2), u16(out, subtable+12))
+	assert.Equal(t, uint16(2), u16(out, subtable+14))
+	assert.Equal(t, []uint16{0, 2, 20, 22}, []uint16{
+		u16(out, subtable+16), u16(out, subtable+18), u16(out, subtable+20), u16(out, subtable+22),
+	})
+}
+
+func TestPack(t *testing.T) {
+	// Identical objects are written once
+	child := func() *object { return (&object{}).u16(1, 2) }
+	root := (&object{}).offset16(child()).offset16(child()).offset32(nil)
+	data, err := pack(root)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 8, 0, 8, 0, 0, 0, 0, 0, 1, 0, 2}, data)
+
+	// Offsets that don't fit are reported
+	large := (&object{}).bytes(make([]byte, 0x10000))
+	_, err = pack((&object{}).offset16(large).offset16(child()))
+	assert.ErrorIs(t, err, errOffsetOverflow)
+}
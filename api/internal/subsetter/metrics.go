@@ -0,0 +1,222 @@
+package subsetter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// subsetMetrics subsets the hmtx or vmtx table and updates the number of
+// long metrics in the hhea or vhea table, which share their layout. The
+// metrics of glyphs that are emptied are zeroed.
+func subsetMetrics(font *sfnt.Font, headerTag string, metricsTag string, glyphs glyphMap) (header []byte, metrics []byte, err error) {
+	numGlyphs, err := font.NumGlyphs()
+	if err != nil {
+		return nil, nil, err
+	}
+	header = slices.Clone(font.Tables[headerTag])
+	if len(header) < 36 {
+		return nil, nil, fmt.Errorf("%w: missing %s table", sfnt.ErrInvalidFont, headerTag)
+	}
+	source := font.Tables[metricsTag]
+	numberOfMetrics := int(binary.BigEndian.Uint16(header[34:]))
+	if numberOfMetrics == 0 || numberOfMetrics > numGlyphs || len(source) < 4*numberOfMetrics+2*(numGlyphs-numberOfMetrics) {
+		return nil, nil, fmt.Errorf("%w: truncated %s table", sfnt.ErrInvalidFont, metricsTag)
+	}
+	count := len(glyphs.oldGids)
+	advances := make([]uint16, count)
+	bearings := make([]uint16, count)
+	for gid, old := range glyphs.oldGids {
+		if _, retained := glyphs.newGids[old]; !retained || int(old) >= numGlyphs {
+			continue
+		}
+		if int(old) < numberOfMetrics {
+			advances[gid] = binary.BigEndian.Uint16(source[4*old:])
+			bearings[gid] = binary.BigEndian.Uint16(source[4*old+2:])
+		} else {
+			advances[gid] = binary.BigEndian.Uint16(source[4*(numberOfMetrics-1):])
+			bearings[gid] = binary.BigEndian.Uint16(source[4*numberOfMetrics+2*(int(old)-numberOfMetrics):])
+		}
+	}
+	// Glyphs after the last long metric share its advance
+	numberOfMetrics = count
+	for numberOfMetrics > 1 && advances[numberOfMetrics-1] == advances[numberOfMetrics-2] {
+		numberOfMetrics--
+	}
+	metrics = make([]byte, 0, 4*numberOfMetrics+2*(count-numberOfMetrics))
+	for gid := 0; gid < count; gid++ {
+		if gid < numberOfMetrics {
+			metrics = binary.BigEndian.AppendUint16(metrics, advances[gid])
+		}
+		metrics = binary.BigEndian.AppendUint16(metrics, bearings[gid])
+	}
+	binary.BigEndian.PutUint16(header[34:], uint16(numberOfMetrics))
+	return header, metrics, nil
+}
+
+// subsetMetricsVariations subsets an HVAR or VVAR table. The mapping of
+// advances is rebuilt for the new glyph ids and the optional mappings of side
+// bearings and origins are dropped, in which case their variations are taken
+// from gvar.
+func subsetMetricsVariations(table []byte, tag string, glyphs glyphMap) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed %s table: %v", sfnt.ErrInvalidFont, tag, r)
+		}
+	}()
+	// VVAR has a mapping of vertical origins after those of HVAR
+	headerSize := 20
+	if tag == "VVAR" {
+		headerSize = 24
+	}
+	storeOffset := int(u32(table, 4))
+	store := (&object{}).bytes(table[storeOffset : storeOffset+itemVariationStoreLength(table, storeOffset)])
+	var advanceMap []byte
+	if offset := u32(table, 8); offset != 0 {
+		advanceMap = table[offset:]
+	}
+	entries := make([][2]int, len(glyphs.oldGids))
+	for gid, old := range glyphs.oldGids {
+		// Without a mapping the deltas are indexed by glyph id
+		entries[gid] = [2]int{0, int(old)}
+		if advanceMap != nil {
+			outer, inner := deltaSetIndex(advanceMap, int(old))
+			entries[gid] = [2]int{outer, inner}
+		}
+	}
+	o := (&object{}).bytes(table[:4]).offset32(store).offset32(buildDeltaSetIndexMap(entries))
+	o.bytes(make([]byte, headerSize-12))
+	return pack(o)
+}
+
+// Masks of the entry format of delta-set index maps
+const (
+	innerIndexBitCountMask = 0x0F
+	mapEntrySizeMask       = 0x30
+)
+
+// deltaSetIndex returns the outer and inner index of the deltas of item i of
+// a delta-set index map. Items after the end of the map use its last entry.
+func deltaSetIndex(indexMap []byte, i int) (outer int, inner int) {
+	entryFormat := int(indexMap[1])
+	mapCount, entries := int(u16(indexMap, 2)), indexMap[4:]
+	if indexMap[0] == 1 {
+		mapCount, entries = int(u32(indexMap, 2)), indexMap[6:]
+	}
+	i = min(i, mapCount-1)
+	entrySize := (entryFormat&mapEntrySizeMask)>>4 + 1
+	entry := 0
+	for _, b := range entries[entrySize*i : entrySize*(i+1)] {
+		entry = entry<<8 | int(b)
+	}
+	innerBits := entryFormat&innerIndexBitCountMask + 1
+	return entry >> innerBits, entry & (1<<innerBits - 1)
+}
+
+// buildDeltaSetIndexMap builds a delta-set index map of outer and inner
+// indices with the smallest entries that fit them. Entries at the end that
+// equal the one before them are left out.
+func buildDeltaSetIndexMap(entries [][2]int) *object {
+	for len(entries) > 1 && entries[len(entries)-1] == entries[len(entries)-2] {
+		entries = entries[:len(entries)-1]
+	}
+	maxOuter, maxInner := 0, 0
+	for _, e := range entries {
+		maxOuter = max(maxOuter, e[0])
+		maxInner = max(maxInner, e[1])
+	}
+	innerBits := max(bits.Len(uint(maxInner)), 1)
+	entrySize := (bits.Len(uint(maxOuter)) + innerBits + 7) / 8
+	o := &object{data: []byte{0, byte((entrySize-1)<<4 | (innerBits - 1))}}
+	o.u16(uint16(len(entries)))
+	for _, e := range entries {
+		entry := uint32(e[0]<<innerBits | e[1])
+		for i := entrySize - 1; i >= 0; i-- {
+			o.data = append(o.data, byte(entry>>(8*i)))
+		}
+	}
+	return o
+}
+
+// subsetVORG subsets the vertical origins of a VORG table.
+func subsetVORG(vorg []byte, glyphs glyphMap) ([]byte, error) {
+	if len(vorg) < 8 || len(vorg) < 8+4*int(binary.BigEndian.Uint16(vorg[6:])) {
+		return nil, fmt.Errorf("%w: truncated VORG table", sfnt.ErrInvalidFont)
+	}
+	count := int(binary.BigEndian.Uint16(vorg[6:]))
+	out := slices.Clone(vorg[:8])
+	retained := 0
+	for i := 0; i < count; i++ {
+		record := vorg[8+4*i:]
+		if gid, found := glyphs.newGids[binary.BigEndian.Uint16(record)]; found {
+			out = binary.BigEndian.AppendUint16(out, gid)
+			out = append(out, record[2:4]...)
+			retained++
+		}
+	}
+	binary.BigEndian.PutUint16(out[6:], uint16(retained))
+	return out, nil
+}
+
+// subsetKern subsets the pairs of the format 0 subtables of a kern table.
+// Other subtables are dropped, as are kern tables of Apple's format, for
+// which nil is returned.
+func subsetKern(kern []byte, glyphs glyphMap) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed kern table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	if u16(kern, 0) != 0 {
+		return nil, nil
+	}
+	tableCount := int(u16(kern, 2))
+	o := (&object{}).u16(0, 0)
+	retained := 0
+	offset := 4
+	for i := 0; i < tableCount; i++ {
+		length := int(u16(kern, offset+2))
+		coverage := u16(kern, offset+4)
+		if coverage>>8 != 0 {
+			offset += length
+			continue
+		}
+		pairCount := int(u16(kern, offset+6))
+		type pair struct{ left, right, value uint16 }
+		var pairs []pair
+		for j := 0; j < pairCount; j++ {
+			record := offset + 14 + 6*j
+			left, leftRetained := glyphs.newGids[u16(kern, record)]
+			right, rightRetained := glyphs.newGids[u16(kern, record+2)]
+			if leftRetained && rightRetained {
+				pairs = append(pairs, pair{left, right, u16(kern, record+4)})
+			}
+		}
+		// The length of large subtables overflows, so it's not used to find
+		// the next one
+		offset += 14 + 6*pairCount
+		slices.SortFunc(pairs, func(a, b pair) int {
+			return int(a.left)<<16 | int(a.right) - (int(b.left)<<16 | int(b.right))
+		})
+		searchRange := 0
+		if len(pairs) > 0 {
+			searchRange = 1 << (bits.Len(uint(len(pairs))) - 1)
+		}
+		entrySelector := max(bits.Len(uint(searchRange))-1, 0)
+		o.u16(0, uint16(14+6*len(pairs)), coverage, uint16(len(pairs)))
+		o.u16(uint16(6*searchRange), uint16(entrySelector), uint16(6*(len(pairs)-searchRange)))
+		for _, p := range pairs {
+			o.u16(p.left, p.right, p.value)
+		}
+		retained++
+	}
+	if retained == 0 {
+		return nil, nil
+	}
+	o.data[3] = byte(retained)
+	o.data[2] = byte(retained >> 8)
+	return o.data, nil
+}
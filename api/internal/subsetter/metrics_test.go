@@ -0,0 +1,52 @@
+package subsetter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubsetMetricsVariations(t *testing.T) {
+	// An item variation store without regions, of which only the indices of
+	// the deltas matter
+	itemData := (&object{}).u16(4, 0, 0)
+	store := (&object{}).u16(1).offset32((&object{}).u16(0, 0)).u16(1).offset32(itemData)
+
+	tests := []struct {
+		advanceMap *object
+		expected   [][2]int
+	}{
+		// Without a mapping the deltas are indexed by glyph id
+		{nil, [][2]int{{0, 0}, {0, 2}, {0, 3}}},
+		{buildDeltaSetIndexMap([][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}}), [][2]int{{0, 0}, {1, 0}, {1, 1}}},
+	}
+	for _, tt := range tests {
+		hvar, err := pack((&object{}).u16(1, 0).offset32(store).offset32(tt.advanceMap).u32(0).u32(0))
+		require.NoError(t, err)
+		out, err := subsetMetricsVariations(hvar, "HVAR", newGlyphMap(glyphSet{0: true, 2: true, 3: true}, 4, true))
+		require.NoError(t, err)
+
+		advanceMap := out[u32(out, 8):]
+		var entries [][2]int
+		for i := range tt.expected {
+			outer, inner := deltaSetIndex(advanceMap, i)
+			entries = append(entries, [2]int{outer, inner})
+		}
+		assert.Equal(t, tt.expected, entries)
+		assert.Zero(t, u32(out, 12))
+		assert.Zero(t, u32(out, 16))
+	}
+}
+
+func TestBuildDeltaSetIndexMap(t *testing.T) {
+	entries := [][2]int{{0, 300}, {2, 0}, {1, 7}, {1, 7}}
+	indexMap, err := pack(buildDeltaSetIndexMap(entries))
+	require.NoError(t, err)
+	// The repeated entry at the end is left out
+	assert.Equal(t, uint16(3), u16(indexMap, 2))
+	for i, e := range entries {
+		outer, inner := deltaSetIndex(indexMap, i)
+		assert.Equal(t, e, [2]int{outer, inner})
+	}
+}
@@ -0,0 +1,136 @@
+package subsetter
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errOffsetOverflow is returned when a packed table has an offset that
+// doesn't fit its field.
+var errOffsetOverflow = errors.New("offset overflow")
+
+// object is a table or subtable that is being built. Its offsets to other
+// objects are written when it is packed, relative to the start of the object.
+type object struct {
+	data  []byte
+	links []link
+}
+
+// link is an offset from an object to another object.
+type link struct {
+	pos   int
+	size  int
+	child *object
+}
+
+func (o *object) u16(values ...uint16) *object {
+	for _, v := range values {
+		o.data = binary.BigEndian.AppendUint16(o.data, v)
+	}
+	return o
+}
+
+func (o *object) u32(v uint32) *object {
+	o.data = binary.BigEndian.AppendUint32(o.data, v)
+	return o
+}
+
+// bytes appends raw data.
+func (o *object) bytes(data []byte) *object {
+	o.data = append(o.data, data...)
+	return o
+}
+
+// offset16 appends a 16-bit offset to the child, or a null offset if the
+// child is nil.
+func (o *object) offset16(child *object) *object {
+	return o.offset(child, 2)
+}
+
+// offset32 appends a 32-bit offset to the child, or a null offset if the
+// child is nil.
+func (o *object) offset32(child *object) *object {
+	return o.offset(child, 4)
+}
+
+func (o *object) offset(child *object, size int) *object {
+	if child != nil {
+		o.links = append(o.links, link{len(o.data), size, child})
+	}
+	o.data = append(o.data, make([]byte, size)...)
+	return o
+}
+
+// pack serializes the object and the objects it links to. Identical objects
+// are written once. Objects are ordered breadth first, after all objects that
+// link to them, which keeps the offsets of small tables short.
+func pack(root *object) ([]byte, error) {
+	// Deduplicate objects by their data and the objects they link to
+	ids := make(map[*object]int)
+	keys := make(map[string]int)
+	var nodes []*object
+	var intern func(o *object) int
+	intern = func(o *object) int {
+		if id, found := ids[o]; found {
+			return id
+		}
+		var key strings.Builder
+		key.Write(o.data)
+		for _, l := range o.links {
+			key.WriteString(" " + strconv.Itoa(l.pos) + ":" + strconv.Itoa(intern(l.child)))
+		}
+		id, found := keys[key.String()]
+		if !found {
+			id = len(nodes)
+			keys[key.String()] = id
+			nodes = append(nodes, o)
+		}
+		ids[o] = id
+		return id
+	}
+	rootID := intern(root)
+
+	// Order the objects topologically with Kahn's algorithm
+	parents := make([]int, len(nodes))
+	for _, o := range nodes {
+		for _, l := range o.links {
+			parents[ids[l.child]]++
+		}
+	}
+	order := []int{rootID}
+	for i := 0; i < len(order); i++ {
+		for _, l := range nodes[order[i]].links {
+			child := ids[l.child]
+			parents[child]--
+			if parents[child] == 0 {
+				order = append(order, child)
+			}
+		}
+	}
+	positions := make([]int, len(nodes))
+	size := 0
+	for _, id := range order {
+		positions[id] = size
+		size += len(nodes[id].data)
+	}
+
+	out := make([]byte, 0, size)
+	for _, id := range order {
+		start := len(out)
+		out = append(out, nodes[id].data...)
+		for _, l := range nodes[id].links {
+			offset := positions[ids[l.child]] - positions[id]
+			if l.size == 2 {
+				if offset > 0xFFFF {
+					return nil, errOffsetOverflow
+				}
+				binary.BigEndian.PutUint16(out[start+l.pos:], uint16(offset))
+			} else {
+				binary.BigEndian.PutUint32(out[start+l.pos:], uint32(offset))
+			}
+		}
+	}
+	return out, nil
+}
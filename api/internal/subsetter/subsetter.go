@@ -0,0 +1,400 @@
+// Package subsetter subsets TrueType and OpenType fonts to a set of code
+// points without depending on external tools.
+//
+// The retained glyphs are renumbered in order, and the tables that refer to
+// glyphs by id are rewritten for the new ids: outlines, metrics, variations
+// and the GSUB, GPOS and GDEF tables, of which lookups and features that no
+// longer apply are removed. Fonts with tables that refer to glyphs and that
+// can't be subsetted, such as color and bitmap glyphs, retain their glyph ids
+// instead, and glyphs that are not needed are emptied rather than removed.
+package subsetter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// unsupportedTables are the tables that refer to glyphs by id and can't be
+// subsetted. Fonts with any of them retain their glyph ids.
+var unsupportedTables = []string{
+	"CFF2", "COLR", "SVG ", "CBDT", "CBLC", "EBDT", "EBLC", "EBSC", "sbix", "MATH", "JSTF",
+	// Apple Advanced Typography
+	"ankr", "bsln", "just", "kerx", "lcar", "morx", "mort", "opbd", "prop",
+}
+
+// glyphMap maps the glyph ids of a font to those of its subset.
+type glyphMap struct {
+	// newGids are the new ids of the retained glyphs
+	newGids map[uint16]uint16
+	// oldGids are the ids in the font of the glyphs of the subset, which
+	// include the glyphs that are emptied when glyph ids are retained
+	oldGids []uint16
+}
+
+// newGlyphMap maps the glyphs of a set to new ids in order, or to their own
+// ids if renumber is false.
+func newGlyphMap(glyphs glyphSet, numGlyphs int, renumber bool) glyphMap {
+	m := glyphMap{newGids: make(map[uint16]uint16)}
+	for gid := 0; gid < numGlyphs; gid++ {
+		retained := glyphs[uint16(gid)]
+		if retained {
+			m.newGids[uint16(gid)] = uint16(gid)
+			if renumber {
+				m.newGids[uint16(gid)] = uint16(len(m.oldGids))
+			}
+		}
+		if retained || !renumber {
+			m.oldGids = append(m.oldGids, uint16(gid))
+		}
+	}
+	return m
+}
+
+// Subset returns a copy of the font in data that only contains the glyphs
+// needed to render the given code points, including glyphs that can be
+// reached through substitutions and composite glyphs.
+func Subset(data []byte, runes []rune) ([]byte, error) {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	cmap, err := font.CharacterMap()
+	if err != nil {
+		return nil, err
+	}
+	numGlyphs, err := font.NumGlyphs()
+	if err != nil {
+		return nil, err
+	}
+	symbol := font.IsSymbolFont()
+
+	// Collect the glyphs mapped by the requested code points
+	mapping := make(map[rune]uint16)
+	glyphs := glyphSet{0: true}
+	for _, r := range runes {
+		gid, found := cmap[r]
+		if !found {
+			continue
+		}
+		// Symbol fonts store their code points in the private use area
+		if symbol && r <= 0xFF {
+			r += 0xF000
+		}
+		mapping[r] = gid
+		glyphs[gid] = true
+	}
+	if err := closeOverGSUB(font.Tables["GSUB"], glyphs); err != nil {
+		return nil, err
+	}
+	var outlines [][]byte
+	if font.Tables["glyf"] != nil {
+		outlines, err = font.Glyphs()
+		if err != nil {
+			return nil, err
+		}
+		if err := closeOverComposites(outlines, glyphs); err != nil {
+			return nil, err
+		}
+	}
+	renumber := !slices.ContainsFunc(unsupportedTables, func(tag string) bool { return font.Tables[tag] != nil })
+	if base := font.Tables["BASE"]; renumber && base != nil {
+		references, err := baseReferencesGlyphs(base)
+		if err != nil {
+			return nil, err
+		}
+		renumber = !references
+	}
+	glyphMap := newGlyphMap(glyphs, numGlyphs, renumber)
+	for r, gid := range mapping {
+		mapping[r] = glyphMap.newGids[gid]
+	}
+
+	out := &sfnt.Font{
+		Version: font.Version,
+		Tables:  make(map[string][]byte),
+	}
+	for tag, table := range font.Tables {
+		var subsetted []byte
+		switch tag {
+		case "DSIG":
+			// The signature is no longer valid once the font is modified
+			continue
+		case "hdmx", "LTSH":
+			// Optional tables with a record for every glyph
+			if renumber {
+				continue
+			}
+			subsetted = table
+		case "cmap", "glyf", "loca", "head", "hmtx", "vmtx", "maxp":
+			// Written below
+			continue
+		case "gvar":
+			subsetted, err = subsetGvar(table, glyphMap)
+		case "HVAR", "VVAR":
+			subsetted = table
+			if renumber {
+				subsetted, err = subsetMetricsVariations(table, tag, glyphMap)
+			}
+		case "VORG":
+			subsetted, err = subsetVORG(table, glyphMap)
+		case "CFF ":
+			subsetted, err = subsetCFF(table, glyphMap)
+		case "GSUB", "GPOS":
+			subsetted, err = subsetLayout(table, tag == "GPOS", glyphMap)
+		case "GDEF":
+			subsetted, err = subsetGDEF(table, glyphMap)
+		case "kern":
+			subsetted, err = subsetKern(table, glyphMap)
+		case "post":
+			subsetted = subsetPost(table)
+		case "name":
+			subsetted, err = subsetName(font)
+		case "OS/2":
+			subsetted = subsetOS2(table, mapping)
+		default:
+			subsetted = table
+		}
+		if err != nil {
+			return nil, err
+		}
+		if subsetted != nil {
+			out.Tables[tag] = subsetted
+		}
+	}
+	out.Tables["cmap"] = sfnt.BuildCmap(mapping, symbol)
+
+	maxp := slices.Clone(font.Tables["maxp"])
+	binary.BigEndian.PutUint16(maxp[4:], uint16(len(glyphMap.oldGids)))
+	out.Tables["maxp"] = maxp
+
+	head := slices.Clone(font.Tables["head"])
+	if len(head) < 54 {
+		return nil, fmt.Errorf("%w: missing head table", sfnt.ErrInvalidFont)
+	}
+	if outlines != nil {
+		subsetted := make([][]byte, len(glyphMap.oldGids))
+		for gid, old := range glyphMap.oldGids {
+			if !glyphs[old] {
+				continue
+			}
+			subsetted[gid], err = sfnt.RemapComponents(outlines[old], func(component uint16) uint16 {
+				return glyphMap.newGids[component]
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		glyf, loca, indexToLocFormat := sfnt.BuildGlyf(subsetted)
+		out.Tables["glyf"] = glyf
+		out.Tables["loca"] = loca
+		binary.BigEndian.PutUint16(head[50:], indexToLocFormat)
+	}
+	out.Tables["head"] = head
+
+	for _, tags := range [][2]string{{"hhea", "hmtx"}, {"vhea", "vmtx"}} {
+		if font.Tables[tags[1]] == nil {
+			continue
+		}
+		header, metrics, err := subsetMetrics(font, tags[0], tags[1], glyphMap)
+		if err != nil {
+			return nil, err
+		}
+		out.Tables[tags[0]] = header
+		out.Tables[tags[1]] = metrics
+	}
+	return out.Bytes(), nil
+}
+
+// baseReferencesGlyphs reports whether a BASE table has coordinates that
+// refer to the outline of a glyph, which are rarely used.
+func baseReferencesGlyphs(base []byte) (references bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed BASE table: %v", sfnt.ErrInvalidFont, r)
+		}
+	}()
+	coordinate := func(table int, offset uint16) {
+		if offset != 0 && u16(base, table+int(offset)) == 2 {
+			references = true
+		}
+	}
+	for _, axisOffset := range []int{4, 6} {
+		axis := int(u16(base, axisOffset))
+		if axis == 0 || u16(base, axis+2) == 0 {
+			continue
+		}
+		scriptList := axis + int(u16(base, axis+2))
+		scriptCount := int(u16(base, scriptList))
+		for i := 0; i < scriptCount; i++ {
+			script := scriptList + int(u16(base, scriptList+2+6*i+4))
+			if values := int(u16(base, script)); values != 0 {
+				values += script
+				for j := 0; j < int(u16(base, values+2)); j++ {
+					coordinate(values, u16(base, values+4+2*j))
+				}
+			}
+			var minMaxes []int
+			if minMax := int(u16(base, script+2)); minMax != 0 {
+				minMaxes = append(minMaxes, script+minMax)
+			}
+			for j := 0; j < int(u16(base, script+4)); j++ {
+				minMaxes = append(minMaxes, script+int(u16(base, script+6+6*j+4)))
+			}
+			for _, minMax := range minMaxes {
+				coordinate(minMax, u16(base, minMax))
+				coordinate(minMax, u16(base, minMax+2))
+				for j := 0; j < int(u16(base, minMax+4)); j++ {
+					record := minMax + 6 + 8*j
+					coordinate(minMax, u16(base, record+4))
+					coordinate(minMax, u16(base, record+6))
+				}
+			}
+		}
+	}
+	return references, nil
+}
+
+// closeOverComposites adds the components of all composite glyphs in the
+// set, recursively.
+func closeOverComposites(outlines [][]byte, glyphs glyphSet) error {
+	var stack []uint16
+	for gid := range glyphs {
+		stack = append(stack, gid)
+	}
+	for len(stack) > 0 {
+		gid := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if int(gid) >= len(outlines) {
+			continue
+		}
+		components, err := sfnt.Components(outlines[gid])
+		if err != nil {
+			return err
+		}
+		for _, component := range components {
+			if !glyphs[component] {
+				glyphs[component] = true
+				stack = append(stack, component)
+			}
+		}
+	}
+	return nil
+}
+
+// subsetGvar subsets the variation data of the glyphs.
+func subsetGvar(gvar []byte, glyphs glyphMap) ([]byte, error) {
+	if len(gvar) < 20 {
+		return nil, fmt.Errorf("%w: truncated gvar table", sfnt.ErrInvalidFont)
+	}
+	axisCount := int(binary.BigEndian.Uint16(gvar[4:]))
+	sharedTupleCount := int(binary.BigEndian.Uint16(gvar[6:]))
+	sharedTuplesOffset := int(binary.BigEndian.Uint32(gvar[8:]))
+	glyphCount := int(binary.BigEndian.Uint16(gvar[12:]))
+	flags := binary.BigEndian.Uint16(gvar[14:])
+	dataOffset := int(binary.BigEndian.Uint32(gvar[16:]))
+	longOffsets := flags&1 != 0
+	offset := func(i int) int {
+		if longOffsets {
+			return int(binary.BigEndian.Uint32(gvar[20+4*i:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(gvar[20+2*i:]))
+	}
+	offsetsSize := 2 * (glyphCount + 1)
+	if longOffsets {
+		offsetsSize *= 2
+	}
+	sharedTuplesSize := 2 * axisCount * sharedTupleCount
+	if len(gvar) < 20+offsetsSize || len(gvar) < sharedTuplesOffset+sharedTuplesSize {
+		return nil, fmt.Errorf("%w: truncated gvar table", sfnt.ErrInvalidFont)
+	}
+
+	// The new table always uses long offsets
+	newGlyphCount := len(glyphs.oldGids)
+	newOffsetsSize := 4 * (newGlyphCount + 1)
+	newDataOffset := 20 + newOffsetsSize + sharedTuplesSize
+	out := make([]byte, newDataOffset)
+	copy(out, gvar[:20])
+	binary.BigEndian.PutUint32(out[8:], uint32(20+newOffsetsSize))
+	binary.BigEndian.PutUint16(out[12:], uint16(newGlyphCount))
+	binary.BigEndian.PutUint16(out[14:], flags|1)
+	binary.BigEndian.PutUint32(out[16:], uint32(newDataOffset))
+	copy(out[20+newOffsetsSize:], gvar[sharedTuplesOffset:sharedTuplesOffset+sharedTuplesSize])
+	for gid, old := range glyphs.oldGids {
+		binary.BigEndian.PutUint32(out[20+4*gid:], uint32(len(out)-newDataOffset))
+		if _, retained := glyphs.newGids[old]; !retained || int(old) >= glyphCount {
+			continue
+		}
+		start, end := dataOffset+offset(int(old)), dataOffset+offset(int(old)+1)
+		if start > end || end > len(gvar) {
+			return nil, fmt.Errorf("%w: gvar data for glyph %d out of bounds", sfnt.ErrInvalidFont, old)
+		}
+		out = append(out, gvar[start:end]...)
+	}
+	binary.BigEndian.PutUint32(out[20+4*newGlyphCount:], uint32(len(out)-newDataOffset))
+	return out, nil
+}
+
+// subsetPost converts the post table to version 3, which drops glyph names.
+func subsetPost(post []byte) []byte {
+	if len(post) < 32 {
+		return post
+	}
+	out := slices.Clone(post[:32])
+	binary.BigEndian.PutUint32(out, 0x00030000)
+	return out
+}
+
+// subsetName keeps the English records of the basic name ids.
+func subsetName(font *sfnt.Font) ([]byte, error) {
+	records, err := font.Names()
+	if err != nil {
+		return nil, err
+	}
+	var retained []sfnt.NameRecord
+	for _, r := range records {
+		if r.NameID > sfnt.NamePostScript && r.NameID != sfnt.NameTypographicFamily && r.NameID != sfnt.NameTypographicSubfamily {
+			continue
+		}
+		if r.PlatformID == sfnt.PlatformUnicode ||
+			(r.PlatformID == sfnt.PlatformMacintosh && r.LanguageID == 0) ||
+			(r.PlatformID == sfnt.PlatformWindows && r.LanguageID == sfnt.LanguageWindowsEnglish) {
+			retained = append(retained, r)
+		}
+	}
+	if len(retained) == 0 {
+		return font.Tables["name"], nil
+	}
+	return sfnt.BuildName(retained), nil
+}
+
+// subsetOS2 updates the character range fields of the OS/2 table to the
+// retained code points.
+func subsetOS2(os2 []byte, mapping map[rune]uint16) []byte {
+	if len(os2) < 68 {
+		return os2
+	}
+	out := slices.Clone(os2)
+	var runes []rune
+	for r := range mapping {
+		runes = append(runes, r)
+	}
+	ranges := sfnt.UnicodeRanges(runes)
+	for i, bits := range ranges {
+		original := binary.BigEndian.Uint32(out[42+4*i:])
+		binary.BigEndian.PutUint32(out[42+4*i:], original&bits)
+	}
+	first, last := rune(0xFFFF), rune(0)
+	for _, r := range runes {
+		first = min(first, r)
+		last = max(last, r)
+	}
+	if len(runes) == 0 {
+		first = 0
+	}
+	binary.BigEndian.PutUint16(out[64:], uint16(min(first, 0xFFFF)))
+	binary.BigEndian.PutUint16(out[66:], uint16(min(last, 0xFFFF)))
+	return out
+}
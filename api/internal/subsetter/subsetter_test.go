@@ -0,0 +1,104 @@
+package subsetter_test
+
+import (
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/lyxell/font.delivery/api/internal/subsetter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+	xsfnt "golang.org/x/image/font/sfnt"
+)
+
+func TestSubset(t *testing.T) {
+	data, err := subsetter.Subset(goregular.TTF, []rune("AB"))
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(goregular.TTF)/4)
+
+	// The subsetted font should be loadable by other parsers
+	font, err := xsfnt.Parse(data)
+	require.NoError(t, err)
+	var buf xsfnt.Buffer
+	for _, r := range "AB" {
+		gid, err := font.GlyphIndex(&buf, r)
+		require.NoError(t, err)
+		assert.NotZero(t, gid, "missing glyph for %q", r)
+	}
+	gid, err := font.GlyphIndex(&buf, 'C')
+	require.NoError(t, err)
+	assert.Zero(t, gid)
+}
+
+func TestSubsetRenumbersGlyphs(t *testing.T) {
+	source, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	sourceCmap, err := source.CharacterMap()
+	require.NoError(t, err)
+	sourceGlyphs, err := source.Glyphs()
+	require.NoError(t, err)
+
+	data, err := subsetter.Subset(goregular.TTF, []rune("xÅ"))
+	require.NoError(t, err)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	cmap, err := font.CharacterMap()
+	require.NoError(t, err)
+	glyphs, err := font.Glyphs()
+	require.NoError(t, err)
+
+	// The notdef glyph is followed by the retained glyphs in their order in
+	// the font
+	require.Less(t, sourceCmap['x'], sourceCmap['Å'])
+	assert.Equal(t, map[rune]uint16{'x': 1, 'Å': 2}, cmap)
+	require.Len(t, glyphs, 3)
+	for _, r := range "xÅ" {
+		assert.Equal(t, sourceGlyphs[sourceCmap[r]], glyphs[cmap[r]])
+	}
+	assert.Equal(t, sourceGlyphs[0], glyphs[0])
+
+	// The advances are those of the original glyphs
+	sourceFont, err := xsfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	subsetFont, err := xsfnt.Parse(data)
+	require.NoError(t, err)
+	var buf xsfnt.Buffer
+	for _, r := range "xÅ" {
+		expected, err := sourceFont.GlyphAdvance(&buf, xsfnt.GlyphIndex(sourceCmap[r]), 1000, 0)
+		require.NoError(t, err)
+		advance, err := subsetFont.GlyphAdvance(&buf, xsfnt.GlyphIndex(cmap[r]), 1000, 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, advance)
+	}
+}
+
+func TestSubsetRetainsGlyphIdsOfUnsupportedFonts(t *testing.T) {
+	source, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	sourceCmap, err := source.CharacterMap()
+	require.NoError(t, err)
+	sourceGlyphs, err := source.Glyphs()
+	require.NoError(t, err)
+
+	// Color glyphs refer to glyphs by id
+	source.Tables["COLR"] = make([]byte, 14)
+	data, err := subsetter.Subset(source.Bytes(), []rune("xÅ"))
+	require.NoError(t, err)
+	font, err := sfnt.Parse(data)
+	require.NoError(t, err)
+	cmap, err := font.CharacterMap()
+	require.NoError(t, err)
+	glyphs, err := font.Glyphs()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[rune]uint16{'Å': sourceCmap['Å'], 'x': sourceCmap['x']}, cmap)
+	assert.Len(t, glyphs, len(sourceGlyphs))
+	assert.Equal(t, sourceGlyphs[cmap['x']], glyphs[cmap['x']])
+	assert.Empty(t, glyphs[sourceCmap['y']])
+	assert.NotNil(t, font.Tables["COLR"])
+}
+
+func TestSubsetInvalidFont(t *testing.T) {
+	_, err := subsetter.Subset([]byte("not a font"), []rune("A"))
+	assert.ErrorIs(t, err, sfnt.ErrInvalidFont)
+}
@@ -0,0 +1,204 @@
+package subsetting
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// subsetName matches the names of subsets, which are part of file names.
+var subsetName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Load reads the subset definitions in dir and adds them to the known subsets,
+// replacing bundled subsets of the same name. Each subset is defined by a file
+// named after it:
+//
+//   - {subset}.txt is a range list with one code point or range per line,
+//     such as "0131" or "0000-00FF". Entries can also be separated by commas
+//     and prefixed with "U+", as in CSS.
+//   - {subset}.nam is a glyph set file of google/fonts, where every line
+//     starts with a code point such as "0x0041". Other files are included
+//     with "#$ include other.nam".
+//
+// In both formats everything after a "#" is a comment. Load must be called
+// before the other functions of this package are used.
+func Load(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	subsets, err := parseDir(os.DirFS(dir), ".")
+	if err != nil {
+		return err
+	}
+	maps.Copy(subsetRanges, subsets)
+	return nil
+}
+
+// parseDir parses the subset definitions in dir of fsys.
+func parseDir(fsys fs.FS, dir string) (map[string][][]rune, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	subsets := make(map[string][][]rune)
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".txt" && ext != ".nam") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		filePath := path.Join(dir, entry.Name())
+		if !subsetName.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid subset name %q", filePath, name)
+		}
+		if _, found := shardedSubsets[name]; found {
+			return nil, fmt.Errorf("%s: the sharded subset %s can't be redefined", filePath, name)
+		}
+		if _, found := subsets[name]; found {
+			return nil, fmt.Errorf("%s: subset %s is defined more than once", filePath, name)
+		}
+		var unicodeRanges [][]rune
+		if ext == ".txt" {
+			unicodeRanges, err = parseRangeList(fsys, filePath)
+		} else {
+			var runes []rune
+			runes, err = parseNam(fsys, filePath, nil)
+			unicodeRanges = toRanges(runes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(unicodeRanges) == 0 {
+			return nil, fmt.Errorf("%s: subset %s has no code points", filePath, name)
+		}
+		subsets[name] = unicodeRanges
+	}
+	return subsets, nil
+}
+
+// parseRangeList parses a range list, keeping the ranges in the order they
+// are listed.
+func parseRangeList(fsys fs.FS, filePath string) ([][]rune, error) {
+	data, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	var unicodeRanges [][]rune
+	err = eachLine(data, func(line string, number int) error {
+		lineRanges, err := ParseRanges(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filePath, number, err)
+		}
+		unicodeRanges = append(unicodeRanges, lineRanges...)
+		return nil
+	}, nil)
+	return unicodeRanges, err
+}
+
+// Takes a list of code points and ranges separated by commas or spaces, such
+// as "U+0041-005A, U+0061" or "41-5A 61", and returns the ranges in the order
+// they are listed.
+func ParseRanges(s string) ([][]rune, error) {
+	var unicodeRanges [][]rune
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "U+"), "u+")
+		first, last, isRange := strings.Cut(entry, "-")
+		start, err := parseCodePoint(first)
+		if err != nil {
+			return nil, err
+		}
+		if !isRange {
+			unicodeRanges = append(unicodeRanges, []rune{start})
+			continue
+		}
+		end, err := parseCodePoint(last)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid range %s", entry)
+		}
+		unicodeRanges = append(unicodeRanges, []rune{start, end})
+	}
+	return unicodeRanges, nil
+}
+
+// parseNam parses a glyph set file and the files it includes. included holds
+// the files that are being parsed, to detect include cycles.
+func parseNam(fsys fs.FS, filePath string, included []string) ([]rune, error) {
+	for _, f := range included {
+		if f == filePath {
+			return nil, fmt.Errorf("%s: include cycle", filePath)
+		}
+	}
+	included = append(included, filePath)
+	data, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	var runes []rune
+	err = eachLine(data, func(line string, number int) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "0x") {
+			// Lines without a code point name glyphs that aren't mapped
+			return nil
+		}
+		r, err := parseCodePoint(strings.TrimPrefix(fields[0], "0x"))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filePath, number, err)
+		}
+		runes = append(runes, r)
+		return nil
+	}, func(include string, number int) error {
+		includePath := path.Join(path.Dir(filePath), include)
+		if !fs.ValidPath(includePath) {
+			return fmt.Errorf("%s:%d: invalid include %s", filePath, number, include)
+		}
+		includedRunes, err := parseNam(fsys, includePath, included)
+		if err != nil {
+			return err
+		}
+		runes = append(runes, includedRunes...)
+		return nil
+	})
+	return runes, err
+}
+
+// eachLine calls handle with every line of data with comments removed, along
+// with its line number. Unless include is nil it is called with the path of
+// every "#$ include" directive.
+func eachLine(data []byte, handle func(line string, number int) error, include func(path string, number int) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if directive, found := strings.CutPrefix(line, "#$"); found && include != nil {
+			if target, found := strings.CutPrefix(strings.TrimSpace(directive), "include "); found {
+				if err := include(strings.TrimSpace(target), number); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		line, _, _ = strings.Cut(line, "#")
+		if err := handle(line, number); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseCodePoint parses a hexadecimal code point.
+func parseCodePoint(s string) (rune, error) {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || r > 0x10FFFF {
+		return 0, fmt.Errorf("invalid code point %q", s)
+	}
+	return rune(r), nil
+}
@@ -0,0 +1,263 @@
+package subsetting
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+//go:embed frequencies/*.txt
+var frequencies embed.FS
+
+// shardCount is the number of shards that sharded subsets are split into.
+const shardCount = 100
+
+// shardedSubset is a subset that is too large to be published as a single
+// file, such as the subsets of Chinese, Japanese and Korean. It is split into
+// shards of consecutive code points in order of frequency, each with its own
+// Unicode ranges, so that browsers only download the shards a page uses.
+type shardedSubset struct {
+	// order returns the code points of the subset, most frequently used first
+	order func() []rune
+
+	once   sync.Once
+	ranges [][]rune
+	shards [][][]rune
+}
+
+// load splits the subset into its shards the first time it is used.
+func (s *shardedSubset) load() {
+	s.once.Do(func() {
+		runes := s.order()
+		size := (len(runes) + shardCount - 1) / shardCount
+		for i := 0; i < shardCount; i++ {
+			start := min(i*size, len(runes))
+			end := min(start+size, len(runes))
+			s.shards = append(s.shards, toRanges(runes[start:end]))
+		}
+		s.ranges = toRanges(runes)
+	})
+}
+
+var shardedSubsets = map[string]*shardedSubset{
+	"chinese-simplified":  {order: chineseSimplifiedOrder},
+	"chinese-traditional": {order: chineseTraditionalOrder},
+	"japanese":            {order: japaneseOrder},
+	"korean":              {order: koreanOrder},
+}
+
+// Takes a subset and returns the names of the files it is published as. These
+// are the shards of a sharded subset, e.g. "japanese-0" to "japanese-99", or
+// the subset itself for other subsets. Shards can be passed to the other
+// functions of this package like any subset.
+func Shards(subset string) []string {
+	if _, found := shardedSubsets[subset]; !found {
+		return []string{subset}
+	}
+	var shards []string
+	for i := 0; i < shardCount; i++ {
+		shards = append(shards, fmt.Sprintf("%s-%d", subset, i))
+	}
+	return shards
+}
+
+// Takes a subset or a shard and returns whether it belongs to a sharded
+// subset, e.g. "japanese" and "japanese-17".
+func IsSharded(name string) bool {
+	_, found := shardRanges(name)
+	return found
+}
+
+// shardRanges returns the Unicode ranges of a sharded subset or of a shard.
+func shardRanges(name string) ([][]rune, bool) {
+	if s, found := shardedSubsets[name]; found {
+		s.load()
+		return s.ranges, true
+	}
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return nil, false
+	}
+	s, found := shardedSubsets[name[:i]]
+	if !found {
+		return nil, false
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil || n < 0 || n >= shardCount || strconv.Itoa(n) != name[i+1:] {
+		return nil, false
+	}
+	s.load()
+	return s.shards[n], true
+}
+
+// toRanges sorts code points and merges consecutive code points into ranges.
+func toRanges(runes []rune) [][]rune {
+	runes = slices.Clone(runes)
+	slices.Sort(runes)
+	var ranges [][]rune
+	for i := 0; i < len(runes); {
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, []rune{runes[i]})
+		} else {
+			ranges = append(ranges, []rune{runes[i], runes[j]})
+		}
+		i = j + 1
+	}
+	return ranges
+}
+
+// runeOrder collects code points in order, ignoring code points that have
+// already been added.
+type runeOrder struct {
+	seen  map[rune]bool
+	runes []rune
+}
+
+func (o *runeOrder) add(r rune) {
+	if o.seen == nil {
+		o.seen = make(map[rune]bool)
+	}
+	if !o.seen[r] {
+		o.seen[r] = true
+		o.runes = append(o.runes, r)
+	}
+}
+
+func (o *runeOrder) addRange(first rune, last rune) {
+	for r := first; r <= last; r++ {
+		o.add(r)
+	}
+}
+
+// addFrequencies adds the characters of the frequency list
+// frequencies/{name}.txt, most frequently used first. Lines starting with "#"
+// are comments.
+func (o *runeOrder) addFrequencies(name string) {
+	data, err := frequencies.ReadFile("frequencies/" + name + ".txt")
+	if err != nil {
+		panic(err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, r := range line {
+			o.add(r)
+		}
+	}
+}
+
+// addCharset adds the characters of a double-byte character set that belong
+// to table, in the order they are encoded for the lead bytes first to last.
+// This adds the characters that the frequency lists leave out in the levels
+// of national character sets, which group rarely used characters together.
+func (o *runeOrder) addCharset(enc encoding.Encoding, first byte, last byte, trails [][2]byte, table *unicode.RangeTable) {
+	decoder := enc.NewDecoder()
+	for lead := int(first); lead <= int(last); lead++ {
+		for _, trail := range trails {
+			for t := int(trail[0]); t <= int(trail[1]); t++ {
+				decoded, err := decoder.Bytes([]byte{byte(lead), byte(t)})
+				if err != nil {
+					continue
+				}
+				r, size := utf8.DecodeRune(decoded)
+				if size != len(decoded) || r == utf8.RuneError || !unicode.Is(table, r) {
+					continue
+				}
+				o.add(r)
+			}
+		}
+	}
+}
+
+// eucTrails are the trail bytes of the EUC encodings of GB 2312, JIS X 0208
+// and KS X 1001.
+var eucTrails = [][2]byte{{0xA1, 0xFE}}
+
+// addPunctuation adds the punctuation shared by Chinese, Japanese and Korean,
+// which is needed by almost every page.
+func (o *runeOrder) addPunctuation() {
+	o.addRange(0x3000, 0x303F)
+	o.addRange(0xFF00, 0xFFEF)
+}
+
+// addIdeographs adds all CJK ideographs, radicals and compatibility
+// characters that have not been added yet.
+func (o *runeOrder) addIdeographs() {
+	o.addRange(0x4E00, 0x9FFF)
+	o.addRange(0x3400, 0x4DBF)
+	o.addRange(0xF900, 0xFAFF)
+	o.addRange(0x2E80, 0x2FDF)
+	o.addRange(0x31C0, 0x31EF)
+	o.addRange(0x3200, 0x33FF)
+}
+
+func chineseSimplifiedOrder() []rune {
+	var o runeOrder
+	o.addPunctuation()
+	o.addFrequencies("chinese-simplified")
+	// The two levels of hanzi of GB 2312
+	o.addCharset(simplifiedchinese.GBK, 0xB0, 0xD7, eucTrails, unicode.Han)
+	o.addCharset(simplifiedchinese.GBK, 0xD8, 0xF7, eucTrails, unicode.Han)
+	o.addRange(0x3100, 0x312F)
+	o.addIdeographs()
+	return o.runes
+}
+
+func chineseTraditionalOrder() []rune {
+	var o runeOrder
+	o.addPunctuation()
+	o.addRange(0x3100, 0x312F)
+	o.addRange(0x31A0, 0x31BF)
+	o.addFrequencies("chinese-traditional")
+	// The frequently and less frequently used hanzi of Big5
+	big5Trails := [][2]byte{{0x40, 0x7E}, {0xA1, 0xFE}}
+	o.addCharset(traditionalchinese.Big5, 0xA4, 0xC6, big5Trails, unicode.Han)
+	o.addCharset(traditionalchinese.Big5, 0xC9, 0xF9, big5Trails, unicode.Han)
+	o.addIdeographs()
+	return o.runes
+}
+
+func japaneseOrder() []rune {
+	var o runeOrder
+	o.addPunctuation()
+	o.addRange(0x3040, 0x30FF)
+	o.addRange(0x31F0, 0x31FF)
+	o.addFrequencies("japanese")
+	// The two levels of kanji of JIS X 0208
+	o.addCharset(japanese.EUCJP, 0xB0, 0xCF, eucTrails, unicode.Han)
+	o.addCharset(japanese.EUCJP, 0xD0, 0xF4, eucTrails, unicode.Han)
+	o.addIdeographs()
+	return o.runes
+}
+
+func koreanOrder() []rune {
+	var o runeOrder
+	o.addPunctuation()
+	o.addRange(0x3130, 0x318F)
+	// The hangul of KS X 1001, followed by the remaining hangul
+	o.addFrequencies("korean")
+	o.addRange(0xAC00, 0xD7A3)
+	o.addRange(0x1100, 0x11FF)
+	o.addRange(0xA960, 0xA97F)
+	o.addRange(0xD7B0, 0xD7FF)
+	// The hanja of KS X 1001
+	o.addCharset(korean.EUCKR, 0xCA, 0xFD, eucTrails, unicode.Han)
+	o.addIdeographs()
+	return o.runes
+}
@@ -1,101 +1,25 @@
 package subsetting
 
 import (
+	"embed"
 	"fmt"
 	"strings"
 )
 
-var subsetRanges = map[string][][]rune{
-	"latin": {
-		{0x0000, 0x00FF},
-		{0x0131},
-		{0x0152, 0x0153},
-		{0x02BB, 0x02BC},
-		{0x02C6},
-		{0x02DA},
-		{0x02DC},
-		{0x0304},
-		{0x0308},
-		{0x0329},
-		{0x2000, 0x206F},
-		{0x20AC},
-		{0x2122},
-		{0x2191},
-		{0x2193},
-		{0x2212},
-		{0x2215},
-		{0xFEFF},
-		{0xFFFD},
-	},
-	"latin-ext": {
-		{0x0100, 0x02BA},
-		{0x02BD, 0x02C5},
-		{0x02C7, 0x02CC},
-		{0x02CE, 0x02D7},
-		{0x02DD, 0x02FF},
-		{0x0304},
-		{0x0308},
-		{0x0329},
-		{0x1D00, 0x1DBF},
-		{0x1E00, 0x1E9F},
-		{0x1EF2, 0x1EFF},
-		{0x2020},
-		{0x20A0, 0x20AB},
-		{0x20AD, 0x20C0},
-		{0x2113},
-		{0x2C60, 0x2C7F},
-		{0xA720, 0xA7FF},
-	},
-	"vietnamese": {
-		{0x0102, 0x0103},
-		{0x0110, 0x0111},
-		{0x0128, 0x0129},
-		{0x0168, 0x0169},
-		{0x01A0, 0x01A1},
-		{0x01AF, 0x01B0},
-		{0x0300, 0x0301},
-		{0x0303, 0x0304},
-		{0x0308, 0x0309},
-		{0x0323},
-		{0x0329},
-		{0x1EA0, 0x1EF9},
-		{0x20AB},
-	},
-	"cyrillic-ext": {
-		{0x0460, 0x052F},
-		{0x1C80, 0x1C8A},
-		{0x20B4},
-		{0x2DE0, 0x2DFF},
-		{0xA640, 0xA69F},
-		{0xFE2E, 0xFE2F},
-	},
-	"cyrillic": {
-		{0x0301},
-		{0x0400, 0x045F},
-		{0x0490, 0x0491},
-		{0x04B0, 0x04B1},
-		{0x2116},
-	},
-	"greek-ext": {
-		{0x1F00, 0x1FFF},
-	},
-	"greek": {
-		{0x0370, 0x0377},
-		{0x037A, 0x037F},
-		{0x0384, 0x038A},
-		{0x038C},
-		{0x038E, 0x03A1},
-		{0x03A3, 0x03FF},
-	},
-	"hebrew": {
-		{0x0307, 0x0308},
-		{0x0590, 0x05FF},
-		{0x200C, 0x2010},
-		{0x20AA},
-		{0x25CC},
-		{0xFB1D, 0xFB4F},
-	},
-}
+//go:embed subsets/*.txt
+var bundledSubsets embed.FS
+
+// subsetRanges are the Unicode ranges of each subset, loaded from the bundled
+// definitions and from the directories passed to Load. The subsets of complex
+// scripts also cover the combining marks they share with other scripts, ZWJ
+// and ZWNJ, and the dotted circle that shapers insert before stray marks.
+var subsetRanges = func() map[string][][]rune {
+	subsets, err := parseDir(bundledSubsets, "subsets")
+	if err != nil {
+		panic(err)
+	}
+	return subsets
+}()
 
 // Takes a subset and returns a string in HarfBuzz format:
 //
@@ -104,7 +28,7 @@ var subsetRanges = map[string][][]rune{
 //	0152-0153
 //	...
 func BuildHarfbuzzString(subset string) string {
-	unicodeRanges, found := subsetRanges[subset]
+	unicodeRanges, found := lookup(subset)
 	if !found {
 		panic(fmt.Errorf("invalid subset key: %s", subset))
 	}
@@ -123,7 +47,7 @@ func BuildHarfbuzzString(subset string) string {
 //
 //	"U+0000-00FF, U+0131, U+0152-0153, ..."
 func BuildCSSString(subset string) string {
-	unicodeRanges, found := subsetRanges[subset]
+	unicodeRanges, found := lookup(subset)
 	if !found {
 		panic(fmt.Errorf("invalid subset key: %s", subset))
 	}
@@ -137,3 +61,35 @@ func BuildCSSString(subset string) string {
 	}
 	return strings.Join(cssRanges, ", ")
 }
+
+// Takes a subset and returns all code points covered by its Unicode ranges.
+func Runes(subset string) []rune {
+	unicodeRanges, found := lookup(subset)
+	if !found {
+		panic(fmt.Errorf("invalid subset key: %s", subset))
+	}
+	var runes []rune
+	for _, r := range unicodeRanges {
+		last := r[len(r)-1]
+		for c := r[0]; c <= last; c++ {
+			runes = append(runes, c)
+		}
+	}
+	return runes
+}
+
+// Reports whether the subset is known, i.e. whether it can be passed to the
+// other functions of this package.
+func Exists(subset string) bool {
+	_, found := lookup(subset)
+	return found
+}
+
+// lookup returns the Unicode ranges of a subset or of a shard of a sharded
+// subset.
+func lookup(subset string) ([][]rune, bool) {
+	if unicodeRanges, found := subsetRanges[subset]; found {
+		return unicodeRanges, true
+	}
+	return shardRanges(subset)
+}
@@ -1,10 +1,14 @@
 package subsetting_test
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/lyxell/font.delivery/api/internal/subsetting"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildHarfbuzzString(t *testing.T) {
@@ -66,6 +70,18 @@ func TestBuildCSSString(t *testing.T) {
 			subset:   "latin-ext",
 			expected: "U+0100-02BA, U+02BD-02C5, U+02C7-02CC, U+02CE-02D7, U+02DD-02FF, U+0304, U+0308, U+0329, U+1D00-1DBF, U+1E00-1E9F, U+1EF2-1EFF, U+2020, U+20A0-20AB, U+20AD-20C0, U+2113, U+2C60-2C7F, U+A720-A7FF",
 		},
+		{
+			subset:   "arabic",
+			expected: "U+0600-06FF, U+0750-077F, U+0870-088E, U+0890-0891, U+0898-08E1, U+08E3-08FF, U+200C-200E, U+2010-2011, U+204F, U+25CC, U+2E41, U+FB50-FDFF, U+FE70-FE74, U+FE76-FEFC",
+		},
+		{
+			subset:   "devanagari",
+			expected: "U+0900-097F, U+1CD0-1CF9, U+200C-200D, U+20A8, U+20B9, U+20F0, U+25CC, U+A830-A839, U+A8E0-A8FF",
+		},
+		{
+			subset:   "thai",
+			expected: "U+02D7, U+0303, U+0331, U+0E01-0E5B, U+200C-200D, U+25CC",
+		},
 		{
 			subset:   "latin",
 			expected: "U+0000-00FF, U+0131, U+0152-0153, U+02BB-02BC, U+02C6, U+02DA, U+02DC, U+0304, U+0308, U+0329, U+2000-206F, U+20AC, U+2122, U+2191, U+2193, U+2212, U+2215, U+FEFF, U+FFFD",
@@ -79,6 +95,32 @@ func TestBuildCSSString(t *testing.T) {
 	}
 }
 
+func TestRunes(t *testing.T) {
+	runes := subsetting.Runes("greek-ext")
+	assert.Len(t, runes, 0x100)
+	assert.Equal(t, rune(0x1F00), runes[0])
+	assert.Equal(t, rune(0x1FFF), runes[len(runes)-1])
+
+	runes = subsetting.Runes("cyrillic")
+	assert.Equal(t, []rune{0x0301, 0x0400, 0x0401}, runes[:3])
+	assert.Equal(t, rune(0x2116), runes[len(runes)-1])
+}
+
+func TestComplexScriptSubsets(t *testing.T) {
+	subsets := []string{"arabic", "bengali", "devanagari", "gujarati", "gurmukhi", "kannada", "khmer", "lao", "malayalam", "myanmar", "oriya", "sinhala", "tamil", "telugu", "thai"}
+	for _, subset := range subsets {
+		t.Run(subset, func(t *testing.T) {
+			require.True(t, subsetting.Exists(subset))
+			runes := subsetting.Runes(subset)
+			// Shapers need ZWNJ, ZWJ and the dotted circle
+			assert.Contains(t, runes, rune(0x200C))
+			assert.Contains(t, runes, rune(0x200D))
+			assert.Contains(t, runes, rune(0x25CC))
+		})
+	}
+	assert.Contains(t, subsetting.BuildHarfbuzzString("sinhala"), "111E1-111F4\n")
+}
+
 func TestInvalidSubsetKey(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -98,3 +140,101 @@ func TestInvalidSubsetKeyCSS(t *testing.T) {
 
 	subsetting.BuildCSSString("invalid-key")
 }
+
+func TestShards(t *testing.T) {
+	assert.Equal(t, []string{"latin"}, subsetting.Shards("latin"))
+
+	shards := subsetting.Shards("japanese")
+	require.Len(t, shards, 100)
+	assert.Equal(t, "japanese-0", shards[0])
+	assert.Equal(t, "japanese-99", shards[99])
+
+	// Every code point of the subset is in exactly one shard
+	seen := make(map[rune]bool)
+	for _, shard := range shards {
+		require.True(t, subsetting.Exists(shard))
+		for _, r := range subsetting.Runes(shard) {
+			require.False(t, seen[r], "U+%04X is in more than one shard", r)
+			seen[r] = true
+		}
+	}
+	assert.Len(t, seen, len(subsetting.Runes("japanese")))
+
+	// Punctuation and kana come first, common kanji before rare kanji
+	assert.Contains(t, subsetting.Runes("japanese-0"), rune(0x3002))
+	assert.Contains(t, subsetting.Runes("japanese-1"), rune(0x3042))
+	first := slices.IndexFunc(shards, func(shard string) bool { return slices.Contains(subsetting.Runes(shard), '日') })
+	last := slices.IndexFunc(shards, func(shard string) bool { return slices.Contains(subsetting.Runes(shard), '龘') })
+	assert.Less(t, first, last)
+
+	// Hanzi are in order of frequency rather than in the order of GB 2312,
+	// which starts with the rare 啊
+	shards = subsetting.Shards("chinese-simplified")
+	common := slices.IndexFunc(shards, func(shard string) bool { return slices.Contains(subsetting.Runes(shard), '的') })
+	rare := slices.IndexFunc(shards, func(shard string) bool { return slices.Contains(subsetting.Runes(shard), '啊') })
+	assert.Less(t, common, rare)
+}
+
+func TestShardNames(t *testing.T) {
+	for _, name := range []string{"korean-0", "chinese-simplified-42", "chinese-traditional-99"} {
+		assert.True(t, subsetting.Exists(name), name)
+	}
+	for _, name := range []string{"korean-100", "korean--1", "korean-07", "korean-", "latin-0", "chinese-1"} {
+		assert.False(t, subsetting.Exists(name), name)
+	}
+	assert.Equal(t, "U+3000-303F, U+FF00-FFE5", subsetting.BuildCSSString("chinese-simplified-0"))
+}
+
+func TestIsSharded(t *testing.T) {
+	for _, name := range []string{"japanese", "japanese-17", "chinese-traditional-0"} {
+		assert.True(t, subsetting.IsSharded(name), name)
+	}
+	for _, name := range []string{"latin", "cyrillic-ext", "japanese-100", "invalid-key"} {
+		assert.False(t, subsetting.IsSharded(name), name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"acme-logo.txt":   "# Letters of a logo\n0041, 0043 # A and C\nU+0045-0046\n",
+		"test-core.nam":   "#$ include test-digits.nam\n0x0020 SPACE\n         .notdef\n0x0021 EXCLAMATION MARK\n",
+		"test-digits.nam": "0x0031 ONE\n0x0030 ZERO\n",
+		"unrelated.json":  "{}",
+		"notes.md":        "",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	require.NoError(t, subsetting.Load(dir))
+
+	assert.Equal(t, "U+0041, U+0043, U+0045-0046", subsetting.BuildCSSString("acme-logo"))
+	assert.Equal(t, "0020-0021\n0030-0031\n", subsetting.BuildHarfbuzzString("test-core"))
+	assert.Equal(t, "U+0030-0031", subsetting.BuildCSSString("test-digits"))
+	assert.False(t, subsetting.Exists("unrelated"))
+	assert.True(t, subsetting.Exists("latin"))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{map[string]string{"test.txt": "0041\nXYZ\n"}, `test.txt:2: invalid code point "XYZ"`},
+		{map[string]string{"test.txt": "0046-0041\n"}, "test.txt:1: invalid range 0046-0041"},
+		{map[string]string{"test.txt": "110000\n"}, `test.txt:1: invalid code point "110000"`},
+		{map[string]string{"test.txt": "# nothing\n"}, "test.txt: subset test has no code points"},
+		{map[string]string{"test.txt": "0041\n", "test.nam": "0x0041\n"}, "test.txt: subset test is defined more than once"},
+		{map[string]string{"Test_1.txt": "0041\n"}, `Test_1.txt: invalid subset name "Test_1"`},
+		{map[string]string{"japanese.txt": "0041\n"}, "japanese.txt: the sharded subset japanese can't be redefined"},
+		{map[string]string{"test.nam": "#$ include test.nam\n"}, "test.nam: include cycle"},
+		{map[string]string{"test.nam": "#$ include ../test.nam\n"}, "test.nam:1: invalid include ../test.nam"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		}
+		assert.EqualError(t, subsetting.Load(dir), tt.expected)
+	}
+}
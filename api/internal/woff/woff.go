@@ -0,0 +1,128 @@
+// Package woff encodes and decodes WOFF font files as described in the W3C
+// WOFF File Format 1.0 specification.
+package woff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+const (
+	signature  = 0x774F4646 // "wOFF"
+	headerSize = 44
+	entrySize  = 20
+)
+
+var ErrInvalidWOFF = errors.New("invalid WOFF file")
+
+// Encode converts a TrueType or OpenType font to WOFF.
+//
+// Tables are compressed with zlib unless compression doesn't make them
+// smaller, in which case they are stored as they are.
+func Encode(data []byte) ([]byte, error) {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	// Serialize the font to get the checksums of the tables, including the
+	// checksum adjustment of the head table
+	normalized := font.Bytes()
+	font, err = sfnt.Parse(normalized)
+	if err != nil {
+		return nil, err
+	}
+	tags := font.Tags()
+
+	out := make([]byte, headerSize+entrySize*len(tags))
+	for i, tag := range tags {
+		table := font.Tables[tag]
+		compressed, err := compress(table)
+		if err != nil {
+			return nil, err
+		}
+		stored := table
+		if len(compressed) < len(table) {
+			stored = compressed
+		}
+		entry := out[headerSize+entrySize*i:]
+		copy(entry[0:4], tag)
+		binary.BigEndian.PutUint32(entry[4:], uint32(len(out)))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(stored)))
+		binary.BigEndian.PutUint32(entry[12:], uint32(len(table)))
+		// The table directory of the serialized font is in tag order too
+		binary.BigEndian.PutUint32(entry[16:], binary.BigEndian.Uint32(normalized[12+16*i+4:]))
+		out = append(out, stored...)
+		out = append(out, make([]byte, (len(stored)+3)&^3-len(stored))...)
+	}
+
+	binary.BigEndian.PutUint32(out[0:], signature)
+	binary.BigEndian.PutUint32(out[4:], font.Version)
+	binary.BigEndian.PutUint32(out[8:], uint32(len(out)))
+	binary.BigEndian.PutUint16(out[12:], uint16(len(tags)))
+	binary.BigEndian.PutUint32(out[16:], uint32(len(normalized)))
+	binary.BigEndian.PutUint16(out[20:], 1)
+	return out, nil
+}
+
+// compress compresses a table with zlib.
+func compress(table []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(table); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode converts a WOFF file back to a TrueType or OpenType font.
+func Decode(data []byte) ([]byte, error) {
+	if len(data) < headerSize || binary.BigEndian.Uint32(data) != signature {
+		return nil, ErrInvalidWOFF
+	}
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	if len(data) < headerSize+entrySize*numTables {
+		return nil, fmt.Errorf("%w: truncated table directory", ErrInvalidWOFF)
+	}
+	font := &sfnt.Font{
+		Version: binary.BigEndian.Uint32(data[4:]),
+		Tables:  make(map[string][]byte, numTables),
+	}
+	for i := 0; i < numTables; i++ {
+		entry := data[headerSize+entrySize*i:]
+		tag := string(entry[0:4])
+		offset := int(binary.BigEndian.Uint32(entry[4:]))
+		compLength := int(binary.BigEndian.Uint32(entry[8:]))
+		origLength := int(binary.BigEndian.Uint32(entry[12:]))
+		if offset < 0 || compLength < 0 || offset+compLength > len(data) || offset+compLength < offset {
+			return nil, fmt.Errorf("%w: table %q out of bounds", ErrInvalidWOFF, tag)
+		}
+		table := data[offset : offset+compLength]
+		if compLength < origLength {
+			reader, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return nil, fmt.Errorf("%w: table %q: %w", ErrInvalidWOFF, tag, err)
+			}
+			table, err = io.ReadAll(io.LimitReader(reader, int64(origLength)+1))
+			if err != nil {
+				return nil, fmt.Errorf("%w: table %q: %w", ErrInvalidWOFF, tag, err)
+			}
+		}
+		if len(table) != origLength {
+			return nil, fmt.Errorf("%w: table %q has wrong length", ErrInvalidWOFF, tag)
+		}
+		font.Tables[tag] = table
+	}
+	return font.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package woff
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	data, err := Encode(goregular.TTF)
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(goregular.TTF))
+	assert.Equal(t, uint32(len(data)), binary.BigEndian.Uint32(data[8:]))
+
+	source, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	normalized := source.Bytes()
+	assert.Equal(t, uint32(len(normalized)), binary.BigEndian.Uint32(data[16:]))
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, normalized, decoded)
+}
+
+func TestEncodeChecksums(t *testing.T) {
+	data, err := Encode(goregular.TTF)
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	font, err := sfnt.Parse(decoded)
+	require.NoError(t, err)
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	for i := 0; i < numTables; i++ {
+		entry := data[headerSize+entrySize*i:]
+		tag := string(entry[0:4])
+		checksum := sfnt.Checksum(font.Tables[tag])
+		if tag == "head" {
+			// The checksum of head is calculated with a zero checksum
+			// adjustment
+			checksum -= binary.BigEndian.Uint32(font.Tables[tag][8:])
+		}
+		assert.Equal(t, checksum, binary.BigEndian.Uint32(entry[16:]), "table %q", tag)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte("not a font"))
+	assert.ErrorIs(t, err, ErrInvalidWOFF)
+	data, err := Encode(goregular.TTF)
+	require.NoError(t, err)
+	_, err = Decode(data[:len(data)/2])
+	assert.ErrorIs(t, err, ErrInvalidWOFF)
+}
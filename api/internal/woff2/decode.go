@@ -0,0 +1,333 @@
+package woff2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/andybalholm/brotli"
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Decode converts a WOFF2 file back to a TrueType or OpenType font.
+func Decode(data []byte) ([]byte, error) {
+	if len(data) < headerSize || binary.BigEndian.Uint32(data) != signature {
+		return nil, ErrInvalidWOFF2
+	}
+	flavor := binary.BigEndian.Uint32(data[4:])
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	totalCompressedSize := int(binary.BigEndian.Uint32(data[20:]))
+
+	type tableEntry struct {
+		tag         string
+		length      int
+		transformed bool
+	}
+	var entries []tableEntry
+	offset := headerSize
+	for i := 0; i < numTables; i++ {
+		if offset >= len(data) {
+			return nil, fmt.Errorf("%w: truncated table directory", ErrInvalidWOFF2)
+		}
+		flags := data[offset]
+		offset++
+		var tag string
+		if index := int(flags & 0x3F); index == 0x3F {
+			if offset+4 > len(data) {
+				return nil, fmt.Errorf("%w: truncated table directory", ErrInvalidWOFF2)
+			}
+			tag = string(data[offset : offset+4])
+			offset += 4
+		} else if index < len(knownTags) {
+			tag = knownTags[index]
+		} else {
+			return nil, fmt.Errorf("%w: unknown table index %d", ErrInvalidWOFF2, index)
+		}
+		origLength, n, err := readUIntBase128(data[offset:])
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+		transformVersion := flags >> 6
+		entry := tableEntry{tag: tag, length: int(origLength)}
+		if tag == "glyf" || tag == "loca" {
+			entry.transformed = transformVersion == 0
+		} else {
+			entry.transformed = transformVersion != 0
+		}
+		if entry.transformed {
+			if tag != "glyf" && tag != "loca" {
+				return nil, fmt.Errorf("%w: unsupported transform of table %q", ErrInvalidWOFF2, tag)
+			}
+			transformLength, n, err := readUIntBase128(data[offset:])
+			if err != nil {
+				return nil, err
+			}
+			offset += n
+			entry.length = int(transformLength)
+		}
+		entries = append(entries, entry)
+	}
+
+	if offset+totalCompressedSize > len(data) {
+		return nil, fmt.Errorf("%w: truncated compressed data", ErrInvalidWOFF2)
+	}
+	tableData, err := io.ReadAll(brotli.NewReader(bytes.NewReader(data[offset : offset+totalCompressedSize])))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWOFF2, err)
+	}
+
+	font := &sfnt.Font{
+		Version: flavor,
+		Tables:  make(map[string][]byte),
+	}
+	offset = 0
+	glyfTransformed := false
+	for _, entry := range entries {
+		if offset+entry.length > len(tableData) {
+			return nil, fmt.Errorf("%w: table %q out of bounds", ErrInvalidWOFF2, entry.tag)
+		}
+		font.Tables[entry.tag] = tableData[offset : offset+entry.length]
+		offset += entry.length
+		if entry.tag == "glyf" && entry.transformed {
+			glyfTransformed = true
+		}
+	}
+	if glyfTransformed {
+		glyf, loca, err := reconstructGlyf(font.Tables["glyf"])
+		if err != nil {
+			return nil, err
+		}
+		font.Tables["glyf"] = glyf
+		font.Tables["loca"] = loca
+	}
+	return font.Bytes(), nil
+}
+
+// reconstructGlyf reverses the glyf table transform and returns the glyf and
+// loca tables.
+func reconstructGlyf(data []byte) ([]byte, []byte, error) {
+	if len(data) < glyfHeaderSize {
+		return nil, nil, fmt.Errorf("%w: truncated glyf table", ErrInvalidWOFF2)
+	}
+	optionFlags := binary.BigEndian.Uint16(data[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(data[4:]))
+	indexFormat := binary.BigEndian.Uint16(data[6:])
+	var streams [7][]byte
+	offset := glyfHeaderSize
+	for i := range streams {
+		size := int(binary.BigEndian.Uint32(data[8+4*i:]))
+		if offset+size > len(data) || size < 0 {
+			return nil, nil, fmt.Errorf("%w: glyf stream out of bounds", ErrInvalidWOFF2)
+		}
+		streams[i] = data[offset : offset+size]
+		offset += size
+	}
+	nContourStream, nPointsStream, flagStream, glyphStream, compositeStream, bboxStream, instructionStream := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	bboxBitmapSize := 4 * ((numGlyphs + 31) / 32)
+	var overlapBitmap []byte
+	if optionFlags&overlapFlagsBit != 0 {
+		overlapBitmap = data[offset:]
+		if len(overlapBitmap) < (numGlyphs+7)/8 {
+			return nil, nil, fmt.Errorf("%w: truncated overlap bitmap", ErrInvalidWOFF2)
+		}
+	}
+	if len(nContourStream) < 2*numGlyphs || len(bboxStream) < bboxBitmapSize {
+		return nil, nil, fmt.Errorf("%w: truncated glyf stream", ErrInvalidWOFF2)
+	}
+	bboxBitmap := bboxStream[:bboxBitmapSize]
+	bboxStream = bboxStream[bboxBitmapSize:]
+
+	// take consumes n bytes from a stream
+	take := func(stream *[]byte, n int) ([]byte, error) {
+		if n > len(*stream) {
+			return nil, fmt.Errorf("%w: truncated glyf stream", ErrInvalidWOFF2)
+		}
+		result := (*stream)[:n]
+		*stream = (*stream)[n:]
+		return result, nil
+	}
+	read255 := func(stream *[]byte) (int, error) {
+		value, n, err := read255UInt16(*stream)
+		*stream = (*stream)[n:]
+		return int(value), err
+	}
+
+	glyphs := make([][]byte, numGlyphs)
+	for gid := range glyphs {
+		numberOfContours := int16(binary.BigEndian.Uint16(nContourStream[2*gid:]))
+		hasBbox := bboxBitmap[gid>>3]&(0x80>>(gid&7)) != 0
+		var bbox []byte
+		if hasBbox {
+			var err error
+			if bbox, err = take(&bboxStream, 8); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		switch {
+		case numberOfContours == 0:
+			if hasBbox {
+				return nil, nil, fmt.Errorf("%w: empty glyph %d with bounding box", ErrInvalidWOFF2, gid)
+			}
+
+		case numberOfContours < 0:
+			if !hasBbox {
+				return nil, nil, fmt.Errorf("%w: composite glyph %d without bounding box", ErrInvalidWOFF2, gid)
+			}
+			// Parse the component records by prepending a glyph header
+			header := slices.Concat([]byte{0xFF, 0xFF}, bbox)
+			length, hasInstructions, err := sfnt.CompositeLength(slices.Concat(header, compositeStream))
+			if err != nil {
+				return nil, nil, err
+			}
+			components, _ := take(&compositeStream, length-10)
+			glyph := slices.Concat(header, components)
+			if hasInstructions {
+				instructionLength, err := read255(&glyphStream)
+				if err != nil {
+					return nil, nil, err
+				}
+				instructions, err := take(&instructionStream, instructionLength)
+				if err != nil {
+					return nil, nil, err
+				}
+				glyph = binary.BigEndian.AppendUint16(glyph, uint16(instructionLength))
+				glyph = append(glyph, instructions...)
+			}
+			glyphs[gid] = glyph
+
+		default:
+			var g simpleGlyph
+			numPoints := 0
+			for i := 0; i < int(numberOfContours); i++ {
+				n, err := read255(&nPointsStream)
+				if err != nil {
+					return nil, nil, err
+				}
+				numPoints += n
+				g.endPoints = append(g.endPoints, uint16(numPoints-1))
+			}
+			flags, err := take(&flagStream, numPoints)
+			if err != nil {
+				return nil, nil, err
+			}
+			x, y := 0, 0
+			for _, flag := range flags {
+				dx, dy, onCurve, n, err := readTriplet(flag, glyphStream)
+				if err != nil {
+					return nil, nil, err
+				}
+				glyphStream = glyphStream[n:]
+				x, y = x+dx, y+dy
+				g.points = append(g.points, point{x, y, onCurve})
+			}
+			instructionLength, err := read255(&glyphStream)
+			if err != nil {
+				return nil, nil, err
+			}
+			if g.instructions, err = take(&instructionStream, instructionLength); err != nil {
+				return nil, nil, err
+			}
+			if hasBbox {
+				for i := range g.bbox {
+					g.bbox[i] = int16(binary.BigEndian.Uint16(bbox[2*i:]))
+				}
+			} else {
+				g.bbox = boundingBox(g.points)
+			}
+			g.overlap = overlapBitmap != nil && overlapBitmap[gid>>3]&(0x80>>(gid&7)) != 0
+			glyphs[gid] = encodeSimpleGlyph(g)
+		}
+	}
+
+	// Build the glyf and loca tables using the loca format of the font
+	var glyf []byte
+	var loca []byte
+	for gid := 0; gid <= numGlyphs; gid++ {
+		if indexFormat == 0 {
+			if len(glyf) > 0x1FFFE {
+				return nil, nil, fmt.Errorf("%w: glyf table too large for short loca offsets", ErrInvalidWOFF2)
+			}
+			loca = binary.BigEndian.AppendUint16(loca, uint16(len(glyf)/2))
+		} else {
+			loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+		}
+		if gid < numGlyphs {
+			glyf = append(glyf, glyphs[gid]...)
+			if len(glyf)%2 == 1 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	return glyf, loca, nil
+}
+
+// encodeSimpleGlyph encodes a simple glyph description using the most compact
+// representation of flags and coordinates.
+func encodeSimpleGlyph(g simpleGlyph) []byte {
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(g.endPoints)))
+	for _, v := range g.bbox {
+		out = binary.BigEndian.AppendUint16(out, uint16(v))
+	}
+	for _, end := range g.endPoints {
+		out = binary.BigEndian.AppendUint16(out, end)
+	}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(g.instructions)))
+	out = append(out, g.instructions...)
+
+	flags := make([]byte, len(g.points))
+	var xs, ys []byte
+	x, y := 0, 0
+	for i, p := range g.points {
+		dx, dy := p.x-x, p.y-y
+		x, y = p.x, p.y
+		if p.onCurve {
+			flags[i] |= onCurvePoint
+		}
+		if i == 0 && g.overlap {
+			flags[i] |= overlapSimple
+		}
+		switch {
+		case dx == 0:
+			flags[i] |= xIsSameOrPos
+		case dx >= -255 && dx <= 255:
+			flags[i] |= xShortVector
+			if dx > 0 {
+				flags[i] |= xIsSameOrPos
+			}
+			xs = append(xs, byte(abs(dx)))
+		default:
+			xs = binary.BigEndian.AppendUint16(xs, uint16(int16(dx)))
+		}
+		switch {
+		case dy == 0:
+			flags[i] |= yIsSameOrPos
+		case dy >= -255 && dy <= 255:
+			flags[i] |= yShortVector
+			if dy > 0 {
+				flags[i] |= yIsSameOrPos
+			}
+			ys = append(ys, byte(abs(dy)))
+		default:
+			ys = binary.BigEndian.AppendUint16(ys, uint16(int16(dy)))
+		}
+	}
+	// Compress runs of identical flags using the repeat flag
+	for i := 0; i < len(flags); {
+		j := i + 1
+		for j < len(flags) && flags[j] == flags[i] && j-i <= 255 {
+			j++
+		}
+		if j-i > 1 {
+			out = append(out, flags[i]|repeatFlag, byte(j-i-1))
+		} else {
+			out = append(out, flags[i])
+		}
+		i = j
+	}
+	out = append(out, xs...)
+	return append(out, ys...)
+}
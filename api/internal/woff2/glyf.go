@@ -0,0 +1,342 @@
+package woff2
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+// Flags used by simple glyph descriptions
+const (
+	onCurvePoint  = 0x01
+	xShortVector  = 0x02
+	yShortVector  = 0x04
+	repeatFlag    = 0x08
+	xIsSameOrPos  = 0x10
+	yIsSameOrPos  = 0x20
+	overlapSimple = 0x40
+)
+
+const (
+	// glyfHeaderSize is the size of the header of the transformed glyf table
+	glyfHeaderSize = 36
+	// overlapFlagsBit is set in the option flags of the transformed glyf
+	// table if it contains an overlap bitmap
+	overlapFlagsBit = 0x0001
+)
+
+// point is a point of a simple glyph in absolute coordinates.
+type point struct {
+	x, y    int
+	onCurve bool
+}
+
+// simpleGlyph is a parsed simple glyph description.
+type simpleGlyph struct {
+	bbox         [4]int16
+	endPoints    []uint16
+	instructions []byte
+	points       []point
+	overlap      bool
+}
+
+// transformGlyf applies the WOFF2 glyf table transform, which splits the
+// glyph descriptions into separate streams that compress better.
+func transformGlyf(font *sfnt.Font) ([]byte, error) {
+	glyphs, err := font.Glyphs()
+	if err != nil {
+		return nil, err
+	}
+	numGlyphs := len(glyphs)
+	indexFormat := binary.BigEndian.Uint16(font.Tables["head"][50:])
+
+	var nContourStream, nPointsStream, flagStream, glyphStream, compositeStream, bboxStream, instructionStream []byte
+	bboxBitmap := make([]byte, 4*((numGlyphs+31)/32))
+	overlapBitmap := make([]byte, (numGlyphs+7)/8)
+	hasOverlap := false
+
+	for gid, glyph := range glyphs {
+		if len(glyph) == 0 {
+			nContourStream = binary.BigEndian.AppendUint16(nContourStream, 0)
+			continue
+		}
+		if len(glyph) < 10 {
+			return nil, fmt.Errorf("glyph %d is truncated", gid)
+		}
+		numberOfContours := int16(binary.BigEndian.Uint16(glyph))
+		nContourStream = binary.BigEndian.AppendUint16(nContourStream, uint16(numberOfContours))
+
+		if numberOfContours < 0 {
+			// Composite glyphs are stored as they are but always have an
+			// explicit bounding box
+			length, hasInstructions, err := sfnt.CompositeLength(glyph)
+			if err != nil {
+				return nil, err
+			}
+			compositeStream = append(compositeStream, glyph[10:length]...)
+			bboxBitmap[gid>>3] |= 0x80 >> (gid & 7)
+			bboxStream = append(bboxStream, glyph[2:10]...)
+			if hasInstructions {
+				if len(glyph) < length+2 {
+					return nil, fmt.Errorf("glyph %d is truncated", gid)
+				}
+				instructionLength := int(binary.BigEndian.Uint16(glyph[length:]))
+				if len(glyph) < length+2+instructionLength {
+					return nil, fmt.Errorf("glyph %d is truncated", gid)
+				}
+				glyphStream = append255UInt16(glyphStream, uint16(instructionLength))
+				instructionStream = append(instructionStream, glyph[length+2:length+2+instructionLength]...)
+			}
+			continue
+		}
+
+		simple, err := parseSimpleGlyph(glyph)
+		if err != nil {
+			return nil, fmt.Errorf("glyph %d: %w", gid, err)
+		}
+		previousEnd := -1
+		for _, end := range simple.endPoints {
+			nPointsStream = append255UInt16(nPointsStream, uint16(int(end)-previousEnd))
+			previousEnd = int(end)
+		}
+		x, y := 0, 0
+		for _, p := range simple.points {
+			flagStream, glyphStream = appendTriplet(flagStream, glyphStream, p.x-x, p.y-y, p.onCurve)
+			x, y = p.x, p.y
+		}
+		glyphStream = append255UInt16(glyphStream, uint16(len(simple.instructions)))
+		instructionStream = append(instructionStream, simple.instructions...)
+		// The bounding box is only stored if it differs from the one
+		// calculated from the points
+		if simple.bbox != boundingBox(simple.points) {
+			bboxBitmap[gid>>3] |= 0x80 >> (gid & 7)
+			for _, v := range simple.bbox {
+				bboxStream = binary.BigEndian.AppendUint16(bboxStream, uint16(v))
+			}
+		}
+		if simple.overlap {
+			overlapBitmap[gid>>3] |= 0x80 >> (gid & 7)
+			hasOverlap = true
+		}
+	}
+
+	out := make([]byte, glyfHeaderSize)
+	if hasOverlap {
+		binary.BigEndian.PutUint16(out[2:], overlapFlagsBit)
+	}
+	binary.BigEndian.PutUint16(out[4:], uint16(numGlyphs))
+	binary.BigEndian.PutUint16(out[6:], indexFormat)
+	streams := [][]byte{
+		nContourStream,
+		nPointsStream,
+		flagStream,
+		glyphStream,
+		compositeStream,
+		append(bboxBitmap, bboxStream...),
+		instructionStream,
+	}
+	for i, stream := range streams {
+		binary.BigEndian.PutUint32(out[8+4*i:], uint32(len(stream)))
+		out = append(out, stream...)
+	}
+	if hasOverlap {
+		out = append(out, overlapBitmap...)
+	}
+	return out, nil
+}
+
+// parseSimpleGlyph parses a simple glyph description.
+func parseSimpleGlyph(glyph []byte) (g simpleGlyph, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed glyph: %v", r)
+		}
+	}()
+	numberOfContours := int(binary.BigEndian.Uint16(glyph))
+	for i := range g.bbox {
+		g.bbox[i] = int16(binary.BigEndian.Uint16(glyph[2+2*i:]))
+	}
+	offset := 10
+	numPoints := 0
+	for i := 0; i < numberOfContours; i++ {
+		end := binary.BigEndian.Uint16(glyph[offset:])
+		if int(end)+1 <= numPoints && i > 0 {
+			return g, fmt.Errorf("contour end points are not increasing")
+		}
+		g.endPoints = append(g.endPoints, end)
+		numPoints = int(end) + 1
+		offset += 2
+	}
+	instructionLength := int(binary.BigEndian.Uint16(glyph[offset:]))
+	offset += 2
+	g.instructions = glyph[offset : offset+instructionLength]
+	offset += instructionLength
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		flag := glyph[offset]
+		offset++
+		flags = append(flags, flag)
+		if flag&repeatFlag != 0 {
+			count := int(glyph[offset])
+			offset++
+			for i := 0; i < count; i++ {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if len(flags) > numPoints {
+		return g, fmt.Errorf("too many flags")
+	}
+	g.overlap = numPoints > 0 && flags[0]&overlapSimple != 0
+
+	g.points = make([]point, numPoints)
+	x := 0
+	for i, flag := range flags {
+		switch {
+		case flag&xShortVector != 0:
+			dx := int(glyph[offset])
+			offset++
+			if flag&xIsSameOrPos == 0 {
+				dx = -dx
+			}
+			x += dx
+		case flag&xIsSameOrPos == 0:
+			x += int(int16(binary.BigEndian.Uint16(glyph[offset:])))
+			offset += 2
+		}
+		g.points[i].x = x
+		g.points[i].onCurve = flag&onCurvePoint != 0
+	}
+	y := 0
+	for i, flag := range flags {
+		switch {
+		case flag&yShortVector != 0:
+			dy := int(glyph[offset])
+			offset++
+			if flag&yIsSameOrPos == 0 {
+				dy = -dy
+			}
+			y += dy
+		case flag&yIsSameOrPos == 0:
+			y += int(int16(binary.BigEndian.Uint16(glyph[offset:])))
+			offset += 2
+		}
+		g.points[i].y = y
+	}
+	if offset > len(glyph) {
+		return g, fmt.Errorf("glyph is truncated")
+	}
+	return g, nil
+}
+
+// boundingBox calculates the bounding box of a set of points.
+func boundingBox(points []point) [4]int16 {
+	if len(points) == 0 {
+		return [4]int16{}
+	}
+	xMin, yMin, xMax, yMax := points[0].x, points[0].y, points[0].x, points[0].y
+	for _, p := range points[1:] {
+		xMin = min(xMin, p.x)
+		yMin = min(yMin, p.y)
+		xMax = max(xMax, p.x)
+		yMax = max(yMax, p.y)
+	}
+	return [4]int16{int16(xMin), int16(yMin), int16(xMax), int16(yMax)}
+}
+
+// appendTriplet appends a point delta using the triplet encoding of the
+// transformed glyf table.
+func appendTriplet(flags []byte, data []byte, dx int, dy int, onCurve bool) ([]byte, []byte) {
+	absX, absY := abs(dx), abs(dy)
+	flag := byte(0)
+	if !onCurve {
+		flag = 128
+	}
+	xSign, ySign := byte(0), byte(0)
+	if dx >= 0 {
+		xSign = 1
+	}
+	if dy >= 0 {
+		ySign = 1
+	}
+	xySigns := xSign + 2*ySign
+	switch {
+	case dx == 0 && absY < 1280:
+		flags = append(flags, flag+byte((absY&0xF00)>>7)+ySign)
+		data = append(data, byte(absY))
+	case dy == 0 && absX < 1280:
+		flags = append(flags, flag+10+byte((absX&0xF00)>>7)+xSign)
+		data = append(data, byte(absX))
+	case absX < 65 && absY < 65:
+		flags = append(flags, flag+20+byte((absX-1)&0x30)+byte(((absY-1)&0x30)>>2)+xySigns)
+		data = append(data, byte(((absX-1)&0xF)<<4|((absY-1)&0xF)))
+	case absX < 769 && absY < 769:
+		flags = append(flags, flag+84+12*byte(((absX-1)&0x300)>>8)+byte(((absY-1)&0x300)>>6)+xySigns)
+		data = append(data, byte(absX-1), byte(absY-1))
+	case absX < 4096 && absY < 4096:
+		flags = append(flags, flag+120+xySigns)
+		data = append(data, byte(absX>>4), byte((absX&0xF)<<4|absY>>8), byte(absY))
+	default:
+		flags = append(flags, flag+124+xySigns)
+		data = append(data, byte(absX>>8), byte(absX), byte(absY>>8), byte(absY))
+	}
+	return flags, data
+}
+
+// readTriplet decodes a point delta using the triplet encoding of the
+// transformed glyf table and returns the number of data bytes read.
+func readTriplet(flag byte, data []byte) (dx int, dy int, onCurve bool, n int, err error) {
+	onCurve = flag&0x80 == 0
+	flag &= 0x7F
+	switch {
+	case flag < 84:
+		n = 1
+	case flag < 120:
+		n = 2
+	case flag < 124:
+		n = 3
+	default:
+		n = 4
+	}
+	if len(data) < n {
+		return 0, 0, false, 0, fmt.Errorf("%w: truncated glyph stream", ErrInvalidWOFF2)
+	}
+	withSign := func(flag byte, value int) int {
+		if flag&1 != 0 {
+			return value
+		}
+		return -value
+	}
+	switch {
+	case flag < 10:
+		dy = withSign(flag, int(flag&14)<<7+int(data[0]))
+	case flag < 20:
+		dx = withSign(flag, int((flag-10)&14)<<7+int(data[0]))
+	case flag < 84:
+		b0 := int(flag - 20)
+		b1 := int(data[0])
+		dx = withSign(flag, 1+(b0&0x30)+(b1>>4))
+		dy = withSign(flag>>1, 1+(b0&0x0C)<<2+(b1&0x0F))
+	case flag < 120:
+		b0 := int(flag - 84)
+		dx = withSign(flag, 1+(b0/12)<<8+int(data[0]))
+		dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(data[1]))
+	case flag < 124:
+		b2 := int(data[1])
+		dx = withSign(flag, int(data[0])<<4+b2>>4)
+		dy = withSign(flag>>1, (b2&0x0F)<<8+int(data[2]))
+	default:
+		dx = withSign(flag, int(data[0])<<8+int(data[1]))
+		dy = withSign(flag>>1, int(data[2])<<8+int(data[3]))
+	}
+	return dx, dy, onCurve, n, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,230 @@
+// Package woff2 encodes and decodes WOFF2 font files as described in the
+// W3C WOFF File Format 2.0 specification.
+package woff2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/andybalholm/brotli"
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+)
+
+const (
+	signature  = 0x774F4632 // "wOF2"
+	headerSize = 48
+)
+
+var ErrInvalidWOFF2 = errors.New("invalid WOFF2 file")
+
+// knownTags are the table tags that can be referenced by index in the table
+// directory.
+var knownTags = []string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// Encode converts a TrueType or OpenType font to WOFF2.
+//
+// The glyf and loca tables are transformed if present and all table data is
+// compressed with Brotli.
+func Encode(data []byte) ([]byte, error) {
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to storing glyf and loca as they are if the glyf table can't
+	// be transformed
+	var transformedGlyf []byte
+	if font.Tables["glyf"] != nil && font.Tables["loca"] != nil {
+		transformedGlyf, err = transformGlyf(font)
+		if err != nil {
+			transformedGlyf = nil
+		}
+	}
+
+	var directory []byte
+	var tableData []byte
+	totalSfntSize := 12 + 16*len(font.Tables)
+	for _, tag := range tableOrder(font) {
+		table := font.Tables[tag]
+		origLength := len(table)
+		totalSfntSize += (origLength + 3) &^ 3
+
+		transformVersion := byte(0)
+		transformed := false
+		switch tag {
+		case "glyf":
+			if transformedGlyf != nil {
+				table = transformedGlyf
+				transformed = true
+			} else {
+				transformVersion = 3
+			}
+		case "loca":
+			if transformedGlyf != nil {
+				// The loca table is reconstructed from the glyf table
+				origLength = reconstructedLocaLength(font)
+				table = nil
+				transformed = true
+			} else {
+				transformVersion = 3
+			}
+		case "head":
+			// Bit 11 of the flags is set for fonts that have been
+			// converted by a lossless transform
+			table = slices.Clone(table)
+			if len(table) >= 18 {
+				flags := binary.BigEndian.Uint16(table[16:])
+				binary.BigEndian.PutUint16(table[16:], flags|0x0800)
+			}
+		}
+
+		index := slices.Index(knownTags, tag)
+		if index < 0 {
+			directory = append(directory, 0x3F|transformVersion<<6)
+			directory = append(directory, tag...)
+		} else {
+			directory = append(directory, byte(index)|transformVersion<<6)
+		}
+		directory = appendUIntBase128(directory, uint32(origLength))
+		if transformed {
+			directory = appendUIntBase128(directory, uint32(len(table)))
+		}
+		tableData = append(tableData, table...)
+	}
+
+	var compressed bytes.Buffer
+	writer := brotli.NewWriterOptions(&compressed, brotli.WriterOptions{Quality: brotli.BestCompression, LGWin: 24})
+	if _, err := writer.Write(tableData); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	length := headerSize + len(directory) + compressed.Len()
+	paddedLength := (length + 3) &^ 3
+	out := make([]byte, headerSize, paddedLength)
+	binary.BigEndian.PutUint32(out[0:], signature)
+	binary.BigEndian.PutUint32(out[4:], font.Version)
+	binary.BigEndian.PutUint32(out[8:], uint32(paddedLength))
+	binary.BigEndian.PutUint16(out[12:], uint16(len(font.Tables)))
+	binary.BigEndian.PutUint32(out[16:], uint32(totalSfntSize))
+	binary.BigEndian.PutUint32(out[20:], uint32(compressed.Len()))
+	binary.BigEndian.PutUint16(out[24:], 1)
+	out = append(out, directory...)
+	out = append(out, compressed.Bytes()...)
+	out = append(out, make([]byte, paddedLength-length)...)
+	return out, nil
+}
+
+// tableOrder returns the order in which tables are stored. Tables are sorted
+// by tag, except that loca immediately follows glyf.
+func tableOrder(font *sfnt.Font) []string {
+	tags := slices.DeleteFunc(font.Tags(), func(tag string) bool {
+		return tag == "loca"
+	})
+	if font.Tables["loca"] != nil {
+		index := slices.Index(tags, "glyf")
+		tags = slices.Insert(tags, index+1, "loca")
+	}
+	return tags
+}
+
+// reconstructedLocaLength returns the length of the loca table that a decoder
+// reconstructs from a transformed glyf table.
+func reconstructedLocaLength(font *sfnt.Font) int {
+	numGlyphs, _ := font.NumGlyphs()
+	if binary.BigEndian.Uint16(font.Tables["head"][50:]) != 0 {
+		return 4 * (numGlyphs + 1)
+	}
+	return 2 * (numGlyphs + 1)
+}
+
+// appendUIntBase128 appends a variable length encoded integer.
+func appendUIntBase128(data []byte, value uint32) []byte {
+	size := 1
+	for value>>(7*size) != 0 {
+		size++
+	}
+	for i := size - 1; i >= 0; i-- {
+		b := byte(value>>(7*i)) & 0x7F
+		if i > 0 {
+			b |= 0x80
+		}
+		data = append(data, b)
+	}
+	return data
+}
+
+// readUIntBase128 reads a variable length encoded integer and returns the
+// value and the number of bytes read.
+func readUIntBase128(data []byte) (uint32, int, error) {
+	var value uint32
+	for i := 0; i < 5 && i < len(data); i++ {
+		b := data[i]
+		// Leading zeros are not allowed
+		if i == 0 && b == 0x80 {
+			return 0, 0, fmt.Errorf("%w: UIntBase128 with leading zeros", ErrInvalidWOFF2)
+		}
+		// The value must fit in 32 bits
+		if value&0xFE000000 != 0 {
+			return 0, 0, fmt.Errorf("%w: UIntBase128 overflow", ErrInvalidWOFF2)
+		}
+		value = value<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: truncated UIntBase128", ErrInvalidWOFF2)
+}
+
+// append255UInt16 appends a variable length encoded 16-bit integer.
+func append255UInt16(data []byte, value uint16) []byte {
+	switch {
+	case value < 253:
+		return append(data, byte(value))
+	case value < 506:
+		return append(data, 255, byte(value-253))
+	case value < 762:
+		return append(data, 254, byte(value-506))
+	default:
+		return append(data, 253, byte(value>>8), byte(value))
+	}
+}
+
+// read255UInt16 reads a variable length encoded 16-bit integer and returns
+// the value and the number of bytes read.
+func read255UInt16(data []byte) (uint16, int, error) {
+	if len(data) < 1 {
+		return 0, 0, fmt.Errorf("%w: truncated 255UInt16", ErrInvalidWOFF2)
+	}
+	switch code := data[0]; code {
+	case 253:
+		if len(data) < 3 {
+			return 0, 0, fmt.Errorf("%w: truncated 255UInt16", ErrInvalidWOFF2)
+		}
+		return binary.BigEndian.Uint16(data[1:]), 3, nil
+	case 254, 255:
+		if len(data) < 2 {
+			return 0, 0, fmt.Errorf("%w: truncated 255UInt16", ErrInvalidWOFF2)
+		}
+		base := uint16(253)
+		if code == 254 {
+			base = 506
+		}
+		return base + uint16(data[1]), 2, nil
+	default:
+		return uint16(code), 1, nil
+	}
+}
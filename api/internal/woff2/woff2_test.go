@@ -0,0 +1,136 @@
+package woff2
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/lyxell/font.delivery/api/internal/sfnt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+	xsfnt "golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestUIntBase128(t *testing.T) {
+	for _, value := range []uint32{0, 1, 127, 128, 16383, 16384, 1 << 28, 0xFFFFFFFF} {
+		data := appendUIntBase128(nil, value)
+		decoded, n, err := readUIntBase128(data)
+		require.NoError(t, err)
+		assert.Equal(t, value, decoded)
+		assert.Equal(t, len(data), n)
+	}
+	_, _, err := readUIntBase128([]byte{0x80, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidWOFF2)
+	_, _, err = readUIntBase128([]byte{0x90, 0x80, 0x80, 0x80, 0x00})
+	assert.ErrorIs(t, err, ErrInvalidWOFF2)
+	_, _, err = readUIntBase128([]byte{0x81})
+	assert.ErrorIs(t, err, ErrInvalidWOFF2)
+}
+
+func Test255UInt16(t *testing.T) {
+	for _, value := range []uint16{0, 252, 253, 505, 506, 761, 762, 0xFFFF} {
+		data := append255UInt16(nil, value)
+		decoded, n, err := read255UInt16(data)
+		require.NoError(t, err)
+		assert.Equal(t, value, decoded)
+		assert.Equal(t, len(data), n)
+	}
+}
+
+func TestTriplet(t *testing.T) {
+	values := []int{0, 1, -1, 64, -65, 300, -768, 769, 1279, 1280, -4095, 4096, 32767, -32768}
+	for _, dx := range values {
+		for _, dy := range values {
+			for _, onCurve := range []bool{true, false} {
+				flags, data := appendTriplet(nil, nil, dx, dy, onCurve)
+				require.Len(t, flags, 1)
+				decodedX, decodedY, decodedOnCurve, n, err := readTriplet(flags[0], data)
+				require.NoError(t, err)
+				assert.Equal(t, []any{dx, dy, onCurve, len(data)}, []any{decodedX, decodedY, decodedOnCurve, n})
+			}
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	data, err := Encode(goregular.TTF)
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(goregular.TTF)/2)
+	assert.Zero(t, len(data)%4)
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	source, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	font, err := sfnt.Parse(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, source.Tags(), font.Tags())
+	for _, tag := range source.Tags() {
+		switch tag {
+		case "glyf", "loca":
+		case "head":
+			// Only the checksum adjustment and flags may differ
+			assert.Equal(t, source.Tables[tag][:8], font.Tables[tag][:8])
+			assert.Equal(t, source.Tables[tag][18:], font.Tables[tag][18:])
+		default:
+			assert.Equal(t, source.Tables[tag], font.Tables[tag], "table %q differs", tag)
+		}
+	}
+
+	// The glyph outlines should be unchanged
+	sourceFont, err := xsfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	decodedFont, err := xsfnt.Parse(decoded)
+	require.NoError(t, err)
+	var buf xsfnt.Buffer
+	ppem := fixed.I(int(sourceFont.UnitsPerEm()))
+	for gid := 0; gid < sourceFont.NumGlyphs(); gid++ {
+		expected, err := sourceFont.LoadGlyph(&buf, xsfnt.GlyphIndex(gid), ppem, nil)
+		require.NoError(t, err)
+		expected = append(xsfnt.Segments{}, expected...)
+		actual, err := decodedFont.LoadGlyph(&buf, xsfnt.GlyphIndex(gid), ppem, nil)
+		require.NoError(t, err)
+		require.Equal(t, expected, append(xsfnt.Segments{}, actual...), "glyph %d differs", gid)
+	}
+}
+
+func TestEncodeDecodeComposite(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	require.NoError(t, err)
+	glyphs, err := font.Glyphs()
+	require.NoError(t, err)
+
+	// Replace the last glyph by a composite of glyphs 1 and 2 with
+	// instructions
+	composite := binary.BigEndian.AppendUint16(nil, 0xFFFF)
+	composite = append(composite, glyphs[1][2:10]...)
+	composite = append(composite, 0x00, 0x23, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00) // moreComponents, argsAreWords
+	composite = append(composite, 0x01, 0x02, 0x00, 0x02, 0x10, 0x20)             // weHaveInstructions
+	composite = append(composite, 0x00, 0x02, 0xB0, 0x01)
+	glyphs[len(glyphs)-1] = composite
+	glyf, loca, indexToLocFormat := sfnt.BuildGlyf(glyphs)
+	font.Tables["glyf"] = glyf
+	font.Tables["loca"] = loca
+	binary.BigEndian.PutUint16(font.Tables["head"][50:], indexToLocFormat)
+
+	data, err := Encode(font.Bytes())
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	decodedFont, err := sfnt.Parse(decoded)
+	require.NoError(t, err)
+	decodedGlyphs, err := decodedFont.Glyphs()
+	require.NoError(t, err)
+	require.Len(t, decodedGlyphs, len(glyphs))
+	assert.Equal(t, composite, decodedGlyphs[len(glyphs)-1][:len(composite)])
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte("not a font"))
+	assert.ErrorIs(t, err, ErrInvalidWOFF2)
+	data, err := Encode(goregular.TTF)
+	require.NoError(t, err)
+	_, err = Decode(data[:len(data)/2])
+	assert.ErrorIs(t, err, ErrInvalidWOFF2)
+}
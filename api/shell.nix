@@ -9,6 +9,5 @@ pkgs.mkShell {
 	pkgs.just
 	pkgs.miniserve
 	pkgs.redocly
-	pkgs.woff2
   ];
 }
@@ -2,32 +2,177 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/huh"
+	"github.com/lyxell/font.delivery/api/fontaxes"
 	"github.com/lyxell/font.delivery/cli/internal/api"
 )
 
+// fontFormats are the formats fonts are downloaded in for each value of the
+// --format flag, in the order they are listed in the generated CSS, out of the
+// formats the selected family is published in. WOFF is a fallback for browsers
+// without WOFF2 support. No CSS is generated for TTF files, which are meant for
+// desktop and native apps.
+var fontFormats = map[string][]string{
+	"woff2": {"woff2", "woff"},
+	"ttf":   {"ttf"},
+}
+
+// availableFormats returns the formats that a font family is published in, out
+// of the formats to download. The builder doesn't generate WOFF files unless
+// it is configured to.
+func availableFormats(formats []string, published []api.GetFonts200Formats) []string {
+	var result []string
+	for _, format := range formats {
+		if slices.Contains(published, api.GetFonts200Formats(format)) {
+			result = append(result, format)
+		}
+	}
+	return result
+}
+
+// fontAxes formats the variation axes other than wght the way they appear in
+// font file names, e.g. "opsz8-144.wdth25-151"
+func fontAxes(axes []api.FontFamilyAxis) string {
+	return fontaxes.FileName(familyAxes(axes))
+}
+
+// familyAxes converts the variation axes of a font family as returned by the
+// API
+func familyAxes(axes []api.FontFamilyAxis) []fontaxes.Axis {
+	var result []fontaxes.Axis
+	for _, axis := range axes {
+		result = append(result, fontaxes.Axis{Tag: axis.Tag, MinValue: axis.MinValue, MaxValue: axis.MaxValue})
+	}
+	return result
+}
+
+// fontFileName returns the file name of a font as served by the API
+func fontFileName(fontID, subset, weight, style, format string, axes []api.FontFamilyAxis) string {
+	if axesString := fontAxes(axes); axesString != "" {
+		return fmt.Sprintf("%s_%s_%s_%s_%s.%s", fontID, subset, weight, style, axesString, format)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.%s", fontID, subset, weight, style, format)
+}
+
 // generateFontFaceCSS generates the @font-face CSS rule for a font
 func generateFontFaceCSS(
 	fontName, fontID, subset, weight, style, unicodeRange string,
+	formats []string,
+	axes []api.FontFamilyAxis,
 ) string {
-	url := fmt.Sprintf("%s_%s_%s_%s.woff2", fontID, subset, weight, style)
+	var sources []string
+	for _, format := range formats {
+		url := fontFileName(fontID, subset, weight, style, format, axes)
+		sources = append(sources, fmt.Sprintf("url('%s') format('%s')", url, format))
+	}
+	descriptors := fontaxes.CSS(style, familyAxes(axes))
+	var extra strings.Builder
+	if descriptors.FontStretch != "" {
+		fmt.Fprintf(&extra, "  font-stretch: %s;\n", descriptors.FontStretch)
+	}
+	if descriptors.FontVariationSettings != "" {
+		fmt.Fprintf(&extra, "  font-variation-settings: %s;\n", descriptors.FontVariationSettings)
+	}
 	return strings.TrimSpace(fmt.Sprintf(`
 @font-face {
   font-family: '%s';
   font-style: %s;
   font-weight: %s;
-  src: url('%s') format('woff2');
+%s  src: %s;
   unicode-range: %s;
 }
-`, fontName, style, strings.Replace(weight, "-", " ", 1), url, unicodeRange))
+`, fontName, descriptors.FontStyle, strings.Replace(weight, "-", " ", 1), extra.String(), strings.Join(sources, ", "), unicodeRange))
 }
 
-func run() error {
+// generateFallbackCSS generates the @font-face rule of a fallback of a font
+// family, which declares the local fonts of the fallback with metric overrides
+// as the companion family "{name} Fallback"
+func generateFallbackCSS(fontName string, fallback api.FontFamilyFallback) string {
+	var sources []string
+	for _, name := range fallback.LocalSrc {
+		sources = append(sources, fmt.Sprintf("local('%s')", name))
+	}
+	var weight, overrides strings.Builder
+	if fallback.Weight != nil {
+		fmt.Fprintf(&weight, "  font-weight: %s;\n", strings.Replace(*fallback.Weight, "-", " ", 1))
+	}
+	if fallback.SizeAdjust != 0 {
+		fmt.Fprintf(&overrides, "  size-adjust: %v%%;\n", fallback.SizeAdjust)
+	}
+	if fallback.AscentOverride != 0 {
+		fmt.Fprintf(&overrides, "  ascent-override: %v%%;\n", fallback.AscentOverride)
+	}
+	if fallback.DescentOverride != nil {
+		fmt.Fprintf(&overrides, "  descent-override: %v%%;\n", *fallback.DescentOverride)
+	}
+	if fallback.LineGapOverride != nil {
+		fmt.Fprintf(&overrides, "  line-gap-override: %v%%;\n", *fallback.LineGapOverride)
+	}
+	return strings.TrimSpace(fmt.Sprintf(`
+@font-face {
+  font-family: '%s Fallback';
+%s  src: %s;
+%s}
+`, fontName, weight.String(), strings.Join(sources, ", "), overrides.String()))
+}
+
+// downloadFont downloads a font, using the variable font endpoint for fonts
+// that have other axes than wght
+func downloadFont(client *api.ClientWithResponses, fontID, subset, weight, style, format string, axes []api.FontFamilyAxis) ([]byte, error) {
+	var body []byte
+	var statusCode int
+	if axesString := fontAxes(axes); axesString != "" {
+		response, err := client.DownloadVariableFontWithResponse(
+			context.Background(),
+			fontID,
+			subset,
+			weight,
+			api.DownloadVariableFontParamsStyle(style),
+			axesString,
+			api.DownloadVariableFontParamsFormat(format),
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("downloading font: %w", err)
+		}
+		body, statusCode = response.Body, response.StatusCode()
+	} else {
+		response, err := client.DownloadFontWithResponse(
+			context.Background(),
+			fontID,
+			subset,
+			weight,
+			api.DownloadFontParamsStyle(style),
+			api.DownloadFontParamsFormat(format),
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("downloading font: %w", err)
+		}
+		body, statusCode = response.Body, response.StatusCode()
+	}
+	if statusCode != 200 {
+		return nil, fmt.Errorf("failed to download font, HTTP status: %d", statusCode)
+	}
+	return body, nil
+}
+
+// supportsLanguage reports whether a font family supports a language, given
+// as a language and script code such as vi_Latn. Every font family supports
+// the empty language.
+func supportsLanguage(languages []string, language string) bool {
+	return language == "" || slices.Contains(languages, language)
+}
+
+// list prints the IDs and names of the font families that support a language
+func list(language string) error {
 	client, err := api.NewClientWithResponses("https://font.delivery/api/v2")
 	if err != nil {
 		return fmt.Errorf("creating API client: %w", err)
@@ -37,12 +182,54 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("fetching fonts: %w", err)
 	}
+	if fonts.JSON200 == nil {
+		return fmt.Errorf("failed to fetch fonts, HTTP status: %d", fonts.StatusCode())
+	}
+
+	for _, font := range *fonts.JSON200 {
+		if !supportsLanguage(font.Languages, language) {
+			continue
+		}
+		fmt.Printf("%s\t%s\n", font.Id, font.Name)
+	}
+	return nil
+}
+
+func run(format, language string) error {
+	formats, found := fontFormats[format]
+	if !found {
+		return fmt.Errorf("unknown format %q, must be woff2 or ttf", format)
+	}
+	generateCSS := format != "ttf"
+
+	client, err := api.NewClientWithResponses("https://font.delivery/api/v2")
+	if err != nil {
+		return fmt.Errorf("creating API client: %w", err)
+	}
+
+	fonts, err := client.GetFontsWithResponse(context.Background())
+	if err != nil {
+		return fmt.Errorf("fetching fonts: %w", err)
+	}
+	if fonts.JSON200 == nil {
+		return fmt.Errorf("failed to fetch fonts, HTTP status: %d", fonts.StatusCode())
+	}
 
 	var fontOptions []huh.Option[int]
 	for i, font := range *fonts.JSON200 {
+		if !slices.Contains(font.Formats, api.GetFonts200Formats(formats[0])) {
+			continue
+		}
+		if !supportsLanguage(font.Languages, language) {
+			continue
+		}
 		fontOptions = append(fontOptions, huh.NewOption(font.Name, i))
 	}
 
+	if len(fontOptions) == 0 {
+		return fmt.Errorf("no font families are published in %s and support the language %q", formats[0], language)
+	}
+
 	var selected int
 	err = huh.NewForm(
 		huh.NewGroup(
@@ -59,6 +246,7 @@ func run() error {
 	}
 
 	selectedFont := (*fonts.JSON200)[selected]
+	formats = availableFormats(formats, selectedFont.Formats)
 
 	var subsetOptions []huh.Option[string]
 	for _, subset := range selectedFont.Subsets {
@@ -100,14 +288,41 @@ func run() error {
 
 	// Fetch subsets
 	subsetsResponse, err := client.GetSubsetsWithResponse(context.Background())
-	if err != nil || subsetsResponse.JSON200 == nil {
+	if err != nil {
 		return fmt.Errorf("fetching subsets: %w", err)
 	}
+	if subsetsResponse.JSON200 == nil {
+		return fmt.Errorf("failed to fetch subsets, HTTP status: %d", subsetsResponse.StatusCode())
+	}
 
-	// Build a map of subset to unicode ranges
+	// Build a map of subset to unicode ranges, including the shards of
+	// sharded subsets
 	subsetRanges := make(map[string]string)
+	subsetShards := make(map[string][]string)
 	for _, subset := range *subsetsResponse.JSON200 {
 		subsetRanges[string(subset.Subset)] = subset.Ranges
+		if subset.Shards == nil {
+			continue
+		}
+		for _, shard := range *subset.Shards {
+			subsetRanges[shard.Shard] = shard.Ranges
+			subsetShards[string(subset.Subset)] = append(subsetShards[string(subset.Subset)], shard.Shard)
+		}
+	}
+
+	// Sharded subsets are downloaded shard by shard, leaving out the shards
+	// that aren't published for the font
+	var downloadSubsets []string
+	for _, subset := range selectedSubsets {
+		if shards, found := subsetShards[subset]; found {
+			for _, shard := range shards {
+				if !slices.Contains(selectedFont.EmptyShards, shard) {
+					downloadSubsets = append(downloadSubsets, shard)
+				}
+			}
+		} else {
+			downloadSubsets = append(downloadSubsets, subset)
+		}
 	}
 
 	// Download license file
@@ -129,30 +344,33 @@ func run() error {
 
 	var cssContent strings.Builder
 	for _, style := range selectedStyles {
-		for _, subset := range selectedSubsets {
+		for _, subset := range downloadSubsets {
 			for _, weight := range selectedWeights {
-				response, err := client.DownloadFontWithResponse(
-					context.Background(),
-					selectedFont.Id,
-					api.DownloadFontParamsSubset(subset),
-					weight,
-					api.DownloadFontParamsStyle(style),
-				)
-				if err != nil {
-					return fmt.Errorf("downloading font: %w", err)
-				}
+				// downloadedFormats are the formats listed in the CSS, which
+				// leaves out fallback formats that fail to download
+				var downloadedFormats []string
+				for i, format := range formats {
+					fontFileName := fontFileName(selectedFont.Id, subset, weight, style, format, selectedFont.Axes)
+					body, err := downloadFont(client, selectedFont.Id, subset, weight, style, format, selectedFont.Axes)
+					if err != nil && i > 0 {
+						log.Printf("Warning: skipping %s: %v", fontFileName, err)
+						continue
+					}
+					if err != nil {
+						return err
+					}
 
-				if response.StatusCode() != 200 {
-					return fmt.Errorf("failed to download font, HTTP status: %d", response.StatusCode())
-				}
+					err = os.WriteFile(fontFileName, body, 0o644)
+					if err != nil {
+						return fmt.Errorf("writing font file: %w", err)
+					}
 
-				fontFileName := fmt.Sprintf("%s_%s_%s_%s.woff2", selectedFont.Id, subset, weight, style)
-				err = os.WriteFile(fontFileName, response.Body, 0o644)
-				if err != nil {
-					return fmt.Errorf("writing font file: %w", err)
+					fmt.Printf("Font downloaded and saved as %s\n", fontFileName)
+					downloadedFormats = append(downloadedFormats, format)
+				}
+				if !generateCSS {
+					continue
 				}
-
-				fmt.Printf("Font downloaded and saved as %s\n", fontFileName)
 				cssContent.WriteString(generateFontFaceCSS(
 					selectedFont.Name,
 					selectedFont.Id,
@@ -160,23 +378,59 @@ func run() error {
 					weight,
 					string(style),
 					subsetRanges[string(subset)],
+					downloadedFormats,
+					selectedFont.Axes,
 				))
 				cssContent.WriteString("\n")
 			}
 		}
 	}
 
+	if !generateCSS {
+		return nil
+	}
+
+	// Declare the fallbacks of the family, which stand in for it while the
+	// fonts load
+	familyResponse, err := client.GetFontFamilyWithResponse(context.Background(), selectedFont.Id)
+	if err != nil {
+		return fmt.Errorf("fetching font family: %w", err)
+	}
+	if familyResponse.JSON200 == nil {
+		return fmt.Errorf("failed to fetch font family, HTTP status: %d", familyResponse.StatusCode())
+	}
+	for _, fallback := range familyResponse.JSON200.Fallbacks {
+		cssContent.WriteString(generateFallbackCSS(selectedFont.Name, fallback))
+		cssContent.WriteString("\n")
+	}
+
 	cssFileName := selectedFont.Id + ".css"
 	err = os.WriteFile(cssFileName, []byte(cssContent.String()), 0o644)
 	if err != nil {
 		return fmt.Errorf("writing CSS file: %w", err)
 	}
 	fmt.Printf("CSS file generated: %s\n", cssFileName)
+	fmt.Printf("Use the family in your CSS: font-family: %s;\n", familyResponse.JSON200.FontFamily)
 	return nil
 }
 
 func main() {
-	if err := run(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "list" {
+		listFlags := flag.NewFlagSet("list", flag.ExitOnError)
+		language := listFlags.String("language", "", "Only list font families that support a language, given as a language and script code such as vi_Latn")
+		listFlags.Parse(os.Args[2:])
+
+		if err := list(*language); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	format := flag.String("format", "woff2", "Format to download fonts in: woff2 for websites, with WOFF fallbacks where published and a stylesheet, or ttf for desktop and native apps")
+	language := flag.String("language", "", "Only offer font families that support a language, given as a language and script code such as vi_Latn")
+	flag.Parse()
+
+	if err := run(*format, *language); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 }
@@ -2,10 +2,18 @@ module github.com/lyxell/font.delivery/cli
 
 go 1.23.3
 
+require (
+	github.com/lyxell/font.delivery/api v0.0.0
+	github.com/oapi-codegen/runtime v1.1.1
+)
+
+// The api module is part of the same repository, and shares how fonts are
+// named and described with the CLI
+replace github.com/lyxell/font.delivery/api => ../api
+
 require (
 	github.com/apapsch/go-jsonmerge/v2 v2.0.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/oapi-codegen/runtime v1.1.1 // indirect
 )
 
 require (
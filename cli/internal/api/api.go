@@ -15,30 +15,198 @@ import (
 	"github.com/oapi-codegen/runtime"
 )
 
-// Defines values for DownloadFontParamsSubset.
+// Defines values for GetFontFamilySubsetCSSParamsSubset.
 const (
-	Cyrillic    DownloadFontParamsSubset = "cyrillic"
-	CyrillicExt DownloadFontParamsSubset = "cyrillic-ext"
-	Greek       DownloadFontParamsSubset = "greek"
-	GreekExt    DownloadFontParamsSubset = "greek-ext"
-	Hebrew      DownloadFontParamsSubset = "hebrew"
-	Latin       DownloadFontParamsSubset = "latin"
-	LatinExt    DownloadFontParamsSubset = "latin-ext"
-	Vietnamese  DownloadFontParamsSubset = "vietnamese"
+	Arabic             GetFontFamilySubsetCSSParamsSubset = "arabic"
+	Bengali            GetFontFamilySubsetCSSParamsSubset = "bengali"
+	ChineseSimplified  GetFontFamilySubsetCSSParamsSubset = "chinese-simplified"
+	ChineseTraditional GetFontFamilySubsetCSSParamsSubset = "chinese-traditional"
+	Cyrillic           GetFontFamilySubsetCSSParamsSubset = "cyrillic"
+	CyrillicExt        GetFontFamilySubsetCSSParamsSubset = "cyrillic-ext"
+	Devanagari         GetFontFamilySubsetCSSParamsSubset = "devanagari"
+	Greek              GetFontFamilySubsetCSSParamsSubset = "greek"
+	GreekExt           GetFontFamilySubsetCSSParamsSubset = "greek-ext"
+	Gujarati           GetFontFamilySubsetCSSParamsSubset = "gujarati"
+	Gurmukhi           GetFontFamilySubsetCSSParamsSubset = "gurmukhi"
+	Hebrew             GetFontFamilySubsetCSSParamsSubset = "hebrew"
+	Japanese           GetFontFamilySubsetCSSParamsSubset = "japanese"
+	Kannada            GetFontFamilySubsetCSSParamsSubset = "kannada"
+	Khmer              GetFontFamilySubsetCSSParamsSubset = "khmer"
+	Korean             GetFontFamilySubsetCSSParamsSubset = "korean"
+	Lao                GetFontFamilySubsetCSSParamsSubset = "lao"
+	Latin              GetFontFamilySubsetCSSParamsSubset = "latin"
+	LatinExt           GetFontFamilySubsetCSSParamsSubset = "latin-ext"
+	Malayalam          GetFontFamilySubsetCSSParamsSubset = "malayalam"
+	Myanmar            GetFontFamilySubsetCSSParamsSubset = "myanmar"
+	Oriya              GetFontFamilySubsetCSSParamsSubset = "oriya"
+	Sinhala            GetFontFamilySubsetCSSParamsSubset = "sinhala"
+	Tamil              GetFontFamilySubsetCSSParamsSubset = "tamil"
+	Telugu             GetFontFamilySubsetCSSParamsSubset = "telugu"
+	Thai               GetFontFamilySubsetCSSParamsSubset = "thai"
+	Vietnamese         GetFontFamilySubsetCSSParamsSubset = "vietnamese"
+)
+
+// Defines values for GetCSS2ParamsDisplay.
+const (
+	Auto     GetCSS2ParamsDisplay = "auto"
+	Block    GetCSS2ParamsDisplay = "block"
+	Fallback GetCSS2ParamsDisplay = "fallback"
+	Optional GetCSS2ParamsDisplay = "optional"
+	Swap     GetCSS2ParamsDisplay = "swap"
 )
 
 // Defines values for DownloadFontParamsStyle.
 const (
-	Italic DownloadFontParamsStyle = "italic"
-	Normal DownloadFontParamsStyle = "normal"
+	DownloadFontParamsStyleItalic DownloadFontParamsStyle = "italic"
+	DownloadFontParamsStyleNormal DownloadFontParamsStyle = "normal"
+)
+
+// Defines values for DownloadFontParamsFormat.
+const (
+	DownloadFontParamsFormatTtf   DownloadFontParamsFormat = "ttf"
+	DownloadFontParamsFormatWoff  DownloadFontParamsFormat = "woff"
+	DownloadFontParamsFormatWoff2 DownloadFontParamsFormat = "woff2"
+)
+
+// Defines values for DownloadVariableFontParamsStyle.
+const (
+	DownloadVariableFontParamsStyleItalic DownloadVariableFontParamsStyle = "italic"
+	DownloadVariableFontParamsStyleNormal DownloadVariableFontParamsStyle = "normal"
+)
+
+// Defines values for DownloadVariableFontParamsFormat.
+const (
+	DownloadVariableFontParamsFormatTtf   DownloadVariableFontParamsFormat = "ttf"
+	DownloadVariableFontParamsFormatWoff  DownloadVariableFontParamsFormat = "woff"
+	DownloadVariableFontParamsFormatWoff2 DownloadVariableFontParamsFormat = "woff2"
 )
 
-// DownloadFontParamsSubset defines parameters for DownloadFont.
-type DownloadFontParamsSubset string
+// FontFamilyAxis defines model for FontFamilyAxis.
+type FontFamilyAxis struct {
+	// MaxValue The maximum value of the axis
+	MaxValue float32 `json:"max_value"`
+
+	// MinValue The minimum value of the axis
+	MinValue float32 `json:"min_value"`
+
+	// Tag The tag of the axis
+	Tag string `json:"tag"`
+}
+
+// FontFamilyFallback defines model for FontFamilyFallback.
+type FontFamilyFallback struct {
+	// AscentOverride The ascent-override of the local fonts in percent, or 0 if it is not set
+	AscentOverride float32 `json:"ascent_override"`
+
+	// DescentOverride The descent-override of the local fonts in percent. Left out if it is not set.
+	DescentOverride *float32 `json:"descent_override,omitempty"`
+
+	// LineGapOverride The line-gap-override of the local fonts in percent. Left out if it is not set.
+	LineGapOverride *float32 `json:"line_gap_override,omitempty"`
+
+	// LocalSrc The names of the local fonts in order of preference
+	LocalSrc []string `json:"local_src"`
+
+	// SizeAdjust The size-adjust of the local fonts in percent, or 0 if it is not set
+	SizeAdjust float32 `json:"size_adjust"`
+
+	// Weight The weight the fallback applies to, formatted as a range for ranges of weights. Fallbacks without a weight apply to all weights.
+	Weight *string `json:"weight,omitempty"`
+}
+
+// FontMetrics The metrics of a font in font units, as read from its head, hhea, OS/2, cmap and hmtx tables. The vertical metrics are those browsers lay out lines with. Left out if the font file could not be read.
+type FontMetrics struct {
+	// Ascender The ascender of the font
+	Ascender int `json:"ascender"`
+
+	// AvgCharWidth The average advance width of the characters of English text, i.e. of the lowercase letters and the space weighted by their frequency, or the xAvgCharWidth of the OS/2 table for fonts without any of these characters
+	AvgCharWidth int `json:"avg_char_width"`
+
+	// CapHeight The height of uppercase letters, or 0 if the font does not specify it
+	CapHeight int `json:"cap_height"`
+
+	// Descender The descender of the font, negative for descents below the baseline
+	Descender int `json:"descender"`
+
+	// LineGap The line gap of the font
+	LineGap int `json:"line_gap"`
+
+	// UnitsPerEm The number of font units per em
+	UnitsPerEm int `json:"units_per_em"`
+
+	// XHeight The height of lowercase letters, or 0 if the font does not specify it
+	XHeight int `json:"x_height"`
+}
+
+// FormatFiles defines model for FormatFiles.
+type FormatFiles struct {
+	// Files Download URLs of the files in the format, keyed by subset, or by shard for sharded subsets
+	Files map[string]string `json:"files"`
+
+	// HashedFiles Content-addressed copies of the files in the format, keyed by subset, or by shard for sharded subsets. Only listed if the catalog is built with hashed file names.
+	HashedFiles *map[string]HashedFile `json:"hashed_files,omitempty"`
+}
+
+// HashedFile defines model for HashedFile.
+type HashedFile struct {
+	// Hash The SHA-256 hash of the file, of which the first 16 hex digits are part of its name
+	Hash string `json:"hash"`
+
+	// Size The size of the file in bytes
+	Size int `json:"size"`
+
+	// Url The download URL of the content-addressed file
+	Url string `json:"url"`
+}
+
+// GetFontFamilySubsetCSSParamsSubset defines parameters for GetFontFamilySubsetCSS.
+type GetFontFamilySubsetCSSParamsSubset string
+
+// GetCSS2Params defines parameters for GetCSS2.
+type GetCSS2Params struct {
+	// Family A font family by name, optionally followed by a colon, a comma-separated list of axis tags, an @ and a semicolon-separated list of tuples of axis values, e.g. Roboto:ital,wght@0,400;1,100..900. The ital axis is 0 for normal and 1 for italic, and other axes take a value or a range of values. A family without axes is requested in normal 400. The parameter is repeated for each family.
+	Family []string `form:"family" json:"family"`
+
+	// Display The font-display of the rules
+	Display *GetCSS2ParamsDisplay `form:"display,omitempty" json:"display,omitempty"`
+
+	// Text Text that the fonts are subsetted to on demand. The stylesheet then has one rule per style without unicode-range.
+	Text *string `form:"text,omitempty" json:"text,omitempty"`
+}
+
+// GetCSS2ParamsDisplay defines parameters for GetCSS2.
+type GetCSS2ParamsDisplay string
+
+// DownloadFontParams defines parameters for DownloadFont.
+type DownloadFontParams struct {
+	// Text Text that the font is subsetted to on demand, e.g. the name of a logo. The subset in the path is not applied to fonts that are subsetted on demand. Only supported by the font server.
+	Text *string `form:"text,omitempty" json:"text,omitempty"`
+
+	// Unicodes Code points and ranges of code points that the font is subsetted to on demand, separated by commas, in addition to the code points of text. Only supported by the font server.
+	Unicodes *string `form:"unicodes,omitempty" json:"unicodes,omitempty"`
+}
 
 // DownloadFontParamsStyle defines parameters for DownloadFont.
 type DownloadFontParamsStyle string
 
+// DownloadFontParamsFormat defines parameters for DownloadFont.
+type DownloadFontParamsFormat string
+
+// DownloadVariableFontParams defines parameters for DownloadVariableFont.
+type DownloadVariableFontParams struct {
+	// Text Text that the font is subsetted to on demand, e.g. the name of a logo. The subset in the path is not applied to fonts that are subsetted on demand. Only supported by the font server.
+	Text *string `form:"text,omitempty" json:"text,omitempty"`
+
+	// Unicodes Code points and ranges of code points that the font is subsetted to on demand, separated by commas, in addition to the code points of text. Only supported by the font server.
+	Unicodes *string `form:"unicodes,omitempty" json:"unicodes,omitempty"`
+}
+
+// DownloadVariableFontParamsStyle defines parameters for DownloadVariableFont.
+type DownloadVariableFontParamsStyle string
+
+// DownloadVariableFontParamsFormat defines parameters for DownloadVariableFont.
+type DownloadVariableFontParamsFormat string
+
 // RequestEditorFn  is the function signature for the RequestEditor callback function
 type RequestEditorFn func(ctx context.Context, req *http.Request) error
 
@@ -112,17 +280,74 @@ func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
 
 // The interface specification for the client above.
 type ClientInterface interface {
+	// GetFontFamilyCSS request
+	GetFontFamilyCSS(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetFontFamilySubsetCSS request
+	GetFontFamilySubsetCSS(ctx context.Context, id string, subset GetFontFamilySubsetCSSParamsSubset, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetCSS2 request
+	GetCSS2(ctx context.Context, params *GetCSS2Params, reqEditors ...RequestEditorFn) (*http.Response, error)
+
 	// GetFonts request
 	GetFonts(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
 
+	// GetFontFamily request
+	GetFontFamily(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error)
+
 	// DownloadFont request
-	DownloadFont(ctx context.Context, id string, subset DownloadFontParamsSubset, weight string, style DownloadFontParamsStyle, reqEditors ...RequestEditorFn) (*http.Response, error)
+	DownloadFont(ctx context.Context, id string, subset string, weight string, style DownloadFontParamsStyle, format DownloadFontParamsFormat, params *DownloadFontParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// DownloadVariableFont request
+	DownloadVariableFont(ctx context.Context, id string, subset string, weight string, style DownloadVariableFontParamsStyle, axes string, format DownloadVariableFontParamsFormat, params *DownloadVariableFontParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetLanguages request
+	GetLanguages(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
 
 	// DownloadLicense request
 	DownloadLicense(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error)
 
 	// GetSubsets request
 	GetSubsets(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// DownloadFamilyZip request
+	DownloadFamilyZip(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error)
+}
+
+func (c *Client) GetFontFamilyCSS(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetFontFamilyCSSRequest(c.Server, id)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetFontFamilySubsetCSS(ctx context.Context, id string, subset GetFontFamilySubsetCSSParamsSubset, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetFontFamilySubsetCSSRequest(c.Server, id, subset)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetCSS2(ctx context.Context, params *GetCSS2Params, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetCSS2Request(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
 func (c *Client) GetFonts(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error) {
@@ -137,8 +362,44 @@ func (c *Client) GetFonts(ctx context.Context, reqEditors ...RequestEditorFn) (*
 	return c.Client.Do(req)
 }
 
-func (c *Client) DownloadFont(ctx context.Context, id string, subset DownloadFontParamsSubset, weight string, style DownloadFontParamsStyle, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewDownloadFontRequest(c.Server, id, subset, weight, style)
+func (c *Client) GetFontFamily(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetFontFamilyRequest(c.Server, id)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) DownloadFont(ctx context.Context, id string, subset string, weight string, style DownloadFontParamsStyle, format DownloadFontParamsFormat, params *DownloadFontParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewDownloadFontRequest(c.Server, id, subset, weight, style, format, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) DownloadVariableFont(ctx context.Context, id string, subset string, weight string, style DownloadVariableFontParamsStyle, axes string, format DownloadVariableFontParamsFormat, params *DownloadVariableFontParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewDownloadVariableFontRequest(c.Server, id, subset, weight, style, axes, format, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetLanguages(ctx context.Context, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetLanguagesRequest(c.Server)
 	if err != nil {
 		return nil, err
 	}
@@ -173,16 +434,35 @@ func (c *Client) GetSubsets(ctx context.Context, reqEditors ...RequestEditorFn)
 	return c.Client.Do(req)
 }
 
-// NewGetFontsRequest generates requests for GetFonts
-func NewGetFontsRequest(server string) (*http.Request, error) {
+func (c *Client) DownloadFamilyZip(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewDownloadFamilyZipRequest(c.Server, id)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+// NewGetFontFamilyCSSRequest generates requests for GetFontFamilyCSS
+func NewGetFontFamilyCSSRequest(server string, id string) (*http.Request, error) {
 	var err error
 
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, err
 	}
 
-	operationPath := fmt.Sprintf("/fonts.json")
+	operationPath := fmt.Sprintf("/css/%s.css", pathParam0)
 	if operationPath[0] == '/' {
 		operationPath = "." + operationPath
 	}
@@ -200,8 +480,8 @@ func NewGetFontsRequest(server string) (*http.Request, error) {
 	return req, nil
 }
 
-// NewDownloadFontRequest generates requests for DownloadFont
-func NewDownloadFontRequest(server string, id string, subset DownloadFontParamsSubset, weight string, style DownloadFontParamsStyle) (*http.Request, error) {
+// NewGetFontFamilySubsetCSSRequest generates requests for GetFontFamilySubsetCSS
+func NewGetFontFamilySubsetCSSRequest(server string, id string, subset GetFontFamilySubsetCSSParamsSubset) (*http.Request, error) {
 	var err error
 
 	var pathParam0 string
@@ -218,26 +498,39 @@ func NewDownloadFontRequest(server string, id string, subset DownloadFontParamsS
 		return nil, err
 	}
 
-	var pathParam2 string
-
-	pathParam2, err = runtime.StyleParamWithLocation("simple", false, "weight", runtime.ParamLocationPath, weight)
+	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, err
 	}
 
-	var pathParam3 string
+	operationPath := fmt.Sprintf("/css/%s.%s.css", pathParam0, pathParam1)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
 
-	pathParam3, err = runtime.StyleParamWithLocation("simple", false, "style", runtime.ParamLocationPath, style)
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
+	return req, nil
+}
+
+// NewGetCSS2Request generates requests for GetCSS2
+func NewGetCSS2Request(server string, params *GetCSS2Params) (*http.Request, error) {
+	var err error
+
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, err
 	}
 
-	operationPath := fmt.Sprintf("/fonts/%s_%s_%s_%s.woff2", pathParam0, pathParam1, pathParam2, pathParam3)
+	operationPath := fmt.Sprintf("/css2")
 	if operationPath[0] == '/' {
 		operationPath = "." + operationPath
 	}
@@ -247,6 +540,56 @@ func NewDownloadFontRequest(server string, id string, subset DownloadFontParamsS
 		return nil, err
 	}
 
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "family", runtime.ParamLocationQuery, params.Family); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		if params.Display != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "display", runtime.ParamLocationQuery, *params.Display); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Text != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "text", runtime.ParamLocationQuery, *params.Text); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
 	req, err := http.NewRequest("GET", queryURL.String(), nil)
 	if err != nil {
 		return nil, err
@@ -255,23 +598,16 @@ func NewDownloadFontRequest(server string, id string, subset DownloadFontParamsS
 	return req, nil
 }
 
-// NewDownloadLicenseRequest generates requests for DownloadLicense
-func NewDownloadLicenseRequest(server string, id string) (*http.Request, error) {
+// NewGetFontsRequest generates requests for GetFonts
+func NewGetFontsRequest(server string) (*http.Request, error) {
 	var err error
 
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
-	if err != nil {
-		return nil, err
-	}
-
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, err
 	}
 
-	operationPath := fmt.Sprintf("/licenses/%s-LICENSE.txt", pathParam0)
+	operationPath := fmt.Sprintf("/fonts.json")
 	if operationPath[0] == '/' {
 		operationPath = "." + operationPath
 	}
@@ -289,16 +625,23 @@ func NewDownloadLicenseRequest(server string, id string) (*http.Request, error)
 	return req, nil
 }
 
-// NewGetSubsetsRequest generates requests for GetSubsets
-func NewGetSubsetsRequest(server string) (*http.Request, error) {
+// NewGetFontFamilyRequest generates requests for GetFontFamily
+func NewGetFontFamilyRequest(server string, id string) (*http.Request, error) {
 	var err error
 
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, err
 	}
 
-	operationPath := fmt.Sprintf("/subsets.json")
+	operationPath := fmt.Sprintf("/fonts/%s.json", pathParam0)
 	if operationPath[0] == '/' {
 		operationPath = "." + operationPath
 	}
@@ -316,93 +659,699 @@ func NewGetSubsetsRequest(server string) (*http.Request, error) {
 	return req, nil
 }
 
-func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
-	for _, r := range c.RequestEditors {
-		if err := r(ctx, req); err != nil {
-			return err
-		}
-	}
-	for _, r := range additionalEditors {
-		if err := r(ctx, req); err != nil {
-			return err
-		}
-	}
-	return nil
-}
+// NewDownloadFontRequest generates requests for DownloadFont
+func NewDownloadFontRequest(server string, id string, subset string, weight string, style DownloadFontParamsStyle, format DownloadFontParamsFormat, params *DownloadFontParams) (*http.Request, error) {
+	var err error
 
-// ClientWithResponses builds on ClientInterface to offer response payloads
-type ClientWithResponses struct {
-	ClientInterface
-}
+	var pathParam0 string
 
-// NewClientWithResponses creates a new ClientWithResponses, which wraps
-// Client with return type handling
-func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
-	client, err := NewClient(server, opts...)
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
 	if err != nil {
 		return nil, err
 	}
-	return &ClientWithResponses{client}, nil
-}
 
-// WithBaseURL overrides the baseURL.
-func WithBaseURL(baseURL string) ClientOption {
-	return func(c *Client) error {
-		newBaseURL, err := url.Parse(baseURL)
-		if err != nil {
-			return err
-		}
-		c.Server = newBaseURL.String()
-		return nil
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "subset", runtime.ParamLocationPath, subset)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// ClientWithResponsesInterface is the interface specification for the client with responses above.
-type ClientWithResponsesInterface interface {
-	// GetFontsWithResponse request
-	GetFontsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetFontsResponse, error)
+	var pathParam2 string
 
-	// DownloadFontWithResponse request
-	DownloadFontWithResponse(ctx context.Context, id string, subset DownloadFontParamsSubset, weight string, style DownloadFontParamsStyle, reqEditors ...RequestEditorFn) (*DownloadFontResponse, error)
+	pathParam2, err = runtime.StyleParamWithLocation("simple", false, "weight", runtime.ParamLocationPath, weight)
+	if err != nil {
+		return nil, err
+	}
 
-	// DownloadLicenseWithResponse request
-	DownloadLicenseWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*DownloadLicenseResponse, error)
+	var pathParam3 string
 
-	// GetSubsetsWithResponse request
-	GetSubsetsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetSubsetsResponse, error)
-}
+	pathParam3, err = runtime.StyleParamWithLocation("simple", false, "style", runtime.ParamLocationPath, style)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam4 string
+
+	pathParam4, err = runtime.StyleParamWithLocation("simple", false, "format", runtime.ParamLocationPath, format)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/fonts/%s_%s_%s_%s.%s", pathParam0, pathParam1, pathParam2, pathParam3, pathParam4)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.Text != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "text", runtime.ParamLocationQuery, *params.Text); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Unicodes != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "unicodes", runtime.ParamLocationQuery, *params.Unicodes); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewDownloadVariableFontRequest generates requests for DownloadVariableFont
+func NewDownloadVariableFontRequest(server string, id string, subset string, weight string, style DownloadVariableFontParamsStyle, axes string, format DownloadVariableFontParamsFormat, params *DownloadVariableFontParams) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "subset", runtime.ParamLocationPath, subset)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam2 string
+
+	pathParam2, err = runtime.StyleParamWithLocation("simple", false, "weight", runtime.ParamLocationPath, weight)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam3 string
+
+	pathParam3, err = runtime.StyleParamWithLocation("simple", false, "style", runtime.ParamLocationPath, style)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam4 string
+
+	pathParam4, err = runtime.StyleParamWithLocation("simple", false, "axes", runtime.ParamLocationPath, axes)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam5 string
+
+	pathParam5, err = runtime.StyleParamWithLocation("simple", false, "format", runtime.ParamLocationPath, format)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/fonts/%s_%s_%s_%s_%s.%s", pathParam0, pathParam1, pathParam2, pathParam3, pathParam4, pathParam5)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.Text != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "text", runtime.ParamLocationQuery, *params.Text); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Unicodes != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "unicodes", runtime.ParamLocationQuery, *params.Unicodes); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetLanguagesRequest generates requests for GetLanguages
+func NewGetLanguagesRequest(server string) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/languages.json")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewDownloadLicenseRequest generates requests for DownloadLicense
+func NewDownloadLicenseRequest(server string, id string) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/licenses/%s-LICENSE.txt", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetSubsetsRequest generates requests for GetSubsets
+func NewGetSubsetsRequest(server string) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/subsets.json")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewDownloadFamilyZipRequest generates requests for DownloadFamilyZip
+func NewDownloadFamilyZipRequest(server string, id string) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "id", runtime.ParamLocationPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/zips/%s.zip", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
+	for _, r := range c.RequestEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	for _, r := range additionalEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientWithResponses builds on ClientInterface to offer response payloads
+type ClientWithResponses struct {
+	ClientInterface
+}
+
+// NewClientWithResponses creates a new ClientWithResponses, which wraps
+// Client with return type handling
+func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
+	client, err := NewClient(server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientWithResponses{client}, nil
+}
+
+// WithBaseURL overrides the baseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		newBaseURL, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		c.Server = newBaseURL.String()
+		return nil
+	}
+}
+
+// ClientWithResponsesInterface is the interface specification for the client with responses above.
+type ClientWithResponsesInterface interface {
+	// GetFontFamilyCSSWithResponse request
+	GetFontFamilyCSSWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*GetFontFamilyCSSResponse, error)
+
+	// GetFontFamilySubsetCSSWithResponse request
+	GetFontFamilySubsetCSSWithResponse(ctx context.Context, id string, subset GetFontFamilySubsetCSSParamsSubset, reqEditors ...RequestEditorFn) (*GetFontFamilySubsetCSSResponse, error)
+
+	// GetCSS2WithResponse request
+	GetCSS2WithResponse(ctx context.Context, params *GetCSS2Params, reqEditors ...RequestEditorFn) (*GetCSS2Response, error)
+
+	// GetFontsWithResponse request
+	GetFontsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetFontsResponse, error)
+
+	// GetFontFamilyWithResponse request
+	GetFontFamilyWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*GetFontFamilyResponse, error)
+
+	// DownloadFontWithResponse request
+	DownloadFontWithResponse(ctx context.Context, id string, subset string, weight string, style DownloadFontParamsStyle, format DownloadFontParamsFormat, params *DownloadFontParams, reqEditors ...RequestEditorFn) (*DownloadFontResponse, error)
+
+	// DownloadVariableFontWithResponse request
+	DownloadVariableFontWithResponse(ctx context.Context, id string, subset string, weight string, style DownloadVariableFontParamsStyle, axes string, format DownloadVariableFontParamsFormat, params *DownloadVariableFontParams, reqEditors ...RequestEditorFn) (*DownloadVariableFontResponse, error)
+
+	// GetLanguagesWithResponse request
+	GetLanguagesWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetLanguagesResponse, error)
+
+	// DownloadLicenseWithResponse request
+	DownloadLicenseWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*DownloadLicenseResponse, error)
+
+	// GetSubsetsWithResponse request
+	GetSubsetsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetSubsetsResponse, error)
+
+	// DownloadFamilyZipWithResponse request
+	DownloadFamilyZipWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*DownloadFamilyZipResponse, error)
+}
+
+type GetFontFamilyCSSResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r GetFontFamilyCSSResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetFontFamilyCSSResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetFontFamilySubsetCSSResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r GetFontFamilySubsetCSSResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetFontFamilySubsetCSSResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetCSS2Response struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r GetCSS2Response) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetCSS2Response) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
 
 type GetFontsResponse struct {
 	Body         []byte
 	HTTPResponse *http.Response
 	JSON200      *[]struct {
+		// Axes The variation axes of the font family
+		Axes []FontFamilyAxis `json:"axes"`
+
+		// Designer Name(s) of the designer(s)
+		Designer string `json:"designer"`
+
+		// EmptyShards The shards of the sharded subsets of the font family that none of its fonts have a character of. Font files are not published for these shards, and they are left out of the family JSON and the stylesheets.
+		EmptyShards []string `json:"empty_shards"`
+
+		// Formats The formats the fonts of the font family can be downloaded in
+		Formats []GetFonts200Formats `json:"formats"`
+
+		// Id Unique identifier for the font family
+		Id string `json:"id"`
+
+		// Languages The languages supported by the font family, as language and script codes. Empty if the metadata of the font family lists no languages.
+		Languages []string `json:"languages"`
+
+		// License The SPDX license identifier for the font family. The version of the SIL Open Font License is detected from the license bundled with the font family.
+		License GetFonts200License `json:"license"`
+
+		// Name Name of the font family
+		Name string `json:"name"`
+
+		// PrimaryLanguage The language the font family is primarily designed for, or an empty string if there is none
+		PrimaryLanguage string `json:"primary_language"`
+
+		// PrimaryScript The script the font family is primarily designed for, or an empty string if there is none
+		PrimaryScript string `json:"primary_script"`
+
+		// StaticWeights The weights of the static instances generated from the variable fonts of the font family
+		StaticWeights []int `json:"static_weights"`
+
+		// Styles Available styles for the font family
+		Styles []GetFonts200Styles `json:"styles"`
+
+		// Subsets Available subsets for the font family
+		Subsets []GetFonts200Subsets `json:"subsets"`
+
+		// Weights Available font weights for the font family
+		Weights []string `json:"weights"`
+	}
+}
+type GetFonts200Formats string
+type GetFonts200License string
+type GetFonts200Styles string
+type GetFonts200Subsets string
+
+// Status returns HTTPResponse.Status
+func (r GetFontsResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetFontsResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetFontFamilyResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *struct {
+		// Axes The variation axes of the font family
+		Axes []FontFamilyAxis `json:"axes"`
+
+		// Category The categories of the font family
+		Category []string `json:"category"`
+
 		// Designer Name(s) of the designer(s)
 		Designer string `json:"designer"`
 
+		// Fallbacks The local fonts that stand in for the font family while its fonts load, with metric overrides that avoid layout shift when the fonts are swapped. The stylesheets of the font family declare them as the companion family "{name} Fallback". Font families whose metadata lists no fallbacks get one computed from the metrics of their regular font against a common system font of their category.
+		Fallbacks []FontFamilyFallback `json:"fallbacks"`
+
+		// FontFamily The value of the CSS font-family property that uses the font family, followed by the companion family of its fallbacks if it has any and the generic family of its category
+		FontFamily string `json:"font_family"`
+
+		// Fonts The fonts of the font family
+		Fonts []struct {
+			// Copyright Copyright notice of the font
+			Copyright string `json:"copyright"`
+
+			// Filename File name of the original font file
+			Filename string `json:"filename"`
+
+			// Files Download URLs of the font, keyed by subset, or by shard for sharded subsets
+			Files map[string]string `json:"files"`
+
+			// Formats The files of the font in every format the catalog is published in, keyed by format
+			Formats map[string]FormatFiles `json:"formats"`
+
+			// FullName Full name of the font
+			FullName string `json:"full_name"`
+
+			// HashedFiles Content-addressed copies of the files of the font, keyed by subset, or by shard for sharded subsets. Only listed if the catalog is built with hashed file names. Since their content never changes they can be cached indefinitely.
+			HashedFiles *map[string]HashedFile `json:"hashed_files,omitempty"`
+
+			// Instances The static instances generated from the font if it is variable
+			Instances []struct {
+				// Files Download URLs of the static instance, keyed by subset, or by shard for sharded subsets
+				Files map[string]string `json:"files"`
+
+				// Formats The files of the static instance in every format the catalog is published in, keyed by format
+				Formats map[string]FormatFiles `json:"formats"`
+
+				// HashedFiles Content-addressed copies of the files of the static instance, keyed by subset, or by shard for sharded subsets. Only listed if the catalog is built with hashed file names. Since their content never changes they can be cached indefinitely.
+				HashedFiles *map[string]HashedFile `json:"hashed_files,omitempty"`
+
+				// Weight Weight of the static instance
+				Weight int `json:"weight"`
+			} `json:"instances"`
+
+			// Metrics The metrics of a font in font units, as read from its head, hhea, OS/2, cmap and hmtx tables. The vertical metrics are those browsers lay out lines with. Left out if the font file could not be read.
+			Metrics *FontMetrics `json:"metrics,omitempty"`
+
+			// Name Name of the font
+			Name string `json:"name"`
+
+			// PostScriptName PostScript name of the font
+			PostScriptName string `json:"post_script_name"`
+
+			// Style Style of the font
+			Style GetFontFamily200FontsStyle `json:"style"`
+
+			// Weight Weight of the font, formatted as a range for variable fonts
+			Weight string `json:"weight"`
+		} `json:"fonts"`
+
 		// Id Unique identifier for the font family
 		Id string `json:"id"`
 
-		// License The SPDX license identifier for the font family
-		License string `json:"license"`
+		// Languages The languages supported by the font family, as language and script codes. Empty if the metadata of the font family lists no languages.
+		Languages []string `json:"languages"`
+
+		// License The SPDX license identifier for the font family. The version of the SIL Open Font License is detected from the license bundled with the font family.
+		License GetFontFamily200License `json:"license"`
+
+		// LicenseUrl The download URL of the license of the font family
+		LicenseUrl string `json:"license_url"`
+
+		// MinisiteUrl URL of the website of the font family, or an empty string if there is none
+		MinisiteUrl string `json:"minisite_url"`
 
 		// Name Name of the font family
 		Name string `json:"name"`
 
-		// Styles Available styles for the font family
-		Styles []GetFonts200Styles `json:"styles"`
+		// PrimaryLanguage The language the font family is primarily designed for, or an empty string if there is none
+		PrimaryLanguage string `json:"primary_language"`
+
+		// PrimaryScript The script the font family is primarily designed for, or an empty string if there is none
+		PrimaryScript string `json:"primary_script"`
+
+		// StaticWeights The weights of the static instances generated from the variable fonts of the font family
+		StaticWeights []int `json:"static_weights"`
+
+		// Styles Available styles for the font family
+		Styles []GetFontFamily200Styles `json:"styles"`
+
+		// Subsets Available subsets for the font family
+		Subsets []GetFontFamily200Subsets `json:"subsets"`
+
+		// Weights Available font weights for the font family
+		Weights []string `json:"weights"`
+	}
+}
+type GetFontFamily200FontsStyle string
+type GetFontFamily200License string
+type GetFontFamily200Styles string
+type GetFontFamily200Subsets string
+
+// Status returns HTTPResponse.Status
+func (r GetFontFamilyResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetFontFamilyResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type DownloadFontResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
 
-		// Subsets Available subsets for the font family
-		Subsets []GetFonts200Subsets `json:"subsets"`
+// Status returns HTTPResponse.Status
+func (r DownloadFontResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
 
-		// Weights Available font weights for the font family
-		Weights []string `json:"weights"`
+// StatusCode returns HTTPResponse.StatusCode
+func (r DownloadFontResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
 	}
+	return 0
+}
+
+type DownloadVariableFontResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
 }
-type GetFonts200Styles string
-type GetFonts200Subsets string
 
 // Status returns HTTPResponse.Status
-func (r GetFontsResponse) Status() string {
+func (r DownloadVariableFontResponse) Status() string {
 	if r.HTTPResponse != nil {
 		return r.HTTPResponse.Status
 	}
@@ -410,20 +1359,27 @@ func (r GetFontsResponse) Status() string {
 }
 
 // StatusCode returns HTTPResponse.StatusCode
-func (r GetFontsResponse) StatusCode() int {
+func (r DownloadVariableFontResponse) StatusCode() int {
 	if r.HTTPResponse != nil {
 		return r.HTTPResponse.StatusCode
 	}
 	return 0
 }
 
-type DownloadFontResponse struct {
+type GetLanguagesResponse struct {
 	Body         []byte
 	HTTPResponse *http.Response
+	JSON200      *[]struct {
+		// Families The IDs of the font families that support the language
+		Families []string `json:"families"`
+
+		// Language The language and script code of the language
+		Language string `json:"language"`
+	}
 }
 
 // Status returns HTTPResponse.Status
-func (r DownloadFontResponse) Status() string {
+func (r GetLanguagesResponse) Status() string {
 	if r.HTTPResponse != nil {
 		return r.HTTPResponse.Status
 	}
@@ -431,7 +1387,7 @@ func (r DownloadFontResponse) Status() string {
 }
 
 // StatusCode returns HTTPResponse.StatusCode
-func (r DownloadFontResponse) StatusCode() int {
+func (r GetLanguagesResponse) StatusCode() int {
 	if r.HTTPResponse != nil {
 		return r.HTTPResponse.StatusCode
 	}
@@ -466,6 +1422,15 @@ type GetSubsetsResponse struct {
 		// Ranges The Unicode ranges covered by the subset, formatted as a comma-separated list of hexadecimal ranges
 		Ranges string `json:"ranges"`
 
+		// Shards The shards of subsets that are too large to be published as one file, such as Chinese, Japanese and Korean. The fonts of these subsets are split into shards of code points in order of how commonly they are used, each with its own Unicode ranges, so that browsers only download the shards a page uses. Shards that a font family has no characters of are not published for it, see empty_shards in fonts.json.
+		Shards *[]struct {
+			// Ranges The Unicode ranges covered by the shard
+			Ranges string `json:"ranges"`
+
+			// Shard The name of the shard, used in place of the subset in file names
+			Shard string `json:"shard"`
+		} `json:"shards,omitempty"`
+
 		// Subset The name of the subset
 		Subset GetSubsets200Subset `json:"subset"`
 	}
@@ -488,6 +1453,54 @@ func (r GetSubsetsResponse) StatusCode() int {
 	return 0
 }
 
+type DownloadFamilyZipResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r DownloadFamilyZipResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r DownloadFamilyZipResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+// GetFontFamilyCSSWithResponse request returning *GetFontFamilyCSSResponse
+func (c *ClientWithResponses) GetFontFamilyCSSWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*GetFontFamilyCSSResponse, error) {
+	rsp, err := c.GetFontFamilyCSS(ctx, id, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetFontFamilyCSSResponse(rsp)
+}
+
+// GetFontFamilySubsetCSSWithResponse request returning *GetFontFamilySubsetCSSResponse
+func (c *ClientWithResponses) GetFontFamilySubsetCSSWithResponse(ctx context.Context, id string, subset GetFontFamilySubsetCSSParamsSubset, reqEditors ...RequestEditorFn) (*GetFontFamilySubsetCSSResponse, error) {
+	rsp, err := c.GetFontFamilySubsetCSS(ctx, id, subset, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetFontFamilySubsetCSSResponse(rsp)
+}
+
+// GetCSS2WithResponse request returning *GetCSS2Response
+func (c *ClientWithResponses) GetCSS2WithResponse(ctx context.Context, params *GetCSS2Params, reqEditors ...RequestEditorFn) (*GetCSS2Response, error) {
+	rsp, err := c.GetCSS2(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetCSS2Response(rsp)
+}
+
 // GetFontsWithResponse request returning *GetFontsResponse
 func (c *ClientWithResponses) GetFontsWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetFontsResponse, error) {
 	rsp, err := c.GetFonts(ctx, reqEditors...)
@@ -497,15 +1510,42 @@ func (c *ClientWithResponses) GetFontsWithResponse(ctx context.Context, reqEdito
 	return ParseGetFontsResponse(rsp)
 }
 
+// GetFontFamilyWithResponse request returning *GetFontFamilyResponse
+func (c *ClientWithResponses) GetFontFamilyWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*GetFontFamilyResponse, error) {
+	rsp, err := c.GetFontFamily(ctx, id, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetFontFamilyResponse(rsp)
+}
+
 // DownloadFontWithResponse request returning *DownloadFontResponse
-func (c *ClientWithResponses) DownloadFontWithResponse(ctx context.Context, id string, subset DownloadFontParamsSubset, weight string, style DownloadFontParamsStyle, reqEditors ...RequestEditorFn) (*DownloadFontResponse, error) {
-	rsp, err := c.DownloadFont(ctx, id, subset, weight, style, reqEditors...)
+func (c *ClientWithResponses) DownloadFontWithResponse(ctx context.Context, id string, subset string, weight string, style DownloadFontParamsStyle, format DownloadFontParamsFormat, params *DownloadFontParams, reqEditors ...RequestEditorFn) (*DownloadFontResponse, error) {
+	rsp, err := c.DownloadFont(ctx, id, subset, weight, style, format, params, reqEditors...)
 	if err != nil {
 		return nil, err
 	}
 	return ParseDownloadFontResponse(rsp)
 }
 
+// DownloadVariableFontWithResponse request returning *DownloadVariableFontResponse
+func (c *ClientWithResponses) DownloadVariableFontWithResponse(ctx context.Context, id string, subset string, weight string, style DownloadVariableFontParamsStyle, axes string, format DownloadVariableFontParamsFormat, params *DownloadVariableFontParams, reqEditors ...RequestEditorFn) (*DownloadVariableFontResponse, error) {
+	rsp, err := c.DownloadVariableFont(ctx, id, subset, weight, style, axes, format, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDownloadVariableFontResponse(rsp)
+}
+
+// GetLanguagesWithResponse request returning *GetLanguagesResponse
+func (c *ClientWithResponses) GetLanguagesWithResponse(ctx context.Context, reqEditors ...RequestEditorFn) (*GetLanguagesResponse, error) {
+	rsp, err := c.GetLanguages(ctx, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetLanguagesResponse(rsp)
+}
+
 // DownloadLicenseWithResponse request returning *DownloadLicenseResponse
 func (c *ClientWithResponses) DownloadLicenseWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*DownloadLicenseResponse, error) {
 	rsp, err := c.DownloadLicense(ctx, id, reqEditors...)
@@ -524,6 +1564,63 @@ func (c *ClientWithResponses) GetSubsetsWithResponse(ctx context.Context, reqEdi
 	return ParseGetSubsetsResponse(rsp)
 }
 
+// DownloadFamilyZipWithResponse request returning *DownloadFamilyZipResponse
+func (c *ClientWithResponses) DownloadFamilyZipWithResponse(ctx context.Context, id string, reqEditors ...RequestEditorFn) (*DownloadFamilyZipResponse, error) {
+	rsp, err := c.DownloadFamilyZip(ctx, id, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDownloadFamilyZipResponse(rsp)
+}
+
+// ParseGetFontFamilyCSSResponse parses an HTTP response from a GetFontFamilyCSSWithResponse call
+func ParseGetFontFamilyCSSResponse(rsp *http.Response) (*GetFontFamilyCSSResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetFontFamilyCSSResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
+// ParseGetFontFamilySubsetCSSResponse parses an HTTP response from a GetFontFamilySubsetCSSWithResponse call
+func ParseGetFontFamilySubsetCSSResponse(rsp *http.Response) (*GetFontFamilySubsetCSSResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetFontFamilySubsetCSSResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
+// ParseGetCSS2Response parses an HTTP response from a GetCSS2WithResponse call
+func ParseGetCSS2Response(rsp *http.Response) (*GetCSS2Response, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetCSS2Response{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
 // ParseGetFontsResponse parses an HTTP response from a GetFontsWithResponse call
 func ParseGetFontsResponse(rsp *http.Response) (*GetFontsResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
@@ -540,18 +1637,39 @@ func ParseGetFontsResponse(rsp *http.Response) (*GetFontsResponse, error) {
 	switch {
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
 		var dest []struct {
+			// Axes The variation axes of the font family
+			Axes []FontFamilyAxis `json:"axes"`
+
 			// Designer Name(s) of the designer(s)
 			Designer string `json:"designer"`
 
+			// EmptyShards The shards of the sharded subsets of the font family that none of its fonts have a character of. Font files are not published for these shards, and they are left out of the family JSON and the stylesheets.
+			EmptyShards []string `json:"empty_shards"`
+
+			// Formats The formats the fonts of the font family can be downloaded in
+			Formats []GetFonts200Formats `json:"formats"`
+
 			// Id Unique identifier for the font family
 			Id string `json:"id"`
 
-			// License The SPDX license identifier for the font family
-			License string `json:"license"`
+			// Languages The languages supported by the font family, as language and script codes. Empty if the metadata of the font family lists no languages.
+			Languages []string `json:"languages"`
+
+			// License The SPDX license identifier for the font family. The version of the SIL Open Font License is detected from the license bundled with the font family.
+			License GetFonts200License `json:"license"`
 
 			// Name Name of the font family
 			Name string `json:"name"`
 
+			// PrimaryLanguage The language the font family is primarily designed for, or an empty string if there is none
+			PrimaryLanguage string `json:"primary_language"`
+
+			// PrimaryScript The script the font family is primarily designed for, or an empty string if there is none
+			PrimaryScript string `json:"primary_script"`
+
+			// StaticWeights The weights of the static instances generated from the variable fonts of the font family
+			StaticWeights []int `json:"static_weights"`
+
 			// Styles Available styles for the font family
 			Styles []GetFonts200Styles `json:"styles"`
 
@@ -571,6 +1689,134 @@ func ParseGetFontsResponse(rsp *http.Response) (*GetFontsResponse, error) {
 	return response, nil
 }
 
+// ParseGetFontFamilyResponse parses an HTTP response from a GetFontFamilyWithResponse call
+func ParseGetFontFamilyResponse(rsp *http.Response) (*GetFontFamilyResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetFontFamilyResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest struct {
+			// Axes The variation axes of the font family
+			Axes []FontFamilyAxis `json:"axes"`
+
+			// Category The categories of the font family
+			Category []string `json:"category"`
+
+			// Designer Name(s) of the designer(s)
+			Designer string `json:"designer"`
+
+			// Fallbacks The local fonts that stand in for the font family while its fonts load, with metric overrides that avoid layout shift when the fonts are swapped. The stylesheets of the font family declare them as the companion family "{name} Fallback". Font families whose metadata lists no fallbacks get one computed from the metrics of their regular font against a common system font of their category.
+			Fallbacks []FontFamilyFallback `json:"fallbacks"`
+
+			// FontFamily The value of the CSS font-family property that uses the font family, followed by the companion family of its fallbacks if it has any and the generic family of its category
+			FontFamily string `json:"font_family"`
+
+			// Fonts The fonts of the font family
+			Fonts []struct {
+				// Copyright Copyright notice of the font
+				Copyright string `json:"copyright"`
+
+				// Filename File name of the original font file
+				Filename string `json:"filename"`
+
+				// Files Download URLs of the font, keyed by subset, or by shard for sharded subsets
+				Files map[string]string `json:"files"`
+
+				// Formats The files of the font in every format the catalog is published in, keyed by format
+				Formats map[string]FormatFiles `json:"formats"`
+
+				// FullName Full name of the font
+				FullName string `json:"full_name"`
+
+				// HashedFiles Content-addressed copies of the files of the font, keyed by subset, or by shard for sharded subsets. Only listed if the catalog is built with hashed file names. Since their content never changes they can be cached indefinitely.
+				HashedFiles *map[string]HashedFile `json:"hashed_files,omitempty"`
+
+				// Instances The static instances generated from the font if it is variable
+				Instances []struct {
+					// Files Download URLs of the static instance, keyed by subset, or by shard for sharded subsets
+					Files map[string]string `json:"files"`
+
+					// Formats The files of the static instance in every format the catalog is published in, keyed by format
+					Formats map[string]FormatFiles `json:"formats"`
+
+					// HashedFiles Content-addressed copies of the files of the static instance, keyed by subset, or by shard for sharded subsets. Only listed if the catalog is built with hashed file names. Since their content never changes they can be cached indefinitely.
+					HashedFiles *map[string]HashedFile `json:"hashed_files,omitempty"`
+
+					// Weight Weight of the static instance
+					Weight int `json:"weight"`
+				} `json:"instances"`
+
+				// Metrics The metrics of a font in font units, as read from its head, hhea, OS/2, cmap and hmtx tables. The vertical metrics are those browsers lay out lines with. Left out if the font file could not be read.
+				Metrics *FontMetrics `json:"metrics,omitempty"`
+
+				// Name Name of the font
+				Name string `json:"name"`
+
+				// PostScriptName PostScript name of the font
+				PostScriptName string `json:"post_script_name"`
+
+				// Style Style of the font
+				Style GetFontFamily200FontsStyle `json:"style"`
+
+				// Weight Weight of the font, formatted as a range for variable fonts
+				Weight string `json:"weight"`
+			} `json:"fonts"`
+
+			// Id Unique identifier for the font family
+			Id string `json:"id"`
+
+			// Languages The languages supported by the font family, as language and script codes. Empty if the metadata of the font family lists no languages.
+			Languages []string `json:"languages"`
+
+			// License The SPDX license identifier for the font family. The version of the SIL Open Font License is detected from the license bundled with the font family.
+			License GetFontFamily200License `json:"license"`
+
+			// LicenseUrl The download URL of the license of the font family
+			LicenseUrl string `json:"license_url"`
+
+			// MinisiteUrl URL of the website of the font family, or an empty string if there is none
+			MinisiteUrl string `json:"minisite_url"`
+
+			// Name Name of the font family
+			Name string `json:"name"`
+
+			// PrimaryLanguage The language the font family is primarily designed for, or an empty string if there is none
+			PrimaryLanguage string `json:"primary_language"`
+
+			// PrimaryScript The script the font family is primarily designed for, or an empty string if there is none
+			PrimaryScript string `json:"primary_script"`
+
+			// StaticWeights The weights of the static instances generated from the variable fonts of the font family
+			StaticWeights []int `json:"static_weights"`
+
+			// Styles Available styles for the font family
+			Styles []GetFontFamily200Styles `json:"styles"`
+
+			// Subsets Available subsets for the font family
+			Subsets []GetFontFamily200Subsets `json:"subsets"`
+
+			// Weights Available font weights for the font family
+			Weights []string `json:"weights"`
+		}
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
 // ParseDownloadFontResponse parses an HTTP response from a DownloadFontWithResponse call
 func ParseDownloadFontResponse(rsp *http.Response) (*DownloadFontResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
@@ -587,6 +1833,54 @@ func ParseDownloadFontResponse(rsp *http.Response) (*DownloadFontResponse, error
 	return response, nil
 }
 
+// ParseDownloadVariableFontResponse parses an HTTP response from a DownloadVariableFontWithResponse call
+func ParseDownloadVariableFontResponse(rsp *http.Response) (*DownloadVariableFontResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &DownloadVariableFontResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
+
+// ParseGetLanguagesResponse parses an HTTP response from a GetLanguagesWithResponse call
+func ParseGetLanguagesResponse(rsp *http.Response) (*GetLanguagesResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetLanguagesResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []struct {
+			// Families The IDs of the font families that support the language
+			Families []string `json:"families"`
+
+			// Language The language and script code of the language
+			Language string `json:"language"`
+		}
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	}
+
+	return response, nil
+}
+
 // ParseDownloadLicenseResponse parses an HTTP response from a DownloadLicenseWithResponse call
 func ParseDownloadLicenseResponse(rsp *http.Response) (*DownloadLicenseResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
@@ -622,6 +1916,15 @@ func ParseGetSubsetsResponse(rsp *http.Response) (*GetSubsetsResponse, error) {
 			// Ranges The Unicode ranges covered by the subset, formatted as a comma-separated list of hexadecimal ranges
 			Ranges string `json:"ranges"`
 
+			// Shards The shards of subsets that are too large to be published as one file, such as Chinese, Japanese and Korean. The fonts of these subsets are split into shards of code points in order of how commonly they are used, each with its own Unicode ranges, so that browsers only download the shards a page uses. Shards that a font family has no characters of are not published for it, see empty_shards in fonts.json.
+			Shards *[]struct {
+				// Ranges The Unicode ranges covered by the shard
+				Ranges string `json:"ranges"`
+
+				// Shard The name of the shard, used in place of the subset in file names
+				Shard string `json:"shard"`
+			} `json:"shards,omitempty"`
+
 			// Subset The name of the subset
 			Subset GetSubsets200Subset `json:"subset"`
 		}
@@ -634,3 +1937,19 @@ func ParseGetSubsetsResponse(rsp *http.Response) (*GetSubsetsResponse, error) {
 
 	return response, nil
 }
+
+// ParseDownloadFamilyZipResponse parses an HTTP response from a DownloadFamilyZipWithResponse call
+func ParseDownloadFamilyZipResponse(rsp *http.Response) (*DownloadFamilyZipResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &DownloadFamilyZipResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	return response, nil
+}
@@ -21,11 +21,69 @@ interface Font {
 	subsets: string[];
 	weights: string[];
 	styles: string[];
+	axes: FontFamilyAxis[];
+	empty_shards: string[];
+}
+
+interface FontFamilyAxis {
+	tag: string;
+	min_value: number;
+	max_value: number;
 }
 
 interface Subset {
 	subset: string;
 	ranges: string;
+	shards?: Shard[];
+}
+
+interface Shard {
+	shard: string;
+	ranges: string;
+}
+
+// The shards that the fonts of a subset are published under. Sharded subsets
+// such as japanese are split into shards such as japanese-17, each with its
+// own unicode ranges, leaving out the shards the font has no characters of,
+// while other subsets are published as they are.
+function subsetShards(subsets: Subset[], font: Font, name: string): Shard[] {
+	const subset = subsets.find((s) => s.subset == name);
+	if (!subset) {
+		throw new Error(`Subset ${name} not found`);
+	}
+	if (!subset.shards) {
+		return [{ shard: subset.subset, ranges: subset.ranges }];
+	}
+	return subset.shards.filter((s) => !font.empty_shards.includes(s.shard));
+}
+
+// An axis value as written in file names, with "p" for the decimal point and
+// "m" for the minus sign, e.g. "62p5" and "m10"
+function fileNameAxisValue(value: number): string {
+	return String(value).replace(".", "p").replace("-", "m");
+}
+
+// The file name of a font as served by the API. The variation axes other than
+// wght are appended for families that have them, e.g.
+// "roboto-flex_latin_100-1000_normal_opsz8-144.woff2"
+function fontFileName(
+	font: Font,
+	subset: string,
+	weight: string,
+	style: string,
+): string {
+	const axes = font.axes
+		.filter((axis) => axis.tag != "wght")
+		.map(
+			(axis) =>
+				`${axis.tag}${fileNameAxisValue(axis.min_value)}-${fileNameAxisValue(axis.max_value)}`,
+		)
+		.sort()
+		.join(".");
+	if (axes) {
+		return `${font.id}_${subset}_${weight}_${style}_${axes}.woff2`;
+	}
+	return `${font.id}_${subset}_${weight}_${style}.woff2`;
 }
 
 function generateFontFaceCSS(
@@ -36,7 +94,7 @@ function generateFontFaceCSS(
 	unicodeRange: string,
 	urlPrefix: string,
 ): string {
-	const url = `${urlPrefix}${font.id}_${subset}_${weight}_${style}.woff2`;
+	const url = `${urlPrefix}${fontFileName(font, subset, weight, style)}`;
 
 	return `
 @font-face {
@@ -85,20 +143,18 @@ function FontCSSInjector() {
 
 		for (const font of fonts) {
 			for (const subset of font.subsets) {
-				const unicodeRange = subsets.find((s) => s.subset == subset)?.ranges;
-				if (!unicodeRange) {
-					throw new Error(`Subset ${subset} not found`);
-				}
-				for (const weight of font.weights) {
-					for (const style of font.styles) {
-						cssContent += generateFontFaceCSS(
-							font,
-							subset,
-							weight,
-							style,
-							unicodeRange,
-							`${API_BASE}/fonts/`,
-						);
+				for (const { shard, ranges } of subsetShards(subsets, font, subset)) {
+					for (const weight of font.weights) {
+						for (const style of font.styles) {
+							cssContent += generateFontFaceCSS(
+								font,
+								shard,
+								weight,
+								style,
+								ranges,
+								`${API_BASE}/fonts/`,
+							);
+						}
 					}
 				}
 			}
@@ -231,28 +287,20 @@ function DownloadForm({ fontId }: { fontId: string }) {
 		let cssOutput = "";
 
 		for (const subset of subsets) {
-			const unicodeRange = apiSubsets!.find((s) => s.subset == subset)?.ranges;
-			if (!unicodeRange) {
-				throw new Error(`Subset ${subset} not found`);
-			}
-			for (const weight of weights) {
-				for (const style of styles) {
-					fontFiles.push(`${fontId}_${subset}_${weight}_${style}`);
-					cssOutput +=
-						generateFontFaceCSS(
-							font!,
-							subset,
-							weight,
-							style,
-							unicodeRange,
-							"",
-						) + "\n";
+			for (const { shard, ranges } of subsetShards(apiSubsets!, font!, subset)) {
+				for (const weight of weights) {
+					for (const style of styles) {
+						fontFiles.push(fontFileName(font!, shard, weight, style));
+						cssOutput +=
+							generateFontFaceCSS(font!, shard, weight, style, ranges, "") +
+							"\n";
+					}
 				}
 			}
 		}
 
 		const fontBlobs = await Promise.all(
-			fontFiles.map((name) => fetch(`${API_BASE}/fonts/${name}.woff2`)),
+			fontFiles.map((name) => fetch(`${API_BASE}/fonts/${name}`)),
 		);
 
 		const cssBlob = {